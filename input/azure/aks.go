@@ -0,0 +1,318 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/elastic/assetbeat/input/internal"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// aksNodePoolTag is the tag AKS sets on the VM scale sets backing an agent pool, holding the name of the pool.
+const aksNodePoolTag = "aks-managed-poolName"
+
+type AKSCluster struct {
+	ID             string
+	Name           string
+	SubscriptionID string
+	Region         string
+	Parents        []string
+	Children       []string
+	Tags           map[string]*string
+	Metadata       mapstr.M
+	NodePools      []aksNodePool
+}
+
+// aksNodePool is an agent pool of an AKS cluster, identified by its resource ID.
+type aksNodePool struct {
+	ID             string
+	Name           string
+	SubscriptionID string
+	Region         string
+	Parents        []string
+	Children       []string
+	Tags           map[string]*string
+	Metadata       mapstr.M
+}
+
+func collectAzureAKSAssets(ctx context.Context, client *armcontainerservice.ManagedClustersClient, scaleSetClient *armcompute.VirtualMachineScaleSetsClient, vmClient *armcompute.VirtualMachineScaleSetVMsClient, subscriptionId string, regions []string, assetTypes []string, log *logp.Logger, publisher stateless.Publisher) error {
+	clusters, err := getAllAzureAKSClusters(ctx, client, scaleSetClient, vmClient, subscriptionId, regions, log)
+	if err != nil {
+		return err
+	}
+
+	publishAzureAKSClusters(publisher, clusters, assetTypes, log)
+	return nil
+}
+
+// publishAzureAKSClusters publishes the AKS clusters and their node pools, depending on the enabled asset types.
+func publishAzureAKSClusters(publisher stateless.Publisher, clusters []AKSCluster, assetTypes []string, log *logp.Logger) {
+	if internal.IsTypeEnabled(assetTypes, "k8s.cluster") {
+		log.Debug("Publishing Azure AKS clusters")
+		for _, cluster := range clusters {
+			options := []internal.AssetOption{
+				internal.WithAssetCloudProvider("azure"),
+				internal.WithAssetRegion(cluster.Region),
+				internal.WithAssetAccountID(cluster.SubscriptionID),
+				internal.WithAssetKindAndID("cluster", cluster.ID),
+				internal.WithAssetType("k8s.cluster"),
+				internal.WithAssetParents(cluster.Parents),
+				internal.WithAssetChildren(cluster.Children),
+				internal.WithAssetMetadata(cluster.Metadata),
+				WithAssetTags(flattenAzureTags(cluster.Tags)),
+			}
+			if cluster.Name != "" {
+				options = append(options, internal.WithAssetName(cluster.Name))
+			}
+			internal.Publish(publisher, nil, options...)
+		}
+	}
+	if internal.IsTypeEnabled(assetTypes, "azure.aks.nodepool") {
+		log.Debug("Publishing Azure AKS node pools")
+		for _, cluster := range clusters {
+			for _, pool := range cluster.NodePools {
+				internal.Publish(publisher, nil,
+					internal.WithAssetCloudProvider("azure"),
+					internal.WithAssetRegion(pool.Region),
+					internal.WithAssetAccountID(pool.SubscriptionID),
+					internal.WithAssetKindAndID("node_pool", pool.ID),
+					internal.WithAssetType("azure.aks.nodepool"),
+					internal.WithAssetName(pool.Name),
+					internal.WithAssetParents(pool.Parents),
+					internal.WithAssetChildren(pool.Children),
+					internal.WithAssetMetadata(pool.Metadata),
+					WithAssetTags(flattenAzureTags(pool.Tags)),
+				)
+			}
+		}
+	}
+}
+
+// getAllAzureAKSClusters lists the AKS clusters of a subscription. The VM scale sets backing
+// their agent pools, and the instances of these scale sets, are retrieved from the node resource
+// group of each cluster.
+func getAllAzureAKSClusters(ctx context.Context, client *armcontainerservice.ManagedClustersClient, scaleSetClient *armcompute.VirtualMachineScaleSetsClient, vmClient *armcompute.VirtualMachineScaleSetVMsClient, subscriptionId string, regions []string, log *logp.Logger) ([]AKSCluster, error) {
+	var clusters []AKSCluster
+	pager := client.NewListPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to advance page: %v", err)
+		}
+		for _, v := range page.Value {
+			if !isRegionWanted(*v.Location, regions) {
+				continue
+			}
+			scaleSets, err := getAKSClusterScaleSets(ctx, scaleSetClient, v)
+			// We should not fail hard here since the core information for the asset comes from the AKS cluster data
+			if err != nil {
+				log.Warnf("Error while retrieving VM scale sets for AKS cluster %s: %+v", *v.ID, err)
+			}
+			hosts, err := getAKSClusterHosts(ctx, vmClient, v, scaleSets)
+			if err != nil {
+				log.Warnf("Error while retrieving VM scale set instances for AKS cluster %s: %+v", *v.ID, err)
+			}
+			clusters = append(clusters, newAKSCluster(v, subscriptionId, scaleSets, hosts))
+		}
+	}
+	return clusters, nil
+}
+
+// getAKSClusterScaleSets returns the VM scale sets of the node resource group of an AKS cluster,
+// where AKS creates the scale sets backing the agent pools.
+func getAKSClusterScaleSets(ctx context.Context, scaleSetClient *armcompute.VirtualMachineScaleSetsClient, v *armcontainerservice.ManagedCluster) ([]*armcompute.VirtualMachineScaleSet, error) {
+	if v.Properties == nil || v.Properties.NodeResourceGroup == nil {
+		return nil, nil
+	}
+	var scaleSets []*armcompute.VirtualMachineScaleSet
+	pager := scaleSetClient.NewListPager(*v.Properties.NodeResourceGroup, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to advance page: %v", err)
+		}
+		scaleSets = append(scaleSets, page.Value...)
+	}
+	return scaleSets, nil
+}
+
+// getAKSClusterHosts returns the EANs of the instances of the given VM scale sets of the node
+// resource group of an AKS cluster, i.e. of the VMs running its nodes.
+func getAKSClusterHosts(ctx context.Context, vmClient *armcompute.VirtualMachineScaleSetVMsClient, v *armcontainerservice.ManagedCluster, scaleSets []*armcompute.VirtualMachineScaleSet) ([]string, error) {
+	if v.Properties == nil || v.Properties.NodeResourceGroup == nil {
+		return nil, nil
+	}
+	var hosts []string
+	for _, ss := range scaleSets {
+		pager := vmClient.NewListPager(*v.Properties.NodeResourceGroup, *ss.Name, nil)
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return hosts, fmt.Errorf("failed to advance page: %v", err)
+			}
+			for _, vm := range page.Value {
+				if vm.Properties != nil && vm.Properties.VMID != nil {
+					hosts = append(hosts, "host:"+*vm.Properties.VMID)
+				}
+			}
+		}
+	}
+	return hosts, nil
+}
+
+// newAKSCluster returns an AKS cluster along with its node pools, which are linked to the
+// given VM scale sets through the tag AKS sets on them. The cluster is linked to its node
+// pools and to hosts, the instances of these scale sets, so that its VMs, and the k8s nodes
+// running on them, can be related to it whether or not the scale sets are collected.
+func newAKSCluster(v *armcontainerservice.ManagedCluster, subscriptionId string, scaleSets []*armcompute.VirtualMachineScaleSet, hosts []string) AKSCluster {
+	metadata := mapstr.M{
+		"resource_group": getResourceGroupFromId(*v.ID),
+	}
+	var agentPools []*armcontainerservice.ManagedClusterAgentPoolProfile
+	if p := v.Properties; p != nil {
+		if p.PowerState != nil && p.PowerState.Code != nil {
+			metadata["state"] = string(*p.PowerState.Code)
+		}
+		if p.ProvisioningState != nil {
+			metadata["provisioning_state"] = *p.ProvisioningState
+		}
+		if p.KubernetesVersion != nil {
+			metadata["kubernetes_version"] = *p.KubernetesVersion
+		}
+		if p.Fqdn != nil {
+			metadata["fqdn"] = *p.Fqdn
+		}
+		if p.NodeResourceGroup != nil {
+			metadata["node_resource_group"] = *p.NodeResourceGroup
+		}
+		agentPools = p.AgentPoolProfiles
+	}
+
+	scaleSetsByPool := make(map[string][]string)
+	for _, ss := range scaleSets {
+		if pool, ok := ss.Tags[aksNodePoolTag]; ok && pool != nil {
+			scaleSetsByPool[*pool] = append(scaleSetsByPool[*pool], "instance_group:"+*ss.ID)
+		}
+	}
+
+	cluster := AKSCluster{
+		ID:             *v.ID,
+		Name:           *v.Name,
+		SubscriptionID: subscriptionId,
+		Region:         *v.Location,
+		Parents:        append([]string{getResourceGroupEAN(*v.ID)}, getAKSClusterVnets(agentPools)...),
+		Tags:           v.Tags,
+		Metadata:       metadata,
+	}
+	for _, pool := range agentPools {
+		if pool.Name == nil {
+			continue
+		}
+		nodePool := newAKSNodePool(cluster, pool, scaleSetsByPool[*pool.Name])
+		cluster.NodePools = append(cluster.NodePools, nodePool)
+		cluster.Children = append(cluster.Children, "node_pool:"+nodePool.ID)
+	}
+	cluster.Children = append(cluster.Children, hosts...)
+	return cluster
+}
+
+func newAKSNodePool(cluster AKSCluster, pool *armcontainerservice.ManagedClusterAgentPoolProfile, scaleSets []string) aksNodePool {
+	metadata := mapstr.M{}
+	if pool.PowerState != nil && pool.PowerState.Code != nil {
+		metadata["state"] = string(*pool.PowerState.Code)
+	}
+	if pool.ProvisioningState != nil {
+		metadata["provisioning_state"] = *pool.ProvisioningState
+	}
+	if pool.Count != nil {
+		metadata["count"] = *pool.Count
+	}
+	if pool.VMSize != nil {
+		metadata["vm_size"] = *pool.VMSize
+	}
+	if pool.OSType != nil {
+		metadata["os_type"] = string(*pool.OSType)
+	}
+	if pool.Mode != nil {
+		metadata["mode"] = string(*pool.Mode)
+	}
+	if pool.OrchestratorVersion != nil {
+		metadata["orchestrator_version"] = *pool.OrchestratorVersion
+	}
+	if pool.EnableAutoScaling != nil && *pool.EnableAutoScaling {
+		autoscaling := mapstr.M{"enabled": true}
+		if pool.MinCount != nil {
+			autoscaling["min_count"] = *pool.MinCount
+		}
+		if pool.MaxCount != nil {
+			autoscaling["max_count"] = *pool.MaxCount
+		}
+		metadata["autoscaling"] = autoscaling
+	}
+
+	return aksNodePool{
+		ID:             cluster.ID + "/agentPools/" + *pool.Name,
+		Name:           *pool.Name,
+		SubscriptionID: cluster.SubscriptionID,
+		Region:         cluster.Region,
+		Parents:        []string{"cluster:" + cluster.ID},
+		Children:       scaleSets,
+		Tags:           pool.Tags,
+		Metadata:       metadata,
+	}
+}
+
+// getAKSClusterVnets returns the EANs of the virtual networks the agent pools are attached to.
+// Agent pools without a subnet use a virtual network managed by AKS, which is not returned.
+func getAKSClusterVnets(agentPools []*armcontainerservice.ManagedClusterAgentPoolProfile) []string {
+	var vnets []string
+	seen := make(map[string]struct{})
+	for _, pool := range agentPools {
+		if pool.VnetSubnetID == nil {
+			continue
+		}
+		vnetID := getVnetIdFromSubnetId(*pool.VnetSubnetID)
+		if vnetID == "" {
+			continue
+		}
+		vnet := getNetworkEAN(vnetID)
+		if _, ok := seen[vnet]; ok {
+			continue
+		}
+		seen[vnet] = struct{}{}
+		vnets = append(vnets, vnet)
+	}
+	return vnets
+}
+
+// getVnetIdFromSubnetId returns the ID of the virtual network a subnet belongs to,
+// e.g. /subscriptions/{sub}/resourceGroups/{rg}/providers/Microsoft.Network/virtualNetworks/{vnet}.
+func getVnetIdFromSubnetId(subnetId string) string {
+	vnetId, _, found := strings.Cut(subnetId, "/subnets/")
+	if !found {
+		return ""
+	}
+	return vnetId
+}
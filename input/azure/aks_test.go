@@ -0,0 +1,313 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	containerservicefake "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4/fake"
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/assetbeat/input/testutil"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/stretchr/testify/assert"
+)
+
+const aksCluster1Name = "cluster1"
+const aksCluster2Name = "cluster2"
+const aksNodeResourceGroup = "MC_TESTVM_cluster1_westeurope"
+
+var aksCluster1ID = fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerService/managedClusters/%s", subscriptionId, resourceGroup1, aksCluster1Name)
+var aksCluster2ID = fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerService/managedClusters/%s", subscriptionId, resourceGroup1, aksCluster2Name)
+var aksScaleSetID = fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute/virtualMachineScaleSets/aks-nodepool1-vmss", subscriptionId, aksNodeResourceGroup)
+var vnetID = fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/virtualNetworks/vnet1", subscriptionId, resourceGroup1)
+
+var aksCluster1Properties = armcontainerservice.ManagedClusterProperties{
+	KubernetesVersion: to.Ptr("1.27.3"),
+	ProvisioningState: to.Ptr("Succeeded"),
+	PowerState:        &armcontainerservice.PowerState{Code: to.Ptr(armcontainerservice.CodeRunning)},
+	Fqdn:              to.Ptr("cluster1-dns.hcp.westeurope.azmk8s.io"),
+	NodeResourceGroup: to.Ptr(aksNodeResourceGroup),
+	AgentPoolProfiles: []*armcontainerservice.ManagedClusterAgentPoolProfile{
+		{
+			Name:                to.Ptr("nodepool1"),
+			Count:               to.Ptr[int32](2),
+			VMSize:              to.Ptr("Standard_DS2_v2"),
+			OSType:              to.Ptr(armcontainerservice.OSTypeLinux),
+			Mode:                to.Ptr(armcontainerservice.AgentPoolModeSystem),
+			OrchestratorVersion: to.Ptr("1.27.3"),
+			ProvisioningState:   to.Ptr("Succeeded"),
+			PowerState:          &armcontainerservice.PowerState{Code: to.Ptr(armcontainerservice.CodeRunning)},
+			EnableAutoScaling:   to.Ptr(true),
+			MinCount:            to.Ptr[int32](1),
+			MaxCount:            to.Ptr[int32](3),
+			VnetSubnetID:        to.Ptr(vnetID + "/subnets/subnet1"),
+		},
+	},
+}
+
+var aksCluster1 = armcontainerservice.ManagedCluster{
+	Location:   to.Ptr("westeurope"),
+	ID:         to.Ptr(aksCluster1ID),
+	Name:       to.Ptr(aksCluster1Name),
+	Properties: &aksCluster1Properties,
+}
+
+var aksCluster2 = armcontainerservice.ManagedCluster{
+	Location: to.Ptr("eastus"),
+	ID:       to.Ptr(aksCluster2ID),
+	Name:     to.Ptr(aksCluster2Name),
+}
+
+// aksScaleSet is the VM scale set AKS created for the agent pool of aksCluster1.
+var aksScaleSet = armcompute.VirtualMachineScaleSet{
+	Location: to.Ptr("westeurope"),
+	ID:       to.Ptr(aksScaleSetID),
+	Name:     to.Ptr("aks-nodepool1-vmss"),
+	Tags:     map[string]*string{aksNodePoolTag: to.Ptr("nodepool1")},
+}
+
+var aksCluster1Event = beat.Event{
+	Fields: mapstr.M{
+		"asset.ean":                          "cluster:" + aksCluster1ID,
+		"asset.id":                           aksCluster1ID,
+		"asset.name":                         aksCluster1Name,
+		"asset.type":                         "k8s.cluster",
+		"asset.kind":                         "cluster",
+		"asset.parents":                      []string{resourceGroup1EAN, "network:" + strings.ToLower(vnetID)},
+		"asset.children":                     []string{"node_pool:" + aksCluster1ID + "/agentPools/nodepool1", "host:" + instanceVMId2},
+		"asset.metadata.state":               "Running",
+		"asset.metadata.provisioning_state":  "Succeeded",
+		"asset.metadata.kubernetes_version":  "1.27.3",
+		"asset.metadata.fqdn":                "cluster1-dns.hcp.westeurope.azmk8s.io",
+		"asset.metadata.resource_group":      "TESTVM",
+		"asset.metadata.node_resource_group": aksNodeResourceGroup,
+		"cloud.account.id":                   subscriptionId,
+		"cloud.provider":                     "azure",
+		"cloud.region":                       "westeurope",
+	},
+	Meta: mapstr.M{
+		"index": internal.GetDefaultIndexName(),
+	},
+}
+
+var aksNodePool1Event = beat.Event{
+	Fields: mapstr.M{
+		"asset.ean":                            "node_pool:" + aksCluster1ID + "/agentPools/nodepool1",
+		"asset.id":                             aksCluster1ID + "/agentPools/nodepool1",
+		"asset.name":                           "nodepool1",
+		"asset.type":                           "azure.aks.nodepool",
+		"asset.kind":                           "node_pool",
+		"asset.parents":                        []string{"cluster:" + aksCluster1ID},
+		"asset.children":                       []string{"instance_group:" + aksScaleSetID},
+		"asset.metadata.state":                 "Running",
+		"asset.metadata.provisioning_state":    "Succeeded",
+		"asset.metadata.count":                 int32(2),
+		"asset.metadata.vm_size":               "Standard_DS2_v2",
+		"asset.metadata.os_type":               "Linux",
+		"asset.metadata.mode":                  "System",
+		"asset.metadata.orchestrator_version":  "1.27.3",
+		"asset.metadata.autoscaling.enabled":   true,
+		"asset.metadata.autoscaling.min_count": int32(1),
+		"asset.metadata.autoscaling.max_count": int32(3),
+		"cloud.account.id":                     subscriptionId,
+		"cloud.provider":                       "azure",
+		"cloud.region":                         "westeurope",
+	},
+	Meta: mapstr.M{
+		"index": internal.GetDefaultIndexName(),
+	},
+}
+
+func TestAssetsAzure_collectAzureAKSAssets(t *testing.T) {
+	clustersServer := containerservicefake.ManagedClustersServer{
+		NewListPager: func(options *armcontainerservice.ManagedClustersClientListOptions) (resp azfake.PagerResponder[armcontainerservice.ManagedClustersClientListResponse]) {
+			page := armcontainerservice.ManagedClustersClientListResponse{
+				ManagedClusterListResult: armcontainerservice.ManagedClusterListResult{
+					Value: []*armcontainerservice.ManagedCluster{
+						&aksCluster1,
+						&aksCluster2,
+					},
+				},
+			}
+			resp.AddPage(http.StatusOK, page, nil)
+			return
+		},
+	}
+
+	// the instances of the scale set of the node pool, which are listed in the node resource group
+	vmServer := fake.VirtualMachineScaleSetVMsServer{
+		NewListPager: func(resourceGroupName string, vmScaleSetName string, options *armcompute.VirtualMachineScaleSetVMsClientListOptions) (resp azfake.PagerResponder[armcompute.VirtualMachineScaleSetVMsClientListResponse]) {
+			page := armcompute.VirtualMachineScaleSetVMsClientListResponse{}
+			if resourceGroupName == aksNodeResourceGroup && vmScaleSetName == *aksScaleSet.Name {
+				page.Value = []*armcompute.VirtualMachineScaleSetVM{
+					{ID: to.Ptr(aksScaleSetID + "/virtualMachines/0"), Properties: &armcompute.VirtualMachineScaleSetVMProperties{VMID: to.Ptr(instanceVMId2)}},
+				}
+			}
+			resp.AddPage(http.StatusOK, page, nil)
+			return
+		},
+	}
+	failingVMServer := fake.VirtualMachineScaleSetVMsServer{
+		NewListPager: func(resourceGroupName string, vmScaleSetName string, options *armcompute.VirtualMachineScaleSetVMsClientListOptions) (resp azfake.PagerResponder[armcompute.VirtualMachineScaleSetVMsClientListResponse]) {
+			resp.AddResponseError(http.StatusForbidden, "AuthorizationFailed")
+			return
+		},
+	}
+	aksScaleSetsServer := fake.VirtualMachineScaleSetsServer{
+		NewListPager: func(resourceGroupName string, options *armcompute.VirtualMachineScaleSetsClientListOptions) (resp azfake.PagerResponder[armcompute.VirtualMachineScaleSetsClientListResponse]) {
+			page := armcompute.VirtualMachineScaleSetsClientListResponse{}
+			if resourceGroupName == aksNodeResourceGroup {
+				page.Value = []*armcompute.VirtualMachineScaleSet{&aksScaleSet, &scaleSet}
+			}
+			resp.AddPage(http.StatusOK, page, nil)
+			return
+		},
+	}
+
+	for _, tt := range []struct {
+		name           string
+		regions        []string
+		assetTypes     []string
+		fakeSSServer   fake.VirtualMachineScaleSetsServer
+		fakeVMServer   fake.VirtualMachineScaleSetVMsServer
+		expectedEvents []beat.Event
+	}{
+		{
+			name:           "Test with one AKS cluster with a node pool backed by a scale set",
+			regions:        []string{"westeurope"},
+			assetTypes:     []string{"k8s.cluster", "azure.aks.nodepool"},
+			fakeSSServer:   aksScaleSetsServer,
+			fakeVMServer:   vmServer,
+			expectedEvents: []beat.Event{aksCluster1Event, aksNodePool1Event},
+		},
+		{
+			name:           "Test with only AKS clusters, linked to the instances of the scale sets which are not collected",
+			regions:        []string{"westeurope"},
+			assetTypes:     []string{"k8s.cluster"},
+			fakeSSServer:   aksScaleSetsServer,
+			fakeVMServer:   vmServer,
+			expectedEvents: []beat.Event{aksCluster1Event},
+		},
+		{
+			name:         "Test with AKS clusters, when the scale set instances cannot be listed",
+			regions:      []string{"westeurope"},
+			assetTypes:   []string{"k8s.cluster"},
+			fakeSSServer: aksScaleSetsServer,
+			fakeVMServer: failingVMServer,
+			expectedEvents: []beat.Event{
+				{
+					Fields: func() mapstr.M {
+						fields := aksCluster1Event.Fields.Clone()
+						fields["asset.children"] = []string{"node_pool:" + aksCluster1ID + "/agentPools/nodepool1"}
+						return fields
+					}(),
+					Meta: aksCluster1Event.Meta,
+				},
+			},
+		},
+		{
+			name:       "Test with only AKS node pools, when the scale sets cannot be listed",
+			regions:    []string{"westeurope"},
+			assetTypes: []string{"azure.aks.nodepool"},
+			fakeSSServer: fake.VirtualMachineScaleSetsServer{
+				NewListPager: func(resourceGroupName string, options *armcompute.VirtualMachineScaleSetsClientListOptions) (resp azfake.PagerResponder[armcompute.VirtualMachineScaleSetsClientListResponse]) {
+					resp.AddResponseError(http.StatusForbidden, "AuthorizationFailed")
+					return
+				},
+			},
+			expectedEvents: []beat.Event{
+				{
+					Fields: func() mapstr.M {
+						fields := aksNodePool1Event.Fields.Clone()
+						fields["asset.children"] = []string(nil)
+						return fields
+					}(),
+					Meta: aksNodePool1Event.Meta,
+				},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := testutil.NewInMemoryPublisher()
+
+			ctx := context.Background()
+			logger := logp.NewLogger("test")
+
+			client, err := armcontainerservice.NewManagedClustersClient("subscriptionID", &azfake.TokenCredential{}, &arm.ClientOptions{
+				ClientOptions: azcore.ClientOptions{
+					Transport: containerservicefake.NewManagedClustersServerTransport(&clustersServer),
+				},
+			})
+			assert.NoError(t, err)
+
+			ssclient, err := armcompute.NewVirtualMachineScaleSetsClient("subscriptionID", &azfake.TokenCredential{}, &arm.ClientOptions{
+				ClientOptions: azcore.ClientOptions{
+					Transport: fake.NewVirtualMachineScaleSetsServerTransport(&tt.fakeSSServer),
+				},
+			})
+			assert.NoError(t, err)
+
+			vmclient, err := armcompute.NewVirtualMachineScaleSetVMsClient("subscriptionID", &azfake.TokenCredential{}, &arm.ClientOptions{
+				ClientOptions: azcore.ClientOptions{
+					Transport: fake.NewVirtualMachineScaleSetVMsServerTransport(&tt.fakeVMServer),
+				},
+			})
+			assert.NoError(t, err)
+
+			err = collectAzureAKSAssets(ctx, client, ssclient, vmclient, subscriptionId, tt.regions, tt.assetTypes, logger, publisher)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedEvents, publisher.Events)
+		})
+	}
+}
+
+func TestGetVnetIdFromSubnetId(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		subnetId string
+		expected string
+	}{
+		{
+			name:     "with a subnet ID",
+			subnetId: vnetID + "/subnets/subnet1",
+			expected: vnetID,
+		},
+		{
+			name:     "with an empty subnet ID",
+			subnetId: "",
+			expected: "",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, getVnetIdFromSubnetId(tt.subnetId))
+		})
+	}
+}
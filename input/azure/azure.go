@@ -19,12 +19,18 @@ package azure
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/subscription/armsubscription"
 	"github.com/elastic/assetbeat/input/internal"
 	input "github.com/elastic/beats/v7/filebeat/input/v2"
@@ -55,16 +61,38 @@ func configure(inputCfg *conf.C) (stateless.Input, error) {
 }
 
 func newAssetsAzure(cfg config) (*assetsAzure, error) {
+	if cfg.Source != sourceAPI && cfg.Source != sourceResourceGraph {
+		return nil, fmt.Errorf("invalid source %q, must be either %q or %q", cfg.Source, sourceAPI, sourceResourceGraph)
+	}
+	if _, err := getCloudConfiguration(cfg.Cloud); err != nil {
+		return nil, err
+	}
+	if err := validateTenantsConfig(cfg); err != nil {
+		return nil, err
+	}
 	return &assetsAzure{cfg}, nil
 }
 
+const (
+	// sourceAPI collects assets through the Azure Resource Manager APIs, subscription by subscription
+	sourceAPI = "api"
+	// sourceResourceGraph collects assets with Azure Resource Graph queries, across subscriptions
+	sourceResourceGraph = "resource_graph"
+)
+
 type config struct {
 	internal.BaseConfig `config:",inline"`
-	Regions             []string `config:"regions"`
-	ClientID            string   `config:"client_id"`
-	ClientSecret        string   `config:"client_secret"`
-	SubscriptionID      string   `config:"subscription_id"`
-	TenantID            string   `config:"tenant_id"`
+	Regions             []string       `config:"regions"`
+	ClientID            string         `config:"client_id"`
+	ClientSecret        string         `config:"client_secret"`
+	SubscriptionID      string         `config:"subscription_id"`
+	TenantID            string         `config:"tenant_id"`
+	CredentialType      string         `config:"credential_type"`
+	CertificatePath     string         `config:"certificate_path"`
+	CertificatePassword string         `config:"certificate_password"`
+	Source              string         `config:"source"`
+	Cloud               string         `config:"cloud"`
+	Tenants             []tenantConfig `config:"tenants"`
 }
 
 func defaultConfig() config {
@@ -78,6 +106,9 @@ func defaultConfig() config {
 		ClientSecret:   "",
 		SubscriptionID: "",
 		TenantID:       "",
+		CredentialType: "",
+		Source:         sourceAPI,
+		Cloud:          cloudAzurePublic,
 	}
 }
 
@@ -87,8 +118,22 @@ type assetsAzure struct {
 
 func (s *assetsAzure) Name() string { return "assets_azure" }
 
-func (s *assetsAzure) Test(_ input.TestContext) error {
-	return nil
+// Test verifies that a token can be acquired with the configured credentials, that the
+// subscriptions are accessible and that the configured regions are available in them.
+func (s *assetsAzure) Test(testCtx input.TestContext) error {
+	ctx := ctxtool.FromCanceller(testCtx.Cancelation)
+	log := testCtx.Logger.With("assets_azure")
+
+	var errs []error
+	for _, tenant := range getTenants(s.Config) {
+		if err := checkAzureTenant(ctx, s.Config, tenant, log); err != nil {
+			if tenant.TenantID != "" {
+				err = fmt.Errorf("tenant %s: %w", tenant.TenantID, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
 func (s *assetsAzure) Run(inputCtx input.Context, publisher stateless.Publisher) error {
@@ -118,72 +163,195 @@ func (s *assetsAzure) Run(inputCtx input.Context, publisher stateless.Publisher)
 	}
 }
 
-func getAzureCredentials(cfg config, log *logp.Logger) (azcore.TokenCredential, error) {
-	if cfg.TenantID != "" && cfg.ClientID != "" && cfg.ClientSecret != "" {
-		log.Debug("Retrieving Azure credentials from assetbeat configuration...")
-		return azidentity.NewClientSecretCredential(cfg.TenantID, cfg.ClientID, cfg.ClientSecret, nil)
-	} else {
-		log.Debug("No Client or Tenant configuration provided. Retrieving default Azure credentials")
-		return azidentity.NewDefaultAzureCredential(nil)
+func collectAzureAssets(ctx context.Context, log *logp.Logger, cfg config, publisher stateless.Publisher) {
+	for _, tenant := range getTenants(cfg) {
+		tenantLog := log
+		if tenant.TenantID != "" {
+			tenantLog = log.With("tenant_id", tenant.TenantID)
+		}
+		collectAzureTenantAssets(ctx, tenantLog, cfg, tenant, newTenantPublisher(publisher, tenant.TenantID))
 	}
 }
 
-func collectAzureAssets(ctx context.Context, log *logp.Logger, cfg config, publisher stateless.Publisher) {
-	cred, err := getAzureCredentials(cfg, log)
+// collectAzureTenantAssets collects the assets of the subscriptions of a tenant with its credentials.
+func collectAzureTenantAssets(ctx context.Context, log *logp.Logger, cfg config, tenant tenantConfig, publisher stateless.Publisher) {
+	cloudCfg, err := getCloudConfiguration(cfg.Cloud)
 	if err != nil {
-		log.Errorf("Error while retrieving Azure credentials: %v")
+		log.Errorf("Error while retrieving Azure cloud configuration: %v", err)
+		return
 	}
-	subscriptions, err := getAzureSubscriptions(ctx, cfg, cred)
+	clientOptions := getARMClientOptions(cloudCfg)
+	cred, err := getAzureCredentials(tenant, cloudCfg, log)
 	if err != nil {
-		log.Errorf("Error while retrieving Azure subscriptions list: %v")
+		log.Errorf("Error while retrieving Azure credentials: %v", err)
+		return
+	}
+
+	if cfg.Source == sourceResourceGraph {
+		client, err := armresourcegraph.NewClient(cred, clientOptions)
+		if err != nil {
+			log.Errorf("Error creating Azure Resource Graph Client: %v", err)
+			return
+		}
+		err = collectAzureResourceGraphAssets(ctx, client, tenant.SubscriptionIDs, cfg, log, publisher)
+		if err != nil {
+			log.Errorf("Error while collecting Azure assets from Resource Graph: %v", err)
+		}
+		return
+	}
+
+	subscriptions, err := getAzureSubscriptions(ctx, tenant.SubscriptionIDs, cred, clientOptions)
+	if err != nil {
+		log.Errorf("Error while retrieving Azure subscriptions list: %v", err)
+		return
+	}
+
+	var subscriptionClient *armsubscription.SubscriptionsClient
+	if internal.IsTypeEnabled(cfg.AssetTypes, "azure.subscription") {
+		subscriptionClient, err = armsubscription.NewSubscriptionsClient(cred, clientOptions)
+		if err != nil {
+			log.Errorf("Error creating Azure Subscriptions Client: %v", err)
+			return
+		}
 	}
 
 	for _, sub := range subscriptions {
-		if internal.IsTypeEnabled(cfg.AssetTypes, "azure.vm.instance") {
-			clientFactory, err := armcompute.NewClientFactory(sub, cred, nil)
+		computeClientFactory, err := armcompute.NewClientFactory(sub, cred, clientOptions)
+		if err != nil {
+			log.Errorf("Error creating Azure Compute Client Factory: %v", err)
+			return
+		}
+		networkClientFactory, err := armnetwork.NewClientFactory(sub, cred, clientOptions)
+		if err != nil {
+			log.Errorf("Error creating Azure Network Client Factory: %v", err)
+			return
+		}
+		vmClient := computeClientFactory.NewVirtualMachineScaleSetVMsClient()
+		scaleSetsClient := computeClientFactory.NewVirtualMachineScaleSetsClient()
+
+		if internal.IsAnyTypeEnabled(cfg.AssetTypes, "azure.subscription", "azure.resource_group") {
+			groupsClient, err := armresources.NewResourceGroupsClient(sub, cred, clientOptions)
 			if err != nil {
-				log.Errorf("Error creating Azure Compute Client Factory: %v", err)
+				log.Errorf("Error creating Azure Resource Groups Client: %v", err)
 				return
 			}
-			client := clientFactory.NewVirtualMachinesClient()
 			go func(currentSub string) {
-				err = collectAzureVMAssets(ctx, client, currentSub, cfg.Regions, log, publisher)
+				err := collectAzureSubscriptionAssets(ctx, subscriptionClient, groupsClient, currentSub, cfg.AssetTypes, log, publisher)
 				if err != nil {
-					log.Errorf("Error while collecting Azure VM assets: %v", err)
+					log.Errorf("Error while collecting Azure subscription assets: %v", err)
 				}
 			}(sub)
-			vmClient := clientFactory.NewVirtualMachineScaleSetVMsClient()
-			scaleSetsClient := clientFactory.NewVirtualMachineScaleSetsClient()
+		}
+		if internal.IsAnyTypeEnabled(cfg.AssetTypes, "azure.vnet", "azure.subnet") {
+			client := networkClientFactory.NewVirtualNetworksClient()
 			go func(currentSub string) {
-				err = collectAzureScaleSetsVMAssets(ctx, vmClient, scaleSetsClient, currentSub, cfg.Regions, log, publisher)
+				err := collectAzureVnetAssets(ctx, client, currentSub, cfg.Regions, cfg.AssetTypes, log, publisher)
 				if err != nil {
-					log.Errorf("Error while collecting Azure Scale Sets VM assets: %v", err)
+					log.Errorf("Error while collecting Azure virtual network assets: %v", err)
 				}
 			}(sub)
 		}
-	}
-}
-
-func getAzureSubscriptions(ctx context.Context, cfg config, cred azcore.TokenCredential) ([]string, error) {
-	var subscriptions []string
-	if cfg.SubscriptionID != "" {
-		subscriptions = append(subscriptions, cfg.SubscriptionID)
-	} else {
-		subscriptionClientFactory, _ := armsubscription.NewClientFactory(cred, nil)
-		client := subscriptionClientFactory.NewSubscriptionsClient()
-
-		pager := client.NewListPager(nil)
-
-		for pager.More() {
-			page, err := pager.NextPage(ctx)
+		if internal.IsAnyTypeEnabled(cfg.AssetTypes, "azure.vm.instance", "azure.network_interface", "azure.disk") {
+			interfacesClient := networkClientFactory.NewInterfacesClient()
+			client := computeClientFactory.NewVirtualMachinesClient()
+			disksClient := computeClientFactory.NewDisksClient()
+			go func(currentSub string) {
+				var err error
+				// network interfaces are listed first, as VMs are linked to their subnets through them
+				var interfaces []AzureNetworkAsset
+				if internal.IsAnyTypeEnabled(cfg.AssetTypes, "azure.vm.instance", "azure.network_interface") {
+					interfaces, err = getAllAzureNetworkInterfaces(ctx, interfacesClient, currentSub, cfg.Regions)
+					if err != nil {
+						log.Errorf("Error while collecting Azure network interface assets: %v", err)
+					}
+				}
+				if internal.IsTypeEnabled(cfg.AssetTypes, "azure.network_interface") {
+					collectAzureNetworkInterfaceAssets(interfaces, log, publisher)
+				}
+				// VMs are listed before disks, as disks are linked to the VMs they are attached to
+				var instances []AzureVMInstance
+				if internal.IsAnyTypeEnabled(cfg.AssetTypes, "azure.vm.instance", "azure.disk") {
+					instances, err = getAllAzureVMInstances(ctx, client, currentSub, cfg.Regions, getSubnetsByNetworkInterface(interfaces))
+					if err != nil {
+						log.Errorf("Error while collecting Azure VM assets: %v", err)
+					}
+				}
+				if internal.IsTypeEnabled(cfg.AssetTypes, "azure.vm.instance") {
+					log.Debug("Publishing Azure VM instances")
+					publishAzureVMInstances(publisher, instances)
+				}
+				if internal.IsTypeEnabled(cfg.AssetTypes, "azure.disk") {
+					err = collectAzureDiskAssets(ctx, disksClient, currentSub, cfg.Regions, getVMsByResourceId(instances), log, publisher)
+					if err != nil {
+						log.Errorf("Error while collecting Azure disk assets: %v", err)
+					}
+				}
+			}(sub)
+		}
+		if internal.IsAnyTypeEnabled(cfg.AssetTypes, "azure.storage_account", "azure.sql.database") {
+			endpointsClient := networkClientFactory.NewPrivateEndpointsClient()
+			storageClient, err := armstorage.NewAccountsClient(sub, cred, clientOptions)
 			if err != nil {
-				return nil, fmt.Errorf("failed to advance page: %v", err)
+				log.Errorf("Error creating Azure Storage Accounts Client: %v", err)
+				return
 			}
-			for _, v := range page.Value {
-				subscriptions = append(subscriptions, *v.SubscriptionID)
+			resourcesClient, err := armresources.NewClient(sub, cred, clientOptions)
+			if err != nil {
+				log.Errorf("Error creating Azure Resources Client: %v", err)
+				return
+			}
+			go func(currentSub string) {
+				// private endpoints are listed first, as storage accounts and SQL databases are linked to their virtual networks
+				endpointVnets, err := getPrivateEndpointVnets(ctx, endpointsClient)
+				if err != nil {
+					log.Warnf("Error while retrieving Azure private endpoints: %v", err)
+				}
+				if internal.IsTypeEnabled(cfg.AssetTypes, "azure.storage_account") {
+					err = collectAzureStorageAccountAssets(ctx, storageClient, currentSub, cfg.Regions, endpointVnets, log, publisher)
+					if err != nil {
+						log.Errorf("Error while collecting Azure storage account assets: %v", err)
+					}
+				}
+				if internal.IsTypeEnabled(cfg.AssetTypes, "azure.sql.database") {
+					err = collectAzureSQLDatabaseAssets(ctx, resourcesClient, currentSub, cfg.Regions, endpointVnets, log, publisher)
+					if err != nil {
+						log.Errorf("Error while collecting Azure SQL database assets: %v", err)
+					}
+				}
+			}(sub)
+		}
+		if internal.IsAnyTypeEnabled(cfg.AssetTypes, "azure.vm.instance", "azure.vmss") {
+			go func(currentSub string) {
+				err := collectAzureScaleSetsVMAssets(ctx, vmClient, scaleSetsClient, currentSub, cfg.Regions, cfg.AssetTypes, log, publisher)
+				if err != nil {
+					log.Errorf("Error while collecting Azure Scale Sets VM assets: %v", err)
+				}
+			}(sub)
+		}
+		if internal.IsAnyTypeEnabled(cfg.AssetTypes, "k8s.cluster", "azure.aks.nodepool") {
+			clustersClient, err := armcontainerservice.NewManagedClustersClient(sub, cred, clientOptions)
+			if err != nil {
+				log.Errorf("Error creating Azure Managed Clusters Client: %v", err)
+				return
 			}
+			go func(currentSub string) {
+				err := collectAzureAKSAssets(ctx, clustersClient, scaleSetsClient, vmClient, currentSub, cfg.Regions, cfg.AssetTypes, log, publisher)
+				if err != nil {
+					log.Errorf("Error while collecting Azure AKS assets: %v", err)
+				}
+			}(sub)
 		}
 	}
+}
 
-	return subscriptions, nil
+// getAzureSubscriptions returns the given subscriptions or, if there are none, all the accessible subscriptions.
+func getAzureSubscriptions(ctx context.Context, subscriptionIds []string, cred azcore.TokenCredential, clientOptions *arm.ClientOptions) ([]string, error) {
+	if len(subscriptionIds) > 0 {
+		return subscriptionIds, nil
+	}
+	client, err := armsubscription.NewSubscriptionsClient(cred, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure Subscriptions Client: %w", err)
+	}
+	return listAccessibleSubscriptions(ctx, client)
 }
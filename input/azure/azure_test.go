@@ -21,6 +21,7 @@ import (
 	"context"
 	"github.com/elastic/assetbeat/input/testutil"
 	v2 "github.com/elastic/beats/v7/filebeat/input/v2"
+	conf "github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp"
 	"github.com/stretchr/testify/assert"
 	"sync"
@@ -69,3 +70,71 @@ func TestAssetsAzure_Run(t *testing.T) {
 		// Waitgroup finished in time, nothing to do
 	}
 }
+
+func TestNewAssetsAzure(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		source        string
+		cloud         string
+		expectedError string
+	}{
+		{
+			name:   "with the api source",
+			source: sourceAPI,
+		},
+		{
+			name:   "with the resource_graph source",
+			source: sourceResourceGraph,
+		},
+		{
+			name:          "with an unknown source",
+			source:        "cli",
+			expectedError: "invalid source \"cli\"",
+		},
+		{
+			name:          "with an unknown cloud",
+			source:        sourceAPI,
+			cloud:         "AzureStack",
+			expectedError: "invalid cloud \"AzureStack\"",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.Source = tt.source
+			if tt.cloud != "" {
+				cfg.Cloud = tt.cloud
+			}
+			_, err := newAssetsAzure(cfg)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestConfigure_Tenants(t *testing.T) {
+	inputCfg := conf.MustNewConfigFrom(map[string]interface{}{
+		"tenants": []map[string]interface{}{
+			{
+				"tenant_id":        "tenant1",
+				"credential_type":  "client_secret",
+				"client_id":        "client",
+				"client_secret":    "secret",
+				"subscription_ids": []string{subscriptionId},
+			},
+			{
+				"tenant_id":       "tenant2",
+				"credential_type": "workload_identity",
+			},
+		},
+	})
+
+	input, err := configure(inputCfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []tenantConfig{
+		{TenantID: "tenant1", CredentialType: "client_secret", ClientID: "client", ClientSecret: "secret", SubscriptionIDs: []string{subscriptionId}},
+		{TenantID: "tenant2", CredentialType: "workload_identity"},
+	}, input.(*assetsAzure).Config.Tenants)
+}
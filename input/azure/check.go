@@ -0,0 +1,165 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/subscription/armsubscription"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// checkAzureTenant verifies the credentials and subscriptions of a tenant.
+func checkAzureTenant(ctx context.Context, cfg config, tenant tenantConfig, log *logp.Logger) error {
+	cloudCfg, err := getCloudConfiguration(cfg.Cloud)
+	if err != nil {
+		return err
+	}
+	cred, err := getAzureCredentials(tenant, cloudCfg, log)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve Azure credentials: %w", err)
+	}
+	if err := checkAzureCredentials(ctx, cred, cloudCfg); err != nil {
+		return err
+	}
+	client, err := armsubscription.NewSubscriptionsClient(cred, getARMClientOptions(cloudCfg))
+	if err != nil {
+		return fmt.Errorf("failed to create Azure Subscriptions Client: %w", err)
+	}
+	return checkAzureSubscriptions(ctx, tenant.SubscriptionIDs, cfg.Regions, client, log)
+}
+
+// checkAzureCredentials verifies the credentials by acquiring a token for the Azure Resource Manager APIs.
+func checkAzureCredentials(ctx context.Context, cred azcore.TokenCredential, cloudCfg cloud.Configuration) error {
+	_, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{getManagementScope(cloudCfg)}})
+	if err != nil {
+		return fmt.Errorf("failed to acquire an Azure token: %w", err)
+	}
+	return nil
+}
+
+// checkAzureSubscriptions verifies that the given subscriptions, or at least one subscription when
+// none is given, are accessible, and that the regions are available in each of them.
+func checkAzureSubscriptions(ctx context.Context, subscriptionIds []string, regions []string, client *armsubscription.SubscriptionsClient, log *logp.Logger) error {
+	accessible, err := listAccessibleSubscriptions(ctx, client)
+	if err != nil {
+		return fmt.Errorf("unable to list Azure subscriptions: %w", err)
+	}
+	if len(accessible) == 0 {
+		return errors.New("no Azure subscription is accessible with the configured credentials")
+	}
+
+	subscriptions := accessible
+	if len(subscriptionIds) > 0 {
+		var inaccessible []string
+		for _, sub := range subscriptionIds {
+			if !containsFold(accessible, sub) {
+				inaccessible = append(inaccessible, sub)
+			}
+		}
+		if len(inaccessible) > 0 {
+			return fmt.Errorf("subscriptions not accessible with the configured credentials: %s", strings.Join(inaccessible, ", "))
+		}
+		subscriptions = subscriptionIds
+	}
+	if len(regions) == 0 {
+		log.Infof("%d Azure subscription(s) accessible, no regions to validate", len(subscriptions))
+		return nil
+	}
+
+	var errs []error
+	for _, sub := range subscriptions {
+		locations, err := listSubscriptionLocations(ctx, client, sub)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("subscription %s: unable to list locations: %w", sub, err))
+			continue
+		}
+		var missing []string
+		for _, region := range regions {
+			if !contains(locations, region) {
+				missing = append(missing, region)
+			}
+		}
+		if len(missing) > 0 {
+			errs = append(errs, fmt.Errorf("subscription %s: regions not available: %s", sub, strings.Join(missing, ", ")))
+			continue
+		}
+		log.Infof("subscription %s: all configured regions are available", sub)
+	}
+	return errors.Join(errs...)
+}
+
+func listAccessibleSubscriptions(ctx context.Context, client *armsubscription.SubscriptionsClient) ([]string, error) {
+	var subscriptions []string
+	pager := client.NewListPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to advance page: %v", err)
+		}
+		for _, v := range page.Value {
+			if v.SubscriptionID != nil {
+				subscriptions = append(subscriptions, *v.SubscriptionID)
+			}
+		}
+	}
+	return subscriptions, nil
+}
+
+// listSubscriptionLocations returns the names of the locations available in a subscription, e.g. westeurope.
+func listSubscriptionLocations(ctx context.Context, client *armsubscription.SubscriptionsClient, subscriptionId string) ([]string, error) {
+	var locations []string
+	pager := client.NewListLocationsPager(subscriptionId, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to advance page: %v", err)
+		}
+		for _, v := range page.Value {
+			if v.Name != nil {
+				locations = append(locations, *v.Name)
+			}
+		}
+	}
+	return locations, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold is like contains, but ignores the case of the values, e.g. for subscription IDs.
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
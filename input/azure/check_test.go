@@ -0,0 +1,155 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/subscription/armsubscription"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/stretchr/testify/assert"
+)
+
+// checkTransport serves the subscriptions and locations listed by the Subscriptions API, which has no fake server.
+type checkTransport struct {
+	subscriptions []string
+	locations     []string
+	failLocations bool
+	failListing   bool
+}
+
+func (c checkTransport) Do(req *http.Request) (*http.Response, error) {
+	status := http.StatusOK
+	var body string
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/locations"):
+		if c.failLocations {
+			status, body = http.StatusForbidden, `{"error": {"code": "AuthorizationFailed"}}`
+			break
+		}
+		var values []string
+		for _, l := range c.locations {
+			values = append(values, fmt.Sprintf(`{"name": "%s"}`, l))
+		}
+		body = fmt.Sprintf(`{"value": [%s]}`, strings.Join(values, ","))
+	default:
+		if c.failListing {
+			status, body = http.StatusForbidden, `{"error": {"code": "AuthorizationFailed"}}`
+			break
+		}
+		var values []string
+		for _, s := range c.subscriptions {
+			values = append(values, fmt.Sprintf(`{"subscriptionId": "%s"}`, s))
+		}
+		body = fmt.Sprintf(`{"value": [%s]}`, strings.Join(values, ","))
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestCheckAzureCredentials(t *testing.T) {
+	cred := &azfake.TokenCredential{}
+	assert.NoError(t, checkAzureCredentials(context.Background(), cred, cloud.AzurePublic))
+
+	cred.SetError(errors.New("invalid client secret"))
+	assert.ErrorContains(t, checkAzureCredentials(context.Background(), cred, cloud.AzurePublic), "invalid client secret")
+}
+
+func TestCheckAzureSubscriptions(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		subscriptionIDs []string
+		regions         []string
+		transport       checkTransport
+		expectedError   string
+	}{
+		{
+			name:      "with accessible subscriptions and no regions",
+			transport: checkTransport{subscriptions: []string{subscriptionId}},
+		},
+		{
+			name:            "with available regions",
+			subscriptionIDs: []string{subscriptionId},
+			regions:         []string{"westeurope"},
+			transport:       checkTransport{subscriptions: []string{subscriptionId}, locations: []string{"westeurope", "northeurope"}},
+		},
+		{
+			name:            "with a subscription configured with a different case",
+			subscriptionIDs: []string{strings.ToUpper(subscriptionId)},
+			transport:       checkTransport{subscriptions: []string{subscriptionId}},
+		},
+		{
+			name:          "with no accessible subscription",
+			transport:     checkTransport{},
+			expectedError: "no Azure subscription is accessible",
+		},
+		{
+			name:          "with a failure to list subscriptions",
+			transport:     checkTransport{failListing: true},
+			expectedError: "unable to list Azure subscriptions",
+		},
+		{
+			name:            "with an inaccessible subscription",
+			subscriptionIDs: []string{subscriptionId, "other"},
+			transport:       checkTransport{subscriptions: []string{subscriptionId}},
+			expectedError:   "subscriptions not accessible with the configured credentials: other",
+		},
+		{
+			name:          "with unavailable regions",
+			regions:       []string{"westeurope", "West Europe", "moon"},
+			transport:     checkTransport{subscriptions: []string{subscriptionId}, locations: []string{"westeurope"}},
+			expectedError: fmt.Sprintf("subscription %s: regions not available: West Europe, moon", subscriptionId),
+		},
+		{
+			name:          "with a failure to list locations",
+			regions:       []string{"westeurope"},
+			transport:     checkTransport{subscriptions: []string{subscriptionId}, failLocations: true},
+			expectedError: fmt.Sprintf("subscription %s: unable to list locations", subscriptionId),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := armsubscription.NewSubscriptionsClient(&azfake.TokenCredential{}, &arm.ClientOptions{
+				ClientOptions: azcore.ClientOptions{
+					Transport: tt.transport,
+				},
+			})
+			assert.NoError(t, err)
+
+			err = checkAzureSubscriptions(context.Background(), tt.subscriptionIDs, tt.regions, client, logp.NewLogger("test"))
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+)
+
+const (
+	// cloudAzurePublic is the global Azure cloud
+	cloudAzurePublic = "azure_public"
+	// cloudAzureChina is the Azure cloud operated by 21Vianet in China
+	cloudAzureChina = "azure_china"
+	// cloudAzureGovernment is the Azure cloud for US government agencies
+	cloudAzureGovernment = "azure_government"
+)
+
+// getCloudConfiguration returns the authority and endpoints of an Azure cloud, the public one by default.
+func getCloudConfiguration(name string) (cloud.Configuration, error) {
+	switch name {
+	case "", cloudAzurePublic:
+		return cloud.AzurePublic, nil
+	case cloudAzureChina:
+		return cloud.AzureChina, nil
+	case cloudAzureGovernment:
+		return cloud.AzureGovernment, nil
+	}
+	return cloud.Configuration{}, fmt.Errorf("invalid cloud %q, must be one of %q, %q or %q", name, cloudAzurePublic, cloudAzureChina, cloudAzureGovernment)
+}
+
+// getARMClientOptions returns the options of the Azure Resource Manager clients targeting an Azure cloud.
+func getARMClientOptions(cloudCfg cloud.Configuration) *arm.ClientOptions {
+	return &arm.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Cloud: cloudCfg,
+		},
+	}
+}
+
+// getManagementScope returns the scope of the tokens used to call the Azure Resource Manager APIs of an Azure cloud.
+func getManagementScope(cloudCfg cloud.Configuration) string {
+	return cloudCfg.Services[cloud.ResourceManager].Audience + "/.default"
+}
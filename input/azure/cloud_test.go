@@ -0,0 +1,91 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/subscription/armsubscription"
+	"github.com/stretchr/testify/assert"
+)
+
+// hostTransport records the hosts of the requests it serves.
+type hostTransport struct {
+	checkTransport
+	hosts *[]string
+}
+
+func (h hostTransport) Do(req *http.Request) (*http.Response, error) {
+	*h.hosts = append(*h.hosts, req.URL.Host)
+	return h.checkTransport.Do(req)
+}
+
+func TestGetCloudConfiguration(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		cloud         string
+		expectedHost  string
+		expectedScope string
+		expectedError string
+	}{
+		{
+			name:          "with the default cloud",
+			expectedHost:  "management.azure.com",
+			expectedScope: "https://management.core.windows.net//.default",
+		},
+		{
+			name:          "with Azure China",
+			cloud:         cloudAzureChina,
+			expectedHost:  "management.chinacloudapi.cn",
+			expectedScope: "https://management.core.chinacloudapi.cn/.default",
+		},
+		{
+			name:          "with Azure Government",
+			cloud:         cloudAzureGovernment,
+			expectedHost:  "management.usgovcloudapi.net",
+			expectedScope: "https://management.core.usgovcloudapi.net/.default",
+		},
+		{
+			name:          "with an unknown cloud",
+			cloud:         "AzureStack",
+			expectedError: "invalid cloud \"AzureStack\"",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cloudCfg, err := getCloudConfiguration(tt.cloud)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedScope, getManagementScope(cloudCfg))
+
+			var hosts []string
+			options := getARMClientOptions(cloudCfg)
+			options.Transport = hostTransport{checkTransport: checkTransport{subscriptions: []string{subscriptionId}}, hosts: &hosts}
+			client, err := armsubscription.NewSubscriptionsClient(&azfake.TokenCredential{}, options)
+			assert.NoError(t, err)
+			_, err = listAccessibleSubscriptions(context.Background(), client)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{tt.expectedHost}, hosts)
+		})
+	}
+}
@@ -0,0 +1,195 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const (
+	// credentialTypeClientSecret authenticates as a service principal with tenant_id, client_id and client_secret
+	credentialTypeClientSecret = "client_secret"
+	// credentialTypeClientCertificate authenticates as a service principal with tenant_id, client_id and certificate_path
+	credentialTypeClientCertificate = "client_certificate"
+	// credentialTypeManagedIdentity authenticates with the managed identity of the host, user-assigned if client_id is set
+	credentialTypeManagedIdentity = "managed_identity"
+	// credentialTypeWorkloadIdentity authenticates with the AKS workload identity of the pod
+	credentialTypeWorkloadIdentity = "workload_identity"
+	// credentialTypeAzureCLI authenticates with the account logged in with `az login`
+	credentialTypeAzureCLI = "azure_cli"
+)
+
+// tenantConfig holds the credentials used to collect the assets of an Azure tenant,
+// and the subscriptions to collect them from, all the accessible ones if empty.
+type tenantConfig struct {
+	TenantID            string   `config:"tenant_id"`
+	ClientID            string   `config:"client_id"`
+	ClientSecret        string   `config:"client_secret"`
+	CredentialType      string   `config:"credential_type"`
+	CertificatePath     string   `config:"certificate_path"`
+	CertificatePassword string   `config:"certificate_password"`
+	SubscriptionIDs     []string `config:"subscription_ids"`
+}
+
+// getTenants returns the configured tenants or, without tenants, a single
+// tenant made of the top-level credentials and subscription_id settings.
+func getTenants(cfg config) []tenantConfig {
+	if len(cfg.Tenants) > 0 {
+		return cfg.Tenants
+	}
+	tenant := tenantConfig{
+		TenantID:            cfg.TenantID,
+		ClientID:            cfg.ClientID,
+		ClientSecret:        cfg.ClientSecret,
+		CredentialType:      cfg.CredentialType,
+		CertificatePath:     cfg.CertificatePath,
+		CertificatePassword: cfg.CertificatePassword,
+	}
+	if cfg.SubscriptionID != "" {
+		tenant.SubscriptionIDs = []string{cfg.SubscriptionID}
+	}
+	return []tenantConfig{tenant}
+}
+
+// validateTenantsConfig checks the credentials of each tenant. The top-level credentials and subscription_id
+// cannot be combined with tenants, and each tenant needs a tenant_id to tell the assets of the tenants apart.
+func validateTenantsConfig(cfg config) error {
+	if len(cfg.Tenants) == 0 {
+		return validateCredentialConfig(getTenants(cfg)[0])
+	}
+	if cfg.TenantID != "" || cfg.ClientID != "" || cfg.ClientSecret != "" || cfg.CredentialType != "" || cfg.CertificatePath != "" || cfg.CertificatePassword != "" || cfg.SubscriptionID != "" {
+		return errors.New("tenants cannot be combined with top-level credentials or subscription_id")
+	}
+	for i, tenant := range cfg.Tenants {
+		if tenant.TenantID == "" {
+			return fmt.Errorf("tenants[%d] requires tenant_id to be set", i)
+		}
+		if err := validateCredentialConfig(tenant); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant.TenantID, err)
+		}
+	}
+	return nil
+}
+
+// validateCredentialConfig checks that credential_type is known and that the settings it requires are set.
+// Without credential_type, the credentials are chosen from the settings which are present.
+// certificate_password only applies to certificate_path, so it cannot be set without it.
+func validateCredentialConfig(cfg tenantConfig) error {
+	if cfg.CertificatePassword != "" && cfg.CertificatePath == "" {
+		return errors.New("certificate_password requires certificate_path to be set")
+	}
+	var missing []string
+	switch cfg.CredentialType {
+	case "", credentialTypeManagedIdentity, credentialTypeWorkloadIdentity, credentialTypeAzureCLI:
+		return nil
+	case credentialTypeClientSecret:
+		missing = missingSettings(cfg, "tenant_id", "client_id", "client_secret")
+	case credentialTypeClientCertificate:
+		missing = missingSettings(cfg, "tenant_id", "client_id", "certificate_path")
+	default:
+		return fmt.Errorf("invalid credential_type %q, must be one of %q, %q, %q, %q or %q", cfg.CredentialType,
+			credentialTypeClientSecret, credentialTypeClientCertificate, credentialTypeManagedIdentity, credentialTypeWorkloadIdentity, credentialTypeAzureCLI)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("credential_type %q requires %v to be set", cfg.CredentialType, missing)
+	}
+	return nil
+}
+
+// missingSettings returns the names of the given settings which are not set.
+func missingSettings(cfg tenantConfig, names ...string) []string {
+	values := map[string]string{
+		"tenant_id":        cfg.TenantID,
+		"client_id":        cfg.ClientID,
+		"client_secret":    cfg.ClientSecret,
+		"certificate_path": cfg.CertificatePath,
+	}
+	var missing []string
+	for _, name := range names {
+		if values[name] == "" {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// getAzureCredentials returns the credentials of a tenant, authenticating against the authority of an Azure cloud.
+// The Azure CLI credentials use the cloud selected with `az cloud set` instead.
+func getAzureCredentials(cfg tenantConfig, cloudCfg cloud.Configuration, log *logp.Logger) (azcore.TokenCredential, error) {
+	clientOptions := azcore.ClientOptions{Cloud: cloudCfg}
+	switch cfg.CredentialType {
+	case credentialTypeClientSecret:
+		log.Debug("Retrieving Azure client secret credentials from assetbeat configuration...")
+		return azidentity.NewClientSecretCredential(cfg.TenantID, cfg.ClientID, cfg.ClientSecret, &azidentity.ClientSecretCredentialOptions{ClientOptions: clientOptions})
+	case credentialTypeClientCertificate:
+		log.Debug("Retrieving Azure client certificate credentials from assetbeat configuration...")
+		return getAzureClientCertificateCredential(cfg, clientOptions)
+	case credentialTypeManagedIdentity:
+		log.Debug("Retrieving Azure managed identity credentials...")
+		options := &azidentity.ManagedIdentityCredentialOptions{ClientOptions: clientOptions}
+		if cfg.ClientID != "" {
+			options.ID = azidentity.ClientID(cfg.ClientID)
+		}
+		return azidentity.NewManagedIdentityCredential(options)
+	case credentialTypeWorkloadIdentity:
+		log.Debug("Retrieving Azure workload identity credentials...")
+		return azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+			ClientOptions: clientOptions,
+			ClientID:      cfg.ClientID,
+			TenantID:      cfg.TenantID,
+		})
+	case credentialTypeAzureCLI:
+		log.Debug("Retrieving Azure CLI credentials...")
+		return azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{TenantID: cfg.TenantID})
+	}
+
+	if cfg.TenantID != "" && cfg.ClientID != "" && cfg.ClientSecret != "" {
+		log.Debug("Retrieving Azure credentials from assetbeat configuration...")
+		return azidentity.NewClientSecretCredential(cfg.TenantID, cfg.ClientID, cfg.ClientSecret, &azidentity.ClientSecretCredentialOptions{ClientOptions: clientOptions})
+	} else {
+		log.Debug("No Client or Tenant configuration provided. Retrieving default Azure credentials")
+		return azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{ClientOptions: clientOptions})
+	}
+}
+
+// getAzureClientCertificateCredential reads the certificate and its private key from a PEM or PKCS#12 file.
+func getAzureClientCertificateCredential(cfg tenantConfig, clientOptions azcore.ClientOptions) (azcore.TokenCredential, error) {
+	data, err := os.ReadFile(cfg.CertificatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate %s: %w", cfg.CertificatePath, err)
+	}
+	var password []byte
+	if cfg.CertificatePassword != "" {
+		password = []byte(cfg.CertificatePassword)
+	}
+	certs, key, err := azidentity.ParseCertificates(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate %s: %w", cfg.CertificatePath, err)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found in " + cfg.CertificatePath)
+	}
+	return azidentity.NewClientCertificateCredential(cfg.TenantID, cfg.ClientID, certs, key, &azidentity.ClientCertificateCredentialOptions{ClientOptions: clientOptions})
+}
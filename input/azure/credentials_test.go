@@ -0,0 +1,257 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTenants(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.TenantID = "tenant1"
+	cfg.ClientID = "client"
+	cfg.ClientSecret = "secret"
+	cfg.SubscriptionID = subscriptionId
+	assert.Equal(t, []tenantConfig{
+		{TenantID: "tenant1", ClientID: "client", ClientSecret: "secret", SubscriptionIDs: []string{subscriptionId}},
+	}, getTenants(cfg))
+
+	tenants := []tenantConfig{
+		{TenantID: "tenant1", CredentialType: credentialTypeAzureCLI},
+		{TenantID: "tenant2", CredentialType: credentialTypeAzureCLI, SubscriptionIDs: []string{subscriptionId}},
+	}
+	cfg = defaultConfig()
+	cfg.Tenants = tenants
+	assert.Equal(t, tenants, getTenants(cfg))
+}
+
+func TestValidateTenantsConfig(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		cfg           config
+		expectedError string
+	}{
+		{
+			name: "without tenants",
+			cfg:  config{TenantID: "tenant1", ClientID: "client", ClientSecret: "secret"},
+		},
+		{
+			name: "with tenants",
+			cfg: config{Tenants: []tenantConfig{
+				{TenantID: "tenant1", ClientID: "client", ClientSecret: "secret"},
+				{TenantID: "tenant2", CredentialType: credentialTypeManagedIdentity},
+			}},
+		},
+		{
+			name:          "with invalid top-level credentials",
+			cfg:           config{CredentialType: credentialTypeClientSecret},
+			expectedError: "credential_type \"client_secret\" requires [tenant_id client_id client_secret] to be set",
+		},
+		{
+			name: "with tenants and top-level credentials",
+			cfg: config{ClientID: "client", Tenants: []tenantConfig{
+				{TenantID: "tenant1", CredentialType: credentialTypeAzureCLI},
+			}},
+			expectedError: "tenants cannot be combined with top-level credentials or subscription_id",
+		},
+		{
+			name: "with tenants and a top-level certificate_password",
+			cfg: config{CertificatePassword: "password", Tenants: []tenantConfig{
+				{TenantID: "tenant1", CredentialType: credentialTypeAzureCLI},
+			}},
+			expectedError: "tenants cannot be combined with top-level credentials or subscription_id",
+		},
+		{
+			name: "with a tenant without tenant_id",
+			cfg: config{Tenants: []tenantConfig{
+				{TenantID: "tenant1", CredentialType: credentialTypeAzureCLI},
+				{CredentialType: credentialTypeAzureCLI},
+			}},
+			expectedError: "tenants[1] requires tenant_id to be set",
+		},
+		{
+			name: "with a tenant with invalid credentials",
+			cfg: config{Tenants: []tenantConfig{
+				{TenantID: "tenant1", CredentialType: "password"},
+			}},
+			expectedError: "tenant tenant1: invalid credential_type \"password\"",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTenantsConfig(tt.cfg)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateCredentialConfig(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		cfg           tenantConfig
+		expectedError string
+	}{
+		{
+			name: "without credential_type",
+			cfg:  tenantConfig{},
+		},
+		{
+			name: "with client_secret",
+			cfg:  tenantConfig{CredentialType: credentialTypeClientSecret, TenantID: "tenant", ClientID: "client", ClientSecret: "secret"},
+		},
+		{
+			name:          "with client_secret and no secret",
+			cfg:           tenantConfig{CredentialType: credentialTypeClientSecret, TenantID: "tenant", ClientID: "client"},
+			expectedError: "credential_type \"client_secret\" requires [client_secret] to be set",
+		},
+		{
+			name:          "with client_certificate and no settings",
+			cfg:           tenantConfig{CredentialType: credentialTypeClientCertificate},
+			expectedError: "credential_type \"client_certificate\" requires [tenant_id client_id certificate_path] to be set",
+		},
+		{
+			name:          "with certificate_password and no certificate_path",
+			cfg:           tenantConfig{CertificatePassword: "password"},
+			expectedError: "certificate_password requires certificate_path to be set",
+		},
+		{
+			name:          "with client_secret and a certificate_password",
+			cfg:           tenantConfig{CredentialType: credentialTypeClientSecret, TenantID: "tenant", ClientID: "client", ClientSecret: "secret", CertificatePassword: "password"},
+			expectedError: "certificate_password requires certificate_path to be set",
+		},
+		{
+			name: "with managed_identity",
+			cfg:  tenantConfig{CredentialType: credentialTypeManagedIdentity},
+		},
+		{
+			name: "with workload_identity",
+			cfg:  tenantConfig{CredentialType: credentialTypeWorkloadIdentity},
+		},
+		{
+			name: "with azure_cli",
+			cfg:  tenantConfig{CredentialType: credentialTypeAzureCLI},
+		},
+		{
+			name:          "with an unknown credential_type",
+			cfg:           tenantConfig{CredentialType: "password"},
+			expectedError: "invalid credential_type \"password\"",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCredentialConfig(tt.cfg)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestGetAzureCredentials(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token"), 0600))
+	t.Setenv("AZURE_FEDERATED_TOKEN_FILE", tokenFile)
+
+	for _, tt := range []struct {
+		name     string
+		cfg      tenantConfig
+		expected any
+	}{
+		{
+			name:     "with client_secret",
+			cfg:      tenantConfig{CredentialType: credentialTypeClientSecret, TenantID: "tenant", ClientID: "client", ClientSecret: "secret"},
+			expected: &azidentity.ClientSecretCredential{},
+		},
+		{
+			name:     "with client_certificate",
+			cfg:      tenantConfig{CredentialType: credentialTypeClientCertificate, TenantID: "tenant", ClientID: "client", CertificatePath: writeTestCertificate(t)},
+			expected: &azidentity.ClientCertificateCredential{},
+		},
+		{
+			name:     "with a user-assigned managed_identity",
+			cfg:      tenantConfig{CredentialType: credentialTypeManagedIdentity, ClientID: "client"},
+			expected: &azidentity.ManagedIdentityCredential{},
+		},
+		{
+			name:     "with workload_identity",
+			cfg:      tenantConfig{CredentialType: credentialTypeWorkloadIdentity, TenantID: "tenant", ClientID: "client"},
+			expected: &azidentity.WorkloadIdentityCredential{},
+		},
+		{
+			name:     "with azure_cli",
+			cfg:      tenantConfig{CredentialType: credentialTypeAzureCLI},
+			expected: &azidentity.AzureCLICredential{},
+		},
+		{
+			name:     "with client secret settings and no credential_type",
+			cfg:      tenantConfig{TenantID: "tenant", ClientID: "client", ClientSecret: "secret"},
+			expected: &azidentity.ClientSecretCredential{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cred, err := getAzureCredentials(tt.cfg, cloud.AzurePublic, logp.NewLogger("test"))
+			assert.NoError(t, err)
+			assert.IsType(t, tt.expected, cred)
+		})
+	}
+
+	t.Run("with a missing certificate", func(t *testing.T) {
+		cfg := tenantConfig{CredentialType: credentialTypeClientCertificate, TenantID: "tenant", ClientID: "client", CertificatePath: "missing.pem"}
+		_, err := getAzureCredentials(cfg, cloud.AzurePublic, logp.NewLogger("test"))
+		assert.ErrorContains(t, err, "failed to read certificate missing.pem")
+	})
+}
+
+// writeTestCertificate writes a self-signed certificate and its private key to a PEM file and returns its path.
+func writeTestCertificate(t *testing.T) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "assetbeat"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})...)
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	return path
+}
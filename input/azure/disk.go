@@ -0,0 +1,109 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// collectAzureDiskAssets publishes the managed disks of a subscription, linked to the VMs they are attached to.
+func collectAzureDiskAssets(ctx context.Context, client *armcompute.DisksClient, subscriptionId string, regions []string, vmsByResourceId map[string]string, log *logp.Logger, publisher stateless.Publisher) error {
+	disks, err := getAllAzureDisks(ctx, client, subscriptionId, regions, vmsByResourceId)
+	if err != nil {
+		return err
+	}
+
+	log.Debug("Publishing Azure managed disks")
+	publishAzureStorageAssets(publisher, "azure.disk", "disk", disks)
+	return nil
+}
+
+func getAllAzureDisks(ctx context.Context, client *armcompute.DisksClient, subscriptionId string, regions []string, vmsByResourceId map[string]string) ([]AzureStorageAsset, error) {
+	var disks []AzureStorageAsset
+	pager := client.NewListPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to advance page: %v", err)
+		}
+		for _, v := range page.Value {
+			if !isRegionWanted(*v.Location, regions) {
+				continue
+			}
+			disks = append(disks, newAzureDiskAsset(v, subscriptionId, vmsByResourceId))
+		}
+	}
+	return disks, nil
+}
+
+// newAzureDiskAsset returns the asset of a managed disk. Disks attached to a VM are managed by it, and are parented to it
+// when it is found in vmsByResourceId.
+func newAzureDiskAsset(v *armcompute.Disk, subscriptionId string, vmsByResourceId map[string]string) AzureStorageAsset {
+	parents := []string{getResourceGroupEAN(*v.ID)}
+	if v.ManagedBy != nil {
+		if vm, ok := vmsByResourceId[strings.ToLower(*v.ManagedBy)]; ok {
+			parents = append(parents, vm)
+		}
+	}
+	metadata := mapstr.M{
+		"resource_group": getResourceGroupFromId(*v.ID),
+	}
+	if v.SKU != nil && v.SKU.Name != nil {
+		metadata["sku"] = string(*v.SKU.Name)
+	}
+	if p := v.Properties; p != nil {
+		if p.DiskSizeGB != nil {
+			metadata["size_gb"] = *p.DiskSizeGB
+		}
+		if p.DiskState != nil {
+			metadata["state"] = string(*p.DiskState)
+		}
+		if p.OSType != nil {
+			metadata["os_type"] = string(*p.OSType)
+		}
+		if p.Encryption != nil && p.Encryption.Type != nil {
+			metadata["encryption"] = string(*p.Encryption.Type)
+		}
+	}
+	return AzureStorageAsset{
+		ID:             *v.ID,
+		Name:           *v.Name,
+		SubscriptionID: subscriptionId,
+		Region:         *v.Location,
+		Parents:        parents,
+		Tags:           v.Tags,
+		Metadata:       metadata,
+	}
+}
+
+// getVMsByResourceId maps the resource IDs of VMs to their EANs, which are based on their VM IDs.
+// Azure resource IDs are case-insensitive, so the keys are lower cased.
+func getVMsByResourceId(instances []AzureVMInstance) map[string]string {
+	vms := make(map[string]string, len(instances))
+	for _, instance := range instances {
+		vms[strings.ToLower(instance.ResourceID)] = "host:" + instance.ID
+	}
+	return vms
+}
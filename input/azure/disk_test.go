@@ -0,0 +1,136 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5/fake"
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/assetbeat/input/testutil"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/stretchr/testify/assert"
+)
+
+var diskID = fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute/disks/disk1", subscriptionId, resourceGroup1)
+
+var disk1 = armcompute.Disk{
+	Location:  to.Ptr("westeurope"),
+	ID:        to.Ptr(diskID),
+	Name:      to.Ptr("disk1"),
+	ManagedBy: to.Ptr(strings.ToUpper(instanceid1)),
+	Tags:      map[string]*string{"env": to.Ptr("prod")},
+	SKU:       &armcompute.DiskSKU{Name: to.Ptr(armcompute.DiskStorageAccountTypesPremiumLRS)},
+	Properties: &armcompute.DiskProperties{
+		DiskSizeGB: to.Ptr(int32(30)),
+		DiskState:  to.Ptr(armcompute.DiskStateAttached),
+		OSType:     to.Ptr(armcompute.OperatingSystemTypesLinux),
+		Encryption: &armcompute.Encryption{Type: to.Ptr(armcompute.EncryptionTypeEncryptionAtRestWithPlatformKey)},
+	},
+}
+
+func TestAssetsAzure_collectAzureDiskAssets(t *testing.T) {
+	diskEvent := func(parents []string) beat.Event {
+		return beat.Event{
+			Fields: mapstr.M{
+				"asset.ean":                     "disk:" + diskID,
+				"asset.id":                      diskID,
+				"asset.name":                    "disk1",
+				"asset.type":                    "azure.disk",
+				"asset.kind":                    "disk",
+				"asset.parents":                 parents,
+				"asset.metadata.resource_group": "TESTVM",
+				"asset.metadata.sku":            "Premium_LRS",
+				"asset.metadata.size_gb":        int32(30),
+				"asset.metadata.state":          "Attached",
+				"asset.metadata.os_type":        "Linux",
+				"asset.metadata.encryption":     "EncryptionAtRestWithPlatformKey",
+				"asset.metadata.tags.env":       "prod",
+				"cloud.account.id":              subscriptionId,
+				"cloud.provider":                "azure",
+				"cloud.region":                  "westeurope",
+			},
+			Meta: mapstr.M{
+				"index": internal.GetDefaultIndexName(),
+			},
+		}
+	}
+
+	for _, tt := range []struct {
+		name            string
+		regions         []string
+		vmsByResourceId map[string]string
+		expectedEvents  []beat.Event
+	}{
+		{
+			name: "Test with a disk attached to a VM",
+			vmsByResourceId: getVMsByResourceId([]AzureVMInstance{
+				{ID: instanceVMId1, ResourceID: instanceid1},
+			}),
+			expectedEvents: []beat.Event{diskEvent([]string{resourceGroup1EAN, "host:" + instanceVMId1})},
+		},
+		{
+			name:           "Test with a disk attached to an unknown VM",
+			expectedEvents: []beat.Event{diskEvent([]string{resourceGroup1EAN})},
+		},
+		{
+			name:    "Test with another region specified",
+			regions: []string{"eastus"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := testutil.NewInMemoryPublisher()
+
+			ctx := context.Background()
+			logger := logp.NewLogger("test")
+
+			fakeServer := fake.DisksServer{
+				NewListPager: func(options *armcompute.DisksClientListOptions) (resp azfake.PagerResponder[armcompute.DisksClientListResponse]) {
+					page := armcompute.DisksClientListResponse{
+						DiskList: armcompute.DiskList{
+							Value: []*armcompute.Disk{&disk1},
+						},
+					}
+					resp.AddPage(http.StatusOK, page, nil)
+					return
+				},
+			}
+			client, err := armcompute.NewDisksClient("subscriptionID", &azfake.TokenCredential{}, &arm.ClientOptions{
+				ClientOptions: azcore.ClientOptions{
+					Transport: fake.NewDisksServerTransport(&fakeServer),
+				},
+			})
+			assert.NoError(t, err)
+
+			err = collectAzureDiskAssets(ctx, client, subscriptionId, tt.regions, tt.vmsByResourceId, logger, publisher)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedEvents, publisher.Events)
+		})
+	}
+}
@@ -0,0 +1,307 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
+	"github.com/elastic/assetbeat/input/internal"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type AzureNetworkAsset struct {
+	ID             string
+	Name           string
+	SubscriptionID string
+	Region         string
+	Parents        []string
+	Tags           map[string]*string
+	Metadata       mapstr.M
+}
+
+// collectAzureVnetAssets publishes the virtual networks of a subscription, along with their subnets.
+func collectAzureVnetAssets(ctx context.Context, client *armnetwork.VirtualNetworksClient, subscriptionId string, regions []string, assetTypes []string, log *logp.Logger, publisher stateless.Publisher) error {
+	vnets, subnets, err := getAllAzureVnets(ctx, client, subscriptionId, regions)
+	if err != nil {
+		return err
+	}
+
+	if internal.IsTypeEnabled(assetTypes, "azure.vnet") {
+		log.Debug("Publishing Azure virtual networks")
+		publishAzureNetworkAssets(publisher, "azure.vnet", vnets)
+	}
+	if internal.IsTypeEnabled(assetTypes, "azure.subnet") {
+		log.Debug("Publishing Azure subnets")
+		publishAzureNetworkAssets(publisher, "azure.subnet", subnets)
+	}
+	return nil
+}
+
+func collectAzureNetworkInterfaceAssets(interfaces []AzureNetworkAsset, log *logp.Logger, publisher stateless.Publisher) {
+	log.Debug("Publishing Azure network interfaces")
+	publishAzureNetworkAssets(publisher, "azure.network_interface", interfaces)
+}
+
+func publishAzureNetworkAssets(publisher stateless.Publisher, assetType string, assets []AzureNetworkAsset) {
+	assetKind := "network"
+	for _, asset := range assets {
+		options := []internal.AssetOption{
+			internal.WithAssetCloudProvider("azure"),
+			internal.WithAssetRegion(asset.Region),
+			internal.WithAssetAccountID(asset.SubscriptionID),
+			internal.WithAssetKindAndID(assetKind, getNetworkResourceId(asset.ID)),
+			internal.WithAssetType(assetType),
+			internal.WithAssetMetadata(asset.Metadata),
+			WithAssetTags(flattenAzureTags(asset.Tags)),
+		}
+		if asset.Parents != nil {
+			options = append(options, internal.WithAssetParents(asset.Parents))
+		}
+		if asset.Name != "" {
+			options = append(options, internal.WithAssetName(asset.Name))
+		}
+		internal.Publish(publisher, nil, options...)
+	}
+}
+
+// getNetworkResourceId returns the resource ID of a virtual network, subnet or network interface, lower cased
+// like the ID of resource groups for the resources referring to it with a different case to agree on it.
+func getNetworkResourceId(resourceId string) string {
+	return strings.ToLower(resourceId)
+}
+
+// getNetworkEAN returns the EAN of a virtual network, subnet or network interface, from the resource ID.
+func getNetworkEAN(resourceId string) string {
+	return "network:" + getNetworkResourceId(resourceId)
+}
+
+// getAllAzureVnets returns the virtual networks of a subscription and their subnets,
+// which are part of the virtual network resource.
+func getAllAzureVnets(ctx context.Context, client *armnetwork.VirtualNetworksClient, subscriptionId string, regions []string) ([]AzureNetworkAsset, []AzureNetworkAsset, error) {
+	var vnets []AzureNetworkAsset
+	var subnets []AzureNetworkAsset
+	pager := client.NewListAllPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to advance page: %v", err)
+		}
+		for _, v := range page.Value {
+			if !isRegionWanted(*v.Location, regions) {
+				continue
+			}
+			vnet, vnetSubnets := newAzureVnetAssets(v, subscriptionId)
+			vnets = append(vnets, vnet)
+			subnets = append(subnets, vnetSubnets...)
+		}
+	}
+	return vnets, subnets, nil
+}
+
+// newAzureVnetAssets returns the asset of a virtual network, along with the assets of its subnets.
+func newAzureVnetAssets(v *armnetwork.VirtualNetwork, subscriptionId string) (AzureNetworkAsset, []AzureNetworkAsset) {
+	var addressSpace []string
+	var state string
+	if v.Properties != nil {
+		if v.Properties.AddressSpace != nil {
+			addressSpace = toStrings(v.Properties.AddressSpace.AddressPrefixes)
+		}
+		if v.Properties.ProvisioningState != nil {
+			state = string(*v.Properties.ProvisioningState)
+		}
+	}
+	vnet := AzureNetworkAsset{
+		ID:             *v.ID,
+		Name:           *v.Name,
+		SubscriptionID: subscriptionId,
+		Region:         *v.Location,
+		Parents:        []string{getResourceGroupEAN(*v.ID)},
+		Tags:           v.Tags,
+		Metadata: mapstr.M{
+			"state":          state,
+			"address_space":  addressSpace,
+			"resource_group": getResourceGroupFromId(*v.ID),
+		},
+	}
+
+	if v.Properties == nil {
+		return vnet, nil
+	}
+	var subnets []AzureNetworkAsset
+	for _, s := range v.Properties.Subnets {
+		var addressPrefixes []string
+		var subnetState string
+		if s.Properties != nil {
+			addressPrefixes = toStrings(s.Properties.AddressPrefixes)
+			if s.Properties.AddressPrefix != nil {
+				addressPrefixes = append(addressPrefixes, *s.Properties.AddressPrefix)
+			}
+			if s.Properties.ProvisioningState != nil {
+				subnetState = string(*s.Properties.ProvisioningState)
+			}
+		}
+		subnets = append(subnets, AzureNetworkAsset{
+			ID:             *s.ID,
+			Name:           *s.Name,
+			SubscriptionID: subscriptionId,
+			Region:         *v.Location,
+			Parents:        []string{getNetworkEAN(*v.ID)},
+			Metadata: mapstr.M{
+				"state":            subnetState,
+				"address_prefixes": addressPrefixes,
+				"resource_group":   getResourceGroupFromId(*s.ID),
+			},
+		})
+	}
+	return vnet, subnets
+}
+
+// getAllAzureNetworkInterfaces returns the network interfaces of a subscription. Network interfaces of VM scale set instances are not listed here,
+// as they are not standalone resources.
+func getAllAzureNetworkInterfaces(ctx context.Context, client *armnetwork.InterfacesClient, subscriptionId string, regions []string) ([]AzureNetworkAsset, error) {
+	var interfaces []AzureNetworkAsset
+	pager := client.NewListAllPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to advance page: %v", err)
+		}
+		for _, v := range page.Value {
+			if !isRegionWanted(*v.Location, regions) {
+				continue
+			}
+			interfaces = append(interfaces, newAzureNetworkInterfaceAsset(v, subscriptionId))
+		}
+	}
+	return interfaces, nil
+}
+
+// newAzureNetworkInterfaceAsset returns the asset of a network interface, parented to its resource group
+// and to the subnets of its IP configurations.
+func newAzureNetworkInterfaceAsset(v *armnetwork.Interface, subscriptionId string) AzureNetworkAsset {
+	parents := []string{getResourceGroupEAN(*v.ID)}
+	var privateIPs []string
+	metadata := mapstr.M{
+		"resource_group": getResourceGroupFromId(*v.ID),
+	}
+	if v.Properties != nil {
+		for _, ipConfig := range v.Properties.IPConfigurations {
+			if ipConfig.Properties == nil {
+				continue
+			}
+			if ipConfig.Properties.Subnet != nil && ipConfig.Properties.Subnet.ID != nil {
+				parents = appendIfMissing(parents, getNetworkEAN(*ipConfig.Properties.Subnet.ID))
+			}
+			if ipConfig.Properties.PrivateIPAddress != nil {
+				privateIPs = append(privateIPs, *ipConfig.Properties.PrivateIPAddress)
+			}
+		}
+		metadata["private_ip_addresses"] = privateIPs
+		if v.Properties.MacAddress != nil {
+			metadata["mac_address"] = *v.Properties.MacAddress
+		}
+		if v.Properties.VirtualMachine != nil && v.Properties.VirtualMachine.ID != nil {
+			metadata["virtual_machine"] = *v.Properties.VirtualMachine.ID
+		}
+		if v.Properties.ProvisioningState != nil {
+			metadata["state"] = string(*v.Properties.ProvisioningState)
+		}
+	}
+	return AzureNetworkAsset{
+		ID:             *v.ID,
+		Name:           *v.Name,
+		SubscriptionID: subscriptionId,
+		Region:         *v.Location,
+		Parents:        parents,
+		Tags:           v.Tags,
+		Metadata:       metadata,
+	}
+}
+
+// getSubnetsByNetworkInterface maps the network interface IDs to the EANs of their subnets.
+// Azure resource IDs are case-insensitive, so the keys are lower cased.
+func getSubnetsByNetworkInterface(interfaces []AzureNetworkAsset) map[string][]string {
+	subnets := make(map[string][]string, len(interfaces))
+	for _, nic := range interfaces {
+		var nicSubnets []string
+		for _, parent := range nic.Parents {
+			if strings.HasPrefix(parent, "network:") {
+				nicSubnets = append(nicSubnets, parent)
+			}
+		}
+		subnets[strings.ToLower(nic.ID)] = nicSubnets
+	}
+	return subnets
+}
+
+// getPrivateEndpointVnets maps the IDs of the resources exposed through private endpoints to the EANs of the
+// virtual networks of these private endpoints. Azure resource IDs are case-insensitive, so the keys are lower cased.
+func getPrivateEndpointVnets(ctx context.Context, client *armnetwork.PrivateEndpointsClient) (map[string][]string, error) {
+	vnets := make(map[string][]string)
+	pager := client.NewListBySubscriptionPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to advance page: %v", err)
+		}
+		for _, v := range page.Value {
+			addPrivateEndpointVnets(vnets, v)
+		}
+	}
+	return vnets, nil
+}
+
+func addPrivateEndpointVnets(vnets map[string][]string, v *armnetwork.PrivateEndpoint) {
+	if v.Properties == nil || v.Properties.Subnet == nil || v.Properties.Subnet.ID == nil {
+		return
+	}
+	vnet := getNetworkEAN(getVnetIdFromSubnetId(*v.Properties.Subnet.ID))
+	for _, connections := range [][]*armnetwork.PrivateLinkServiceConnection{v.Properties.PrivateLinkServiceConnections, v.Properties.ManualPrivateLinkServiceConnections} {
+		for _, c := range connections {
+			if c.Properties == nil || c.Properties.PrivateLinkServiceID == nil {
+				continue
+			}
+			id := strings.ToLower(*c.Properties.PrivateLinkServiceID)
+			vnets[id] = appendIfMissing(vnets[id], vnet)
+		}
+	}
+}
+
+func toStrings(values []*string) []string {
+	var s []string
+	for _, v := range values {
+		if v != nil {
+			s = append(s, *v)
+		}
+	}
+	return s
+}
+
+func appendIfMissing(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
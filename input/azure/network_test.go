@@ -0,0 +1,263 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
+	networkfake "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4/fake"
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/assetbeat/input/testutil"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/stretchr/testify/assert"
+)
+
+var subnetID = vnetID + "/subnets/subnet1"
+var nicID = fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/networkInterfaces/nic1", subscriptionId, resourceGroup1)
+
+var vnet1 = armnetwork.VirtualNetwork{
+	Location: to.Ptr("westeurope"),
+	ID:       to.Ptr(vnetID),
+	Name:     to.Ptr("vnet1"),
+	Properties: &armnetwork.VirtualNetworkPropertiesFormat{
+		AddressSpace:      &armnetwork.AddressSpace{AddressPrefixes: []*string{to.Ptr("10.0.0.0/16")}},
+		ProvisioningState: to.Ptr(armnetwork.ProvisioningStateSucceeded),
+		Subnets: []*armnetwork.Subnet{
+			{
+				ID:   to.Ptr(subnetID),
+				Name: to.Ptr("subnet1"),
+				Properties: &armnetwork.SubnetPropertiesFormat{
+					AddressPrefix:     to.Ptr("10.0.0.0/24"),
+					ProvisioningState: to.Ptr(armnetwork.ProvisioningStateSucceeded),
+				},
+			},
+		},
+	},
+}
+
+var nic1 = armnetwork.Interface{
+	Location: to.Ptr("westeurope"),
+	ID:       to.Ptr(nicID),
+	Name:     to.Ptr("nic1"),
+	Properties: &armnetwork.InterfacePropertiesFormat{
+		IPConfigurations: []*armnetwork.InterfaceIPConfiguration{
+			{
+				Properties: &armnetwork.InterfaceIPConfigurationPropertiesFormat{
+					Subnet:           &armnetwork.Subnet{ID: to.Ptr(subnetID)},
+					PrivateIPAddress: to.Ptr("10.0.0.4"),
+				},
+			},
+		},
+		MacAddress:        to.Ptr("00-0D-3A-2B-4C-5D"),
+		VirtualMachine:    &armnetwork.SubResource{ID: to.Ptr(instanceid1)},
+		ProvisioningState: to.Ptr(armnetwork.ProvisioningStateSucceeded),
+	},
+}
+
+func TestAssetsAzure_collectAzureVnetAssets(t *testing.T) {
+	vnetEvent := beat.Event{
+		Fields: mapstr.M{
+			"asset.ean":                     "network:" + strings.ToLower(vnetID),
+			"asset.id":                      strings.ToLower(vnetID),
+			"asset.name":                    "vnet1",
+			"asset.type":                    "azure.vnet",
+			"asset.kind":                    "network",
+			"asset.parents":                 []string{resourceGroup1EAN},
+			"asset.metadata.state":          "Succeeded",
+			"asset.metadata.address_space":  []string{"10.0.0.0/16"},
+			"asset.metadata.resource_group": "TESTVM",
+			"cloud.account.id":              "12cabcb4-86e8-404f-111111111111",
+			"cloud.provider":                "azure",
+			"cloud.region":                  "westeurope",
+		},
+		Meta: mapstr.M{
+			"index": internal.GetDefaultIndexName(),
+		},
+	}
+	subnetEvent := beat.Event{
+		Fields: mapstr.M{
+			"asset.ean":                       "network:" + strings.ToLower(subnetID),
+			"asset.id":                        strings.ToLower(subnetID),
+			"asset.name":                      "subnet1",
+			"asset.type":                      "azure.subnet",
+			"asset.kind":                      "network",
+			"asset.parents":                   []string{"network:" + strings.ToLower(vnetID)},
+			"asset.metadata.state":            "Succeeded",
+			"asset.metadata.address_prefixes": []string{"10.0.0.0/24"},
+			"asset.metadata.resource_group":   "TESTVM",
+			"cloud.account.id":                "12cabcb4-86e8-404f-111111111111",
+			"cloud.provider":                  "azure",
+			"cloud.region":                    "westeurope",
+		},
+		Meta: mapstr.M{
+			"index": internal.GetDefaultIndexName(),
+		},
+	}
+
+	for _, tt := range []struct {
+		name           string
+		regions        []string
+		assetTypes     []string
+		subscriptionId string
+		expectedEvents []beat.Event
+	}{
+		{
+			name:           "Test with all types enabled",
+			subscriptionId: "12cabcb4-86e8-404f-111111111111",
+			expectedEvents: []beat.Event{vnetEvent, subnetEvent},
+		},
+		{
+			name:           "Test with only subnets enabled",
+			assetTypes:     []string{"azure.subnet"},
+			subscriptionId: "12cabcb4-86e8-404f-111111111111",
+			expectedEvents: []beat.Event{subnetEvent},
+		},
+		{
+			name:           "Test with another region specified",
+			regions:        []string{"eastus"},
+			subscriptionId: "12cabcb4-86e8-404f-111111111111",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := testutil.NewInMemoryPublisher()
+
+			ctx := context.Background()
+			logger := logp.NewLogger("test")
+
+			fakeServer := networkfake.VirtualNetworksServer{
+				NewListAllPager: func(options *armnetwork.VirtualNetworksClientListAllOptions) (resp azfake.PagerResponder[armnetwork.VirtualNetworksClientListAllResponse]) {
+					page := armnetwork.VirtualNetworksClientListAllResponse{
+						VirtualNetworkListResult: armnetwork.VirtualNetworkListResult{
+							Value: []*armnetwork.VirtualNetwork{&vnet1},
+						},
+					}
+					resp.AddPage(http.StatusOK, page, nil)
+					return
+				},
+			}
+			client, err := armnetwork.NewVirtualNetworksClient("subscriptionID", &azfake.TokenCredential{}, &arm.ClientOptions{
+				ClientOptions: azcore.ClientOptions{
+					Transport: networkfake.NewVirtualNetworksServerTransport(&fakeServer),
+				},
+			})
+			assert.NoError(t, err)
+
+			err = collectAzureVnetAssets(ctx, client, tt.subscriptionId, tt.regions, tt.assetTypes, logger, publisher)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedEvents, publisher.Events)
+		})
+	}
+}
+
+func TestAssetsAzure_getAllAzureNetworkInterfaces(t *testing.T) {
+	fakeServer := networkfake.InterfacesServer{
+		NewListAllPager: func(options *armnetwork.InterfacesClientListAllOptions) (resp azfake.PagerResponder[armnetwork.InterfacesClientListAllResponse]) {
+			page := armnetwork.InterfacesClientListAllResponse{
+				InterfaceListResult: armnetwork.InterfaceListResult{
+					Value: []*armnetwork.Interface{&nic1},
+				},
+			}
+			resp.AddPage(http.StatusOK, page, nil)
+			return
+		},
+	}
+	client, err := armnetwork.NewInterfacesClient("subscriptionID", &azfake.TokenCredential{}, &arm.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Transport: networkfake.NewInterfacesServerTransport(&fakeServer),
+		},
+	})
+	assert.NoError(t, err)
+
+	interfaces, err := getAllAzureNetworkInterfaces(context.Background(), client, subscriptionId, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []AzureNetworkAsset{
+		{
+			ID:             nicID,
+			Name:           "nic1",
+			SubscriptionID: subscriptionId,
+			Region:         "westeurope",
+			Parents:        []string{resourceGroup1EAN, "network:" + strings.ToLower(subnetID)},
+			Metadata: mapstr.M{
+				"resource_group":       "TESTVM",
+				"private_ip_addresses": []string{"10.0.0.4"},
+				"mac_address":          "00-0D-3A-2B-4C-5D",
+				"virtual_machine":      instanceid1,
+				"state":                "Succeeded",
+			},
+		},
+	}, interfaces)
+
+	assert.Equal(t, map[string][]string{
+		strings.ToLower(nicID): {"network:" + strings.ToLower(subnetID)},
+	}, getSubnetsByNetworkInterface(interfaces))
+}
+
+func TestAssetsAzure_getPrivateEndpointVnets(t *testing.T) {
+	fakeServer := networkfake.PrivateEndpointsServer{
+		NewListBySubscriptionPager: func(options *armnetwork.PrivateEndpointsClientListBySubscriptionOptions) (resp azfake.PagerResponder[armnetwork.PrivateEndpointsClientListBySubscriptionResponse]) {
+			page := armnetwork.PrivateEndpointsClientListBySubscriptionResponse{
+				PrivateEndpointListResult: armnetwork.PrivateEndpointListResult{
+					Value: []*armnetwork.PrivateEndpoint{
+						{
+							ID: to.Ptr("endpoint1"),
+							Properties: &armnetwork.PrivateEndpointProperties{
+								Subnet: &armnetwork.Subnet{ID: to.Ptr(subnetID)},
+								PrivateLinkServiceConnections: []*armnetwork.PrivateLinkServiceConnection{
+									{Properties: &armnetwork.PrivateLinkServiceConnectionProperties{PrivateLinkServiceID: to.Ptr(storageAccountID)}},
+								},
+								ManualPrivateLinkServiceConnections: []*armnetwork.PrivateLinkServiceConnection{
+									{Properties: &armnetwork.PrivateLinkServiceConnectionProperties{PrivateLinkServiceID: to.Ptr(sqlServerID)}},
+								},
+							},
+						},
+						{
+							ID:         to.Ptr("endpoint2"),
+							Properties: &armnetwork.PrivateEndpointProperties{},
+						},
+					},
+				},
+			}
+			resp.AddPage(http.StatusOK, page, nil)
+			return
+		},
+	}
+	client, err := armnetwork.NewPrivateEndpointsClient("subscriptionID", &azfake.TokenCredential{}, &arm.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Transport: networkfake.NewPrivateEndpointsServerTransport(&fakeServer),
+		},
+	})
+	assert.NoError(t, err)
+
+	vnets, err := getPrivateEndpointVnets(context.Background(), client)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		strings.ToLower(storageAccountID): {"network:" + strings.ToLower(vnetID)},
+		strings.ToLower(sqlServerID):      {"network:" + strings.ToLower(vnetID)},
+	}, vnets)
+}
@@ -0,0 +1,573 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/subscription/armsubscription"
+	"github.com/elastic/assetbeat/input/internal"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const (
+	resourceGraphVMQuery = `Resources
+| where type =~ 'microsoft.compute/virtualmachines'
+| extend powerState = tostring(properties.extended.instanceView.powerState.displayStatus)
+| project id, name, location, subscriptionId, tags, properties, powerState`
+
+	resourceGraphScaleSetVMQuery = `ComputeResources
+| where type =~ 'microsoft.compute/virtualmachinescalesets/virtualmachines'
+| extend powerState = tostring(properties.extended.instanceView.powerState.displayStatus)
+| project id, name, location, subscriptionId, tags, properties, powerState`
+
+	resourceGraphScaleSetQuery = `Resources
+| where type =~ 'microsoft.compute/virtualmachinescalesets'
+| project id, name, location, subscriptionId, tags, sku, properties`
+
+	resourceGraphVnetQuery = `Resources
+| where type =~ 'microsoft.network/virtualnetworks'
+| project id, name, location, subscriptionId, tags, properties`
+
+	resourceGraphNetworkInterfaceQuery = `Resources
+| where type =~ 'microsoft.network/networkinterfaces'
+| project id, name, location, subscriptionId, tags, properties`
+
+	resourceGraphAKSQuery = `Resources
+| where type =~ 'microsoft.containerservice/managedclusters'
+| project id, name, location, subscriptionId, tags, properties`
+
+	resourceGraphDiskQuery = `Resources
+| where type =~ 'microsoft.compute/disks'
+| project id, name, location, subscriptionId, tags, sku, managedBy, properties`
+
+	resourceGraphStorageAccountQuery = `Resources
+| where type =~ 'microsoft.storage/storageaccounts'
+| project id, name, location, subscriptionId, tags, sku, kind, properties`
+
+	resourceGraphSQLDatabaseQuery = `Resources
+| where type =~ 'microsoft.sql/servers/databases' and name !~ 'master'
+| project id, name, location, subscriptionId, tags, sku, properties`
+
+	resourceGraphPrivateEndpointQuery = `Resources
+| where type =~ 'microsoft.network/privateendpoints'
+| project id, name, location, subscriptionId, properties`
+
+	resourceGraphSubscriptionQuery = `ResourceContainers
+| where type =~ 'microsoft.resources/subscriptions'
+| project id, name, subscriptionId, tags, properties`
+
+	resourceGraphResourceGroupQuery = `ResourceContainers
+| where type =~ 'microsoft.resources/subscriptions/resourcegroups'
+| project id, name, location, subscriptionId, tags, managedBy, properties`
+)
+
+// resourceGraphResource is a row returned by the Azure Resource Graph queries.
+type resourceGraphResource struct {
+	ID             string             `json:"id"`
+	Name           string             `json:"name"`
+	Location       string             `json:"location"`
+	SubscriptionID string             `json:"subscriptionId"`
+	Tags           map[string]*string `json:"tags"`
+	ManagedBy      *string            `json:"managedBy"`
+	Kind           *string            `json:"kind"`
+	SKU            json.RawMessage    `json:"sku"`
+	Properties     json.RawMessage    `json:"properties"`
+	PowerState     string             `json:"powerState"`
+}
+
+// collectAzureResourceGraphAssets collects the assets of all the given subscriptions with Azure Resource Graph queries,
+// or of all the subscriptions the credentials have access to if none is given.
+// Each query returns the resources of a type across subscriptions, which are then mapped onto the same assets
+// as the ones collected through the Azure Resource Manager APIs.
+func collectAzureResourceGraphAssets(ctx context.Context, client *armresourcegraph.Client, subscriptions []string, cfg config, log *logp.Logger, publisher stateless.Publisher) error {
+	var errs []error
+
+	if internal.IsTypeEnabled(cfg.AssetTypes, "azure.subscription") {
+		subs, err := getAzureResourceGraphSubscriptions(ctx, client, subscriptions)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		log.Debug("Publishing Azure subscriptions")
+		publishAzureResourceContainers(publisher, "azure.subscription", "account", subs)
+	}
+
+	if internal.IsTypeEnabled(cfg.AssetTypes, "azure.resource_group") {
+		groups, err := getAzureResourceGraphResourceGroups(ctx, client, subscriptions)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		log.Debug("Publishing Azure resource groups")
+		publishAzureResourceContainers(publisher, "azure.resource_group", "resource_group", groups)
+	}
+
+	if internal.IsAnyTypeEnabled(cfg.AssetTypes, "azure.vnet", "azure.subnet") {
+		vnets, subnets, err := getAzureResourceGraphVnets(ctx, client, subscriptions, cfg.Regions)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if internal.IsTypeEnabled(cfg.AssetTypes, "azure.vnet") {
+			log.Debug("Publishing Azure virtual networks")
+			publishAzureNetworkAssets(publisher, "azure.vnet", vnets)
+		}
+		if internal.IsTypeEnabled(cfg.AssetTypes, "azure.subnet") {
+			log.Debug("Publishing Azure subnets")
+			publishAzureNetworkAssets(publisher, "azure.subnet", subnets)
+		}
+	}
+
+	var interfaces []AzureNetworkAsset
+	if internal.IsAnyTypeEnabled(cfg.AssetTypes, "azure.vm.instance", "azure.network_interface") {
+		var err error
+		interfaces, err = getAzureResourceGraphNetworkInterfaces(ctx, client, subscriptions, cfg.Regions)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if internal.IsTypeEnabled(cfg.AssetTypes, "azure.network_interface") {
+			collectAzureNetworkInterfaceAssets(interfaces, log, publisher)
+		}
+	}
+
+	// scale set instances are also needed to link scale sets and AKS clusters to their instances
+	var scaleSetInstances []resourceGraphResource
+	if internal.IsAnyTypeEnabled(cfg.AssetTypes, "azure.vm.instance", "azure.vmss", "k8s.cluster") {
+		var err error
+		scaleSetInstances, err = queryResourceGraph(ctx, client, subscriptions, resourceGraphScaleSetVMQuery)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if internal.IsTypeEnabled(cfg.AssetTypes, "azure.vmss") {
+		scaleSets, err := getAzureResourceGraphScaleSets(ctx, client, subscriptions, cfg.Regions, scaleSetInstances)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		log.Debug("Publishing Azure VM scale sets")
+		publishAzureVMScaleSets(publisher, scaleSets)
+	}
+
+	// VMs are also needed to link disks to the VMs they are attached to
+	var instances []AzureVMInstance
+	if internal.IsAnyTypeEnabled(cfg.AssetTypes, "azure.vm.instance", "azure.disk") {
+		var err error
+		instances, err = getAzureResourceGraphVMInstances(ctx, client, subscriptions, cfg.Regions, getSubnetsByNetworkInterface(interfaces))
+		if err != nil {
+			errs = append(errs, err)
+		}
+		instances = append(instances, getAzureResourceGraphScaleSetVMInstances(scaleSetInstances, cfg.Regions)...)
+	}
+	if internal.IsTypeEnabled(cfg.AssetTypes, "azure.vm.instance") {
+		log.Debug("Publishing Azure VM instances")
+		publishAzureVMInstances(publisher, instances)
+	}
+
+	if internal.IsTypeEnabled(cfg.AssetTypes, "azure.disk") {
+		disks, err := getAzureResourceGraphDisks(ctx, client, subscriptions, cfg.Regions, getVMsByResourceId(instances))
+		if err != nil {
+			errs = append(errs, err)
+		}
+		log.Debug("Publishing Azure managed disks")
+		publishAzureStorageAssets(publisher, "azure.disk", "disk", disks)
+	}
+
+	if internal.IsAnyTypeEnabled(cfg.AssetTypes, "azure.storage_account", "azure.sql.database") {
+		endpointVnets, err := getAzureResourceGraphPrivateEndpointVnets(ctx, client, subscriptions)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if internal.IsTypeEnabled(cfg.AssetTypes, "azure.storage_account") {
+			accounts, err := getAzureResourceGraphStorageAccounts(ctx, client, subscriptions, cfg.Regions, endpointVnets)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			log.Debug("Publishing Azure storage accounts")
+			publishAzureStorageAssets(publisher, "azure.storage_account", "storage", accounts)
+		}
+		if internal.IsTypeEnabled(cfg.AssetTypes, "azure.sql.database") {
+			databases, err := getAzureResourceGraphSQLDatabases(ctx, client, subscriptions, cfg.Regions, endpointVnets)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			log.Debug("Publishing Azure SQL databases")
+			publishAzureStorageAssets(publisher, "azure.sql.database", "database", databases)
+		}
+	}
+
+	if internal.IsAnyTypeEnabled(cfg.AssetTypes, "k8s.cluster", "azure.aks.nodepool") {
+		clusters, err := getAzureResourceGraphAKSClusters(ctx, client, subscriptions, cfg.Regions, scaleSetInstances)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		publishAzureAKSClusters(publisher, clusters, cfg.AssetTypes, log)
+	}
+
+	return errors.Join(errs...)
+}
+
+func getAzureResourceGraphSubscriptions(ctx context.Context, client *armresourcegraph.Client, subscriptions []string) ([]AzureResourceContainer, error) {
+	resources, err := queryResourceGraph(ctx, client, subscriptions, resourceGraphSubscriptionQuery)
+	if err != nil {
+		return nil, err
+	}
+	var containers []AzureResourceContainer
+	for _, r := range resources {
+		var properties struct {
+			State *armsubscription.SubscriptionState `json:"state"`
+		}
+		if err := json.Unmarshal(r.Properties, &properties); err != nil {
+			return nil, fmt.Errorf("failed to read properties of subscription %s: %v", r.ID, err)
+		}
+		s := &armsubscription.Subscription{DisplayName: &r.Name, State: properties.State}
+		container := newAzureSubscriptionAsset(s, r.SubscriptionID)
+		container.Tags = r.Tags
+		containers = append(containers, container)
+	}
+	return containers, nil
+}
+
+// getAzureResourceGraphResourceGroups returns the resource groups. As with the API source, they are not filtered by region.
+func getAzureResourceGraphResourceGroups(ctx context.Context, client *armresourcegraph.Client, subscriptions []string) ([]AzureResourceContainer, error) {
+	resources, err := queryResourceGraph(ctx, client, subscriptions, resourceGraphResourceGroupQuery)
+	if err != nil {
+		return nil, err
+	}
+	var containers []AzureResourceContainer
+	for _, r := range resources {
+		var properties armresources.ResourceGroupProperties
+		if err := json.Unmarshal(r.Properties, &properties); err != nil {
+			return nil, fmt.Errorf("failed to read properties of resource group %s: %v", r.ID, err)
+		}
+		g := &armresources.ResourceGroup{ID: &r.ID, Name: &r.Name, Location: &r.Location, Tags: r.Tags, ManagedBy: r.ManagedBy, Properties: &properties}
+		containers = append(containers, newAzureResourceGroupAsset(g, r.SubscriptionID))
+	}
+	return containers, nil
+}
+
+func getAzureResourceGraphVMInstances(ctx context.Context, client *armresourcegraph.Client, subscriptions []string, regions []string, nicSubnets map[string][]string) ([]AzureVMInstance, error) {
+	resources, err := queryResourceGraph(ctx, client, subscriptions, resourceGraphVMQuery)
+	if err != nil {
+		return nil, err
+	}
+	var instances []AzureVMInstance
+	for _, r := range resources {
+		if !isRegionWanted(r.Location, regions) {
+			continue
+		}
+		var properties armcompute.VirtualMachineProperties
+		if err := json.Unmarshal(r.Properties, &properties); err != nil {
+			return nil, fmt.Errorf("failed to read properties of VM %s: %v", r.ID, err)
+		}
+		v := &armcompute.VirtualMachine{ID: &r.ID, Name: &r.Name, Location: &r.Location, Tags: r.Tags, Properties: &properties}
+		instances = append(instances, newAzureVMInstance(v, r.SubscriptionID, r.PowerState, nicSubnets))
+	}
+	return instances, nil
+}
+
+func getAzureResourceGraphScaleSetVMInstances(resources []resourceGraphResource, regions []string) []AzureVMInstance {
+	var instances []AzureVMInstance
+	for _, r := range resources {
+		if !isRegionWanted(r.Location, regions) {
+			continue
+		}
+		var properties armcompute.VirtualMachineScaleSetVMProperties
+		if err := json.Unmarshal(r.Properties, &properties); err != nil || properties.VMID == nil {
+			continue
+		}
+		v := &armcompute.VirtualMachineScaleSetVM{ID: &r.ID, Name: &r.Name, Location: &r.Location, Tags: r.Tags, Properties: &properties}
+		instances = append(instances, newAzureScaleSetVMInstance(v, r.SubscriptionID, getScaleSetIdFromVMId(r.ID), getResourceGroupFromId(r.ID), r.PowerState))
+	}
+	return instances
+}
+
+// getAzureResourceGraphScaleSets returns the VM scale sets, linked to their instances.
+func getAzureResourceGraphScaleSets(ctx context.Context, client *armresourcegraph.Client, subscriptions []string, regions []string, scaleSetInstances []resourceGraphResource) ([]vmScaleSet, error) {
+	resources, err := queryResourceGraph(ctx, client, subscriptions, resourceGraphScaleSetQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	instancesByScaleSet := groupScaleSetInstances(scaleSetInstances)
+
+	var scaleSets []vmScaleSet
+	for _, r := range resources {
+		if !isRegionWanted(r.Location, regions) {
+			continue
+		}
+		v := &armcompute.VirtualMachineScaleSet{ID: &r.ID, Name: &r.Name, Location: &r.Location, Tags: r.Tags}
+		if err := unmarshalResourceGraphFields(r, &v.SKU, &v.Properties); err != nil {
+			return nil, fmt.Errorf("failed to read VM scale set %s: %v", r.ID, err)
+		}
+		scaleSet := newAzureVMScaleSet(v, r.SubscriptionID)
+		scaleSet.Children = instancesByScaleSet[strings.ToLower(r.ID)]
+		scaleSets = append(scaleSets, scaleSet)
+	}
+	return scaleSets, nil
+}
+
+// getScaleSetIdFromVMId returns the ID of the scale set a VM scale set instance belongs to, e.g.
+// /subscriptions/{sub}/resourceGroups/{rg}/providers/Microsoft.Compute/virtualMachineScaleSets/{vmss}.
+func getScaleSetIdFromVMId(vmId string) string {
+	scaleSetId, _, found := strings.Cut(vmId, "/virtualMachines/")
+	if !found {
+		return ""
+	}
+	return scaleSetId
+}
+
+func getAzureResourceGraphVnets(ctx context.Context, client *armresourcegraph.Client, subscriptions []string, regions []string) ([]AzureNetworkAsset, []AzureNetworkAsset, error) {
+	resources, err := queryResourceGraph(ctx, client, subscriptions, resourceGraphVnetQuery)
+	if err != nil {
+		return nil, nil, err
+	}
+	var vnets []AzureNetworkAsset
+	var subnets []AzureNetworkAsset
+	for _, r := range resources {
+		if !isRegionWanted(r.Location, regions) {
+			continue
+		}
+		var properties armnetwork.VirtualNetworkPropertiesFormat
+		if err := json.Unmarshal(r.Properties, &properties); err != nil {
+			return nil, nil, fmt.Errorf("failed to read properties of virtual network %s: %v", r.ID, err)
+		}
+		v := &armnetwork.VirtualNetwork{ID: &r.ID, Name: &r.Name, Location: &r.Location, Tags: r.Tags, Properties: &properties}
+		vnet, vnetSubnets := newAzureVnetAssets(v, r.SubscriptionID)
+		vnets = append(vnets, vnet)
+		subnets = append(subnets, vnetSubnets...)
+	}
+	return vnets, subnets, nil
+}
+
+func getAzureResourceGraphNetworkInterfaces(ctx context.Context, client *armresourcegraph.Client, subscriptions []string, regions []string) ([]AzureNetworkAsset, error) {
+	resources, err := queryResourceGraph(ctx, client, subscriptions, resourceGraphNetworkInterfaceQuery)
+	if err != nil {
+		return nil, err
+	}
+	var interfaces []AzureNetworkAsset
+	for _, r := range resources {
+		if !isRegionWanted(r.Location, regions) {
+			continue
+		}
+		var properties armnetwork.InterfacePropertiesFormat
+		if err := json.Unmarshal(r.Properties, &properties); err != nil {
+			return nil, fmt.Errorf("failed to read properties of network interface %s: %v", r.ID, err)
+		}
+		v := &armnetwork.Interface{ID: &r.ID, Name: &r.Name, Location: &r.Location, Tags: r.Tags, Properties: &properties}
+		interfaces = append(interfaces, newAzureNetworkInterfaceAsset(v, r.SubscriptionID))
+	}
+	return interfaces, nil
+}
+
+// getAzureResourceGraphAKSClusters returns the AKS clusters, with their node pools linked to the scale sets of their node resource group,
+// and the clusters linked to the instances of these scale sets.
+func getAzureResourceGraphAKSClusters(ctx context.Context, client *armresourcegraph.Client, subscriptions []string, regions []string, scaleSetInstances []resourceGraphResource) ([]AKSCluster, error) {
+	resources, err := queryResourceGraph(ctx, client, subscriptions, resourceGraphAKSQuery)
+	if err != nil {
+		return nil, err
+	}
+	scaleSetResources, err := queryResourceGraph(ctx, client, subscriptions, resourceGraphScaleSetQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	scaleSetsByResourceGroup := make(map[string][]*armcompute.VirtualMachineScaleSet)
+	for _, r := range scaleSetResources {
+		resourceGroup := strings.ToLower(getResourceGroupFromId(r.ID))
+		scaleSetsByResourceGroup[resourceGroup] = append(scaleSetsByResourceGroup[resourceGroup], &armcompute.VirtualMachineScaleSet{ID: to.Ptr(r.ID), Tags: r.Tags})
+	}
+
+	instancesByScaleSet := groupScaleSetInstances(scaleSetInstances)
+
+	var clusters []AKSCluster
+	for _, r := range resources {
+		if !isRegionWanted(r.Location, regions) {
+			continue
+		}
+		var properties armcontainerservice.ManagedClusterProperties
+		if err := json.Unmarshal(r.Properties, &properties); err != nil {
+			return nil, fmt.Errorf("failed to read properties of AKS cluster %s: %v", r.ID, err)
+		}
+		v := &armcontainerservice.ManagedCluster{ID: to.Ptr(r.ID), Name: to.Ptr(r.Name), Location: to.Ptr(r.Location), Tags: r.Tags, Properties: &properties}
+		var scaleSets []*armcompute.VirtualMachineScaleSet
+		if properties.NodeResourceGroup != nil {
+			scaleSets = scaleSetsByResourceGroup[strings.ToLower(*properties.NodeResourceGroup)]
+		}
+		var hosts []string
+		for _, ss := range scaleSets {
+			hosts = append(hosts, instancesByScaleSet[strings.ToLower(*ss.ID)]...)
+		}
+		clusters = append(clusters, newAKSCluster(v, r.SubscriptionID, scaleSets, hosts))
+	}
+	return clusters, nil
+}
+
+func getAzureResourceGraphDisks(ctx context.Context, client *armresourcegraph.Client, subscriptions []string, regions []string, vmsByResourceId map[string]string) ([]AzureStorageAsset, error) {
+	resources, err := queryResourceGraph(ctx, client, subscriptions, resourceGraphDiskQuery)
+	if err != nil {
+		return nil, err
+	}
+	var disks []AzureStorageAsset
+	for _, r := range resources {
+		if !isRegionWanted(r.Location, regions) {
+			continue
+		}
+		v := &armcompute.Disk{ID: &r.ID, Name: &r.Name, Location: &r.Location, Tags: r.Tags, ManagedBy: r.ManagedBy}
+		if err := unmarshalResourceGraphFields(r, &v.SKU, &v.Properties); err != nil {
+			return nil, fmt.Errorf("failed to read disk %s: %v", r.ID, err)
+		}
+		disks = append(disks, newAzureDiskAsset(v, r.SubscriptionID, vmsByResourceId))
+	}
+	return disks, nil
+}
+
+func getAzureResourceGraphStorageAccounts(ctx context.Context, client *armresourcegraph.Client, subscriptions []string, regions []string, endpointVnets map[string][]string) ([]AzureStorageAsset, error) {
+	resources, err := queryResourceGraph(ctx, client, subscriptions, resourceGraphStorageAccountQuery)
+	if err != nil {
+		return nil, err
+	}
+	var accounts []AzureStorageAsset
+	for _, r := range resources {
+		if !isRegionWanted(r.Location, regions) {
+			continue
+		}
+		v := &armstorage.Account{ID: &r.ID, Name: &r.Name, Location: &r.Location, Tags: r.Tags}
+		if r.Kind != nil {
+			v.Kind = to.Ptr(armstorage.Kind(*r.Kind))
+		}
+		if err := unmarshalResourceGraphFields(r, &v.SKU, &v.Properties); err != nil {
+			return nil, fmt.Errorf("failed to read storage account %s: %v", r.ID, err)
+		}
+		accounts = append(accounts, newAzureStorageAccountAsset(v, r.SubscriptionID, endpointVnets))
+	}
+	return accounts, nil
+}
+
+// getAzureResourceGraphSQLDatabases returns the SQL databases. Their transparent data encryption is not tracked
+// by Azure Resource Graph, so their encryption is not collected.
+func getAzureResourceGraphSQLDatabases(ctx context.Context, client *armresourcegraph.Client, subscriptions []string, regions []string, endpointVnets map[string][]string) ([]AzureStorageAsset, error) {
+	resources, err := queryResourceGraph(ctx, client, subscriptions, resourceGraphSQLDatabaseQuery)
+	if err != nil {
+		return nil, err
+	}
+	var databases []AzureStorageAsset
+	for _, r := range resources {
+		if !isRegionWanted(r.Location, regions) {
+			continue
+		}
+		var sku *armresources.SKU
+		var properties sqlDatabaseProperties
+		if err := unmarshalResourceGraphFields(r, &sku, &properties); err != nil {
+			return nil, fmt.Errorf("failed to read SQL database %s: %v", r.ID, err)
+		}
+		databases = append(databases, newAzureSQLDatabaseAsset(r.ID, r.Location, r.SubscriptionID, r.Tags, sku, properties, "", endpointVnets))
+	}
+	return databases, nil
+}
+
+func getAzureResourceGraphPrivateEndpointVnets(ctx context.Context, client *armresourcegraph.Client, subscriptions []string) (map[string][]string, error) {
+	resources, err := queryResourceGraph(ctx, client, subscriptions, resourceGraphPrivateEndpointQuery)
+	if err != nil {
+		return nil, err
+	}
+	vnets := make(map[string][]string)
+	for _, r := range resources {
+		var properties armnetwork.PrivateEndpointProperties
+		if err := json.Unmarshal(r.Properties, &properties); err != nil {
+			return nil, fmt.Errorf("failed to read properties of private endpoint %s: %v", r.ID, err)
+		}
+		addPrivateEndpointVnets(vnets, &armnetwork.PrivateEndpoint{ID: &r.ID, Properties: &properties})
+	}
+	return vnets, nil
+}
+
+// unmarshalResourceGraphFields decodes the SKU and the properties of a resource, when they are returned.
+func unmarshalResourceGraphFields(r resourceGraphResource, sku any, properties any) error {
+	if len(r.SKU) > 0 {
+		if err := json.Unmarshal(r.SKU, sku); err != nil {
+			return err
+		}
+	}
+	if len(r.Properties) > 0 {
+		if err := json.Unmarshal(r.Properties, properties); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupScaleSetInstances returns the EANs of scale set instances, grouped by the lower cased ID
+// of their scale set, as Azure resource IDs are case-insensitive.
+func groupScaleSetInstances(resources []resourceGraphResource) map[string][]string {
+	groups := make(map[string][]string)
+	for _, r := range resources {
+		var properties armcompute.VirtualMachineScaleSetVMProperties
+		if err := json.Unmarshal(r.Properties, &properties); err != nil || properties.VMID == nil {
+			continue
+		}
+		k := strings.ToLower(getScaleSetIdFromVMId(r.ID))
+		groups[k] = append(groups[k], "host:"+*properties.VMID)
+	}
+	return groups
+}
+
+// queryResourceGraph runs a query against Azure Resource Graph, following the skip tokens of paginated results.
+func queryResourceGraph(ctx context.Context, client *armresourcegraph.Client, subscriptions []string, query string) ([]resourceGraphResource, error) {
+	request := armresourcegraph.QueryRequest{
+		Query: to.Ptr(query),
+		Options: &armresourcegraph.QueryRequestOptions{
+			ResultFormat: to.Ptr(armresourcegraph.ResultFormatObjectArray),
+		},
+	}
+	for _, s := range subscriptions {
+		request.Subscriptions = append(request.Subscriptions, to.Ptr(s))
+	}
+
+	var resources []resourceGraphResource
+	for {
+		res, err := client.Resources(ctx, request, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query Azure Resource Graph: %v", err)
+		}
+		data, err := json.Marshal(res.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Azure Resource Graph results: %v", err)
+		}
+		var page []resourceGraphResource
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, fmt.Errorf("failed to read Azure Resource Graph results: %v", err)
+		}
+		resources = append(resources, page...)
+
+		if res.SkipToken == nil || *res.SkipToken == "" {
+			return resources, nil
+		}
+		request.Options.SkipToken = res.SkipToken
+	}
+}
@@ -0,0 +1,311 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
+	resourcegraphfake "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph/fake"
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/assetbeat/input/testutil"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/stretchr/testify/assert"
+)
+
+// scaleSetVMResourceID is the instance of the VM scale set of the AKS node pool
+var scaleSetVMResourceID = aksScaleSetID + "/virtualMachines/0"
+
+// resourceGraphResults holds the results of the fake Resource Graph queries, in pages.
+var resourceGraphResults = map[string][][]any{
+	resourceGraphVMQuery: {
+		{
+			map[string]any{
+				"id":             instanceid1,
+				"name":           instance1Name,
+				"location":       "westeurope",
+				"subscriptionId": subscriptionId,
+				"properties":     map[string]any{"vmId": instanceVMId1},
+				"powerState":     "VM running",
+			},
+		},
+		{
+			map[string]any{
+				"id":             instanceid3,
+				"name":           instance3Name,
+				"location":       "eastus",
+				"subscriptionId": subscriptionId,
+				"properties":     map[string]any{"vmId": instanceVMId3},
+				"powerState":     "VM deallocated",
+			},
+		},
+	},
+	resourceGraphScaleSetVMQuery: {
+		{
+			map[string]any{
+				"id":             scaleSetVMResourceID,
+				"name":           ssVm1Name,
+				"location":       "westeurope",
+				"subscriptionId": subscriptionId,
+				"properties":     map[string]any{"vmId": instanceVMId2},
+				"powerState":     "VM running",
+			},
+		},
+	},
+	resourceGraphScaleSetQuery: {
+		{
+			map[string]any{
+				"id":             aksScaleSetID,
+				"name":           "aks-nodepool1-vmss",
+				"location":       "westeurope",
+				"subscriptionId": subscriptionId,
+				"tags":           map[string]any{aksNodePoolTag: "nodepool1"},
+			},
+		},
+	},
+	resourceGraphDiskQuery: {
+		{
+			map[string]any{
+				"id":             diskID,
+				"name":           "disk1",
+				"location":       "westeurope",
+				"subscriptionId": subscriptionId,
+				"sku":            map[string]any{"name": "Premium_LRS"},
+				"managedBy":      instanceid1,
+				"properties":     map[string]any{"diskSizeGB": 30, "diskState": "Attached"},
+			},
+		},
+	},
+	resourceGraphSubscriptionQuery: {
+		{
+			map[string]any{
+				"id":             "/subscriptions/" + subscriptionId,
+				"name":           "my subscription",
+				"subscriptionId": subscriptionId,
+				"properties":     map[string]any{"state": "Enabled"},
+			},
+		},
+	},
+	resourceGraphResourceGroupQuery: {
+		{
+			map[string]any{
+				"id":             fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", subscriptionId, resourceGroup1),
+				"name":           resourceGroup1,
+				"location":       "westeurope",
+				"subscriptionId": subscriptionId,
+				"tags":           map[string]any{"env": "prod"},
+				"properties":     map[string]any{"provisioningState": "Succeeded"},
+			},
+		},
+	},
+	resourceGraphAKSQuery: {
+		{
+			map[string]any{
+				"id":             aksCluster1ID,
+				"name":           aksCluster1Name,
+				"location":       "westeurope",
+				"subscriptionId": subscriptionId,
+				"properties":     aksCluster1Properties,
+			},
+		},
+	},
+}
+
+func TestAssetsAzure_collectAzureResourceGraphAssets(t *testing.T) {
+	vmEvent := beat.Event{
+		Fields: mapstr.M{
+			"asset.ean":                     "host:" + instanceVMId1,
+			"asset.id":                      instanceVMId1,
+			"asset.name":                    instance1Name,
+			"asset.type":                    "azure.vm.instance",
+			"asset.kind":                    "host",
+			"asset.parents":                 []string{resourceGroup1EAN},
+			"asset.metadata.state":          "VM running",
+			"asset.metadata.resource_group": "TESTVM",
+			"cloud.account.id":              subscriptionId,
+			"cloud.provider":                "azure",
+			"cloud.region":                  "westeurope",
+		},
+		Meta: mapstr.M{
+			"index": internal.GetDefaultIndexName(),
+		},
+	}
+	scaleSetVMEvent := beat.Event{
+		Fields: mapstr.M{
+			"asset.ean":                     "host:" + instanceVMId2,
+			"asset.id":                      instanceVMId2,
+			"asset.name":                    ssVm1Name,
+			"asset.type":                    "azure.vm.instance",
+			"asset.kind":                    "host",
+			"asset.parents":                 []string{"instance_group:" + getScaleSetIdFromVMId(scaleSetVMResourceID)},
+			"asset.metadata.state":          "VM running",
+			"asset.metadata.resource_group": aksNodeResourceGroup,
+			"cloud.account.id":              subscriptionId,
+			"cloud.provider":                "azure",
+			"cloud.region":                  "westeurope",
+		},
+		Meta: mapstr.M{
+			"index": internal.GetDefaultIndexName(),
+		},
+	}
+	subscriptionEvent := beat.Event{
+		Fields: mapstr.M{
+			"asset.ean":            "account:/subscriptions/" + subscriptionId,
+			"asset.id":             "/subscriptions/" + subscriptionId,
+			"asset.name":           "my subscription",
+			"asset.type":           "azure.subscription",
+			"asset.kind":           "account",
+			"asset.metadata.state": "Enabled",
+			"cloud.account.id":     subscriptionId,
+			"cloud.provider":       "azure",
+		},
+		Meta: mapstr.M{
+			"index": internal.GetDefaultIndexName(),
+		},
+	}
+	resourceGroupEvent := beat.Event{
+		Fields: mapstr.M{
+			"asset.ean":               resourceGroup1EAN,
+			"asset.id":                strings.TrimPrefix(resourceGroup1EAN, "resource_group:"),
+			"asset.name":              resourceGroup1,
+			"asset.type":              "azure.resource_group",
+			"asset.kind":              "resource_group",
+			"asset.parents":           []string{"account:/subscriptions/" + subscriptionId},
+			"asset.metadata.state":    "Succeeded",
+			"asset.metadata.tags.env": "prod",
+			"cloud.account.id":        subscriptionId,
+			"cloud.provider":          "azure",
+			"cloud.region":            "westeurope",
+		},
+		Meta: mapstr.M{
+			"index": internal.GetDefaultIndexName(),
+		},
+	}
+
+	diskEvent := beat.Event{
+		Fields: mapstr.M{
+			"asset.ean":                     "disk:" + diskID,
+			"asset.id":                      diskID,
+			"asset.name":                    "disk1",
+			"asset.type":                    "azure.disk",
+			"asset.kind":                    "disk",
+			"asset.parents":                 []string{resourceGroup1EAN, "host:" + instanceVMId1},
+			"asset.metadata.resource_group": "TESTVM",
+			"asset.metadata.sku":            "Premium_LRS",
+			"asset.metadata.size_gb":        int32(30),
+			"asset.metadata.state":          "Attached",
+			"cloud.account.id":              subscriptionId,
+			"cloud.provider":                "azure",
+			"cloud.region":                  "westeurope",
+		},
+		Meta: mapstr.M{
+			"index": internal.GetDefaultIndexName(),
+		},
+	}
+
+	for _, tt := range []struct {
+		name           string
+		regions        []string
+		assetTypes     []string
+		expectedEvents []beat.Event
+	}{
+		{
+			name:           "Test with VM instances and AKS clusters in one region",
+			regions:        []string{"westeurope"},
+			assetTypes:     []string{"azure.vm.instance", "k8s.cluster"},
+			expectedEvents: []beat.Event{vmEvent, scaleSetVMEvent, aksCluster1Event},
+		},
+		{
+			name:           "Test with only AKS clusters",
+			regions:        []string{"westeurope"},
+			assetTypes:     []string{"k8s.cluster"},
+			expectedEvents: []beat.Event{aksCluster1Event},
+		},
+		{
+			name:           "Test with AKS clusters and their node pools",
+			regions:        []string{"westeurope"},
+			assetTypes:     []string{"k8s.cluster", "azure.aks.nodepool"},
+			expectedEvents: []beat.Event{aksCluster1Event, aksNodePool1Event},
+		},
+		{
+			name:           "Test with disks linked to VM instances which are not published",
+			regions:        []string{"westeurope"},
+			assetTypes:     []string{"azure.disk"},
+			expectedEvents: []beat.Event{diskEvent},
+		},
+		{
+			name:           "Test with subscriptions and resource groups in another region",
+			regions:        []string{"eastus"},
+			assetTypes:     []string{"azure.subscription", "azure.resource_group"},
+			expectedEvents: []beat.Event{subscriptionEvent, resourceGroupEvent},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := testutil.NewInMemoryPublisher()
+
+			ctx := context.Background()
+			logger := logp.NewLogger("test")
+
+			fakeServer := resourcegraphfake.Server{
+				Resources: func(ctx context.Context, query armresourcegraph.QueryRequest, options *armresourcegraph.ClientResourcesOptions) (resp azfake.Responder[armresourcegraph.ClientResourcesResponse], errResp azfake.ErrorResponder) {
+					pages := resourceGraphResults[*query.Query]
+					page := 0
+					if query.Options.SkipToken != nil {
+						fmt.Sscanf(*query.Options.SkipToken, "page%d", &page)
+					}
+					response := armresourcegraph.ClientResourcesResponse{
+						QueryResponse: armresourcegraph.QueryResponse{Data: []any{}},
+					}
+					if page < len(pages) {
+						response.Data = pages[page]
+					}
+					if page+1 < len(pages) {
+						response.SkipToken = to.Ptr(fmt.Sprintf("page%d", page+1))
+					}
+					resp.SetResponse(http.StatusOK, response, nil)
+					return
+				},
+			}
+			client, err := armresourcegraph.NewClient(&azfake.TokenCredential{}, &arm.ClientOptions{
+				ClientOptions: azcore.ClientOptions{
+					Transport: resourcegraphfake.NewServerTransport(&fakeServer),
+				},
+			})
+			assert.NoError(t, err)
+
+			cfg := defaultConfig()
+			cfg.Regions = tt.regions
+			cfg.AssetTypes = tt.assetTypes
+			cfg.Source = sourceResourceGraph
+
+			err = collectAzureResourceGraphAssets(ctx, client, nil, cfg, logger, publisher)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedEvents, publisher.Events)
+		})
+	}
+}
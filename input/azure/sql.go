@@ -0,0 +1,170 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const (
+	sqlDatabaseResourceType = "Microsoft.Sql/servers/databases"
+	sqlAPIVersion           = "2021-11-01"
+	// sqlMasterDatabase is the system database of every SQL server, which is not collected
+	sqlMasterDatabase = "master"
+)
+
+// sqlDatabaseProperties holds the properties of a SQL database collected by assetbeat.
+type sqlDatabaseProperties struct {
+	Status                      string `json:"status"`
+	MaxSizeBytes                int64  `json:"maxSizeBytes"`
+	ZoneRedundant               bool   `json:"zoneRedundant"`
+	CurrentServiceObjectiveName string `json:"currentServiceObjectiveName"`
+}
+
+// collectAzureSQLDatabaseAssets publishes the SQL databases of a subscription, linked to the virtual networks
+// their server is reachable from through private endpoints.
+func collectAzureSQLDatabaseAssets(ctx context.Context, client *armresources.Client, subscriptionId string, regions []string, endpointVnets map[string][]string, log *logp.Logger, publisher stateless.Publisher) error {
+	databases, err := getAllAzureSQLDatabases(ctx, client, subscriptionId, regions, endpointVnets, log)
+	if err != nil {
+		return err
+	}
+
+	log.Debug("Publishing Azure SQL databases")
+	publishAzureStorageAssets(publisher, "azure.sql.database", "database", databases)
+	return nil
+}
+
+// getAllAzureSQLDatabases lists the SQL databases of a subscription through the generic resources API, as there is no
+// SQL client in use. The encryption of each database is retrieved from its transparent data encryption resource.
+// Databases which cannot be retrieved are skipped.
+func getAllAzureSQLDatabases(ctx context.Context, client *armresources.Client, subscriptionId string, regions []string, endpointVnets map[string][]string, log *logp.Logger) ([]AzureStorageAsset, error) {
+	var databases []AzureStorageAsset
+	pager := client.NewListPager(&armresources.ClientListOptions{
+		Filter: to.Ptr(fmt.Sprintf("resourceType eq '%s'", sqlDatabaseResourceType)),
+	})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to advance page: %v", err)
+		}
+		for _, v := range page.Value {
+			if !isRegionWanted(*v.Location, regions) || strings.EqualFold(getResourceNameFromId(*v.ID), sqlMasterDatabase) {
+				continue
+			}
+			res, err := client.GetByID(ctx, *v.ID, sqlAPIVersion, nil)
+			if err != nil {
+				log.Warnf("Error while retrieving SQL database %s: %+v", *v.ID, err)
+				continue
+			}
+			var properties sqlDatabaseProperties
+			if err := convertGenericProperties(res.Properties, &properties); err != nil {
+				return nil, fmt.Errorf("failed to read properties of SQL database %s: %v", *v.ID, err)
+			}
+			encryption, err := getAzureSQLDatabaseEncryption(ctx, client, *v.ID)
+			if err != nil {
+				log.Warnf("Error while retrieving the encryption of SQL database %s: %+v", *v.ID, err)
+			}
+
+			databases = append(databases, newAzureSQLDatabaseAsset(*v.ID, *v.Location, subscriptionId, v.Tags, res.SKU, properties, encryption, endpointVnets))
+		}
+	}
+	return databases, nil
+}
+
+// getAzureSQLDatabaseEncryption returns the state of the transparent data encryption of a SQL database.
+func getAzureSQLDatabaseEncryption(ctx context.Context, client *armresources.Client, databaseId string) (string, error) {
+	res, err := client.GetByID(ctx, databaseId+"/transparentDataEncryption/current", sqlAPIVersion, nil)
+	if err != nil {
+		return "", err
+	}
+	var properties struct {
+		State string `json:"state"`
+	}
+	if err := convertGenericProperties(res.Properties, &properties); err != nil {
+		return "", err
+	}
+	return properties.State, nil
+}
+
+func newAzureSQLDatabaseAsset(id string, location string, subscriptionId string, tags map[string]*string, sku *armresources.SKU, properties sqlDatabaseProperties, encryption string, endpointVnets map[string][]string) AzureStorageAsset {
+	serverId := getSQLServerIdFromDatabaseId(id)
+	parents := []string{getResourceGroupEAN(id)}
+	for _, vnet := range endpointVnets[strings.ToLower(serverId)] {
+		parents = appendIfMissing(parents, vnet)
+	}
+	metadata := mapstr.M{
+		"resource_group": getResourceGroupFromId(id),
+		"server":         getResourceNameFromId(serverId),
+		"status":         properties.Status,
+		"max_size_bytes": properties.MaxSizeBytes,
+		"zone_redundant": properties.ZoneRedundant,
+	}
+	if sku != nil {
+		if sku.Name != nil {
+			metadata["sku"] = *sku.Name
+		}
+		if sku.Tier != nil {
+			metadata["tier"] = *sku.Tier
+		}
+	}
+	if encryption != "" {
+		metadata["encryption"] = encryption
+	}
+	return AzureStorageAsset{
+		ID:             id,
+		Name:           getResourceNameFromId(id),
+		SubscriptionID: subscriptionId,
+		Region:         location,
+		Parents:        parents,
+		Tags:           tags,
+		Metadata:       metadata,
+	}
+}
+
+// getSQLServerIdFromDatabaseId returns the ID of the SQL server a database belongs to, e.g.
+// /subscriptions/{sub}/resourceGroups/{rg}/providers/Microsoft.Sql/servers/{server}.
+func getSQLServerIdFromDatabaseId(databaseId string) string {
+	serverId, _, found := strings.Cut(databaseId, "/databases/")
+	if !found {
+		return ""
+	}
+	return serverId
+}
+
+// getResourceNameFromId returns the name of a resource, which is the last segment of its ID.
+func getResourceNameFromId(id string) string {
+	return id[strings.LastIndex(id, "/")+1:]
+}
+
+// convertGenericProperties decodes the properties of a resource retrieved through the generic resources API.
+func convertGenericProperties(properties any, out any) error {
+	data, err := json.Marshal(properties)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,176 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	resourcesfake "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources/fake"
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/assetbeat/input/testutil"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/stretchr/testify/assert"
+)
+
+var sqlServerID = fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Sql/servers/server1", subscriptionId, resourceGroup1)
+var sqlDatabaseID = sqlServerID + "/databases/db1"
+
+var sqlDatabaseProperties1 = map[string]any{
+	"status":                      "Online",
+	"maxSizeBytes":                34359738368,
+	"zoneRedundant":               false,
+	"currentServiceObjectiveName": "GP_Gen5_2",
+}
+
+func TestAssetsAzure_collectAzureSQLDatabaseAssets(t *testing.T) {
+	sqlDatabaseEvent := beat.Event{
+		Fields: mapstr.M{
+			"asset.ean":                     "database:" + sqlDatabaseID,
+			"asset.id":                      sqlDatabaseID,
+			"asset.name":                    "db1",
+			"asset.type":                    "azure.sql.database",
+			"asset.kind":                    "database",
+			"asset.parents":                 []string{resourceGroup1EAN, "network:" + strings.ToLower(vnetID)},
+			"asset.metadata.resource_group": "TESTVM",
+			"asset.metadata.server":         "server1",
+			"asset.metadata.status":         "Online",
+			"asset.metadata.max_size_bytes": int64(34359738368),
+			"asset.metadata.zone_redundant": false,
+			"asset.metadata.sku":            "GP_Gen5",
+			"asset.metadata.tier":           "GeneralPurpose",
+			"asset.metadata.encryption":     "Enabled",
+			"asset.metadata.tags.env":       "prod",
+			"cloud.account.id":              subscriptionId,
+			"cloud.provider":                "azure",
+			"cloud.region":                  "westeurope",
+		},
+		Meta: mapstr.M{
+			"index": internal.GetDefaultIndexName(),
+		},
+	}
+	sqlDatabaseEventWithoutEncryption := beat.Event{Fields: sqlDatabaseEvent.Fields.Clone(), Meta: sqlDatabaseEvent.Meta}
+	sqlDatabaseEventWithoutEncryption.Fields.Delete("asset.metadata.encryption")
+
+	for _, tt := range []struct {
+		name           string
+		regions        []string
+		failEncryption bool
+		failDatabase   bool
+		expectedEvents []beat.Event
+	}{
+		{
+			name:           "Test with a database and the master database",
+			expectedEvents: []beat.Event{sqlDatabaseEvent},
+		},
+		{
+			name:           "Test with the encryption of a database failing",
+			failEncryption: true,
+			expectedEvents: []beat.Event{sqlDatabaseEventWithoutEncryption},
+		},
+		{
+			name:         "Test with the retrieval of a database failing",
+			failDatabase: true,
+		},
+		{
+			name:    "Test with another region specified",
+			regions: []string{"eastus"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := testutil.NewInMemoryPublisher()
+
+			ctx := context.Background()
+			logger := logp.NewLogger("test")
+
+			fakeServer := resourcesfake.Server{
+				NewListPager: func(options *armresources.ClientListOptions) (resp azfake.PagerResponder[armresources.ClientListResponse]) {
+					page := armresources.ClientListResponse{
+						ResourceListResult: armresources.ResourceListResult{
+							Value: []*armresources.GenericResourceExpanded{
+								{
+									Location: to.Ptr("westeurope"),
+									ID:       to.Ptr(sqlDatabaseID),
+									Name:     to.Ptr("server1/db1"),
+									Tags:     map[string]*string{"env": to.Ptr("prod")},
+								},
+								{
+									Location: to.Ptr("westeurope"),
+									ID:       to.Ptr(sqlServerID + "/databases/master"),
+									Name:     to.Ptr("server1/master"),
+								},
+							},
+						},
+					}
+					resp.AddPage(http.StatusOK, page, nil)
+					return
+				},
+				GetByID: func(ctx context.Context, resourceID string, apiVersion string, options *armresources.ClientGetByIDOptions) (resp azfake.Responder[armresources.ClientGetByIDResponse], errResp azfake.ErrorResponder) {
+					if strings.HasSuffix(resourceID, "/transparentDataEncryption/current") {
+						if tt.failEncryption {
+							errResp.SetResponseError(http.StatusNotFound, "NotFound")
+							return
+						}
+						resp.SetResponse(http.StatusOK, armresources.ClientGetByIDResponse{
+							GenericResource: armresources.GenericResource{
+								ID:         to.Ptr(resourceID),
+								Properties: map[string]any{"state": "Enabled"},
+							},
+						}, nil)
+						return
+					}
+					if tt.failDatabase {
+						errResp.SetResponseError(http.StatusForbidden, "AuthorizationFailed")
+						return
+					}
+					resp.SetResponse(http.StatusOK, armresources.ClientGetByIDResponse{
+						GenericResource: armresources.GenericResource{
+							ID:         to.Ptr(resourceID),
+							SKU:        &armresources.SKU{Name: to.Ptr("GP_Gen5"), Tier: to.Ptr("GeneralPurpose")},
+							Properties: sqlDatabaseProperties1,
+						},
+					}, nil)
+					return
+				},
+			}
+			client, err := armresources.NewClient("subscriptionID", &azfake.TokenCredential{}, &arm.ClientOptions{
+				ClientOptions: azcore.ClientOptions{
+					Transport: resourcesfake.NewServerTransport(&fakeServer),
+				},
+			})
+			assert.NoError(t, err)
+
+			endpointVnets := map[string][]string{
+				strings.ToLower(sqlServerID): {"network:" + strings.ToLower(vnetID)},
+			}
+			err = collectAzureSQLDatabaseAssets(ctx, client, subscriptionId, tt.regions, endpointVnets, logger, publisher)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedEvents, publisher.Events)
+		})
+	}
+}
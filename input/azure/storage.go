@@ -0,0 +1,142 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	"github.com/elastic/assetbeat/input/internal"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// AzureStorageAsset is a resource storing data: a managed disk, a storage account or a SQL database.
+type AzureStorageAsset struct {
+	ID             string
+	Name           string
+	SubscriptionID string
+	Region         string
+	Parents        []string
+	Tags           map[string]*string
+	Metadata       mapstr.M
+}
+
+func publishAzureStorageAssets(publisher stateless.Publisher, assetType string, assetKind string, assets []AzureStorageAsset) {
+	for _, asset := range assets {
+		options := []internal.AssetOption{
+			internal.WithAssetCloudProvider("azure"),
+			internal.WithAssetRegion(asset.Region),
+			internal.WithAssetAccountID(asset.SubscriptionID),
+			internal.WithAssetKindAndID(assetKind, asset.ID),
+			internal.WithAssetType(assetType),
+			internal.WithAssetMetadata(asset.Metadata),
+			WithAssetTags(flattenAzureTags(asset.Tags)),
+		}
+		if asset.Parents != nil {
+			options = append(options, internal.WithAssetParents(asset.Parents))
+		}
+		if asset.Name != "" {
+			options = append(options, internal.WithAssetName(asset.Name))
+		}
+		internal.Publish(publisher, nil, options...)
+	}
+}
+
+// collectAzureStorageAccountAssets publishes the storage accounts of a subscription, linked to the virtual networks
+// they are reachable from through private endpoints or virtual network rules.
+func collectAzureStorageAccountAssets(ctx context.Context, client *armstorage.AccountsClient, subscriptionId string, regions []string, endpointVnets map[string][]string, log *logp.Logger, publisher stateless.Publisher) error {
+	accounts, err := getAllAzureStorageAccounts(ctx, client, subscriptionId, regions, endpointVnets)
+	if err != nil {
+		return err
+	}
+
+	log.Debug("Publishing Azure storage accounts")
+	publishAzureStorageAssets(publisher, "azure.storage_account", "storage", accounts)
+	return nil
+}
+
+func getAllAzureStorageAccounts(ctx context.Context, client *armstorage.AccountsClient, subscriptionId string, regions []string, endpointVnets map[string][]string) ([]AzureStorageAsset, error) {
+	var accounts []AzureStorageAsset
+	pager := client.NewListPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to advance page: %v", err)
+		}
+		for _, v := range page.Value {
+			if !isRegionWanted(*v.Location, regions) {
+				continue
+			}
+			accounts = append(accounts, newAzureStorageAccountAsset(v, subscriptionId, endpointVnets))
+		}
+	}
+	return accounts, nil
+}
+
+func newAzureStorageAccountAsset(v *armstorage.Account, subscriptionId string, endpointVnets map[string][]string) AzureStorageAsset {
+	parents := []string{getResourceGroupEAN(*v.ID)}
+	for _, vnet := range endpointVnets[strings.ToLower(*v.ID)] {
+		parents = appendIfMissing(parents, vnet)
+	}
+	metadata := mapstr.M{
+		"resource_group": getResourceGroupFromId(*v.ID),
+	}
+	if v.Kind != nil {
+		metadata["kind"] = string(*v.Kind)
+	}
+	if v.SKU != nil && v.SKU.Name != nil {
+		metadata["sku"] = string(*v.SKU.Name)
+	}
+	if p := v.Properties; p != nil {
+		if p.ProvisioningState != nil {
+			metadata["state"] = string(*p.ProvisioningState)
+		}
+		if p.AccessTier != nil {
+			metadata["access_tier"] = string(*p.AccessTier)
+		}
+		if p.PublicNetworkAccess != nil {
+			metadata["public_network_access"] = string(*p.PublicNetworkAccess)
+		}
+		if p.Encryption != nil && p.Encryption.KeySource != nil {
+			metadata["encryption"] = string(*p.Encryption.KeySource)
+		}
+		if p.NetworkRuleSet != nil {
+			for _, rule := range p.NetworkRuleSet.VirtualNetworkRules {
+				if rule.VirtualNetworkResourceID == nil {
+					continue
+				}
+				if vnetId := getVnetIdFromSubnetId(*rule.VirtualNetworkResourceID); vnetId != "" {
+					parents = appendIfMissing(parents, getNetworkEAN(vnetId))
+				}
+			}
+		}
+	}
+	return AzureStorageAsset{
+		ID:             *v.ID,
+		Name:           *v.Name,
+		SubscriptionID: subscriptionId,
+		Region:         *v.Location,
+		Parents:        parents,
+		Tags:           v.Tags,
+		Metadata:       metadata,
+	}
+}
@@ -0,0 +1,141 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	storagefake "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage/fake"
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/assetbeat/input/testutil"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/stretchr/testify/assert"
+)
+
+var storageAccountID = fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Storage/storageAccounts/account1", subscriptionId, resourceGroup1)
+var vnet2ID = fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/virtualNetworks/vnet2", subscriptionId, resourceGroup1)
+
+var storageAccount1 = armstorage.Account{
+	Location: to.Ptr("westeurope"),
+	ID:       to.Ptr(storageAccountID),
+	Name:     to.Ptr("account1"),
+	Kind:     to.Ptr(armstorage.KindStorageV2),
+	SKU:      &armstorage.SKU{Name: to.Ptr(armstorage.SKUNameStandardLRS)},
+	Properties: &armstorage.AccountProperties{
+		ProvisioningState:   to.Ptr(armstorage.ProvisioningStateSucceeded),
+		AccessTier:          to.Ptr(armstorage.AccessTierHot),
+		PublicNetworkAccess: to.Ptr(armstorage.PublicNetworkAccessDisabled),
+		Encryption:          &armstorage.Encryption{KeySource: to.Ptr(armstorage.KeySourceMicrosoftStorage)},
+		NetworkRuleSet: &armstorage.NetworkRuleSet{
+			VirtualNetworkRules: []*armstorage.VirtualNetworkRule{
+				{VirtualNetworkResourceID: to.Ptr(vnet2ID + "/subnets/subnet1")},
+			},
+		},
+	},
+}
+
+func TestAssetsAzure_collectAzureStorageAccountAssets(t *testing.T) {
+	storageAccountEvent := func(parents []string) beat.Event {
+		return beat.Event{
+			Fields: mapstr.M{
+				"asset.ean":                            "storage:" + storageAccountID,
+				"asset.id":                             storageAccountID,
+				"asset.name":                           "account1",
+				"asset.type":                           "azure.storage_account",
+				"asset.kind":                           "storage",
+				"asset.parents":                        parents,
+				"asset.metadata.resource_group":        "TESTVM",
+				"asset.metadata.kind":                  "StorageV2",
+				"asset.metadata.sku":                   "Standard_LRS",
+				"asset.metadata.state":                 "Succeeded",
+				"asset.metadata.access_tier":           "Hot",
+				"asset.metadata.public_network_access": "Disabled",
+				"asset.metadata.encryption":            "Microsoft.Storage",
+				"cloud.account.id":                     subscriptionId,
+				"cloud.provider":                       "azure",
+				"cloud.region":                         "westeurope",
+			},
+			Meta: mapstr.M{
+				"index": internal.GetDefaultIndexName(),
+			},
+		}
+	}
+
+	for _, tt := range []struct {
+		name           string
+		regions        []string
+		endpointVnets  map[string][]string
+		expectedEvents []beat.Event
+	}{
+		{
+			name: "Test with a storage account exposed through a private endpoint",
+			endpointVnets: map[string][]string{
+				strings.ToLower(storageAccountID): {"network:" + strings.ToLower(vnetID)},
+			},
+			expectedEvents: []beat.Event{storageAccountEvent([]string{resourceGroup1EAN, "network:" + strings.ToLower(vnetID), "network:" + strings.ToLower(vnet2ID)})},
+		},
+		{
+			name:           "Test with a storage account without private endpoint",
+			expectedEvents: []beat.Event{storageAccountEvent([]string{resourceGroup1EAN, "network:" + strings.ToLower(vnet2ID)})},
+		},
+		{
+			name:    "Test with another region specified",
+			regions: []string{"eastus"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := testutil.NewInMemoryPublisher()
+
+			ctx := context.Background()
+			logger := logp.NewLogger("test")
+
+			fakeServer := storagefake.AccountsServer{
+				NewListPager: func(options *armstorage.AccountsClientListOptions) (resp azfake.PagerResponder[armstorage.AccountsClientListResponse]) {
+					page := armstorage.AccountsClientListResponse{
+						AccountListResult: armstorage.AccountListResult{
+							Value: []*armstorage.Account{&storageAccount1},
+						},
+					}
+					resp.AddPage(http.StatusOK, page, nil)
+					return
+				},
+			}
+			client, err := armstorage.NewAccountsClient("subscriptionID", &azfake.TokenCredential{}, &arm.ClientOptions{
+				ClientOptions: azcore.ClientOptions{
+					Transport: storagefake.NewAccountsServerTransport(&fakeServer),
+				},
+			})
+			assert.NoError(t, err)
+
+			err = collectAzureStorageAccountAssets(ctx, client, subscriptionId, tt.regions, tt.endpointVnets, logger, publisher)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedEvents, publisher.Events)
+		})
+	}
+}
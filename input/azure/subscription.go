@@ -0,0 +1,166 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/subscription/armsubscription"
+	"github.com/elastic/assetbeat/input/internal"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// AzureResourceContainer is a subscription or a resource group, which contain the other Azure resources.
+type AzureResourceContainer struct {
+	ID             string
+	Name           string
+	SubscriptionID string
+	Region         string
+	Parents        []string
+	Tags           map[string]*string
+	Metadata       mapstr.M
+}
+
+// collectAzureSubscriptionAssets publishes a subscription along with its resource groups.
+// Resource groups are not filtered by region, as their location does not constrain the one of their resources.
+func collectAzureSubscriptionAssets(ctx context.Context, subscriptionClient *armsubscription.SubscriptionsClient, groupsClient *armresources.ResourceGroupsClient, subscriptionId string, assetTypes []string, log *logp.Logger, publisher stateless.Publisher) error {
+	if internal.IsTypeEnabled(assetTypes, "azure.subscription") {
+		subscription, err := getAzureSubscription(ctx, subscriptionClient, subscriptionId)
+		if err != nil {
+			return err
+		}
+		log.Debug("Publishing Azure subscriptions")
+		publishAzureResourceContainers(publisher, "azure.subscription", "account", []AzureResourceContainer{subscription})
+	}
+	if internal.IsTypeEnabled(assetTypes, "azure.resource_group") {
+		groups, err := getAllAzureResourceGroups(ctx, groupsClient, subscriptionId)
+		if err != nil {
+			return err
+		}
+		log.Debug("Publishing Azure resource groups")
+		publishAzureResourceContainers(publisher, "azure.resource_group", "resource_group", groups)
+	}
+	return nil
+}
+
+func publishAzureResourceContainers(publisher stateless.Publisher, assetType string, assetKind string, containers []AzureResourceContainer) {
+	for _, container := range containers {
+		options := []internal.AssetOption{
+			internal.WithAssetCloudProvider("azure"),
+			internal.WithAssetAccountID(container.SubscriptionID),
+			internal.WithAssetKindAndID(assetKind, container.ID),
+			internal.WithAssetType(assetType),
+			internal.WithAssetMetadata(container.Metadata),
+			WithAssetTags(flattenAzureTags(container.Tags)),
+		}
+		if container.Region != "" {
+			options = append(options, internal.WithAssetRegion(container.Region))
+		}
+		if container.Parents != nil {
+			options = append(options, internal.WithAssetParents(container.Parents))
+		}
+		if container.Name != "" {
+			options = append(options, internal.WithAssetName(container.Name))
+		}
+		internal.Publish(publisher, nil, options...)
+	}
+}
+
+func getAzureSubscription(ctx context.Context, client *armsubscription.SubscriptionsClient, subscriptionId string) (AzureResourceContainer, error) {
+	res, err := client.Get(ctx, subscriptionId, nil)
+	if err != nil {
+		return AzureResourceContainer{}, fmt.Errorf("failed to get subscription %s: %v", subscriptionId, err)
+	}
+	return newAzureSubscriptionAsset(&res.Subscription, subscriptionId), nil
+}
+
+func newAzureSubscriptionAsset(s *armsubscription.Subscription, subscriptionId string) AzureResourceContainer {
+	metadata := mapstr.M{}
+	if s.State != nil {
+		metadata["state"] = string(*s.State)
+	}
+	var name string
+	if s.DisplayName != nil {
+		name = *s.DisplayName
+	}
+	return AzureResourceContainer{
+		ID:             getSubscriptionResourceId(subscriptionId),
+		Name:           name,
+		SubscriptionID: subscriptionId,
+		Metadata:       metadata,
+	}
+}
+
+func getAllAzureResourceGroups(ctx context.Context, client *armresources.ResourceGroupsClient, subscriptionId string) ([]AzureResourceContainer, error) {
+	var groups []AzureResourceContainer
+	pager := client.NewListPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to advance page: %v", err)
+		}
+		for _, g := range page.Value {
+			groups = append(groups, newAzureResourceGroupAsset(g, subscriptionId))
+		}
+	}
+	return groups, nil
+}
+
+func newAzureResourceGroupAsset(g *armresources.ResourceGroup, subscriptionId string) AzureResourceContainer {
+	metadata := mapstr.M{}
+	if g.Properties != nil && g.Properties.ProvisioningState != nil {
+		metadata["state"] = *g.Properties.ProvisioningState
+	}
+	if g.ManagedBy != nil {
+		metadata["managed_by"] = *g.ManagedBy
+	}
+	return AzureResourceContainer{
+		ID:             getResourceGroupResourceId(subscriptionId, *g.Name),
+		Name:           *g.Name,
+		SubscriptionID: subscriptionId,
+		Region:         *g.Location,
+		Parents:        []string{"account:" + getSubscriptionResourceId(subscriptionId)},
+		Tags:           g.Tags,
+		Metadata:       metadata,
+	}
+}
+
+func getSubscriptionResourceId(subscriptionId string) string {
+	return "/subscriptions/" + strings.ToLower(subscriptionId)
+}
+
+// getResourceGroupResourceId returns the resource ID of a resource group. Azure resource IDs are case-insensitive,
+// and resources commonly refer to their resource group with a different case than the one it was created with,
+// so the ID is lower cased for the resource group asset and its children to agree on it.
+func getResourceGroupResourceId(subscriptionId string, resourceGroup string) string {
+	return fmt.Sprintf("%s/resourcegroups/%s", getSubscriptionResourceId(subscriptionId), strings.ToLower(resourceGroup))
+}
+
+// getResourceGroupEAN returns the EAN of the resource group of an Azure resource, from the resource ID.
+func getResourceGroupEAN(resourceId string) string {
+	s := strings.Split(resourceId, "/")
+	if len(s) < 5 {
+		return ""
+	}
+	return "resource_group:" + getResourceGroupResourceId(s[2], s[4])
+}
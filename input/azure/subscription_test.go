@@ -0,0 +1,180 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	resourcesfake "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/subscription/armsubscription"
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/assetbeat/input/testutil"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/stretchr/testify/assert"
+)
+
+var resourceGroup1EAN = fmt.Sprintf("resource_group:/subscriptions/%s/resourcegroups/testvm", subscriptionId)
+
+// subscriptionTransport serves the subscription returned by the Subscriptions API, which has no fake server.
+type subscriptionTransport struct{}
+
+func (subscriptionTransport) Do(req *http.Request) (*http.Response, error) {
+	body := fmt.Sprintf(`{"id": "/subscriptions/%s", "subscriptionId": "%s", "displayName": "my subscription", "state": "Enabled"}`, subscriptionId, subscriptionId)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestAssetsAzure_collectAzureSubscriptionAssets(t *testing.T) {
+	subscriptionEvent := beat.Event{
+		Fields: mapstr.M{
+			"asset.ean":            "account:/subscriptions/" + subscriptionId,
+			"asset.id":             "/subscriptions/" + subscriptionId,
+			"asset.name":           "my subscription",
+			"asset.type":           "azure.subscription",
+			"asset.kind":           "account",
+			"asset.metadata.state": "Enabled",
+			"cloud.account.id":     subscriptionId,
+			"cloud.provider":       "azure",
+		},
+		Meta: mapstr.M{
+			"index": internal.GetDefaultIndexName(),
+		},
+	}
+	resourceGroupEvent := beat.Event{
+		Fields: mapstr.M{
+			"asset.ean":               resourceGroup1EAN,
+			"asset.id":                strings.TrimPrefix(resourceGroup1EAN, "resource_group:"),
+			"asset.name":              resourceGroup1,
+			"asset.type":              "azure.resource_group",
+			"asset.kind":              "resource_group",
+			"asset.parents":           []string{"account:/subscriptions/" + subscriptionId},
+			"asset.metadata.state":    "Succeeded",
+			"asset.metadata.tags.env": "prod",
+			"cloud.account.id":        subscriptionId,
+			"cloud.provider":          "azure",
+			"cloud.region":            "westeurope",
+		},
+		Meta: mapstr.M{
+			"index": internal.GetDefaultIndexName(),
+		},
+	}
+
+	for _, tt := range []struct {
+		name           string
+		assetTypes     []string
+		expectedEvents []beat.Event
+	}{
+		{
+			name:           "Test with all types enabled",
+			expectedEvents: []beat.Event{subscriptionEvent, resourceGroupEvent},
+		},
+		{
+			name:           "Test with only resource groups enabled",
+			assetTypes:     []string{"azure.resource_group"},
+			expectedEvents: []beat.Event{resourceGroupEvent},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := testutil.NewInMemoryPublisher()
+
+			ctx := context.Background()
+			logger := logp.NewLogger("test")
+
+			fakeServer := resourcesfake.ResourceGroupsServer{
+				NewListPager: func(options *armresources.ResourceGroupsClientListOptions) (resp azfake.PagerResponder[armresources.ResourceGroupsClientListResponse]) {
+					page := armresources.ResourceGroupsClientListResponse{
+						ResourceGroupListResult: armresources.ResourceGroupListResult{
+							Value: []*armresources.ResourceGroup{
+								{
+									ID:         to.Ptr(fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", subscriptionId, resourceGroup1)),
+									Name:       to.Ptr(resourceGroup1),
+									Location:   to.Ptr("westeurope"),
+									Tags:       map[string]*string{"env": to.Ptr("prod")},
+									Properties: &armresources.ResourceGroupProperties{ProvisioningState: to.Ptr("Succeeded")},
+								},
+							},
+						},
+					}
+					resp.AddPage(http.StatusOK, page, nil)
+					return
+				},
+			}
+			groupsClient, err := armresources.NewResourceGroupsClient("subscriptionID", &azfake.TokenCredential{}, &arm.ClientOptions{
+				ClientOptions: azcore.ClientOptions{
+					Transport: resourcesfake.NewResourceGroupsServerTransport(&fakeServer),
+				},
+			})
+			assert.NoError(t, err)
+
+			subscriptionClient, err := armsubscription.NewSubscriptionsClient(&azfake.TokenCredential{}, &arm.ClientOptions{
+				ClientOptions: azcore.ClientOptions{
+					Transport: subscriptionTransport{},
+				},
+			})
+			assert.NoError(t, err)
+
+			err = collectAzureSubscriptionAssets(ctx, subscriptionClient, groupsClient, subscriptionId, tt.assetTypes, logger, publisher)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedEvents, publisher.Events)
+		})
+	}
+}
+
+func TestGetResourceGroupEAN(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		resourceId string
+		expected   string
+	}{
+		{
+			name:       "with a resource ID",
+			resourceId: instanceid1,
+			expected:   resourceGroup1EAN,
+		},
+		{
+			name:       "with a lower cased resource ID",
+			resourceId: strings.ToLower(instanceid1),
+			expected:   resourceGroup1EAN,
+		},
+		{
+			name:       "with an invalid resource ID",
+			resourceId: "/subscriptions/" + subscriptionId,
+			expected:   "",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, getResourceGroupEAN(tt.resourceId))
+		})
+	}
+}
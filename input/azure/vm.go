@@ -28,61 +28,81 @@ import (
 	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
 	"github.com/elastic/elastic-agent-libs/logp"
 	"github.com/elastic/elastic-agent-libs/mapstr"
+	"golang.org/x/sync/errgroup"
 )
 
 type AzureVMInstance struct {
 	ID             string
+	ResourceID     string
 	Name           string
 	SubscriptionID string
 	Region         string
+	Parents        []string
 	Tags           map[string]*string
 	Metadata       mapstr.M
 }
 
 type vmScaleSet struct {
-	ID   string
-	Name string
+	ID             string
+	Name           string
+	SubscriptionID string
+	Region         string
+	Parents        []string
+	Children       []string
+	Tags           map[string]*string
+	Metadata       mapstr.M
 }
 
-func collectAzureVMAssets(ctx context.Context, client *armcompute.VirtualMachinesClient, subscriptionId string, regions []string, log *logp.Logger, publisher stateless.Publisher) error {
+// scaleSetInstanceViewConcurrency bounds the number of concurrent instance view requests per scale set
+const scaleSetInstanceViewConcurrency = 10
 
-	instances, err := getAllAzureVMInstances(ctx, client, subscriptionId, regions)
+// unknownVMState is the state of the scale set instances whose instance view could not be retrieved
+const unknownVMState = "unknown"
+
+func collectAzureScaleSetsVMAssets(ctx context.Context, vmClient *armcompute.VirtualMachineScaleSetVMsClient, scaleSetClient *armcompute.VirtualMachineScaleSetsClient, subscriptionId string, regions []string, assetTypes []string, log *logp.Logger, publisher stateless.Publisher) error {
+	scaleSets, instances, err := getAllAzureScaleSetsVMInstances(ctx, vmClient, scaleSetClient, subscriptionId, regions, log)
 	if err != nil {
 		return err
 	}
 
-	assetType := "azure.vm.instance"
-	assetKind := "host"
-	log.Debug("Publishing Azure VM instances")
+	if internal.IsTypeEnabled(assetTypes, "azure.vmss") {
+		log.Debug("Publishing Azure VM scale sets")
+		publishAzureVMScaleSets(publisher, scaleSets)
+	}
+	if internal.IsTypeEnabled(assetTypes, "azure.vm.instance") {
+		log.Debug("Publishing Azure VM instances")
+		publishAzureVMInstances(publisher, instances)
+	}
+	return nil
+}
 
-	for _, instance := range instances {
+func publishAzureVMScaleSets(publisher stateless.Publisher, scaleSets []vmScaleSet) {
+	assetType := "azure.vmss"
+	assetKind := "instance_group"
+	for _, scaleSet := range scaleSets {
 		options := []internal.AssetOption{
 			internal.WithAssetCloudProvider("azure"),
-			internal.WithAssetRegion(instance.Region),
-			internal.WithAssetAccountID(instance.SubscriptionID),
-			internal.WithAssetKindAndID(assetKind, instance.ID),
+			internal.WithAssetRegion(scaleSet.Region),
+			internal.WithAssetAccountID(scaleSet.SubscriptionID),
+			internal.WithAssetKindAndID(assetKind, scaleSet.ID),
 			internal.WithAssetType(assetType),
-			internal.WithAssetMetadata(instance.Metadata),
+			internal.WithAssetChildren(scaleSet.Children),
+			internal.WithAssetMetadata(scaleSet.Metadata),
+			WithAssetTags(flattenAzureTags(scaleSet.Tags)),
 		}
-		if instance.Name != "" {
-			options = append(options, internal.WithAssetName(instance.Name))
+		if scaleSet.Parents != nil {
+			options = append(options, internal.WithAssetParents(scaleSet.Parents))
+		}
+		if scaleSet.Name != "" {
+			options = append(options, internal.WithAssetName(scaleSet.Name))
 		}
 		internal.Publish(publisher, nil, options...)
 	}
-
-	return nil
 }
 
-func collectAzureScaleSetsVMAssets(ctx context.Context, vmClient *armcompute.VirtualMachineScaleSetVMsClient, scaleSetClient *armcompute.VirtualMachineScaleSetsClient, subscriptionId string, regions []string, log *logp.Logger, publisher stateless.Publisher) error {
-	instances, err := getAllAzureScaleSetsVMInstances(ctx, vmClient, scaleSetClient, subscriptionId, regions, log)
-	if err != nil {
-		return err
-	}
-
+func publishAzureVMInstances(publisher stateless.Publisher, instances []AzureVMInstance) {
 	assetType := "azure.vm.instance"
 	assetKind := "host"
-	log.Debug("Publishing Azure VM instances")
-
 	for _, instance := range instances {
 		options := []internal.AssetOption{
 			internal.WithAssetCloudProvider("azure"),
@@ -91,64 +111,133 @@ func collectAzureScaleSetsVMAssets(ctx context.Context, vmClient *armcompute.Vir
 			internal.WithAssetKindAndID(assetKind, instance.ID),
 			internal.WithAssetType(assetType),
 			internal.WithAssetMetadata(instance.Metadata),
+			WithAssetTags(flattenAzureTags(instance.Tags)),
+		}
+		if instance.Parents != nil {
+			options = append(options, internal.WithAssetParents(instance.Parents))
 		}
 		if instance.Name != "" {
 			options = append(options, internal.WithAssetName(instance.Name))
 		}
 		internal.Publish(publisher, nil, options...)
 	}
-	return nil
 }
 
-func getAllAzureScaleSetsVMInstances(ctx context.Context, vmClient *armcompute.VirtualMachineScaleSetVMsClient, scaleSetClient *armcompute.VirtualMachineScaleSetsClient, subscriptionId string, regions []string, log *logp.Logger) ([]AzureVMInstance, error) {
-	var vmScaleSets []vmScaleSet
+// getAllAzureScaleSetsVMInstances returns the VM scale sets of a subscription, along with their instances.
+// Failing to retrieve the instances of a scale set, or the instance view of one of them, is reported
+// without preventing the other ones from being collected.
+func getAllAzureScaleSetsVMInstances(ctx context.Context, vmClient *armcompute.VirtualMachineScaleSetVMsClient, scaleSetClient *armcompute.VirtualMachineScaleSetsClient, subscriptionId string, regions []string, log *logp.Logger) ([]vmScaleSet, []AzureVMInstance, error) {
+	scaleSets, err := getAllAzureScaleSets(ctx, scaleSetClient, subscriptionId, regions)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	var vmInstances []AzureVMInstance
-	scaleSetPager := scaleSetClient.NewListAllPager(&armcompute.VirtualMachineScaleSetsClientListAllOptions{})
-	for scaleSetPager.More() {
-		page, err := scaleSetPager.NextPage(ctx)
+	for i, scaleSet := range scaleSets {
+		instances, err := getAzureScaleSetVMInstances(ctx, vmClient, scaleSet, log)
+		if err != nil {
+			log.Warnf("Error while retrieving instances of VM scale set %s: %+v", scaleSet.ID, err)
+		}
+		for _, instance := range instances {
+			scaleSets[i].Children = append(scaleSets[i].Children, "host:"+instance.ID)
+		}
+		vmInstances = append(vmInstances, instances...)
+	}
+	return scaleSets, vmInstances, nil
+}
+
+func getAllAzureScaleSets(ctx context.Context, scaleSetClient *armcompute.VirtualMachineScaleSetsClient, subscriptionId string, regions []string) ([]vmScaleSet, error) {
+	var scaleSets []vmScaleSet
+	pager := scaleSetClient.NewListAllPager(&armcompute.VirtualMachineScaleSetsClientListAllOptions{})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("failed to advance page: %v", err)
 		}
 		for _, v := range page.Value {
-			vmScaleSets = append(vmScaleSets, vmScaleSet{ID: *v.ID, Name: *v.Name})
+			if !isRegionWanted(*v.Location, regions) {
+				continue
+			}
+			scaleSets = append(scaleSets, newAzureVMScaleSet(v, subscriptionId))
+		}
+	}
+	return scaleSets, nil
+}
+
+func newAzureVMScaleSet(v *armcompute.VirtualMachineScaleSet, subscriptionId string) vmScaleSet {
+	metadata := mapstr.M{
+		"resource_group": getResourceGroupFromId(*v.ID),
+	}
+	if v.SKU != nil {
+		if v.SKU.Name != nil {
+			metadata["sku"] = *v.SKU.Name
+		}
+		if v.SKU.Capacity != nil {
+			metadata["capacity"] = *v.SKU.Capacity
 		}
 	}
+	if v.Properties != nil {
+		if v.Properties.OrchestrationMode != nil {
+			metadata["orchestration_mode"] = string(*v.Properties.OrchestrationMode)
+		}
+		if v.Properties.ProvisioningState != nil {
+			metadata["state"] = *v.Properties.ProvisioningState
+		}
+	}
+	return vmScaleSet{
+		ID:             *v.ID,
+		Name:           *v.Name,
+		SubscriptionID: subscriptionId,
+		Region:         *v.Location,
+		Parents:        []string{getResourceGroupEAN(*v.ID)},
+		Tags:           v.Tags,
+		Metadata:       metadata,
+	}
+}
 
-	for _, vmScaleSet := range vmScaleSets {
-		resourceGroup := getResourceGroupFromId(vmScaleSet.ID)
-		pager := vmClient.NewListPager(resourceGroup, vmScaleSet.Name, &armcompute.VirtualMachineScaleSetVMsClientListOptions{})
+// getAzureScaleSetVMInstances returns all the instances of a scale set. Their instance views,
+// which hold their power state, are retrieved concurrently.
+func getAzureScaleSetVMInstances(ctx context.Context, vmClient *armcompute.VirtualMachineScaleSetVMsClient, scaleSet vmScaleSet, log *logp.Logger) ([]AzureVMInstance, error) {
+	resourceGroup := getResourceGroupFromId(scaleSet.ID)
+	var vms []*armcompute.VirtualMachineScaleSetVM
+	pager := vmClient.NewListPager(resourceGroup, scaleSet.Name, &armcompute.VirtualMachineScaleSetVMsClientListOptions{})
+	for pager.More() {
 		page, err := pager.NextPage(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("failed to advance page: %v", err)
 		}
-		for _, v := range page.Value {
-			res, err := vmClient.GetInstanceView(ctx, resourceGroup, vmScaleSet.Name, *v.InstanceID, nil)
+		vms = append(vms, page.Value...)
+	}
+
+	statuses := make([]string, len(vms))
+	g := errgroup.Group{}
+	g.SetLimit(scaleSetInstanceViewConcurrency)
+	for i, v := range vms {
+		i, v := i, v
+		g.Go(func() error {
+			res, err := vmClient.GetInstanceView(ctx, resourceGroup, scaleSet.Name, *v.InstanceID, nil)
 			if err != nil {
-				return nil, fmt.Errorf("failed get the Instance View: %v", err)
+				log.Warnf("Error while retrieving the instance view of VM %s of scale set %s: %+v", *v.Name, scaleSet.ID, err)
+				statuses[i] = unknownVMState
+				return nil
 			}
 			instanceView := res.VirtualMachineScaleSetVMInstanceView
-			var status string
 			if len(instanceView.Statuses) > 1 {
-				status = *instanceView.Statuses[len(instanceView.Statuses)-1].DisplayStatus
+				statuses[i] = *instanceView.Statuses[len(instanceView.Statuses)-1].DisplayStatus
 			}
-			vmInstance := AzureVMInstance{
-				ID:             *v.Properties.VMID,
-				Name:           *v.Name,
-				SubscriptionID: subscriptionId,
-				Region:         *v.Location,
-				Tags:           v.Tags,
-				Metadata: mapstr.M{
-					"state":          status,
-					"resource_group": resourceGroup,
-				},
-			}
-			vmInstances = append(vmInstances, vmInstance)
-		}
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	var vmInstances []AzureVMInstance
+	for i, v := range vms {
+		vmInstances = append(vmInstances, newAzureScaleSetVMInstance(v, scaleSet.SubscriptionID, scaleSet.ID, resourceGroup, statuses[i]))
 	}
 	return vmInstances, nil
 }
 
-func getAllAzureVMInstances(ctx context.Context, client *armcompute.VirtualMachinesClient, subscriptionId string, regions []string) ([]AzureVMInstance, error) {
+func getAllAzureVMInstances(ctx context.Context, client *armcompute.VirtualMachinesClient, subscriptionId string, regions []string, nicSubnets map[string][]string) ([]AzureVMInstance, error) {
 	var vmInstances []AzureVMInstance
 	pager := client.NewListAllPager(&armcompute.VirtualMachinesClientListAllOptions{StatusOnly: to.Ptr("true")})
 	for pager.More() {
@@ -162,30 +251,100 @@ func getAllAzureVMInstances(ctx context.Context, client *armcompute.VirtualMachi
 				if v.Properties != nil && v.Properties.InstanceView != nil && len(v.Properties.InstanceView.Statuses) > 1 {
 					status = *v.Properties.InstanceView.Statuses[len(v.Properties.InstanceView.Statuses)-1].DisplayStatus
 				}
-				vmInstance := AzureVMInstance{
-					ID:             *v.Properties.VMID,
-					Name:           *v.Name,
-					SubscriptionID: subscriptionId,
-					Region:         *v.Location,
-					Tags:           v.Tags,
-					Metadata: mapstr.M{
-						"state":          status,
-						"resource_group": getResourceGroupFromId(*v.ID),
-					},
-				}
-				vmInstances = append(vmInstances, vmInstance)
+				vmInstances = append(vmInstances, newAzureVMInstance(v, subscriptionId, status, nicSubnets))
 			}
 		}
 	}
 	return vmInstances, nil
 }
 
+func newAzureVMInstance(v *armcompute.VirtualMachine, subscriptionId string, status string, nicSubnets map[string][]string) AzureVMInstance {
+	return AzureVMInstance{
+		ID:             *v.Properties.VMID,
+		ResourceID:     *v.ID,
+		Name:           *v.Name,
+		SubscriptionID: subscriptionId,
+		Region:         *v.Location,
+		Parents:        append([]string{getResourceGroupEAN(*v.ID)}, getVMSubnets(v, nicSubnets)...),
+		Tags:           v.Tags,
+		Metadata: mapstr.M{
+			"state":          status,
+			"resource_group": getResourceGroupFromId(*v.ID),
+		},
+	}
+}
+
+func newAzureScaleSetVMInstance(v *armcompute.VirtualMachineScaleSetVM, subscriptionId string, scaleSetId string, resourceGroup string, status string) AzureVMInstance {
+	parents := getScaleSetVMSubnets(v)
+	if scaleSetId != "" {
+		parents = append(parents, "instance_group:"+scaleSetId)
+	}
+	var resourceId string
+	if v.ID != nil {
+		resourceId = *v.ID
+	}
+	return AzureVMInstance{
+		ID:             *v.Properties.VMID,
+		ResourceID:     resourceId,
+		Name:           *v.Name,
+		SubscriptionID: subscriptionId,
+		Region:         *v.Location,
+		Parents:        parents,
+		Tags:           v.Tags,
+		Metadata: mapstr.M{
+			"state":          status,
+			"resource_group": resourceGroup,
+		},
+	}
+}
+
+// getVMSubnets returns the EANs of the subnets a VM is attached to, through its network interfaces.
+func getVMSubnets(v *armcompute.VirtualMachine, nicSubnets map[string][]string) []string {
+	if v.Properties == nil || v.Properties.NetworkProfile == nil {
+		return nil
+	}
+	var subnets []string
+	for _, nic := range v.Properties.NetworkProfile.NetworkInterfaces {
+		if nic.ID == nil {
+			continue
+		}
+		for _, subnet := range nicSubnets[strings.ToLower(*nic.ID)] {
+			subnets = appendIfMissing(subnets, subnet)
+		}
+	}
+	return subnets
+}
+
+// getScaleSetVMSubnets returns the EANs of the subnets a VM scale set instance is attached to.
+// The network interfaces of scale set instances are defined by the network profile configuration.
+func getScaleSetVMSubnets(v *armcompute.VirtualMachineScaleSetVM) []string {
+	if v.Properties == nil || v.Properties.NetworkProfileConfiguration == nil {
+		return nil
+	}
+	var subnets []string
+	for _, nic := range v.Properties.NetworkProfileConfiguration.NetworkInterfaceConfigurations {
+		if nic.Properties == nil {
+			continue
+		}
+		for _, ipConfig := range nic.Properties.IPConfigurations {
+			if ipConfig.Properties != nil && ipConfig.Properties.Subnet != nil && ipConfig.Properties.Subnet.ID != nil {
+				subnets = appendIfMissing(subnets, getNetworkEAN(*ipConfig.Properties.Subnet.ID))
+			}
+		}
+	}
+	return subnets
+}
+
 func wantRegion(v *armcompute.VirtualMachine, regions []string) bool {
+	return isRegionWanted(*v.Location, regions)
+}
+
+func isRegionWanted(location string, regions []string) bool {
 	if len(regions) == 0 {
 		return true
 	}
 	for _, region := range regions {
-		if *v.Location == region {
+		if location == region {
 			return true
 		}
 	}
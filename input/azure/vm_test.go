@@ -33,6 +33,7 @@ import (
 	"github.com/elastic/elastic-agent-libs/mapstr"
 	"github.com/stretchr/testify/assert"
 	"net/http"
+	"strings"
 	"testing"
 )
 
@@ -108,11 +109,12 @@ var status2 = armcompute.InstanceViewStatus{
 	DisplayStatus: to.Ptr("VM Running"),
 }
 
-func TestAssetsAzure_collectAzureVMAssets(t *testing.T) {
+func TestAssetsAzure_getAllAzureVMInstances(t *testing.T) {
 	for _, tt := range []struct {
 		name           string
 		regions        []string
 		fakeServer     fake.VirtualMachinesServer
+		nicSubnets     map[string][]string
 		subscriptionId string
 		expectedEvents []beat.Event
 	}{
@@ -143,6 +145,7 @@ func TestAssetsAzure_collectAzureVMAssets(t *testing.T) {
 						"asset.name":                    instance1Name,
 						"asset.type":                    "azure.vm.instance",
 						"asset.kind":                    "host",
+						"asset.parents":                 []string{resourceGroup1EAN},
 						"asset.metadata.state":          "",
 						"asset.metadata.resource_group": "TESTVM",
 						"cloud.account.id":              "12cabcb4-86e8-404f-111111111111",
@@ -160,6 +163,7 @@ func TestAssetsAzure_collectAzureVMAssets(t *testing.T) {
 						"asset.name":                    instance2Name,
 						"asset.type":                    "azure.vm.instance",
 						"asset.kind":                    "host",
+						"asset.parents":                 []string{resourceGroup1EAN},
 						"asset.metadata.state":          "",
 						"asset.metadata.resource_group": "TESTVM",
 						"cloud.account.id":              "12cabcb4-86e8-404f-111111111111",
@@ -177,6 +181,7 @@ func TestAssetsAzure_collectAzureVMAssets(t *testing.T) {
 						"asset.name":                    instance3Name,
 						"asset.type":                    "azure.vm.instance",
 						"asset.kind":                    "host",
+						"asset.parents":                 []string{resourceGroup1EAN},
 						"asset.metadata.state":          "",
 						"asset.metadata.resource_group": "TESTVM",
 						"cloud.account.id":              "12cabcb4-86e8-404f-111111111111",
@@ -217,6 +222,7 @@ func TestAssetsAzure_collectAzureVMAssets(t *testing.T) {
 						"asset.name":                    instance1Name,
 						"asset.type":                    "azure.vm.instance",
 						"asset.kind":                    "host",
+						"asset.parents":                 []string{resourceGroup1EAN},
 						"asset.metadata.state":          "",
 						"asset.metadata.resource_group": "TESTVM",
 						"cloud.account.id":              "12cabcb4-86e8-404f-111111111111",
@@ -234,6 +240,7 @@ func TestAssetsAzure_collectAzureVMAssets(t *testing.T) {
 						"asset.name":                    instance2Name,
 						"asset.type":                    "azure.vm.instance",
 						"asset.kind":                    "host",
+						"asset.parents":                 []string{resourceGroup1EAN},
 						"asset.metadata.state":          "",
 						"asset.metadata.resource_group": "TESTVM",
 						"cloud.account.id":              "12cabcb4-86e8-404f-111111111111",
@@ -246,22 +253,75 @@ func TestAssetsAzure_collectAzureVMAssets(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:           "Test with a tagged VM attached to a subnet through its network interface",
+			subscriptionId: "12cabcb4-86e8-404f-111111111111",
+			nicSubnets: map[string][]string{
+				strings.ToLower(nicID): {"network:" + strings.ToLower(subnetID)},
+			},
+			fakeServer: fake.VirtualMachinesServer{
+				NewListAllPager: func(options *armcompute.VirtualMachinesClientListAllOptions) (resp azfake.PagerResponder[armcompute.VirtualMachinesClientListAllResponse]) {
+					instance := armcompute.VirtualMachine{
+						Location: to.Ptr("westeurope"),
+						ID:       to.Ptr(instanceid1),
+						Name:     to.Ptr(instance1Name),
+						Tags:     map[string]*string{"env": to.Ptr("prod")},
+						Properties: &armcompute.VirtualMachineProperties{
+							VMID: to.Ptr(instanceVMId1),
+							NetworkProfile: &armcompute.NetworkProfile{
+								NetworkInterfaces: []*armcompute.NetworkInterfaceReference{
+									{ID: to.Ptr(strings.ToUpper(nicID))},
+								},
+							},
+						},
+					}
+					page := armcompute.VirtualMachinesClientListAllResponse{
+						VirtualMachineListResult: armcompute.VirtualMachineListResult{
+							Value: []*armcompute.VirtualMachine{&instance},
+						},
+					}
+					resp.AddPage(http.StatusOK, page, nil)
+					return
+				},
+			},
+			expectedEvents: []beat.Event{
+				{
+					Fields: mapstr.M{
+						"asset.ean":                     "host:" + instanceVMId1,
+						"asset.id":                      instanceVMId1,
+						"asset.name":                    instance1Name,
+						"asset.type":                    "azure.vm.instance",
+						"asset.kind":                    "host",
+						"asset.parents":                 []string{resourceGroup1EAN, "network:" + strings.ToLower(subnetID)},
+						"asset.metadata.state":          "",
+						"asset.metadata.tags.env":       "prod",
+						"asset.metadata.resource_group": "TESTVM",
+						"cloud.account.id":              "12cabcb4-86e8-404f-111111111111",
+						"cloud.provider":                "azure",
+						"cloud.region":                  "westeurope",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+			},
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			publisher := testutil.NewInMemoryPublisher()
 
 			ctx := context.Background()
-			logger := logp.NewLogger("test")
 
-			client, err := armcompute.NewVirtualMachinesClient("subscriptionID", azfake.NewTokenCredential(), &arm.ClientOptions{
+			client, err := armcompute.NewVirtualMachinesClient("subscriptionID", &azfake.TokenCredential{}, &arm.ClientOptions{
 				ClientOptions: azcore.ClientOptions{
 					Transport: fake.NewVirtualMachinesServerTransport(&tt.fakeServer),
 				},
 			})
 			assert.NoError(t, err)
 
-			err = collectAzureVMAssets(ctx, client, tt.subscriptionId, tt.regions, logger, publisher)
+			instances, err := getAllAzureVMInstances(ctx, client, tt.subscriptionId, tt.regions, tt.nicSubnets)
 			assert.NoError(t, err)
+			publishAzureVMInstances(publisher, instances)
 			assert.Equal(t, tt.expectedEvents, publisher.Events)
 		})
 
@@ -269,96 +329,129 @@ func TestAssetsAzure_collectAzureVMAssets(t *testing.T) {
 }
 
 func TestAssetsAzure_collectAzureScaleSetsVMAssets(t *testing.T) {
+	scaleSetServer := fake.VirtualMachineScaleSetsServer{
+		NewListAllPager: func(options *armcompute.VirtualMachineScaleSetsClientListAllOptions) (resp azfake.PagerResponder[armcompute.VirtualMachineScaleSetsClientListAllResponse]) {
+
+			page := armcompute.VirtualMachineScaleSetsClientListAllResponse{
+				VirtualMachineScaleSetListWithLinkResult: armcompute.VirtualMachineScaleSetListWithLinkResult{
+					Value: []*armcompute.VirtualMachineScaleSet{
+						&scaleSet,
+					},
+				},
+			}
+			resp.AddPage(http.StatusOK, page, nil)
+			return
+		},
+	}
+	vmServer := func(failingInstanceID string) fake.VirtualMachineScaleSetVMsServer {
+		return fake.VirtualMachineScaleSetVMsServer{
+			NewListPager: func(resourceGroup string, vmScaleSetName string, options *armcompute.VirtualMachineScaleSetVMsClientListOptions) (resp azfake.PagerResponder[armcompute.VirtualMachineScaleSetVMsClientListResponse]) {
+				page := armcompute.VirtualMachineScaleSetVMsClientListResponse{
+					VirtualMachineScaleSetVMListResult: armcompute.VirtualMachineScaleSetVMListResult{
+						Value: []*armcompute.VirtualMachineScaleSetVM{
+							&scaleSetVm1,
+							&scaleSetVm2,
+						},
+					},
+				}
+				resp.AddPage(http.StatusOK, page, nil)
+				return
+			},
+			GetInstanceView: func(ctx context.Context, resourceGroupName, vmScaleSetName, instanceId string, options *armcompute.VirtualMachineScaleSetVMsClientGetInstanceViewOptions) (resp azfake.Responder[armcompute.VirtualMachineScaleSetVMsClientGetInstanceViewResponse], errResp azfake.ErrorResponder) {
+				if instanceId == failingInstanceID {
+					errResp.SetResponseError(http.StatusNotFound, "NotFound")
+					return
+				}
+				response := armcompute.VirtualMachineScaleSetVMsClientGetInstanceViewResponse{
+					VirtualMachineScaleSetVMInstanceView: armcompute.VirtualMachineScaleSetVMInstanceView{
+						Statuses: []*armcompute.InstanceViewStatus{
+							&status1,
+							&status2,
+						},
+					},
+				}
+				resp.SetResponse(http.StatusOK, response, nil)
+				return
+			},
+		}
+	}
+	scaleSetEvent := beat.Event{
+		Fields: mapstr.M{
+			"asset.ean":                     "instance_group:" + ssID,
+			"asset.id":                      ssID,
+			"asset.name":                    ss1Name,
+			"asset.type":                    "azure.vmss",
+			"asset.kind":                    "instance_group",
+			"asset.parents":                 []string{resourceGroup1EAN},
+			"asset.children":                []string{"host:" + instanceVMId1, "host:" + instanceVMId2},
+			"asset.metadata.resource_group": "TESTVM",
+			"cloud.account.id":              "12cabcb4-86e8-404f-111111111111",
+			"cloud.provider":                "azure",
+			"cloud.region":                  "westeurope",
+		},
+		Meta: mapstr.M{
+			"index": internal.GetDefaultIndexName(),
+		},
+	}
+	instanceEvent := func(vmId string, name string, region string, state string) beat.Event {
+		return beat.Event{
+			Fields: mapstr.M{
+				"asset.ean":                     "host:" + vmId,
+				"asset.id":                      vmId,
+				"asset.name":                    name,
+				"asset.type":                    "azure.vm.instance",
+				"asset.kind":                    "host",
+				"asset.parents":                 []string{"instance_group:" + ssID},
+				"asset.metadata.state":          state,
+				"asset.metadata.resource_group": "TESTVM",
+				"cloud.account.id":              "12cabcb4-86e8-404f-111111111111",
+				"cloud.provider":                "azure",
+				"cloud.region":                  region,
+			},
+			Meta: mapstr.M{
+				"index": internal.GetDefaultIndexName(),
+			},
+		}
+	}
+
 	for _, tt := range []struct {
 		name           string
 		regions        []string
+		assetTypes     []string
 		fakeSSServer   fake.VirtualMachineScaleSetsServer
 		fakeVMServer   fake.VirtualMachineScaleSetVMsServer
 		subscriptionId string
 		expectedEvents []beat.Event
 	}{
 		{
-			name:           "Test with one ScaleSet with tow instances",
+			name:           "Test with one ScaleSet with two instances",
 			subscriptionId: "12cabcb4-86e8-404f-111111111111",
-			fakeSSServer: fake.VirtualMachineScaleSetsServer{
-				NewListAllPager: func(options *armcompute.VirtualMachineScaleSetsClientListAllOptions) (resp azfake.PagerResponder[armcompute.VirtualMachineScaleSetsClientListAllResponse]) {
-
-					page := armcompute.VirtualMachineScaleSetsClientListAllResponse{
-						VirtualMachineScaleSetListWithLinkResult: armcompute.VirtualMachineScaleSetListWithLinkResult{
-							Value: []*armcompute.VirtualMachineScaleSet{
-								&scaleSet,
-							},
-						},
-					}
-					resp.AddPage(http.StatusOK, page, nil)
-					return
-				},
-			},
-			fakeVMServer: fake.VirtualMachineScaleSetVMsServer{
-				NewListPager: func(resourceGroup string, vmScaleSetName string, options *armcompute.VirtualMachineScaleSetVMsClientListOptions) (resp azfake.PagerResponder[armcompute.VirtualMachineScaleSetVMsClientListResponse]) {
-
-					page := armcompute.VirtualMachineScaleSetVMsClientListResponse{
-						VirtualMachineScaleSetVMListResult: armcompute.VirtualMachineScaleSetVMListResult{
-							Value: []*armcompute.VirtualMachineScaleSetVM{
-								&scaleSetVm1,
-								&scaleSetVm2,
-							},
-						},
-					}
-					resp.AddPage(http.StatusOK, page, nil)
-					return
-				},
-				GetInstanceView: func(ctx context.Context, resourceGroupName, vmScaleSetName, instanceId string, options *armcompute.VirtualMachineScaleSetVMsClientGetInstanceViewOptions) (resp azfake.Responder[armcompute.VirtualMachineScaleSetVMsClientGetInstanceViewResponse], errResp azfake.ErrorResponder) {
-
-					response := armcompute.VirtualMachineScaleSetVMsClientGetInstanceViewResponse{
-						VirtualMachineScaleSetVMInstanceView: armcompute.VirtualMachineScaleSetVMInstanceView{
-							Statuses: []*armcompute.InstanceViewStatus{
-								&status1,
-								&status2,
-							},
-						},
-					}
-					resp.SetResponse(http.StatusOK, response, nil)
-					return
-				},
+			fakeSSServer:   scaleSetServer,
+			fakeVMServer:   vmServer(""),
+			expectedEvents: []beat.Event{
+				scaleSetEvent,
+				instanceEvent(instanceVMId1, ssVm1Name, "westeurope", "VM Running"),
+				instanceEvent(instanceVMId2, ssVm2Name, "northeurope", "VM Running"),
 			},
+		},
+		{
+			name:           "Test with the instance view of an instance failing",
+			subscriptionId: "12cabcb4-86e8-404f-111111111111",
+			assetTypes:     []string{"azure.vm.instance"},
+			fakeSSServer:   scaleSetServer,
+			fakeVMServer:   vmServer("1"),
 			expectedEvents: []beat.Event{
-				{
-					Fields: mapstr.M{
-						"asset.ean":                     "host:" + instanceVMId1,
-						"asset.id":                      instanceVMId1,
-						"asset.name":                    ssVm1Name,
-						"asset.type":                    "azure.vm.instance",
-						"asset.kind":                    "host",
-						"asset.metadata.state":          "VM Running",
-						"asset.metadata.resource_group": "TESTVM",
-						"cloud.account.id":              "12cabcb4-86e8-404f-111111111111",
-						"cloud.provider":                "azure",
-						"cloud.region":                  "westeurope",
-					},
-					Meta: mapstr.M{
-						"index": internal.GetDefaultIndexName(),
-					},
-				},
-				{
-					Fields: mapstr.M{
-						"asset.ean":                     "host:" + instanceVMId2,
-						"asset.id":                      instanceVMId2,
-						"asset.name":                    ssVm2Name,
-						"asset.type":                    "azure.vm.instance",
-						"asset.kind":                    "host",
-						"asset.metadata.state":          "VM Running",
-						"asset.metadata.resource_group": "TESTVM",
-						"cloud.account.id":              "12cabcb4-86e8-404f-111111111111",
-						"cloud.provider":                "azure",
-						"cloud.region":                  "northeurope",
-					},
-					Meta: mapstr.M{
-						"index": internal.GetDefaultIndexName(),
-					},
-				},
+				instanceEvent(instanceVMId1, ssVm1Name, "westeurope", "VM Running"),
+				instanceEvent(instanceVMId2, ssVm2Name, "northeurope", "unknown"),
 			},
 		},
+		{
+			name:           "Test with the ScaleSet in another region",
+			regions:        []string{"eastus"},
+			subscriptionId: "12cabcb4-86e8-404f-111111111111",
+			fakeSSServer:   scaleSetServer,
+			fakeVMServer:   vmServer(""),
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			publisher := testutil.NewInMemoryPublisher()
@@ -366,20 +459,20 @@ func TestAssetsAzure_collectAzureScaleSetsVMAssets(t *testing.T) {
 			ctx := context.Background()
 			logger := logp.NewLogger("test")
 
-			vmclient, _ := armcompute.NewVirtualMachineScaleSetVMsClient("subscriptionID", azfake.NewTokenCredential(), &arm.ClientOptions{
+			vmclient, _ := armcompute.NewVirtualMachineScaleSetVMsClient("subscriptionID", &azfake.TokenCredential{}, &arm.ClientOptions{
 				ClientOptions: azcore.ClientOptions{
 					Transport: fake.NewVirtualMachineScaleSetVMsServerTransport(&tt.fakeVMServer),
 				},
 			})
 
-			ssclient, err := armcompute.NewVirtualMachineScaleSetsClient("subscriptionID", azfake.NewTokenCredential(), &arm.ClientOptions{
+			ssclient, err := armcompute.NewVirtualMachineScaleSetsClient("subscriptionID", &azfake.TokenCredential{}, &arm.ClientOptions{
 				ClientOptions: azcore.ClientOptions{
 					Transport: fake.NewVirtualMachineScaleSetsServerTransport(&tt.fakeSSServer),
 				},
 			})
 			assert.NoError(t, err)
 
-			err = collectAzureScaleSetsVMAssets(ctx, vmclient, ssclient, tt.subscriptionId, tt.regions, logger, publisher)
+			err = collectAzureScaleSetsVMAssets(ctx, vmclient, ssclient, tt.subscriptionId, tt.regions, tt.assetTypes, logger, publisher)
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedEvents, publisher.Events)
 		})
@@ -0,0 +1,93 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gcp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/elastic/elastic-agent-libs/monitoring"
+	"github.com/elastic/go-freelru"
+)
+
+const assetCacheSize = 8192
+
+// assetCache is an expiring cache of GCP resources, keyed by self link, which
+// is shared by the collectors of a single input. It is safe for concurrent use.
+// Lookups are counted as hits and misses so that relationships left empty
+// because of a cold cache can be spotted in the input metrics.
+type assetCache[V any] struct {
+	mu     sync.Mutex
+	lru    *freelru.LRU[string, V]
+	hits   *monitoring.Uint
+	misses *monitoring.Uint
+}
+
+// newAssetCache creates a cache, registering its metrics under name in reg.
+// A nil reg creates unregistered metrics.
+func newAssetCache[V any](reg *monitoring.Registry, name string) *assetCache[V] {
+	lru, _ := freelru.New[string, V](assetCacheSize, hashStringXXHASH)
+	if reg == nil {
+		reg = monitoring.NewRegistry()
+	}
+	return &assetCache[V]{
+		lru:    lru,
+		hits:   monitoring.NewUint(reg, name+".hits"),
+		misses: monitoring.NewUint(reg, name+".misses"),
+	}
+}
+
+// Get looks up a resource by self link, recording a hit or a miss.
+func (c *assetCache[V]) Get(selfLink string) (V, bool) {
+	c.mu.Lock()
+	v, ok := c.lru.Get(selfLink)
+	c.mu.Unlock()
+
+	if ok {
+		c.hits.Inc()
+	} else {
+		c.misses.Inc()
+	}
+	return v, ok
+}
+
+func (c *assetCache[V]) AddWithExpire(selfLink string, v V, lifetime time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.AddWithExpire(selfLink, v, lifetime)
+}
+
+// Values returns all the resources which have not expired yet. It is counted
+// as a single lookup, which misses when the cache is empty.
+func (c *assetCache[V]) Values() []V {
+	c.mu.Lock()
+	var values []V
+	for _, k := range c.lru.Keys() {
+		if v, ok := c.lru.Peek(k); ok {
+			values = append(values, v)
+		}
+	}
+	c.mu.Unlock()
+
+	if len(values) != 0 {
+		c.hits.Inc()
+	} else {
+		c.misses.Inc()
+	}
+	return values
+}
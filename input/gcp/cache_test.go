@@ -0,0 +1,73 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gcp
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+func TestAssetCache(t *testing.T) {
+	reg := monitoring.NewRegistry()
+	cache := getVpcCache(reg)
+
+	assert.Empty(t, cache.Values())
+
+	cache.AddWithExpire("my_vpc", &vpc{ID: "1"}, time.Minute)
+	cache.AddWithExpire("my_expired_vpc", &vpc{ID: "2"}, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	v, ok := cache.Get("my_vpc")
+	assert.True(t, ok)
+	assert.Equal(t, "1", v.ID)
+
+	_, ok = cache.Get("my_expired_vpc")
+	assert.False(t, ok)
+
+	_, ok = cache.Get("my_unknown_vpc")
+	assert.False(t, ok)
+
+	assert.Equal(t, []*vpc{{ID: "1"}}, cache.Values())
+
+	snapshot := monitoring.CollectFlatSnapshot(reg, monitoring.Full, false)
+	assert.Equal(t, int64(2), snapshot.Ints["vpc.hits"])
+	assert.Equal(t, int64(3), snapshot.Ints["vpc.misses"])
+}
+
+func TestAssetCache_Concurrent(t *testing.T) {
+	cache := getSubnetCache(nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.AddWithExpire("my_subnet", &subnet{ID: "1"}, time.Minute)
+			cache.Get("my_subnet")
+			cache.Values()
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, cache.Values(), 1)
+}
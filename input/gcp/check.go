@@ -0,0 +1,278 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/option"
+	"google.golang.org/api/serviceusage/v1"
+
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// assetTypeRequirement lists the API that must be enabled, and the
+// permissions that must be granted, to collect an asset type. The API
+// is left empty when it is only called for resources which exist only
+// if it is enabled.
+type assetTypeRequirement struct {
+	Service     string
+	Permissions []string
+}
+
+// collector describes a fetch of resources run by collectAll whenever one of its asset
+// types is enabled, and what it requires. The same asset types gate the collection and
+// the check, so that the check covers every API call made to collect an asset type.
+type collector struct {
+	AssetTypes   []string
+	Requirements []assetTypeRequirement
+}
+
+// isEnabled returns whether the collector runs for the configured asset types.
+func (c collector) isEnabled(assetTypes []string) bool {
+	return internal.IsAnyTypeEnabled(assetTypes, c.AssetTypes...)
+}
+
+var (
+	// the VPCs are fetched for all the asset types parented to them.
+	vpcCollector = collector{
+		AssetTypes: []string{"gcp.vpc", "k8s.cluster", "gcp.gke.nodepool", "gcp.compute.instance_group", "gcp.cloudrun.service",
+			"gcp.cloudfunctions.function", "gcp.compute.forwarding_rule", "gcp.compute.backend_service", "gcp.compute.firewall"},
+		Requirements: []assetTypeRequirement{
+			{Service: "compute.googleapis.com", Permissions: []string{"compute.networks.list"}},
+		},
+	}
+	// the subnets are fetched to resolve the parents of the instances, which are fetched for the disks.
+	subnetCollector = collector{
+		AssetTypes: []string{"gcp.subnet", "gcp.compute.instance", "gcp.compute.disk"},
+		Requirements: []assetTypeRequirement{
+			{Service: "compute.googleapis.com", Permissions: []string{"compute.subnetworks.list"}},
+		},
+	}
+	computeCollector = collector{
+		AssetTypes: []string{"gcp.compute.instance", "gcp.compute.disk"},
+		Requirements: []assetTypeRequirement{
+			{Service: "compute.googleapis.com", Permissions: []string{"compute.instances.list"}},
+		},
+	}
+	// the clusters, node pools and instance groups are resolved together, whichever is enabled.
+	gkeCollector = collector{
+		AssetTypes: []string{"k8s.cluster", "gcp.gke.nodepool", "gcp.compute.instance_group"},
+		Requirements: []assetTypeRequirement{
+			{Service: "container.googleapis.com", Permissions: []string{"container.clusters.list"}},
+			{Service: "compute.googleapis.com", Permissions: []string{"compute.instances.list", "compute.instanceGroupManagers.get"}},
+		},
+	}
+	diskCollector = collector{
+		AssetTypes: []string{"gcp.compute.disk"},
+		Requirements: []assetTypeRequirement{
+			{Service: "compute.googleapis.com", Permissions: []string{"compute.disks.list"}},
+		},
+	}
+	// the VPC access connectors are retrieved to parent the services and functions to their VPC.
+	cloudRunCollector = collector{
+		AssetTypes: []string{"gcp.cloudrun.service"},
+		Requirements: []assetTypeRequirement{
+			{Service: "run.googleapis.com", Permissions: []string{"run.services.list"}},
+			{Permissions: []string{"vpcaccess.connectors.get"}},
+		},
+	}
+	cloudFunctionCollector = collector{
+		AssetTypes: []string{"gcp.cloudfunctions.function"},
+		Requirements: []assetTypeRequirement{
+			{Service: "cloudfunctions.googleapis.com", Permissions: []string{"cloudfunctions.functions.list"}},
+			{Permissions: []string{"vpcaccess.connectors.get"}},
+		},
+	}
+	// the backend services are fetched for the forwarding rules too, to resolve their children.
+	backendServiceCollector = collector{
+		AssetTypes: []string{"gcp.compute.forwarding_rule", "gcp.compute.backend_service"},
+		Requirements: []assetTypeRequirement{
+			{Service: "compute.googleapis.com", Permissions: []string{"compute.backendServices.list", "compute.instanceGroupManagers.get"}},
+		},
+	}
+	forwardingRuleCollector = collector{
+		AssetTypes: []string{"gcp.compute.forwarding_rule"},
+		Requirements: []assetTypeRequirement{
+			{Service: "compute.googleapis.com", Permissions: []string{"compute.forwardingRules.list", "compute.targetHttpProxies.list",
+				"compute.targetHttpsProxies.list", "compute.targetTcpProxies.list", "compute.targetSslProxies.list", "compute.urlMaps.list"}},
+		},
+	}
+	firewallCollector = collector{
+		AssetTypes: []string{"gcp.compute.firewall"},
+		Requirements: []assetTypeRequirement{
+			{Service: "compute.googleapis.com", Permissions: []string{"compute.firewalls.list"}},
+		},
+	}
+
+	collectors = []collector{vpcCollector, subnetCollector, computeCollector, gkeCollector, diskCollector, cloudRunCollector,
+		cloudFunctionCollector, backendServiceCollector, forwardingRuleCollector, firewallCollector}
+)
+
+// projectCheckAPIClient retrieves, for a project, which of the given services are
+// enabled and which of the given permissions are granted to the caller.
+type projectCheckAPIClient struct {
+	EnabledServices    func(ctx context.Context, project string, services []string) ([]string, error)
+	GrantedPermissions func(ctx context.Context, project string, permissions []string) ([]string, error)
+}
+
+func newProjectCheckAPIClient(ctx context.Context, opts []option.ClientOption) (projectCheckAPIClient, error) {
+	serviceUsage, err := serviceusage.NewService(ctx, opts...)
+	if err != nil {
+		return projectCheckAPIClient{}, fmt.Errorf("error creating Service Usage client: %w", err)
+	}
+	resourceManager, err := cloudresourcemanager.NewService(ctx, opts...)
+	if err != nil {
+		return projectCheckAPIClient{}, fmt.Errorf("error creating Resource Manager client: %w", err)
+	}
+
+	return projectCheckAPIClient{
+		EnabledServices: func(ctx context.Context, project string, services []string) ([]string, error) {
+			var names []string
+			for _, s := range services {
+				names = append(names, "projects/"+project+"/services/"+s)
+			}
+			resp, err := serviceUsage.Services.BatchGet("projects/" + project).Names(names...).Context(ctx).Do()
+			if err != nil {
+				return nil, err
+			}
+			var enabled []string
+			for _, s := range resp.Services {
+				if s.State == "ENABLED" {
+					enabled = append(enabled, getResourceNameFromURL(s.Name))
+				}
+			}
+			return enabled, nil
+		},
+		GrantedPermissions: func(ctx context.Context, project string, permissions []string) ([]string, error) {
+			req := &cloudresourcemanager.TestIamPermissionsRequest{Permissions: permissions}
+			resp, err := resourceManager.Projects.TestIamPermissions(project, req).Context(ctx).Do()
+			if err != nil {
+				return nil, err
+			}
+			return resp.Permissions, nil
+		},
+	}, nil
+}
+
+// checkCredentialsFile verifies that the configured credentials file exists
+// and holds valid Google credentials.
+func checkCredentialsFile(ctx context.Context, path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading credentials file: %w", err)
+	}
+	if _, err := google.CredentialsFromJSON(ctx, data, cloudPlatformScope); err != nil {
+		return fmt.Errorf("invalid credentials file %s: %w", path, err)
+	}
+	return nil
+}
+
+// getRequirements returns the services and permissions needed
+// to collect the enabled asset types, sorted and deduplicated.
+func getRequirements(assetTypes []string) ([]string, []string) {
+	services := map[string]struct{}{}
+	permissions := map[string]struct{}{}
+	for _, c := range collectors {
+		if !c.isEnabled(assetTypes) {
+			continue
+		}
+		for _, r := range c.Requirements {
+			if r.Service != "" {
+				services[r.Service] = struct{}{}
+			}
+			for _, p := range r.Permissions {
+				permissions[p] = struct{}{}
+			}
+		}
+	}
+	return sortedKeys(services), sortedKeys(permissions)
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkProjects verifies, for every configured project, that the APIs needed by the enabled
+// asset types are enabled and that the required permissions are granted. All the problems
+// found are reported, grouped by project.
+func checkProjects(ctx context.Context, cfg config, client projectCheckAPIClient, log *logp.Logger) error {
+	if len(cfg.Projects) == 0 {
+		return errors.New("no projects configured")
+	}
+
+	services, permissions := getRequirements(cfg.AssetTypes)
+	var errs []error
+	for _, p := range cfg.Projects {
+		var problems []string
+
+		enabled, err := client.EnabledServices(ctx, p, services)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("unable to check enabled APIs: %v", err))
+		} else if missing := difference(services, enabled); len(missing) > 0 {
+			problems = append(problems, "APIs not enabled: "+strings.Join(missing, ", "))
+		}
+
+		granted, err := client.GrantedPermissions(ctx, p, permissions)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("unable to check permissions: %v", err))
+		} else if missing := difference(permissions, granted); len(missing) > 0 {
+			problems = append(problems, "permissions not granted: "+strings.Join(missing, ", "))
+		}
+
+		if len(problems) > 0 {
+			errs = append(errs, fmt.Errorf("project %s: %s", p, strings.Join(problems, "; ")))
+			continue
+		}
+		log.Infof("project %s: all required APIs are enabled and permissions granted", p)
+	}
+	return errors.Join(errs...)
+}
+
+// difference returns the elements of want which are not in got.
+func difference(want []string, got []string) []string {
+	found := make(map[string]struct{}, len(got))
+	for _, g := range got {
+		found[g] = struct{}{}
+	}
+	var missing []string
+	for _, w := range want {
+		if _, ok := found[w]; !ok {
+			missing = append(missing, w)
+		}
+	}
+	return missing
+}
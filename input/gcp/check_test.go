@@ -0,0 +1,173 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gcp
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+type ProjectCheckClientStub struct {
+	EnabledServices      map[string][]string
+	GrantedPermissions   map[string][]string
+	ReturnServicesError  error
+	ReturnPermissionsErr error
+}
+
+func (s *ProjectCheckClientStub) client() projectCheckAPIClient {
+	return projectCheckAPIClient{
+		EnabledServices: func(ctx context.Context, project string, services []string) ([]string, error) {
+			return s.EnabledServices[project], s.ReturnServicesError
+		},
+		GrantedPermissions: func(ctx context.Context, project string, permissions []string) ([]string, error) {
+			return s.GrantedPermissions[project], s.ReturnPermissionsErr
+		},
+	}
+}
+
+func TestCheckProjects(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+
+		cfg           config
+		client        ProjectCheckClientStub
+		expectedError string
+	}{
+		{
+			name: "with no project specified",
+
+			cfg:           config{},
+			expectedError: "no projects configured",
+		},
+		{
+			name: "with all APIs enabled and permissions granted",
+
+			cfg: config{
+				BaseConfig: internal.BaseConfig{
+					AssetTypes: []string{"gcp.cloudrun.service", "gcp.vpc"},
+				},
+				Projects: []string{"my_project"},
+			},
+			client: ProjectCheckClientStub{
+				EnabledServices: map[string][]string{
+					"my_project": {"compute.googleapis.com", "run.googleapis.com"},
+				},
+				GrantedPermissions: map[string][]string{
+					"my_project": {"compute.networks.list", "run.services.list", "vpcaccess.connectors.get"},
+				},
+			},
+		},
+		{
+			name: "with a project missing APIs and permissions",
+
+			cfg: config{
+				BaseConfig: internal.BaseConfig{
+					AssetTypes: []string{"gcp.cloudrun.service", "gcp.vpc"},
+				},
+				Projects: []string{"my_project", "my_second_project"},
+			},
+			client: ProjectCheckClientStub{
+				EnabledServices: map[string][]string{
+					"my_project":        {"compute.googleapis.com", "run.googleapis.com"},
+					"my_second_project": {"compute.googleapis.com"},
+				},
+				GrantedPermissions: map[string][]string{
+					"my_project": {"compute.networks.list", "run.services.list", "vpcaccess.connectors.get"},
+				},
+			},
+			expectedError: "project my_second_project: APIs not enabled: run.googleapis.com; permissions not granted: compute.networks.list, run.services.list, vpcaccess.connectors.get",
+		},
+		{
+			name: "with API errors",
+
+			cfg: config{
+				BaseConfig: internal.BaseConfig{
+					AssetTypes: []string{"gcp.vpc"},
+				},
+				Projects: []string{"my_project"},
+			},
+			client: ProjectCheckClientStub{
+				ReturnServicesError:  errors.New("service usage API disabled"),
+				ReturnPermissionsErr: errors.New("project not found"),
+			},
+			expectedError: "project my_project: unable to check enabled APIs: service usage API disabled; unable to check permissions: project not found",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkProjects(context.Background(), tt.cfg, tt.client.client(), logp.NewLogger("mylogger"))
+			if tt.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestGetRequirements(t *testing.T) {
+	services, permissions := getRequirements([]string{"k8s.cluster", "gcp.gke.nodepool"})
+	assert.Equal(t, []string{"compute.googleapis.com", "container.googleapis.com"}, services)
+	assert.Equal(t, []string{"compute.instanceGroupManagers.get", "compute.instances.list", "compute.networks.list", "container.clusters.list"}, permissions)
+}
+
+// TestGetRequirements_Collectors lists, for each asset type, the permissions of all the API calls
+// collectAll makes when only this asset type is enabled, including the fetches of the VPCs, subnets
+// and instances used to resolve its relationships.
+func TestGetRequirements_Collectors(t *testing.T) {
+	for _, tt := range []struct {
+		assetType   string
+		permissions []string
+	}{
+		{assetType: "gcp.vpc", permissions: []string{"compute.networks.list"}},
+		{assetType: "gcp.subnet", permissions: []string{"compute.subnetworks.list"}},
+		{assetType: "gcp.compute.instance", permissions: []string{"compute.instances.list", "compute.subnetworks.list"}},
+		{assetType: "k8s.cluster", permissions: []string{"compute.instanceGroupManagers.get", "compute.instances.list", "compute.networks.list", "container.clusters.list"}},
+		{assetType: "gcp.gke.nodepool", permissions: []string{"compute.instanceGroupManagers.get", "compute.instances.list", "compute.networks.list", "container.clusters.list"}},
+		{assetType: "gcp.compute.instance_group", permissions: []string{"compute.instanceGroupManagers.get", "compute.instances.list", "compute.networks.list", "container.clusters.list"}},
+		{assetType: "gcp.compute.disk", permissions: []string{"compute.disks.list", "compute.instances.list", "compute.subnetworks.list"}},
+		{assetType: "gcp.cloudrun.service", permissions: []string{"compute.networks.list", "run.services.list", "vpcaccess.connectors.get"}},
+		{assetType: "gcp.cloudfunctions.function", permissions: []string{"cloudfunctions.functions.list", "compute.networks.list", "vpcaccess.connectors.get"}},
+		{assetType: "gcp.compute.forwarding_rule", permissions: []string{"compute.backendServices.list", "compute.forwardingRules.list", "compute.instanceGroupManagers.get", "compute.networks.list",
+			"compute.targetHttpProxies.list", "compute.targetHttpsProxies.list", "compute.targetSslProxies.list", "compute.targetTcpProxies.list", "compute.urlMaps.list"}},
+		{assetType: "gcp.compute.backend_service", permissions: []string{"compute.backendServices.list", "compute.instanceGroupManagers.get", "compute.networks.list"}},
+		{assetType: "gcp.compute.firewall", permissions: []string{"compute.firewalls.list", "compute.networks.list"}},
+	} {
+		t.Run(tt.assetType, func(t *testing.T) {
+			_, permissions := getRequirements([]string{tt.assetType})
+			assert.Equal(t, tt.permissions, permissions)
+		})
+	}
+}
+
+func TestCheckCredentialsFile(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.json")
+	assert.NoError(t, os.WriteFile(invalid, []byte("not json"), 0o600))
+
+	assert.NoError(t, checkCredentialsFile(context.Background(), ""))
+	assert.ErrorContains(t, checkCredentialsFile(context.Background(), filepath.Join(dir, "missing.json")), "error reading credentials file")
+	assert.ErrorContains(t, checkCredentialsFile(context.Background(), invalid), "invalid credentials file")
+}
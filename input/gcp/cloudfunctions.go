@@ -0,0 +1,131 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gcp
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/functions/apiv2/functionspb"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/iterator"
+
+	"github.com/elastic/assetbeat/input/internal"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type CloudFunctionIterator interface {
+	Next() (*functionspb.Function, error)
+}
+
+type listCloudFunctionsAPIClient struct {
+	ListFunctions func(ctx context.Context, req *functionspb.ListFunctionsRequest, opts ...gax.CallOption) CloudFunctionIterator
+}
+
+type cloudFunction struct {
+	ID       string
+	Region   string
+	Account  string
+	Parents  []string
+	Labels   map[string]string
+	Metadata mapstr.M
+	Name     string
+}
+
+func collectCloudFunctionAssets(ctx context.Context, cfg config, vpcAssetCache *assetCache[*vpc], client listCloudFunctionsAPIClient, connectorClient getVPCConnectorAPIClient, publisher stateless.Publisher, log *logp.Logger) error {
+	functions, err := getAllCloudFunctions(ctx, cfg, client, newVPCConnectorResolver(connectorClient, vpcAssetCache, log))
+	if err != nil {
+		return err
+	}
+
+	assetType := "gcp.cloudfunctions.function"
+	assetKind := "function"
+	log.Debug("Publishing Cloud Functions")
+
+	for _, function := range functions {
+		options := []internal.AssetOption{
+			internal.WithAssetCloudProvider("gcp"),
+			internal.WithAssetRegion(function.Region),
+			internal.WithAssetAccountID(function.Account),
+			internal.WithAssetKindAndID(assetKind, function.ID),
+			internal.WithAssetType(assetType),
+			internal.WithAssetParents(function.Parents),
+			WithAssetLabels(internal.ToMapstr(function.Labels)),
+			internal.WithAssetMetadata(function.Metadata),
+		}
+
+		if function.Name != "" {
+			options = append(options, internal.WithAssetName(function.Name))
+		}
+		internal.Publish(publisher, nil, options...)
+	}
+
+	return nil
+}
+
+func getAllCloudFunctions(ctx context.Context, cfg config, client listCloudFunctionsAPIClient, connectors *vpcConnectorResolver) ([]cloudFunction, error) {
+	var functions []cloudFunction
+
+	for _, p := range cfg.Projects {
+		for _, parent := range getLocationParents(p, cfg.Regions) {
+			req := &functionspb.ListFunctionsRequest{
+				Parent: parent,
+			}
+			it := client.ListFunctions(ctx, req)
+			for {
+				f, err := it.Next()
+				if err == iterator.Done {
+					break
+				}
+				if err != nil {
+					return nil, fmt.Errorf("error retrieving Cloud Functions for project %s: %w", p, err)
+				}
+
+				// the function is parented to the VPC of its VPC access connector
+				var parents []string
+				region := getLocationFromResourceName(f.GetName())
+				if vpcID := connectors.getVpcID(ctx, p, region, f.GetServiceConfig().GetVpcConnector()); vpcID != "" {
+					parents = append(parents, "network:"+vpcID)
+				}
+
+				// Cloud Functions do not expose a unique id, the fully qualified
+				// resource name is used instead.
+				functions = append(functions, cloudFunction{
+					ID:      f.GetName(),
+					Region:  region,
+					Account: p,
+					Parents: parents,
+					Labels:  f.GetLabels(),
+					Metadata: mapstr.M{
+						"state":           f.GetState().String(),
+						"runtime":         f.GetBuildConfig().GetRuntime(),
+						"environment":     f.GetEnvironment().String(),
+						"latest_revision": f.GetServiceConfig().GetRevision(),
+						"url":             f.GetUrl(),
+						"vpc_connector":   f.GetServiceConfig().GetVpcConnector(),
+					},
+					Name: getResourceNameFromURL(f.GetName()),
+				})
+			}
+		}
+	}
+
+	return functions, nil
+}
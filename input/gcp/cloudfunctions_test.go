@@ -0,0 +1,197 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/functions/apiv2/functionspb"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/iterator"
+
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/assetbeat/input/testutil"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type StubCloudFunctionListIterator struct {
+	iterCounter         int
+	ReturnFunctionsList []*functionspb.Function
+	ReturnFunctionError error
+}
+
+func (it *StubCloudFunctionListIterator) Next() (*functionspb.Function, error) {
+
+	if it.ReturnFunctionError != nil {
+		return &functionspb.Function{}, it.ReturnFunctionError
+	}
+
+	if it.iterCounter == len(it.ReturnFunctionsList) {
+		return &functionspb.Function{}, iterator.Done
+	}
+
+	function := it.ReturnFunctionsList[it.iterCounter]
+	it.iterCounter++
+
+	return function, nil
+}
+
+type CloudFunctionsClientStub struct {
+	FunctionListIterator map[string]*StubCloudFunctionListIterator
+}
+
+func (s *CloudFunctionsClientStub) ListFunctions(ctx context.Context, req *functionspb.ListFunctionsRequest, opts ...gax.CallOption) CloudFunctionIterator {
+	return s.FunctionListIterator[req.Parent]
+}
+
+func TestCollectCloudFunctionAssets(t *testing.T) {
+	var parents []string
+	for _, tt := range []struct {
+		name           string
+		cfg            config
+		functions      map[string]*StubCloudFunctionListIterator
+		expectedEvents []beat.Event
+		expectedError  bool
+	}{
+		{
+			name: "with no project specified",
+			cfg:  config{},
+		},
+		{
+			name: "multiple projects, multiple functions",
+			cfg: config{
+				Projects: []string{"my_project", "my_second_project"},
+			},
+			functions: map[string]*StubCloudFunctionListIterator{
+				"projects/my_project/locations/-": {
+					ReturnFunctionsList: []*functionspb.Function{
+						{
+							Name:        "projects/my_project/locations/europe-west1/functions/my-function",
+							State:       functionspb.Function_ACTIVE,
+							Environment: functionspb.Environment_GEN_2,
+							Labels:      map[string]string{"team": "obs"},
+							Url:         "https://europe-west1-my_project.cloudfunctions.net/my-function",
+							BuildConfig: &functionspb.BuildConfig{Runtime: "go121"},
+							ServiceConfig: &functionspb.ServiceConfig{
+								Revision:     "my-function-00001-abc",
+								VpcConnector: "projects/my_project/locations/europe-west1/connectors/my-connector",
+							},
+						},
+					},
+				},
+				"projects/my_second_project/locations/-": {
+					ReturnFunctionsList: []*functionspb.Function{
+						{
+							Name:        "projects/my_second_project/locations/us-central1/functions/my-other-function",
+							State:       functionspb.Function_FAILED,
+							Environment: functionspb.Environment_GEN_1,
+							BuildConfig: &functionspb.BuildConfig{Runtime: "python311"},
+							ServiceConfig: &functionspb.ServiceConfig{
+								VpcConnector: "projects/my_second_project/locations/us-central1/connectors/unknown",
+							},
+						},
+					},
+				},
+			},
+			expectedEvents: []beat.Event{
+				{
+					Fields: mapstr.M{
+						"asset.ean":                      "function:projects/my_project/locations/europe-west1/functions/my-function",
+						"asset.id":                       "projects/my_project/locations/europe-west1/functions/my-function",
+						"asset.name":                     "my-function",
+						"asset.type":                     "gcp.cloudfunctions.function",
+						"asset.kind":                     "function",
+						"asset.parents":                  []string{"network:1"},
+						"asset.metadata.labels.team":     "obs",
+						"asset.metadata.state":           "ACTIVE",
+						"asset.metadata.runtime":         "go121",
+						"asset.metadata.environment":     "GEN_2",
+						"asset.metadata.latest_revision": "my-function-00001-abc",
+						"asset.metadata.url":             "https://europe-west1-my_project.cloudfunctions.net/my-function",
+						"asset.metadata.vpc_connector":   "projects/my_project/locations/europe-west1/connectors/my-connector",
+						"cloud.account.id":               "my_project",
+						"cloud.provider":                 "gcp",
+						"cloud.region":                   "europe-west1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+				{
+					Fields: mapstr.M{
+						"asset.ean":                      "function:projects/my_second_project/locations/us-central1/functions/my-other-function",
+						"asset.id":                       "projects/my_second_project/locations/us-central1/functions/my-other-function",
+						"asset.name":                     "my-other-function",
+						"asset.type":                     "gcp.cloudfunctions.function",
+						"asset.kind":                     "function",
+						"asset.parents":                  parents,
+						"asset.metadata.state":           "FAILED",
+						"asset.metadata.runtime":         "python311",
+						"asset.metadata.environment":     "GEN_1",
+						"asset.metadata.latest_revision": "",
+						"asset.metadata.url":             "",
+						"asset.metadata.vpc_connector":   "projects/my_second_project/locations/us-central1/connectors/unknown",
+						"cloud.account.id":               "my_second_project",
+						"cloud.provider":                 "gcp",
+						"cloud.region":                   "us-central1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+			},
+		},
+		{
+			name: "with an API error",
+			cfg: config{
+				Projects: []string{"my_project"},
+			},
+			functions: map[string]*StubCloudFunctionListIterator{
+				"projects/my_project/locations/-": {
+					ReturnFunctionError: errors.New("permission denied"),
+				},
+			},
+			expectedError: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := testutil.NewInMemoryPublisher()
+
+			ctx := context.Background()
+			client := CloudFunctionsClientStub{FunctionListIterator: tt.functions}
+			listClient := listCloudFunctionsAPIClient{
+				ListFunctions: func(ctx context.Context, req *functionspb.ListFunctionsRequest, opts ...gax.CallOption) CloudFunctionIterator {
+					return client.ListFunctions(ctx, req, opts...)
+				},
+			}
+			log := logp.NewLogger("mylogger")
+			err := collectCloudFunctionAssets(ctx, tt.cfg, getTestVpcCache(), listClient, getTestVPCConnectorClient(), publisher, log)
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedEvents, publisher.Events)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gcp
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/googleapis/gax-go/v2"
+	"golang.org/x/exp/slices"
+	"google.golang.org/api/iterator"
+
+	"github.com/elastic/assetbeat/input/internal"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type CloudRunServiceIterator interface {
+	Next() (*runpb.Service, error)
+}
+
+type listCloudRunServicesAPIClient struct {
+	ListServices func(ctx context.Context, req *runpb.ListServicesRequest, opts ...gax.CallOption) CloudRunServiceIterator
+}
+
+type cloudRunService struct {
+	ID       string
+	Region   string
+	Account  string
+	Parents  []string
+	Labels   map[string]string
+	Metadata mapstr.M
+	Name     string
+}
+
+func collectCloudRunAssets(ctx context.Context, cfg config, vpcAssetCache *assetCache[*vpc], client listCloudRunServicesAPIClient, connectorClient getVPCConnectorAPIClient, publisher stateless.Publisher, log *logp.Logger) error {
+	services, err := getAllCloudRunServices(ctx, cfg, vpcAssetCache, client, newVPCConnectorResolver(connectorClient, vpcAssetCache, log))
+	if err != nil {
+		return err
+	}
+
+	assetType := "gcp.cloudrun.service"
+	assetKind := "service"
+	log.Debug("Publishing Cloud Run services")
+
+	for _, service := range services {
+		options := []internal.AssetOption{
+			internal.WithAssetCloudProvider("gcp"),
+			internal.WithAssetRegion(service.Region),
+			internal.WithAssetAccountID(service.Account),
+			internal.WithAssetKindAndID(assetKind, service.ID),
+			internal.WithAssetType(assetType),
+			internal.WithAssetParents(service.Parents),
+			WithAssetLabels(internal.ToMapstr(service.Labels)),
+			internal.WithAssetMetadata(service.Metadata),
+		}
+
+		if service.Name != "" {
+			options = append(options, internal.WithAssetName(service.Name))
+		}
+		internal.Publish(publisher, nil, options...)
+	}
+
+	return nil
+}
+
+func getAllCloudRunServices(ctx context.Context, cfg config, vpcAssetCache *assetCache[*vpc], client listCloudRunServicesAPIClient, connectors *vpcConnectorResolver) ([]cloudRunService, error) {
+	var services []cloudRunService
+
+	for _, p := range cfg.Projects {
+		for _, parent := range getLocationParents(p, cfg.Regions) {
+			req := &runpb.ListServicesRequest{
+				Parent: parent,
+			}
+			it := client.ListServices(ctx, req)
+			for {
+				s, err := it.Next()
+				if err == iterator.Done {
+					break
+				}
+				if err != nil {
+					return nil, fmt.Errorf("error retrieving Cloud Run services for project %s: %w", p, err)
+				}
+
+				// the service is parented to the VPC of its VPC access connector, and to the VPCs
+				// it has direct egress to
+				var parents []string
+				var executionEnvironment, connector string
+				region := getLocationFromResourceName(s.GetName())
+				if t := s.GetTemplate(); t != nil {
+					connector = t.GetVpcAccess().GetConnector()
+					if vpcID := connectors.getVpcID(ctx, p, region, connector); vpcID != "" {
+						parents = append(parents, "network:"+vpcID)
+					}
+					for _, ni := range t.GetVpcAccess().GetNetworkInterfaces() {
+						vpcID := getVpcIdFromLink(getNetSelfLinkFromName(p, ni.GetNetwork()), vpcAssetCache)
+						if vpcID != "" && !slices.Contains(parents, "network:"+vpcID) {
+							parents = append(parents, "network:"+vpcID)
+						}
+					}
+					executionEnvironment = t.GetExecutionEnvironment().String()
+				}
+
+				services = append(services, cloudRunService{
+					ID:      s.GetUid(),
+					Region:  region,
+					Account: p,
+					Parents: parents,
+					Labels:  s.GetLabels(),
+					Metadata: mapstr.M{
+						"state":                 s.GetTerminalCondition().GetState().String(),
+						"execution_environment": executionEnvironment,
+						"vpc_connector":         connector,
+						"latest_revision":       getResourceNameFromURL(s.GetLatestReadyRevision()),
+						"url":                   s.GetUri(),
+					},
+					Name: getResourceNameFromURL(s.GetName()),
+				})
+			}
+		}
+	}
+
+	return services, nil
+}
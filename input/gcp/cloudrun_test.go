@@ -0,0 +1,291 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"cloud.google.com/go/vpcaccess/apiv1/vpcaccesspb"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/iterator"
+
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/assetbeat/input/testutil"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type StubCloudRunServiceListIterator struct {
+	iterCounter        int
+	ReturnServicesList []*runpb.Service
+	ReturnServiceError error
+}
+
+func (it *StubCloudRunServiceListIterator) Next() (*runpb.Service, error) {
+
+	if it.ReturnServiceError != nil {
+		return &runpb.Service{}, it.ReturnServiceError
+	}
+
+	if it.iterCounter == len(it.ReturnServicesList) {
+		return &runpb.Service{}, iterator.Done
+	}
+
+	service := it.ReturnServicesList[it.iterCounter]
+	it.iterCounter++
+
+	return service, nil
+}
+
+type CloudRunServicesClientStub struct {
+	ServiceListIterator map[string]*StubCloudRunServiceListIterator
+}
+
+func (s *CloudRunServicesClientStub) ListServices(ctx context.Context, req *runpb.ListServicesRequest, opts ...gax.CallOption) CloudRunServiceIterator {
+	return s.ServiceListIterator[req.Parent]
+}
+
+type VPCConnectorClientStub struct {
+	Connectors map[string]*vpcaccesspb.Connector
+}
+
+func (s *VPCConnectorClientStub) GetConnector(ctx context.Context, req *vpcaccesspb.GetConnectorRequest, opts ...gax.CallOption) (*vpcaccesspb.Connector, error) {
+	if c, ok := s.Connectors[req.Name]; ok {
+		return c, nil
+	}
+	return nil, errors.New("connector not found")
+}
+
+func getTestVPCConnectorClient() *VPCConnectorClientStub {
+	return &VPCConnectorClientStub{Connectors: map[string]*vpcaccesspb.Connector{
+		"projects/my_project/locations/europe-west1/connectors/my-connector": {
+			Name:    "projects/my_project/locations/europe-west1/connectors/my-connector",
+			Network: "my_network",
+		},
+	}}
+}
+
+func TestCollectCloudRunAssets(t *testing.T) {
+	var parents []string
+	for _, tt := range []struct {
+		name           string
+		cfg            config
+		services       map[string]*StubCloudRunServiceListIterator
+		expectedEvents []beat.Event
+	}{
+		{
+			name: "with no project specified",
+			cfg:  config{},
+		},
+		{
+			name: "single project, services with and without VPC access",
+			cfg: config{
+				Projects: []string{"my_project"},
+			},
+			services: map[string]*StubCloudRunServiceListIterator{
+				"projects/my_project/locations/-": {
+					ReturnServicesList: []*runpb.Service{
+						{
+							Name:                "projects/my_project/locations/europe-west1/services/my-service",
+							Uid:                 "1a2b3c",
+							Labels:              map[string]string{"team": "obs"},
+							LatestReadyRevision: "projects/my_project/locations/europe-west1/services/my-service/revisions/my-service-00002-abc",
+							Uri:                 "https://my-service-abc.a.run.app",
+							TerminalCondition:   &runpb.Condition{State: runpb.Condition_CONDITION_SUCCEEDED},
+							Template: &runpb.RevisionTemplate{
+								ExecutionEnvironment: runpb.ExecutionEnvironment_EXECUTION_ENVIRONMENT_GEN2,
+								VpcAccess: &runpb.VpcAccess{
+									Connector: "projects/my_project/locations/europe-west1/connectors/my-connector",
+									NetworkInterfaces: []*runpb.VpcAccess_NetworkInterface{
+										{Network: "my_network"},
+									},
+								},
+							},
+						},
+						{
+							Name: "projects/my_project/locations/us-central1/services/my-other-service",
+							Uid:  "4d5e6f",
+						},
+						{
+							Name: "projects/my_project/locations/europe-west1/services/my-connected-service",
+							Uid:  "7a8b9c",
+							Template: &runpb.RevisionTemplate{
+								VpcAccess: &runpb.VpcAccess{Connector: "my-connector"},
+							},
+						},
+						{
+							Name: "projects/my_project/locations/europe-west1/services/my-unknown-connector-service",
+							Uid:  "0d1e2f",
+							Template: &runpb.RevisionTemplate{
+								VpcAccess: &runpb.VpcAccess{Connector: "projects/my_project/locations/europe-west1/connectors/unknown"},
+							},
+						},
+					},
+				},
+			},
+			expectedEvents: []beat.Event{
+				{
+					Fields: mapstr.M{
+						"asset.ean":                            "service:1a2b3c",
+						"asset.id":                             "1a2b3c",
+						"asset.name":                           "my-service",
+						"asset.type":                           "gcp.cloudrun.service",
+						"asset.kind":                           "service",
+						"asset.parents":                        []string{"network:1"},
+						"asset.metadata.labels.team":           "obs",
+						"asset.metadata.state":                 "CONDITION_SUCCEEDED",
+						"asset.metadata.execution_environment": "EXECUTION_ENVIRONMENT_GEN2",
+						"asset.metadata.vpc_connector":         "projects/my_project/locations/europe-west1/connectors/my-connector",
+						"asset.metadata.latest_revision":       "my-service-00002-abc",
+						"asset.metadata.url":                   "https://my-service-abc.a.run.app",
+						"cloud.account.id":                     "my_project",
+						"cloud.provider":                       "gcp",
+						"cloud.region":                         "europe-west1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+				{
+					Fields: mapstr.M{
+						"asset.ean":                            "service:4d5e6f",
+						"asset.id":                             "4d5e6f",
+						"asset.name":                           "my-other-service",
+						"asset.type":                           "gcp.cloudrun.service",
+						"asset.kind":                           "service",
+						"asset.parents":                        parents,
+						"asset.metadata.state":                 "STATE_UNSPECIFIED",
+						"asset.metadata.execution_environment": "",
+						"asset.metadata.vpc_connector":         "",
+						"asset.metadata.latest_revision":       "",
+						"asset.metadata.url":                   "",
+						"cloud.account.id":                     "my_project",
+						"cloud.provider":                       "gcp",
+						"cloud.region":                         "us-central1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+				{
+					Fields: mapstr.M{
+						"asset.ean":                            "service:7a8b9c",
+						"asset.id":                             "7a8b9c",
+						"asset.name":                           "my-connected-service",
+						"asset.type":                           "gcp.cloudrun.service",
+						"asset.kind":                           "service",
+						"asset.parents":                        []string{"network:1"},
+						"asset.metadata.state":                 "STATE_UNSPECIFIED",
+						"asset.metadata.execution_environment": "EXECUTION_ENVIRONMENT_UNSPECIFIED",
+						"asset.metadata.vpc_connector":         "my-connector",
+						"asset.metadata.latest_revision":       "",
+						"asset.metadata.url":                   "",
+						"cloud.account.id":                     "my_project",
+						"cloud.provider":                       "gcp",
+						"cloud.region":                         "europe-west1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+				{
+					Fields: mapstr.M{
+						"asset.ean":                            "service:0d1e2f",
+						"asset.id":                             "0d1e2f",
+						"asset.name":                           "my-unknown-connector-service",
+						"asset.type":                           "gcp.cloudrun.service",
+						"asset.kind":                           "service",
+						"asset.parents":                        parents,
+						"asset.metadata.state":                 "STATE_UNSPECIFIED",
+						"asset.metadata.execution_environment": "EXECUTION_ENVIRONMENT_UNSPECIFIED",
+						"asset.metadata.vpc_connector":         "projects/my_project/locations/europe-west1/connectors/unknown",
+						"asset.metadata.latest_revision":       "",
+						"asset.metadata.url":                   "",
+						"cloud.account.id":                     "my_project",
+						"cloud.provider":                       "gcp",
+						"cloud.region":                         "europe-west1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+			},
+		},
+		{
+			name: "single project, with a regions filter",
+			cfg: config{
+				Projects: []string{"my_project"},
+				Regions:  []string{"us-central1"},
+			},
+			services: map[string]*StubCloudRunServiceListIterator{
+				"projects/my_project/locations/us-central1": {
+					ReturnServicesList: []*runpb.Service{
+						{
+							Name: "projects/my_project/locations/us-central1/services/my-other-service",
+							Uid:  "4d5e6f",
+						},
+					},
+				},
+			},
+			expectedEvents: []beat.Event{
+				{
+					Fields: mapstr.M{
+						"asset.ean":                            "service:4d5e6f",
+						"asset.id":                             "4d5e6f",
+						"asset.name":                           "my-other-service",
+						"asset.type":                           "gcp.cloudrun.service",
+						"asset.kind":                           "service",
+						"asset.parents":                        parents,
+						"asset.metadata.state":                 "STATE_UNSPECIFIED",
+						"asset.metadata.execution_environment": "",
+						"asset.metadata.vpc_connector":         "",
+						"asset.metadata.latest_revision":       "",
+						"asset.metadata.url":                   "",
+						"cloud.account.id":                     "my_project",
+						"cloud.provider":                       "gcp",
+						"cloud.region":                         "us-central1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := testutil.NewInMemoryPublisher()
+
+			ctx := context.Background()
+			client := CloudRunServicesClientStub{ServiceListIterator: tt.services}
+			listClient := listCloudRunServicesAPIClient{
+				ListServices: func(ctx context.Context, req *runpb.ListServicesRequest, opts ...gax.CallOption) CloudRunServiceIterator {
+					return client.ListServices(ctx, req, opts...)
+				},
+			}
+			log := logp.NewLogger("mylogger")
+			err := collectCloudRunAssets(ctx, tt.cfg, getTestVpcCache(), listClient, getTestVPCConnectorClient(), publisher, log)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedEvents, publisher.Events)
+		})
+	}
+}
@@ -31,7 +31,6 @@ import (
 	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
 	"github.com/elastic/elastic-agent-libs/logp"
 	"github.com/elastic/elastic-agent-libs/mapstr"
-	"github.com/elastic/go-freelru"
 )
 
 type AggregatedInstanceIterator interface {
@@ -50,10 +49,12 @@ type computeInstance struct {
 	Labels   map[string]string
 	Metadata mapstr.M
 	RawMd    *computepb.Metadata
-	Name     string
+	// Disks holds the self links of the attached persistent disks
+	Disks []string
+	Name  string
 }
 
-func collectComputeAssets(ctx context.Context, cfg config, subnetAssetCache *freelru.LRU[string, *subnet], computeAssetCache *freelru.LRU[string, *computeInstance], client listInstanceAPIClient, publisher stateless.Publisher, log *logp.Logger) error {
+func collectComputeAssets(ctx context.Context, cfg config, subnetAssetCache *assetCache[*subnet], computeAssetCache *assetCache[*computeInstance], client listInstanceAPIClient, publisher stateless.Publisher, log *logp.Logger) error {
 
 	instances, err := getAllComputeInstances(ctx, cfg, subnetAssetCache, computeAssetCache, client)
 	if err != nil {
@@ -62,6 +63,10 @@ func collectComputeAssets(ctx context.Context, cfg config, subnetAssetCache *fre
 
 	assetType := "gcp.compute.instance"
 	assetKind := "host"
+	if !internal.IsTypeEnabled(cfg.AssetTypes, assetType) {
+		// the instances are still cached, as they are needed to resolve relationships
+		return nil
+	}
 	log.Debug("Publishing GCP compute instances")
 
 	for _, instance := range instances {
@@ -91,7 +96,7 @@ func collectComputeAssets(ctx context.Context, cfg config, subnetAssetCache *fre
 	return nil
 }
 
-func getAllComputeInstances(ctx context.Context, cfg config, subnetAssetCache *freelru.LRU[string, *subnet], computeAssetCache *freelru.LRU[string, *computeInstance], client listInstanceAPIClient) ([]computeInstance, error) {
+func getAllComputeInstances(ctx context.Context, cfg config, subnetAssetCache *assetCache[*subnet], computeAssetCache *assetCache[*computeInstance], client listInstanceAPIClient) ([]computeInstance, error) {
 	var instances []computeInstance
 
 	for _, p := range cfg.Projects {
@@ -115,6 +120,12 @@ func getAllComputeInstances(ctx context.Context, cfg config, subnetAssetCache *f
 					for _, ni := range i.NetworkInterfaces {
 						subnets = append(subnets, getSubnetIdFromLink(*ni.Subnetwork, subnetAssetCache))
 					}
+					var disks []string
+					for _, d := range i.GetDisks() {
+						if d.GetSource() != "" {
+							disks = append(disks, d.GetSource())
+						}
+					}
 					cI := computeInstance{
 						ID:      strconv.FormatUint(*i.Id, 10),
 						Region:  getRegionFromZoneURL(zone),
@@ -125,6 +136,7 @@ func getAllComputeInstances(ctx context.Context, cfg config, subnetAssetCache *f
 							"state": *i.Status,
 						},
 						RawMd: i.GetMetadata(),
+						Disks: disks,
 						Name:  i.GetName(),
 					}
 					selfLink := *i.SelfLink
@@ -293,7 +293,7 @@ func TestGetAllComputeInstances(t *testing.T) {
 					return client.AggregatedList(ctx, req, opts...)
 				},
 			}
-			computeAssetsCache := getComputeCache()
+			computeAssetsCache := getComputeCache(nil)
 			err := collectComputeAssets(tt.ctx, tt.cfg, subnetAssetsCache, computeAssetsCache, clientCreator, publisher, log)
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedEvents, publisher.Events)
@@ -0,0 +1,159 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/iterator"
+
+	"github.com/elastic/assetbeat/input/internal"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const computeAPIPrefix = "https://www.googleapis.com/compute/v1/"
+
+type AggregatedDiskIterator interface {
+	Next() (compute.DisksScopedListPair, error)
+}
+
+type listDiskAPIClient struct {
+	AggregatedList func(ctx context.Context, req *computepb.AggregatedListDisksRequest, opts ...gax.CallOption) AggregatedDiskIterator
+}
+
+type disk struct {
+	ID       string
+	Region   string
+	Account  string
+	Parents  []string
+	Labels   map[string]string
+	Metadata mapstr.M
+	Name     string
+}
+
+func collectDiskAssets(ctx context.Context, cfg config, computeAssetCache *assetCache[*computeInstance], client listDiskAPIClient, publisher stateless.Publisher, log *logp.Logger) error {
+	disks, err := getAllDisks(ctx, cfg, computeAssetCache, client)
+	if err != nil {
+		return err
+	}
+
+	assetType := "gcp.compute.disk"
+	assetKind := "disk"
+	log.Debug("Publishing GCP disks")
+
+	for _, d := range disks {
+		options := []internal.AssetOption{
+			internal.WithAssetCloudProvider("gcp"),
+			internal.WithAssetRegion(d.Region),
+			internal.WithAssetAccountID(d.Account),
+			internal.WithAssetKindAndID(assetKind, d.ID),
+			internal.WithAssetType(assetType),
+			internal.WithAssetParents(d.Parents),
+			WithAssetLabels(internal.ToMapstr(d.Labels)),
+			internal.WithAssetMetadata(d.Metadata),
+		}
+
+		if d.Name != "" {
+			options = append(options, internal.WithAssetName(d.Name))
+		}
+		internal.Publish(publisher, nil, options...)
+	}
+
+	return nil
+}
+
+func getAllDisks(ctx context.Context, cfg config, computeAssetCache *assetCache[*computeInstance], client listDiskAPIClient) ([]disk, error) {
+	var disks []disk
+
+	// the instances a disk is attached to are resolved from the disks attached to each instance
+	attachments := make(map[string][]string)
+	for _, i := range computeAssetCache.Values() {
+		for _, d := range i.Disks {
+			attachments[d] = append(attachments[d], "host:"+i.ID)
+		}
+	}
+
+	for _, p := range cfg.Projects {
+		req := &computepb.AggregatedListDisksRequest{
+			Project: p,
+		}
+		it := client.AggregatedList(ctx, req)
+		for {
+			pair, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error retrieving disks for project %s: %w", p, err)
+			}
+			if !wantScope(pair.Key, cfg.Regions) {
+				continue
+			}
+			for _, d := range pair.Value.GetDisks() {
+				region := getResourceNameFromURL(d.GetRegion())
+				if d.GetZone() != "" {
+					region = getRegionFromZoneURL(d.GetZone())
+				}
+
+				disks = append(disks, disk{
+					ID:      getDiskID(d.GetSelfLink()),
+					Region:  region,
+					Account: p,
+					Parents: attachments[d.GetSelfLink()],
+					Labels:  d.GetLabels(),
+					Metadata: mapstr.M{
+						"size_gb":            d.GetSizeGb(),
+						"type":               getResourceNameFromURL(d.GetType()),
+						"status":             d.GetStatus(),
+						"encryption_key":     getDiskEncryptionKey(d.GetDiskEncryptionKey()),
+						"snapshot_schedules": getResourceNamesFromURLs(d.GetResourcePolicies()),
+					},
+					Name: d.GetName(),
+				})
+			}
+		}
+	}
+
+	return disks, nil
+}
+
+// getDiskID returns the relative resource name of a disk, e.g. projects/{project}/zones/{zone}/disks/{name},
+// which is also how the GCE persistent disk CSI driver identifies volumes.
+func getDiskID(selfLink string) string {
+	return strings.TrimPrefix(selfLink, computeAPIPrefix)
+}
+
+// getDiskEncryptionKey returns the Cloud KMS key protecting a disk, or whether
+// it is protected by a customer supplied or a Google managed key.
+func getDiskEncryptionKey(key *computepb.CustomerEncryptionKey) string {
+	switch {
+	case key.GetKmsKeyName() != "":
+		return key.GetKmsKeyName()
+	case key.GetSha256() != "" || key.GetRsaEncryptedKey() != "":
+		return "customer_supplied"
+	default:
+		return "google_managed"
+	}
+}
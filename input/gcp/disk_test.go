@@ -0,0 +1,248 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gcp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/assetbeat/input/testutil"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type StubAggregatedDiskListIterator struct {
+	iterCounter           int
+	ReturnScopedDisksList []compute.DisksScopedListPair
+	ReturnDisksError      error
+}
+
+func (it *StubAggregatedDiskListIterator) Next() (compute.DisksScopedListPair, error) {
+
+	if it.ReturnDisksError != nil {
+		return compute.DisksScopedListPair{}, it.ReturnDisksError
+	}
+
+	if it.iterCounter == len(it.ReturnScopedDisksList) {
+		return compute.DisksScopedListPair{}, iterator.Done
+	}
+
+	pair := it.ReturnScopedDisksList[it.iterCounter]
+	it.iterCounter++
+
+	return pair, nil
+}
+
+type DisksClientStub struct {
+	AggregatedDiskIterator map[string]*StubAggregatedDiskListIterator
+}
+
+func (s *DisksClientStub) AggregatedList(ctx context.Context, req *computepb.AggregatedListDisksRequest, opts ...gax.CallOption) AggregatedDiskIterator {
+	return s.AggregatedDiskIterator[req.Project]
+}
+
+func TestCollectDiskAssets(t *testing.T) {
+	var parents []string
+	computeAssetsCache := getComputeCache(nil)
+	computeAssetsCache.AddWithExpire("https://www.googleapis.com/compute/v1/projects/my_project/zones/europe-west1-d/instances/my-instance", &computeInstance{
+		ID: "123",
+		Disks: []string{
+			"https://www.googleapis.com/compute/v1/projects/my_project/zones/europe-west1-d/disks/my-boot-disk",
+			"https://www.googleapis.com/compute/v1/projects/my_project/regions/europe-west1/disks/my-shared-disk",
+		},
+	}, time.Minute)
+	computeAssetsCache.AddWithExpire("https://www.googleapis.com/compute/v1/projects/my_project/zones/europe-west1-b/instances/my-other-instance", &computeInstance{
+		ID: "124",
+		Disks: []string{
+			"https://www.googleapis.com/compute/v1/projects/my_project/regions/europe-west1/disks/my-shared-disk",
+		},
+	}, time.Minute)
+
+	for _, tt := range []struct {
+		name           string
+		cfg            config
+		disks          map[string]*StubAggregatedDiskListIterator
+		expectedEvents []beat.Event
+	}{
+		{
+			name: "with no project specified",
+			cfg:  config{},
+		},
+		{
+			name: "zonal, regional and detached disks",
+			cfg: config{
+				Projects: []string{"my_project"},
+				Regions:  []string{"europe-west1"},
+			},
+			disks: map[string]*StubAggregatedDiskListIterator{
+				"my_project": {
+					ReturnScopedDisksList: []compute.DisksScopedListPair{
+						{
+							Key: "zones/europe-west1-d",
+							Value: &computepb.DisksScopedList{
+								Disks: []*computepb.Disk{
+									{
+										Name:             proto.String("my-boot-disk"),
+										SelfLink:         proto.String("https://www.googleapis.com/compute/v1/projects/my_project/zones/europe-west1-d/disks/my-boot-disk"),
+										Zone:             proto.String("https://www.googleapis.com/compute/v1/projects/my_project/zones/europe-west1-d"),
+										SizeGb:           proto.Int64(10),
+										Type:             proto.String("https://www.googleapis.com/compute/v1/projects/my_project/zones/europe-west1-d/diskTypes/pd-balanced"),
+										Status:           proto.String("READY"),
+										Labels:           map[string]string{"backup": "daily"},
+										ResourcePolicies: []string{"https://www.googleapis.com/compute/v1/projects/my_project/regions/europe-west1/resourcePolicies/daily-snapshots"},
+									},
+									{
+										Name:     proto.String("my-detached-disk"),
+										SelfLink: proto.String("https://www.googleapis.com/compute/v1/projects/my_project/zones/europe-west1-d/disks/my-detached-disk"),
+										Zone:     proto.String("https://www.googleapis.com/compute/v1/projects/my_project/zones/europe-west1-d"),
+										SizeGb:   proto.Int64(100),
+										Type:     proto.String("https://www.googleapis.com/compute/v1/projects/my_project/zones/europe-west1-d/diskTypes/pd-ssd"),
+										Status:   proto.String("READY"),
+										DiskEncryptionKey: &computepb.CustomerEncryptionKey{
+											Sha256: proto.String("abc"),
+										},
+									},
+								},
+							},
+						},
+						{
+							Key: "regions/europe-west1",
+							Value: &computepb.DisksScopedList{
+								Disks: []*computepb.Disk{
+									{
+										Name:     proto.String("my-shared-disk"),
+										SelfLink: proto.String("https://www.googleapis.com/compute/v1/projects/my_project/regions/europe-west1/disks/my-shared-disk"),
+										Region:   proto.String("https://www.googleapis.com/compute/v1/projects/my_project/regions/europe-west1"),
+										SizeGb:   proto.Int64(200),
+										Type:     proto.String("https://www.googleapis.com/compute/v1/projects/my_project/regions/europe-west1/diskTypes/pd-balanced"),
+										Status:   proto.String("READY"),
+										DiskEncryptionKey: &computepb.CustomerEncryptionKey{
+											KmsKeyName: proto.String("projects/my_project/locations/europe-west1/keyRings/my-ring/cryptoKeys/my-key/cryptoKeyVersions/1"),
+										},
+									},
+								},
+							},
+						},
+						{
+							Key: "zones/us-central1-a",
+							Value: &computepb.DisksScopedList{
+								Disks: []*computepb.Disk{
+									{
+										Name: proto.String("my-filtered-disk"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedEvents: []beat.Event{
+				{
+					Fields: mapstr.M{
+						"asset.ean":                         "disk:projects/my_project/zones/europe-west1-d/disks/my-boot-disk",
+						"asset.id":                          "projects/my_project/zones/europe-west1-d/disks/my-boot-disk",
+						"asset.name":                        "my-boot-disk",
+						"asset.type":                        "gcp.compute.disk",
+						"asset.kind":                        "disk",
+						"asset.parents":                     []string{"host:123"},
+						"asset.metadata.labels.backup":      "daily",
+						"asset.metadata.size_gb":            int64(10),
+						"asset.metadata.type":               "pd-balanced",
+						"asset.metadata.status":             "READY",
+						"asset.metadata.encryption_key":     "google_managed",
+						"asset.metadata.snapshot_schedules": []string{"daily-snapshots"},
+						"cloud.account.id":                  "my_project",
+						"cloud.provider":                    "gcp",
+						"cloud.region":                      "europe-west1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+				{
+					Fields: mapstr.M{
+						"asset.ean":                         "disk:projects/my_project/zones/europe-west1-d/disks/my-detached-disk",
+						"asset.id":                          "projects/my_project/zones/europe-west1-d/disks/my-detached-disk",
+						"asset.name":                        "my-detached-disk",
+						"asset.type":                        "gcp.compute.disk",
+						"asset.kind":                        "disk",
+						"asset.parents":                     parents,
+						"asset.metadata.size_gb":            int64(100),
+						"asset.metadata.type":               "pd-ssd",
+						"asset.metadata.status":             "READY",
+						"asset.metadata.encryption_key":     "customer_supplied",
+						"asset.metadata.snapshot_schedules": parents,
+						"cloud.account.id":                  "my_project",
+						"cloud.provider":                    "gcp",
+						"cloud.region":                      "europe-west1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+				{
+					Fields: mapstr.M{
+						"asset.ean":                         "disk:projects/my_project/regions/europe-west1/disks/my-shared-disk",
+						"asset.id":                          "projects/my_project/regions/europe-west1/disks/my-shared-disk",
+						"asset.name":                        "my-shared-disk",
+						"asset.type":                        "gcp.compute.disk",
+						"asset.kind":                        "disk",
+						"asset.parents":                     []string{"host:123", "host:124"},
+						"asset.metadata.size_gb":            int64(200),
+						"asset.metadata.type":               "pd-balanced",
+						"asset.metadata.status":             "READY",
+						"asset.metadata.encryption_key":     "projects/my_project/locations/europe-west1/keyRings/my-ring/cryptoKeys/my-key/cryptoKeyVersions/1",
+						"asset.metadata.snapshot_schedules": parents,
+						"cloud.account.id":                  "my_project",
+						"cloud.provider":                    "gcp",
+						"cloud.region":                      "europe-west1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := testutil.NewInMemoryPublisher()
+
+			ctx := context.Background()
+			client := DisksClientStub{AggregatedDiskIterator: tt.disks}
+			listClient := listDiskAPIClient{
+				AggregatedList: func(ctx context.Context, req *computepb.AggregatedListDisksRequest, opts ...gax.CallOption) AggregatedDiskIterator {
+					return client.AggregatedList(ctx, req, opts...)
+				},
+			}
+			log := logp.NewLogger("mylogger")
+			err := collectDiskAssets(ctx, tt.cfg, computeAssetsCache, listClient, publisher, log)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedEvents, publisher.Events)
+		})
+	}
+}
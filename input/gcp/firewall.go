@@ -0,0 +1,139 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/iterator"
+
+	"github.com/elastic/assetbeat/input/internal"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type FirewallIterator interface {
+	Next() (*computepb.Firewall, error)
+}
+
+type listFirewallAPIClient struct {
+	List func(ctx context.Context, req *computepb.ListFirewallsRequest, opts ...gax.CallOption) FirewallIterator
+}
+
+type firewall struct {
+	ID       string
+	Account  string
+	VPC      string
+	Metadata mapstr.M
+	Name     string
+}
+
+func collectFirewallAssets(ctx context.Context, cfg config, vpcAssetCache *assetCache[*vpc], client listFirewallAPIClient, publisher stateless.Publisher, log *logp.Logger) error {
+	firewalls, err := getAllFirewalls(ctx, cfg, vpcAssetCache, client)
+	if err != nil {
+		return err
+	}
+
+	assetType := "gcp.compute.firewall"
+	assetKind := "firewall"
+	log.Debug("Publishing GCP firewall rules")
+
+	for _, fw := range firewalls {
+		var parents []string
+		if fw.VPC != "" {
+			parents = append(parents, "network:"+fw.VPC)
+		}
+		options := []internal.AssetOption{
+			internal.WithAssetCloudProvider("gcp"),
+			internal.WithAssetAccountID(fw.Account),
+			internal.WithAssetKindAndID(assetKind, fw.ID),
+			internal.WithAssetType(assetType),
+			internal.WithAssetParents(parents),
+			internal.WithAssetMetadata(fw.Metadata),
+		}
+
+		if fw.Name != "" {
+			options = append(options, internal.WithAssetName(fw.Name))
+		}
+		internal.Publish(publisher, nil, options...)
+	}
+
+	return nil
+}
+
+func getAllFirewalls(ctx context.Context, cfg config, vpcAssetCache *assetCache[*vpc], client listFirewallAPIClient) ([]firewall, error) {
+	var firewalls []firewall
+
+	for _, p := range cfg.Projects {
+		req := &computepb.ListFirewallsRequest{
+			Project: p,
+		}
+		it := client.List(ctx, req)
+		for {
+			fw, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error retrieving firewall rules for project %s: %w", p, err)
+			}
+
+			var allowed, denied []string
+			for _, a := range fw.GetAllowed() {
+				allowed = append(allowed, formatFirewallRule(a.GetIPProtocol(), a.GetPorts()))
+			}
+			for _, d := range fw.GetDenied() {
+				denied = append(denied, formatFirewallRule(d.GetIPProtocol(), d.GetPorts()))
+			}
+
+			firewalls = append(firewalls, firewall{
+				ID:      strconv.FormatUint(fw.GetId(), 10),
+				Account: p,
+				VPC:     getVpcIdFromLink(fw.GetNetwork(), vpcAssetCache),
+				Metadata: mapstr.M{
+					"direction":          fw.GetDirection(),
+					"priority":           fw.GetPriority(),
+					"disabled":           fw.GetDisabled(),
+					"source_ranges":      fw.GetSourceRanges(),
+					"destination_ranges": fw.GetDestinationRanges(),
+					"source_tags":        fw.GetSourceTags(),
+					"target_tags":        fw.GetTargetTags(),
+					"allowed":            allowed,
+					"denied":             denied,
+				},
+				Name: fw.GetName(),
+			})
+		}
+	}
+
+	return firewalls, nil
+}
+
+// formatFirewallRule formats a protocol and its ports as in gcloud, e.g. tcp:80,443
+func formatFirewallRule(protocol string, ports []string) string {
+	if len(ports) == 0 {
+		return protocol
+	}
+	return protocol + ":" + strings.Join(ports, ",")
+}
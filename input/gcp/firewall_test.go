@@ -0,0 +1,201 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/assetbeat/input/testutil"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type StubFirewallListIterator struct {
+	iterCounter         int
+	ReturnFirewallsList []*computepb.Firewall
+	ReturnFirewallError error
+}
+
+func (it *StubFirewallListIterator) Next() (*computepb.Firewall, error) {
+
+	if it.ReturnFirewallError != nil {
+		return &computepb.Firewall{}, it.ReturnFirewallError
+	}
+
+	if it.iterCounter == len(it.ReturnFirewallsList) {
+		return &computepb.Firewall{}, iterator.Done
+	}
+
+	fw := it.ReturnFirewallsList[it.iterCounter]
+	it.iterCounter++
+
+	return fw, nil
+}
+
+type FirewallsClientStub struct {
+	FirewallListIterator map[string]*StubFirewallListIterator
+}
+
+func (s *FirewallsClientStub) List(ctx context.Context, req *computepb.ListFirewallsRequest, opts ...gax.CallOption) FirewallIterator {
+	return s.FirewallListIterator[req.Project]
+}
+
+func TestCollectFirewallAssets(t *testing.T) {
+	var empty []string
+	for _, tt := range []struct {
+		name           string
+		cfg            config
+		firewalls      map[string]*StubFirewallListIterator
+		expectedEvents []beat.Event
+		expectedError  bool
+	}{
+		{
+			name: "with no project specified",
+			cfg:  config{},
+		},
+		{
+			name: "single project, allow and deny rules",
+			cfg: config{
+				Projects: []string{"my_project"},
+			},
+			firewalls: map[string]*StubFirewallListIterator{
+				"my_project": {
+					ReturnFirewallsList: []*computepb.Firewall{
+						{
+							Id:           proto.Uint64(30),
+							Name:         proto.String("allow-web"),
+							Network:      proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/networks/my_network"),
+							Direction:    proto.String("INGRESS"),
+							Priority:     proto.Int32(1000),
+							SourceRanges: []string{"0.0.0.0/0"},
+							TargetTags:   []string{"web"},
+							Allowed: []*computepb.Allowed{
+								{IPProtocol: proto.String("tcp"), Ports: []string{"80", "443"}},
+								{IPProtocol: proto.String("icmp")},
+							},
+						},
+						{
+							Id:                proto.Uint64(31),
+							Name:              proto.String("deny-egress"),
+							Network:           proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/networks/unknown_network"),
+							Direction:         proto.String("EGRESS"),
+							Priority:          proto.Int32(65534),
+							Disabled:          proto.Bool(true),
+							DestinationRanges: []string{"0.0.0.0/0"},
+							Denied: []*computepb.Denied{
+								{IPProtocol: proto.String("all")},
+							},
+						},
+					},
+				},
+			},
+			expectedEvents: []beat.Event{
+				{
+					Fields: mapstr.M{
+						"asset.ean":                         "firewall:30",
+						"asset.id":                          "30",
+						"asset.name":                        "allow-web",
+						"asset.type":                        "gcp.compute.firewall",
+						"asset.kind":                        "firewall",
+						"asset.parents":                     []string{"network:1"},
+						"asset.metadata.direction":          "INGRESS",
+						"asset.metadata.priority":           int32(1000),
+						"asset.metadata.disabled":           false,
+						"asset.metadata.source_ranges":      []string{"0.0.0.0/0"},
+						"asset.metadata.destination_ranges": empty,
+						"asset.metadata.source_tags":        empty,
+						"asset.metadata.target_tags":        []string{"web"},
+						"asset.metadata.allowed":            []string{"tcp:80,443", "icmp"},
+						"asset.metadata.denied":             empty,
+						"cloud.account.id":                  "my_project",
+						"cloud.provider":                    "gcp",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+				{
+					Fields: mapstr.M{
+						"asset.ean":                         "firewall:31",
+						"asset.id":                          "31",
+						"asset.name":                        "deny-egress",
+						"asset.type":                        "gcp.compute.firewall",
+						"asset.kind":                        "firewall",
+						"asset.parents":                     empty,
+						"asset.metadata.direction":          "EGRESS",
+						"asset.metadata.priority":           int32(65534),
+						"asset.metadata.disabled":           true,
+						"asset.metadata.source_ranges":      empty,
+						"asset.metadata.destination_ranges": []string{"0.0.0.0/0"},
+						"asset.metadata.source_tags":        empty,
+						"asset.metadata.target_tags":        empty,
+						"asset.metadata.allowed":            empty,
+						"asset.metadata.denied":             []string{"all"},
+						"cloud.account.id":                  "my_project",
+						"cloud.provider":                    "gcp",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+			},
+		},
+		{
+			name: "with an API error",
+			cfg: config{
+				Projects: []string{"my_project"},
+			},
+			firewalls: map[string]*StubFirewallListIterator{
+				"my_project": {
+					ReturnFirewallError: errors.New("permission denied"),
+				},
+			},
+			expectedError: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := testutil.NewInMemoryPublisher()
+
+			ctx := context.Background()
+			client := FirewallsClientStub{FirewallListIterator: tt.firewalls}
+			listClient := listFirewallAPIClient{
+				List: func(ctx context.Context, req *computepb.ListFirewallsRequest, opts ...gax.CallOption) FirewallIterator {
+					return client.List(ctx, req, opts...)
+				},
+			}
+			log := logp.NewLogger("mylogger")
+			err := collectFirewallAssets(ctx, tt.cfg, getTestVpcCache(), listClient, publisher, log)
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedEvents, publisher.Events)
+		})
+	}
+}
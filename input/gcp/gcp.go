@@ -19,22 +19,34 @@ package gcp
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
 	"time"
 
 	compute "cloud.google.com/go/compute/apiv1"
 	"cloud.google.com/go/compute/apiv1/computepb"
 	container "cloud.google.com/go/container/apiv1"
+	functions "cloud.google.com/go/functions/apiv2"
+	"cloud.google.com/go/functions/apiv2/functionspb"
+	run "cloud.google.com/go/run/apiv2"
+	"cloud.google.com/go/run/apiv2/runpb"
+	vpcaccess "cloud.google.com/go/vpcaccess/apiv1"
 	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/impersonate"
 	"google.golang.org/api/option"
 
 	"github.com/elastic/assetbeat/input/internal"
 	input "github.com/elastic/beats/v7/filebeat/input/v2"
 	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
 	"github.com/elastic/beats/v7/libbeat/feature"
+	"github.com/elastic/beats/v7/libbeat/monitoring/inputmon"
 	conf "github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/monitoring"
 	"github.com/elastic/go-concert/ctxtool"
-	"github.com/elastic/go-freelru"
 )
 
 func Plugin() input.Plugin {
@@ -57,10 +69,11 @@ func configure(cfg *conf.C) (stateless.Input, error) {
 }
 
 func newAssetsGCP(config config) (*assetsGCP, error) {
-	vpcAssetsCache := getVpcCache()
-	subnetAssetsCache := getSubnetCache()
-	computeAssetsCache := getComputeCache()
-	return &assetsGCP{config, vpcAssetsCache, subnetAssetsCache, computeAssetsCache}, nil
+	metrics := monitoring.NewRegistry()
+	vpcAssetsCache := getVpcCache(metrics)
+	subnetAssetsCache := getSubnetCache(metrics)
+	computeAssetsCache := getComputeCache(metrics)
+	return &assetsGCP{config, metrics, vpcAssetsCache, subnetAssetsCache, computeAssetsCache}, nil
 }
 
 type config struct {
@@ -68,6 +81,10 @@ type config struct {
 	Projects            []string `config:"projects"`
 	Regions             []string `config:"regions"`
 	CredsFilePath       string   `config:"credentials_file_path"`
+	// ExternalAccountFilePath is a workload identity federation credential configuration.
+	ExternalAccountFilePath   string   `config:"external_account_file_path"`
+	ImpersonateServiceAccount string   `config:"impersonate_service_account"`
+	ImpersonateDelegates      []string `config:"impersonate_delegates"`
 }
 
 func defaultConfig() config {
@@ -80,15 +97,35 @@ func defaultConfig() config {
 
 type assetsGCP struct {
 	config
-	VpcAssetsCache     *freelru.LRU[string, *vpc]
-	SubnetAssetsCache  *freelru.LRU[string, *subnet]
-	ComputeAssetsCache *freelru.LRU[string, *computeInstance]
+	// CacheMetrics holds the hit and miss counters of the asset caches.
+	CacheMetrics       *monitoring.Registry
+	VpcAssetsCache     *assetCache[*vpc]
+	SubnetAssetsCache  *assetCache[*subnet]
+	ComputeAssetsCache *assetCache[*computeInstance]
 }
 
 func (s *assetsGCP) Name() string { return "assets_gcp" }
 
-func (s *assetsGCP) Test(_ input.TestContext) error {
-	return nil
+// Test verifies the credentials and, for each configured project, that the APIs
+// required by the enabled asset types are enabled and their permissions granted.
+func (s *assetsGCP) Test(testCtx input.TestContext) error {
+	ctx := ctxtool.FromCanceller(testCtx.Cancelation)
+	log := testCtx.Logger.With("assets_gcp")
+
+	for _, path := range []string{s.config.CredsFilePath, s.config.ExternalAccountFilePath} {
+		if err := checkCredentialsFile(ctx, path); err != nil {
+			return err
+		}
+	}
+	opts, err := buildClientOptions(ctx, s.config)
+	if err != nil {
+		return err
+	}
+	client, err := newProjectCheckAPIClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return checkProjects(ctx, s.config, client, log)
 }
 
 func (s *assetsGCP) Run(inputCtx input.Context, publisher stateless.Publisher) error {
@@ -98,6 +135,10 @@ func (s *assetsGCP) Run(inputCtx input.Context, publisher stateless.Publisher) e
 	log.Info("gcp asset collector run started")
 	defer log.Info("gcp asset collector run stopped")
 
+	reg, unregister := inputmon.NewInputRegistry(s.Name(), inputCtx.ID, nil)
+	defer unregister()
+	reg.Add("cache", s.CacheMetrics, monitoring.Full)
+
 	ticker := time.NewTicker(s.Period)
 	select {
 	case <-ctx.Done():
@@ -121,111 +162,385 @@ func (s *assetsGCP) Run(inputCtx input.Context, publisher stateless.Publisher) e
 	}
 }
 
+// collectAll runs a full collection cycle. Resource types are collected in
+// stages, so that the caches used to resolve relationships are populated
+// before the types depending on them are collected within the same cycle:
+// networks and subnetworks first, then compute instances, serverless
+// resources, load balancers and firewalls, and finally GKE clusters and disks.
 func (s *assetsGCP) collectAll(ctx context.Context, log *logp.Logger, publisher stateless.Publisher) error {
-	if internal.IsTypeEnabled(s.config.AssetTypes, "gcp.compute.instance") {
-		go func() {
-			client, err := compute.NewInstancesRESTClient(ctx, buildClientOptions(s.config)...)
-			if err != nil {
-				log.Errorf("error collecting compute assets: %+v", err)
-			}
-			defer func() {
-				if client != nil {
-					client.Close()
-				}
-			}()
-			listClient := listInstanceAPIClient{
-				AggregatedList: func(ctx context.Context, req *computepb.AggregatedListInstancesRequest, opts ...gax.CallOption) AggregatedInstanceIterator {
-					return client.AggregatedList(ctx, req, opts...)
-				},
-			}
-			err = collectComputeAssets(ctx, s.config, s.SubnetAssetsCache, s.ComputeAssetsCache, listClient, publisher, log)
-			if err != nil {
-				log.Errorf("error collecting compute assets: %+v", err)
-			}
-		}()
+	opts, err := buildClientOptions(ctx, s.config)
+	if err != nil {
+		return err
 	}
-	if internal.IsTypeEnabled(s.config.AssetTypes, "k8s.cluster") {
-		go func() {
-			client, err := container.NewClusterManagerClient(ctx)
-			if err != nil {
-				log.Errorf("error collecting GKE assets: %+v", err)
-			}
 
-			computeClient, err := compute.NewInstancesRESTClient(ctx, buildClientOptions(s.config)...)
-			if err != nil {
-				log.Errorf("error collecting GKE assets: %+v", err)
-			}
-			defer func() {
-				if client != nil {
-					client.Close()
-				}
-				if computeClient != nil {
-					computeClient.Close()
-				}
-			}()
-			listClient := listInstanceAPIClient{
-				AggregatedList: func(ctx context.Context, req *computepb.AggregatedListInstancesRequest, opts ...gax.CallOption) AggregatedInstanceIterator {
-					return computeClient.AggregatedList(ctx, req, opts...)
-				},
-			}
-			err = collectGKEAssets(ctx, s.config, s.VpcAssetsCache, s.ComputeAssetsCache, log, listClient, client, publisher)
-			if err != nil {
-				log.Errorf("error collecting GKE assets: %+v", err)
-			}
-		}()
+	stages := [][]func(context.Context, *logp.Logger, stateless.Publisher, []option.ClientOption){
+		{s.collectVpcs, s.collectSubnets},
+		{s.collectComputeInstances, s.collectCloudRunServices, s.collectCloudFunctions, s.collectLoadBalancers, s.collectFirewalls},
+		{s.collectGKEClusters, s.collectDisks},
 	}
-	if internal.IsTypeEnabled(s.config.AssetTypes, "gcp.vpc") {
-		go func() {
-			client, err := compute.NewNetworksRESTClient(ctx, buildClientOptions(s.config)...)
-			if err != nil {
-				log.Errorf("error collecting VPC assets: %+v", err)
-			}
-			defer func() {
-				if client != nil {
-					client.Close()
-				}
-			}()
-			listClient := listNetworkAPIClient{List: func(ctx context.Context, req *computepb.ListNetworksRequest, opts ...gax.CallOption) NetworkIterator {
-				return client.List(ctx, req, opts...)
-			}}
-			err = collectVpcAssets(ctx, s.config, s.VpcAssetsCache, listClient, publisher, log)
-			if err != nil {
-				log.Errorf("error collecting VPC assets: %+v", err)
-			}
-		}()
-	}
-	if internal.IsTypeEnabled(s.config.AssetTypes, "gcp.subnet") {
-		go func() {
-			client, err := compute.NewSubnetworksRESTClient(ctx, buildClientOptions(s.config)...)
-			if err != nil {
-				log.Errorf("error collecting Subnet assets: %+v", err)
-			}
-			defer func() {
-				if client != nil {
-					client.Close()
-				}
-			}()
-
-			listClient := listSubnetworkAPIClient{
-				AggregatedList: func(ctx context.Context, req *computepb.AggregatedListSubnetworksRequest, opts ...gax.CallOption) AggregatedSubnetworkIterator {
-					return client.AggregatedList(ctx, req, opts...)
-				},
-			}
-			err = collectSubnetAssets(ctx, s.config, s.SubnetAssetsCache, listClient, publisher, log)
-			if err != nil {
-				log.Errorf("error collecting Subnet assets: %+v", err)
-			}
-		}()
+	for _, stage := range stages {
+		var wg sync.WaitGroup
+		for _, collect := range stage {
+			wg.Add(1)
+			go func(collect func(context.Context, *logp.Logger, stateless.Publisher, []option.ClientOption)) {
+				defer wg.Done()
+				collect(ctx, log, publisher, opts)
+			}(collect)
+		}
+		wg.Wait()
 	}
 	return nil
 }
 
-func buildClientOptions(cfg config) []option.ClientOption {
+// collectVpcs fetches the VPCs whenever a dependent type is enabled,
+// as they are needed to resolve parents, but only publishes them if gcp.vpc is enabled.
+func (s *assetsGCP) collectVpcs(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, opts []option.ClientOption) {
+	if !vpcCollector.isEnabled(s.config.AssetTypes) {
+		return
+	}
+	client, err := compute.NewNetworksRESTClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting VPC assets: %+v", err)
+		return
+	}
+	defer client.Close()
+
+	listClient := listNetworkAPIClient{List: func(ctx context.Context, req *computepb.ListNetworksRequest, opts ...gax.CallOption) NetworkIterator {
+		return client.List(ctx, req, opts...)
+	}}
+	err = collectVpcAssets(ctx, s.config, s.VpcAssetsCache, listClient, publisher, log)
+	if err != nil {
+		log.Errorf("error collecting VPC assets: %+v", err)
+	}
+}
+
+// collectSubnets fetches the subnetworks whenever compute instances are enabled,
+// as they are needed to resolve parents, but only publishes them if gcp.subnet is enabled.
+func (s *assetsGCP) collectSubnets(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, opts []option.ClientOption) {
+	if !subnetCollector.isEnabled(s.config.AssetTypes) {
+		return
+	}
+	client, err := compute.NewSubnetworksRESTClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting Subnet assets: %+v", err)
+		return
+	}
+	defer client.Close()
+
+	listClient := listSubnetworkAPIClient{
+		AggregatedList: func(ctx context.Context, req *computepb.AggregatedListSubnetworksRequest, opts ...gax.CallOption) AggregatedSubnetworkIterator {
+			return client.AggregatedList(ctx, req, opts...)
+		},
+	}
+	err = collectSubnetAssets(ctx, s.config, s.SubnetAssetsCache, listClient, publisher, log)
+	if err != nil {
+		log.Errorf("error collecting Subnet assets: %+v", err)
+	}
+}
+
+// collectComputeInstances fetches the instances whenever disks are enabled, as they
+// are needed to resolve attachments, but only publishes them if gcp.compute.instance is enabled.
+func (s *assetsGCP) collectComputeInstances(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, opts []option.ClientOption) {
+	if !computeCollector.isEnabled(s.config.AssetTypes) {
+		return
+	}
+	client, err := compute.NewInstancesRESTClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting compute assets: %+v", err)
+		return
+	}
+	defer client.Close()
+
+	listClient := listInstanceAPIClient{
+		AggregatedList: func(ctx context.Context, req *computepb.AggregatedListInstancesRequest, opts ...gax.CallOption) AggregatedInstanceIterator {
+			return client.AggregatedList(ctx, req, opts...)
+		},
+	}
+	err = collectComputeAssets(ctx, s.config, s.SubnetAssetsCache, s.ComputeAssetsCache, listClient, publisher, log)
+	if err != nil {
+		log.Errorf("error collecting compute assets: %+v", err)
+	}
+}
+
+func (s *assetsGCP) collectGKEClusters(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, opts []option.ClientOption) {
+	if !gkeCollector.isEnabled(s.config.AssetTypes) {
+		return
+	}
+	client, err := container.NewClusterManagerClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting GKE assets: %+v", err)
+		return
+	}
+	defer client.Close()
+
+	computeClient, err := compute.NewInstancesRESTClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting GKE assets: %+v", err)
+		return
+	}
+	defer computeClient.Close()
+
+	instanceGroupClient, err := compute.NewInstanceGroupManagersRESTClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting GKE assets: %+v", err)
+		return
+	}
+	defer instanceGroupClient.Close()
+
+	listClient := listInstanceAPIClient{
+		AggregatedList: func(ctx context.Context, req *computepb.AggregatedListInstancesRequest, opts ...gax.CallOption) AggregatedInstanceIterator {
+			return computeClient.AggregatedList(ctx, req, opts...)
+		},
+	}
+	err = collectGKEAssets(ctx, s.config, s.VpcAssetsCache, s.ComputeAssetsCache, log, listClient, client, instanceGroupClient, publisher)
+	if err != nil {
+		log.Errorf("error collecting GKE assets: %+v", err)
+	}
+}
+
+func (s *assetsGCP) collectDisks(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, opts []option.ClientOption) {
+	if !diskCollector.isEnabled(s.config.AssetTypes) {
+		return
+	}
+	client, err := compute.NewDisksRESTClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting disk assets: %+v", err)
+		return
+	}
+	defer client.Close()
+
+	listClient := listDiskAPIClient{
+		AggregatedList: func(ctx context.Context, req *computepb.AggregatedListDisksRequest, opts ...gax.CallOption) AggregatedDiskIterator {
+			return client.AggregatedList(ctx, req, opts...)
+		},
+	}
+	err = collectDiskAssets(ctx, s.config, s.ComputeAssetsCache, listClient, publisher, log)
+	if err != nil {
+		log.Errorf("error collecting disk assets: %+v", err)
+	}
+}
+
+func (s *assetsGCP) collectCloudRunServices(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, opts []option.ClientOption) {
+	if !cloudRunCollector.isEnabled(s.config.AssetTypes) {
+		return
+	}
+	client, err := run.NewServicesClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting Cloud Run assets: %+v", err)
+		return
+	}
+	defer client.Close()
+
+	listClient := listCloudRunServicesAPIClient{
+		ListServices: func(ctx context.Context, req *runpb.ListServicesRequest, opts ...gax.CallOption) CloudRunServiceIterator {
+			return client.ListServices(ctx, req, opts...)
+		},
+	}
+	connectorClient, err := vpcaccess.NewClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting Cloud Run assets: %+v", err)
+		return
+	}
+	defer connectorClient.Close()
+
+	err = collectCloudRunAssets(ctx, s.config, s.VpcAssetsCache, listClient, connectorClient, publisher, log)
+	if err != nil {
+		log.Errorf("error collecting Cloud Run assets: %+v", err)
+	}
+}
+
+func (s *assetsGCP) collectCloudFunctions(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, opts []option.ClientOption) {
+	if !cloudFunctionCollector.isEnabled(s.config.AssetTypes) {
+		return
+	}
+	client, err := functions.NewFunctionClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting Cloud Functions assets: %+v", err)
+		return
+	}
+	defer client.Close()
+
+	listClient := listCloudFunctionsAPIClient{
+		ListFunctions: func(ctx context.Context, req *functionspb.ListFunctionsRequest, opts ...gax.CallOption) CloudFunctionIterator {
+			return client.ListFunctions(ctx, req, opts...)
+		},
+	}
+	connectorClient, err := vpcaccess.NewClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting Cloud Functions assets: %+v", err)
+		return
+	}
+	defer connectorClient.Close()
+
+	err = collectCloudFunctionAssets(ctx, s.config, s.VpcAssetsCache, listClient, connectorClient, publisher, log)
+	if err != nil {
+		log.Errorf("error collecting Cloud Functions assets: %+v", err)
+	}
+}
+
+func (s *assetsGCP) collectLoadBalancers(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, opts []option.ClientOption) {
+	if !backendServiceCollector.isEnabled(s.config.AssetTypes) {
+		return
+	}
+	forwardingRuleClient, err := compute.NewForwardingRulesRESTClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting load balancing assets: %+v", err)
+		return
+	}
+	defer forwardingRuleClient.Close()
+
+	backendServiceClient, err := compute.NewBackendServicesRESTClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting load balancing assets: %+v", err)
+		return
+	}
+	defer backendServiceClient.Close()
+
+	instanceGroupClient, err := compute.NewInstanceGroupManagersRESTClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting load balancing assets: %+v", err)
+		return
+	}
+	defer instanceGroupClient.Close()
+
+	httpProxyClient, err := compute.NewTargetHttpProxiesRESTClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting load balancing assets: %+v", err)
+		return
+	}
+	defer httpProxyClient.Close()
+
+	httpsProxyClient, err := compute.NewTargetHttpsProxiesRESTClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting load balancing assets: %+v", err)
+		return
+	}
+	defer httpsProxyClient.Close()
+
+	tcpProxyClient, err := compute.NewTargetTcpProxiesRESTClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting load balancing assets: %+v", err)
+		return
+	}
+	defer tcpProxyClient.Close()
+
+	sslProxyClient, err := compute.NewTargetSslProxiesRESTClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting load balancing assets: %+v", err)
+		return
+	}
+	defer sslProxyClient.Close()
+
+	urlMapClient, err := compute.NewUrlMapsRESTClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting load balancing assets: %+v", err)
+		return
+	}
+	defer urlMapClient.Close()
+
+	listForwardingRuleClient := listForwardingRuleAPIClient{
+		AggregatedList: func(ctx context.Context, req *computepb.AggregatedListForwardingRulesRequest, opts ...gax.CallOption) AggregatedForwardingRuleIterator {
+			return forwardingRuleClient.AggregatedList(ctx, req, opts...)
+		},
+	}
+	listBackendServiceClient := listBackendServiceAPIClient{
+		AggregatedList: func(ctx context.Context, req *computepb.AggregatedListBackendServicesRequest, opts ...gax.CallOption) AggregatedBackendServiceIterator {
+			return backendServiceClient.AggregatedList(ctx, req, opts...)
+		},
+	}
+	listTargetClient := listTargetAPIClient{
+		AggregatedListHttpProxies: func(ctx context.Context, req *computepb.AggregatedListTargetHttpProxiesRequest, opts ...gax.CallOption) AggregatedTargetHttpProxyIterator {
+			return httpProxyClient.AggregatedList(ctx, req, opts...)
+		},
+		AggregatedListHttpsProxies: func(ctx context.Context, req *computepb.AggregatedListTargetHttpsProxiesRequest, opts ...gax.CallOption) AggregatedTargetHttpsProxyIterator {
+			return httpsProxyClient.AggregatedList(ctx, req, opts...)
+		},
+		AggregatedListTcpProxies: func(ctx context.Context, req *computepb.AggregatedListTargetTcpProxiesRequest, opts ...gax.CallOption) AggregatedTargetTcpProxyIterator {
+			return tcpProxyClient.AggregatedList(ctx, req, opts...)
+		},
+		ListSslProxies: func(ctx context.Context, req *computepb.ListTargetSslProxiesRequest, opts ...gax.CallOption) TargetSslProxyIterator {
+			return sslProxyClient.List(ctx, req, opts...)
+		},
+		AggregatedListUrlMaps: func(ctx context.Context, req *computepb.AggregatedListUrlMapsRequest, opts ...gax.CallOption) AggregatedUrlMapIterator {
+			return urlMapClient.AggregatedList(ctx, req, opts...)
+		},
+	}
+	err = collectLoadBalancingAssets(ctx, s.config, s.VpcAssetsCache, listForwardingRuleClient, listTargetClient, listBackendServiceClient, instanceGroupClient, publisher, log)
+	if err != nil {
+		log.Errorf("error collecting load balancing assets: %+v", err)
+	}
+}
+
+func (s *assetsGCP) collectFirewalls(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, opts []option.ClientOption) {
+	if !firewallCollector.isEnabled(s.config.AssetTypes) {
+		return
+	}
+	client, err := compute.NewFirewallsRESTClient(ctx, opts...)
+	if err != nil {
+		log.Errorf("error collecting firewall assets: %+v", err)
+		return
+	}
+	defer client.Close()
+
+	listClient := listFirewallAPIClient{
+		List: func(ctx context.Context, req *computepb.ListFirewallsRequest, opts ...gax.CallOption) FirewallIterator {
+			return client.List(ctx, req, opts...)
+		},
+	}
+	err = collectFirewallAssets(ctx, s.config, s.VpcAssetsCache, listClient, publisher, log)
+	if err != nil {
+		log.Errorf("error collecting firewall assets: %+v", err)
+	}
+}
+
+// buildClientOptions returns the options used to authenticate the GCP clients.
+// When a service account is impersonated, the configured credentials, or the
+// application default credentials, are only used to obtain its tokens.
+func buildClientOptions(ctx context.Context, cfg config) ([]option.ClientOption, error) {
 	var opts []option.ClientOption
 
-	if cfg.CredsFilePath != "" {
+	switch {
+	case cfg.CredsFilePath != "" && cfg.ExternalAccountFilePath != "":
+		return nil, errors.New("credentials_file_path and external_account_file_path cannot be used together")
+	case cfg.CredsFilePath != "":
 		opts = append(opts, option.WithCredentialsFile(cfg.CredsFilePath))
+	case cfg.ExternalAccountFilePath != "":
+		if err := checkExternalAccountFile(cfg.ExternalAccountFilePath); err != nil {
+			return nil, err
+		}
+		opts = append(opts, option.WithCredentialsFile(cfg.ExternalAccountFilePath))
 	}
 
-	return opts
+	if cfg.ImpersonateServiceAccount != "" {
+		ts, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+			TargetPrincipal: cfg.ImpersonateServiceAccount,
+			Delegates:       cfg.ImpersonateDelegates,
+			Scopes:          []string{cloudPlatformScope},
+		}, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("error impersonating service account %s: %w", cfg.ImpersonateServiceAccount, err)
+		}
+		opts = []option.ClientOption{option.WithTokenSource(ts)}
+	}
+
+	return opts, nil
+}
+
+// checkExternalAccountFile verifies that path holds a workload identity
+// federation credential configuration.
+func checkExternalAccountFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading external account file: %w", err)
+	}
+	var f struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("invalid external account file %s: %w", path, err)
+	}
+	if f.Type != "external_account" {
+		return fmt.Errorf("invalid external account file %s: unexpected credentials type %q", path, f.Type)
+	}
+	return nil
 }
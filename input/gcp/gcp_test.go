@@ -19,6 +19,8 @@ package gcp
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -87,11 +89,20 @@ func TestAssetsGCP_CollectAll(t *testing.T) {
 }
 
 func TestBuildClientOptions(t *testing.T) {
+	dir := t.TempDir()
+	externalAccountFile := filepath.Join(dir, "external_account.json")
+	err := os.WriteFile(externalAccountFile, []byte(`{"type": "external_account", "audience": "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/my-pool/providers/my-provider"}`), 0o600)
+	assert.NoError(t, err)
+	serviceAccountFile := filepath.Join(dir, "service_account.json")
+	err = os.WriteFile(serviceAccountFile, []byte(`{"type": "service_account"}`), 0o600)
+	assert.NoError(t, err)
+
 	for _, tt := range []struct {
 		name string
 
-		cfg          config
-		expectedOpts []option.ClientOption
+		cfg           config
+		expectedOpts  []option.ClientOption
+		expectedError string
 	}{
 		{
 			name: "with an empty config",
@@ -106,9 +117,50 @@ func TestBuildClientOptions(t *testing.T) {
 				option.WithCredentialsFile("/tmp/file_path"),
 			},
 		},
+		{
+			name: "with an external account file path",
+
+			cfg: config{
+				ExternalAccountFilePath: externalAccountFile,
+			},
+			expectedOpts: []option.ClientOption{
+				option.WithCredentialsFile(externalAccountFile),
+			},
+		},
+		{
+			name: "with an external account file holding another type of credentials",
+
+			cfg: config{
+				ExternalAccountFilePath: serviceAccountFile,
+			},
+			expectedError: "unexpected credentials type \"service_account\"",
+		},
+		{
+			name: "with both a credentials file path and an external account file path",
+
+			cfg: config{
+				CredsFilePath:           "/tmp/file_path",
+				ExternalAccountFilePath: externalAccountFile,
+			},
+			expectedError: "cannot be used together",
+		},
+		{
+			name: "with an impersonated service account and invalid base credentials",
+
+			cfg: config{
+				CredsFilePath:             filepath.Join(dir, "missing.json"),
+				ImpersonateServiceAccount: "assetbeat@my_project.iam.gserviceaccount.com",
+			},
+			expectedError: "error impersonating service account assetbeat@my_project.iam.gserviceaccount.com",
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
-			opts := buildClientOptions(tt.cfg)
+			opts, err := buildClientOptions(context.Background(), tt.cfg)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedOpts, opts)
 		})
 	}
@@ -34,7 +34,6 @@ import (
 	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
 	"github.com/elastic/elastic-agent-libs/logp"
 	"github.com/elastic/elastic-agent-libs/mapstr"
-	"github.com/elastic/go-freelru"
 )
 
 type listClustersAPIClient interface {
@@ -52,7 +51,19 @@ type containerCluster struct {
 	Name      string
 }
 
-func collectGKEAssets(ctx context.Context, cfg config, vpcAssetCache *freelru.LRU[string, *vpc], computeAssetCache *freelru.LRU[string, *computeInstance], log *logp.Logger, listInstanceClient listInstanceAPIClient, listClusterClient listClustersAPIClient, publisher stateless.Publisher) error {
+type getInstanceGroupManagerAPIClient interface {
+	Get(ctx context.Context, req *computepb.GetInstanceGroupManagerRequest, opts ...gax.CallOption) (*computepb.InstanceGroupManager, error)
+}
+
+type instanceGroup struct {
+	ID       string
+	Region   string
+	Account  string
+	Metadata mapstr.M
+	Name     string
+}
+
+func collectGKEAssets(ctx context.Context, cfg config, vpcAssetCache *assetCache[*vpc], computeAssetCache *assetCache[*computeInstance], log *logp.Logger, listInstanceClient listInstanceAPIClient, listClusterClient listClustersAPIClient, instanceGroupClient getInstanceGroupManagerAPIClient, publisher stateless.Publisher) error {
 
 	clusters, err := getAllGKEClusters(ctx, cfg, listClusterClient, vpcAssetCache)
 	if err != nil {
@@ -76,10 +87,21 @@ func collectGKEAssets(ctx context.Context, cfg config, vpcAssetCache *freelru.LR
 		if err != nil {
 			log.Warnf("Error while retrieving instances for GKE cluster %s: %+v", cluster.ID, err)
 		}
-		for _, instance := range instances {
-			children = append(children, "host:"+instance)
+
+		// the cluster is linked to its node pools if they are collected, or else to their children
+		clusterEAN := assetKind + ":" + cluster.ID
+		for _, nodePool := range cluster.NodePools {
+			nodePoolChildren := publishGKENodePool(ctx, cfg, cluster, nodePool, clusterEAN, instances, instanceGroupClient, publisher, log)
+			if internal.IsTypeEnabled(cfg.AssetTypes, "gcp.gke.nodepool") {
+				children = append(children, "node_pool:"+getGKENodePoolID(cluster.ID, nodePool.Name))
+			} else {
+				children = append(children, nodePoolChildren...)
+			}
 		}
 
+		if !internal.IsTypeEnabled(cfg.AssetTypes, assetType) {
+			continue
+		}
 		options := []internal.AssetOption{
 			internal.WithAssetCloudProvider("gcp"),
 			internal.WithAssetRegion(cluster.Region),
@@ -101,6 +123,127 @@ func collectGKEAssets(ctx context.Context, cfg config, vpcAssetCache *freelru.LR
 	return nil
 }
 
+// publishGKENodePool publishes a GKE node pool together with the managed
+// instance groups backing it. instances maps each managed instance group,
+// identified by zone and name, to the ids of the instances it manages.
+// The children of the node pool are returned: its instance groups if they
+// are collected, or else the instances of the groups, so that only
+// collected assets are linked to.
+func publishGKENodePool(ctx context.Context, cfg config, cluster containerCluster, nodePool *containerpb.NodePool, clusterEAN string, instances map[string][]string, instanceGroupClient getInstanceGroupManagerAPIClient, publisher stateless.Publisher, log *logp.Logger) []string {
+	assetType := "gcp.gke.nodepool"
+	assetKind := "node_pool"
+	nodePoolID := getGKENodePoolID(cluster.ID, nodePool.Name)
+	nodePoolEAN := assetKind + ":" + nodePoolID
+
+	var children []string
+	for _, url := range nodePool.InstanceGroupUrls {
+		var hosts []string
+		for _, instance := range instances[getZonalResourceKey(url)] {
+			hosts = append(hosts, "host:"+instance)
+		}
+		if !internal.IsTypeEnabled(cfg.AssetTypes, "gcp.compute.instance_group") {
+			children = append(children, hosts...)
+			continue
+		}
+
+		group, err := getInstanceGroup(ctx, cluster.Account, url, instanceGroupClient)
+		if err != nil {
+			log.Warnf("Error while retrieving instance group %s for GKE node pool %s: %+v", url, nodePoolID, err)
+			children = append(children, hosts...)
+			continue
+		}
+		children = append(children, "instance_group:"+group.ID)
+
+		options := []internal.AssetOption{
+			internal.WithAssetCloudProvider("gcp"),
+			internal.WithAssetRegion(group.Region),
+			internal.WithAssetAccountID(group.Account),
+			internal.WithAssetKindAndID("instance_group", group.ID),
+			internal.WithAssetType("gcp.compute.instance_group"),
+			internal.WithAssetParents([]string{nodePoolEAN}),
+			internal.WithAssetChildren(hosts),
+			internal.WithAssetMetadata(group.Metadata),
+		}
+		if group.Name != "" {
+			options = append(options, internal.WithAssetName(group.Name))
+		}
+		internal.Publish(publisher, nil, options...)
+	}
+
+	if !internal.IsTypeEnabled(cfg.AssetTypes, assetType) {
+		return children
+	}
+	metadata := mapstr.M{
+		"state":              nodePool.GetStatus().String(),
+		"version":            nodePool.GetVersion(),
+		"machine_type":       nodePool.GetConfig().GetMachineType(),
+		"initial_node_count": nodePool.GetInitialNodeCount(),
+	}
+	if autoscaling := nodePool.GetAutoscaling(); autoscaling != nil {
+		metadata["autoscaling"] = mapstr.M{
+			"enabled":              autoscaling.GetEnabled(),
+			"min_node_count":       autoscaling.GetMinNodeCount(),
+			"max_node_count":       autoscaling.GetMaxNodeCount(),
+			"total_min_node_count": autoscaling.GetTotalMinNodeCount(),
+			"total_max_node_count": autoscaling.GetTotalMaxNodeCount(),
+			"location_policy":      autoscaling.GetLocationPolicy().String(),
+		}
+	}
+	options := []internal.AssetOption{
+		internal.WithAssetCloudProvider("gcp"),
+		internal.WithAssetRegion(cluster.Region),
+		internal.WithAssetAccountID(cluster.Account),
+		internal.WithAssetKindAndID(assetKind, nodePoolID),
+		internal.WithAssetType(assetType),
+		internal.WithAssetParents([]string{clusterEAN}),
+		internal.WithAssetChildren(children),
+		WithAssetLabels(internal.ToMapstr(nodePool.GetConfig().GetLabels())),
+		internal.WithAssetMetadata(metadata),
+	}
+	if nodePool.Name != "" {
+		options = append(options, internal.WithAssetName(nodePool.Name))
+	}
+	internal.Publish(publisher, nil, options...)
+	return children
+}
+
+// getGKENodePoolID builds a node pool id, as node pool names are only unique within a cluster.
+func getGKENodePoolID(clusterID string, nodePoolName string) string {
+	return clusterID + "/" + nodePoolName
+}
+
+// getInstanceGroup retrieves the managed instance group referenced by url, in the form of
+// https://www.googleapis.com/compute/v1/projects/{project}/zones/{zone}/instanceGroupManagers/{name}
+func getInstanceGroup(ctx context.Context, project string, url string, client getInstanceGroupManagerAPIClient) (instanceGroup, error) {
+	zone := getZoneFromResourceURL(url)
+	if zone == "" {
+		return instanceGroup{}, fmt.Errorf("unable to extract zone from instance group url %s", url)
+	}
+	req := &computepb.GetInstanceGroupManagerRequest{
+		Project:              project,
+		Zone:                 zone,
+		InstanceGroupManager: getResourceNameFromURL(url),
+	}
+	mig, err := client.Get(ctx, req)
+	if err != nil {
+		return instanceGroup{}, err
+	}
+
+	return instanceGroup{
+		ID:      strconv.FormatUint(mig.GetId(), 10),
+		Region:  getRegionFromZoneURL(zone),
+		Account: project,
+		Metadata: mapstr.M{
+			"zone":               zone,
+			"target_size":        mig.GetTargetSize(),
+			"base_instance_name": mig.GetBaseInstanceName(),
+			"instance_template":  getResourceNameFromURL(mig.GetInstanceTemplate()),
+			"is_stable":          mig.GetStatus().GetIsStable(),
+		},
+		Name: mig.GetName(),
+	}, nil
+}
+
 func getGKEInstanceKubeLabels(rawMd *computepb.Metadata) map[string]string {
 	mappedMd := make(map[string]string)
 	for _, item := range rawMd.GetItems() {
@@ -120,21 +263,34 @@ func getGKEInstanceKubeLabels(rawMd *computepb.Metadata) map[string]string {
 	return mappedMd
 }
 
-func getAllInstancesForGKECluster(ctx context.Context, project string, region string, nodePools []*containerpb.NodePool, computeAssetCache *freelru.LRU[string, *computeInstance], client listInstanceAPIClient) ([]string, error) {
-	var instanceIDs []string
-	var err error
-	if computeAssetCache.Len() != 0 {
-		instanceIDs, err = getInstancesFromCache(ctx, region, nodePools, computeAssetCache)
-		if err != nil {
-			return instanceIDs, err
+// getAllInstancesForGKECluster returns the ids of the instances belonging to the given node pools,
+// grouped by the managed instance group that created them.
+func getAllInstancesForGKECluster(ctx context.Context, project string, region string, nodePools []*containerpb.NodePool, computeAssetCache *assetCache[*computeInstance], client listInstanceAPIClient) (map[string][]string, error) {
+	if instances := computeAssetCache.Values(); len(instances) != 0 {
+		return getInstancesFromCache(region, nodePools, instances), nil
+	}
+	return getInstancesFromApi(ctx, project, region, nodePools, client)
+}
+
+// getInstanceGroupKey returns the zone and name of the managed instance group
+// that created an instance, based on its created-by metadata.
+func getInstanceGroupKey(rawMd *computepb.Metadata) string {
+	for _, item := range rawMd.GetItems() {
+		if item.GetKey() == "created-by" {
+			return getZonalResourceKey(item.GetValue())
 		}
-	} else {
-		instanceIDs, err = getInstancesFromApi(ctx, project, region, nodePools, client)
-		if err != nil {
-			return instanceIDs, err
+	}
+	return ""
+}
+
+func isInNodePools(rawMd *computepb.Metadata, nodePools []*containerpb.NodePool) bool {
+	metadata := getGKEInstanceKubeLabels(rawMd)
+	for _, nodePool := range nodePools {
+		if metadata["cloud.google.com/gke-nodepool"] == nodePool.Name {
+			return true
 		}
 	}
-	return instanceIDs, nil
+	return false
 }
 
 func makeListClusterRequests(project string, zones []string) []*containerpb.ListClustersRequest {
@@ -155,7 +311,7 @@ func makeListClusterRequests(project string, zones []string) []*containerpb.List
 	return requests
 }
 
-func getAllGKEClusters(ctx context.Context, cfg config, client listClustersAPIClient, vpcAssetCache *freelru.LRU[string, *vpc]) ([]containerCluster, error) {
+func getAllGKEClusters(ctx context.Context, cfg config, client listClustersAPIClient, vpcAssetCache *assetCache[*vpc]) ([]containerCluster, error) {
 	var clusters []containerCluster
 	var zones []string
 	if len(cfg.Regions) > 0 {
@@ -194,8 +350,8 @@ func getAllGKEClusters(ctx context.Context, cfg config, client listClustersAPICl
 	return clusters, nil
 }
 
-func getInstancesFromApi(ctx context.Context, project string, region string, nodePools []*containerpb.NodePool, client listInstanceAPIClient) ([]string, error) {
-	var instanceIDs []string
+func getInstancesFromApi(ctx context.Context, project string, region string, nodePools []*containerpb.NodePool, client listInstanceAPIClient) (map[string][]string, error) {
+	instanceIDs := make(map[string][]string)
 	zoneFilter := fmt.Sprintf("zone eq .*%s.*", region)
 	req := &computepb.AggregatedListInstancesRequest{
 		Project: project,
@@ -212,37 +368,25 @@ func getInstancesFromApi(ctx context.Context, project string, region string, nod
 			return nil, err
 		}
 		for _, i := range instanceScopedPair.Value.Instances {
-			metadata := getGKEInstanceKubeLabels(i.Metadata)
-			for _, nodePool := range nodePools {
-				if metadata["cloud.google.com/gke-nodepool"] == nodePool.Name {
-					id := strconv.FormatUint(*i.Id, 10)
-					instanceIDs = append(instanceIDs, id)
-				}
+			if isInNodePools(i.Metadata, nodePools) {
+				key := getInstanceGroupKey(i.Metadata)
+				instanceIDs[key] = append(instanceIDs[key], strconv.FormatUint(*i.Id, 10))
 			}
-
 		}
 	}
 	return instanceIDs, nil
 }
 
-func getInstancesFromCache(ctx context.Context, region string, nodePools []*containerpb.NodePool, computeAssetCache *freelru.LRU[string, *computeInstance]) ([]string, error) {
-	var instanceIDs []string
-	for _, selfLink := range computeAssetCache.Keys() {
-		i, ok := computeAssetCache.Get(selfLink)
-		if !ok {
-			return instanceIDs, fmt.Errorf("compute instance with selfLink %s is not present in cache", selfLink)
-		}
+func getInstancesFromCache(region string, nodePools []*containerpb.NodePool, instances []*computeInstance) map[string][]string {
+	instanceIDs := make(map[string][]string)
+	for _, i := range instances {
 		if i.Region != region {
 			continue
 		}
-		metadata := getGKEInstanceKubeLabels(i.RawMd)
-		for _, nodePool := range nodePools {
-			if metadata["cloud.google.com/gke-nodepool"] == nodePool.Name {
-				id := i.ID
-				instanceIDs = append(instanceIDs, id)
-			}
+		if isInNodePools(i.RawMd, nodePools) {
+			key := getInstanceGroupKey(i.RawMd)
+			instanceIDs[key] = append(instanceIDs[key], i.ID)
 		}
-
 	}
-	return instanceIDs, nil
+	return instanceIDs
 }
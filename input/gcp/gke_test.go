@@ -24,8 +24,6 @@ import (
 	"regexp"
 	"testing"
 
-	"github.com/elastic/go-freelru"
-
 	"github.com/gogo/protobuf/proto"
 
 	"github.com/elastic/assetbeat/input/testutil"
@@ -54,6 +52,18 @@ func (s *ClustersClientStub) ListClusters(ctx context.Context, req *containerpb.
 	return s.Clusters[project], nil
 }
 
+type InstanceGroupManagersClientStub struct {
+	InstanceGroupManagers map[string]*computepb.InstanceGroupManager
+}
+
+func (s *InstanceGroupManagersClientStub) Get(ctx context.Context, req *computepb.GetInstanceGroupManagerRequest, opts ...gax.CallOption) (*computepb.InstanceGroupManager, error) {
+	mig, ok := s.InstanceGroupManagers[req.InstanceGroupManager]
+	if !ok {
+		return nil, fmt.Errorf("instance group manager %s not found", req.InstanceGroupManager)
+	}
+	return mig, nil
+}
+
 var findGKEProjectRe = regexp.MustCompile("projects/([a-z_-]+)/locations/([0-9a-z_,-]+)")
 
 func TestCollectGKEAssets(t *testing.T) {
@@ -67,7 +77,8 @@ func TestCollectGKEAssets(t *testing.T) {
 		cfg                config
 		apiResponses       map[string]*containerpb.ListClustersResponse
 		instances          map[string]*StubAggregatedInstanceListIterator
-		computeAssetsCache *freelru.LRU[string, *computeInstance]
+		instanceGroups     map[string]*computepb.InstanceGroupManager
+		computeAssetsCache *assetCache[*computeInstance]
 		expectedEvents     []beat.Event
 	}{
 		{
@@ -386,7 +397,19 @@ func TestCollectGKEAssets(t *testing.T) {
 							Status: containerpb.Cluster_RUNNING,
 							NodePools: []*containerpb.NodePool{
 								{
-									Name: "mynodepool",
+									Name:              "mynodepool",
+									Status:            containerpb.NodePool_RUNNING,
+									Version:           "1.27.3-gke.100",
+									InitialNodeCount:  3,
+									InstanceGroupUrls: []string{"https://www.googleapis.com/compute/v1/projects/my_project/zones/europe-west1-d/instanceGroupManagers/gke-mycluster-mynodepool-grp"},
+									Config: &containerpb.NodeConfig{
+										MachineType: "e2-medium",
+									},
+									Autoscaling: &containerpb.NodePoolAutoscaling{
+										Enabled:      true,
+										MinNodeCount: 1,
+										MaxNodeCount: 5,
+									},
 								},
 							},
 							Name: "myCluster",
@@ -415,6 +438,10 @@ func TestCollectGKEAssets(t *testing.T) {
 												Key:   proto.String("kube-labels"),
 												Value: proto.String("cloud.google.com/gke-nodepool=mynodepool"),
 											},
+											{
+												Key:   proto.String("created-by"),
+												Value: proto.String("projects/123456789/zones/europe-west1-d/instanceGroupManagers/gke-mycluster-mynodepool-grp"),
+											},
 										},
 									}},
 							},
@@ -423,8 +450,69 @@ func TestCollectGKEAssets(t *testing.T) {
 					},
 				},
 			},
+			instanceGroups: map[string]*computepb.InstanceGroupManager{
+				"gke-mycluster-mynodepool-grp": {
+					Id:               proto.Uint64(42),
+					Name:             proto.String("gke-mycluster-mynodepool-grp"),
+					BaseInstanceName: proto.String("gke-mycluster-mynodepool"),
+					InstanceTemplate: proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/instanceTemplates/gke-mycluster-mynodepool-tpl"),
+					TargetSize:       proto.Int32(3),
+					Status: &computepb.InstanceGroupManagerStatus{
+						IsStable: proto.Bool(true),
+					},
+				},
+			},
 			computeAssetsCache: getTestComputeCache(),
 			expectedEvents: []beat.Event{
+				{
+					Fields: mapstr.M{
+						"asset.ean":                         "instance_group:42",
+						"asset.id":                          "42",
+						"asset.type":                        "gcp.compute.instance_group",
+						"asset.kind":                        "instance_group",
+						"asset.parents":                     []string{"node_pool:1/mynodepool"},
+						"asset.children":                    []string{"host:123"},
+						"asset.name":                        "gke-mycluster-mynodepool-grp",
+						"asset.metadata.zone":               "europe-west1-d",
+						"asset.metadata.target_size":        int32(3),
+						"asset.metadata.base_instance_name": "gke-mycluster-mynodepool",
+						"asset.metadata.instance_template":  "gke-mycluster-mynodepool-tpl",
+						"asset.metadata.is_stable":          true,
+						"cloud.account.id":                  "my_project",
+						"cloud.provider":                    "gcp",
+						"cloud.region":                      "europe-west1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+				{
+					Fields: mapstr.M{
+						"asset.ean":                                       "node_pool:1/mynodepool",
+						"asset.id":                                        "1/mynodepool",
+						"asset.type":                                      "gcp.gke.nodepool",
+						"asset.kind":                                      "node_pool",
+						"asset.parents":                                   []string{"cluster:1"},
+						"asset.children":                                  []string{"instance_group:42"},
+						"asset.name":                                      "mynodepool",
+						"asset.metadata.state":                            "RUNNING",
+						"asset.metadata.version":                          "1.27.3-gke.100",
+						"asset.metadata.machine_type":                     "e2-medium",
+						"asset.metadata.initial_node_count":               int32(3),
+						"asset.metadata.autoscaling.enabled":              true,
+						"asset.metadata.autoscaling.min_node_count":       int32(1),
+						"asset.metadata.autoscaling.max_node_count":       int32(5),
+						"asset.metadata.autoscaling.total_min_node_count": int32(0),
+						"asset.metadata.autoscaling.total_max_node_count": int32(0),
+						"asset.metadata.autoscaling.location_policy":      "LOCATION_POLICY_UNSPECIFIED",
+						"cloud.account.id":                                "my_project",
+						"cloud.provider":                                  "gcp",
+						"cloud.region":                                    "europe-west1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
 				{
 					Fields: mapstr.M{
 						"asset.ean":            "cluster:1",
@@ -433,7 +521,7 @@ func TestCollectGKEAssets(t *testing.T) {
 						"asset.kind":           "cluster",
 						"asset.parents":        []string{"network:1"},
 						"asset.metadata.state": "RUNNING",
-						"asset.children":       []string{"host:123"},
+						"asset.children":       []string{"node_pool:1/mynodepool"},
 						"asset.name":           "myCluster",
 						"cloud.account.id":     "my_project",
 						"cloud.provider":       "gcp",
@@ -466,7 +554,19 @@ func TestCollectGKEAssets(t *testing.T) {
 							Status: containerpb.Cluster_RUNNING,
 							NodePools: []*containerpb.NodePool{
 								{
-									Name: "mynodepool",
+									Name:              "mynodepool",
+									Status:            containerpb.NodePool_RUNNING,
+									Version:           "1.27.3-gke.100",
+									InitialNodeCount:  3,
+									InstanceGroupUrls: []string{"https://www.googleapis.com/compute/v1/projects/my_project/zones/europe-west1-d/instanceGroupManagers/gke-mycluster-mynodepool-grp"},
+									Config: &containerpb.NodeConfig{
+										MachineType: "e2-medium",
+									},
+									Autoscaling: &containerpb.NodePoolAutoscaling{
+										Enabled:      true,
+										MinNodeCount: 1,
+										MaxNodeCount: 5,
+									},
 								},
 							},
 							Name: "myCluster",
@@ -495,6 +595,10 @@ func TestCollectGKEAssets(t *testing.T) {
 												Key:   proto.String("kube-labels"),
 												Value: proto.String("cloud.google.com/gke-nodepool=mynodepool"),
 											},
+											{
+												Key:   proto.String("created-by"),
+												Value: proto.String("projects/123456789/zones/europe-west1-d/instanceGroupManagers/gke-mycluster-mynodepool-grp"),
+											},
 										},
 									}},
 							},
@@ -503,8 +607,263 @@ func TestCollectGKEAssets(t *testing.T) {
 					},
 				},
 			},
-			computeAssetsCache: getComputeCache(),
+			instanceGroups: map[string]*computepb.InstanceGroupManager{
+				"gke-mycluster-mynodepool-grp": {
+					Id:               proto.Uint64(42),
+					Name:             proto.String("gke-mycluster-mynodepool-grp"),
+					BaseInstanceName: proto.String("gke-mycluster-mynodepool"),
+					InstanceTemplate: proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/instanceTemplates/gke-mycluster-mynodepool-tpl"),
+					TargetSize:       proto.Int32(3),
+					Status: &computepb.InstanceGroupManagerStatus{
+						IsStable: proto.Bool(true),
+					},
+				},
+			},
+			computeAssetsCache: getComputeCache(nil),
+			expectedEvents: []beat.Event{
+				{
+					Fields: mapstr.M{
+						"asset.ean":                         "instance_group:42",
+						"asset.id":                          "42",
+						"asset.type":                        "gcp.compute.instance_group",
+						"asset.kind":                        "instance_group",
+						"asset.parents":                     []string{"node_pool:1/mynodepool"},
+						"asset.children":                    []string{"host:124"},
+						"asset.name":                        "gke-mycluster-mynodepool-grp",
+						"asset.metadata.zone":               "europe-west1-d",
+						"asset.metadata.target_size":        int32(3),
+						"asset.metadata.base_instance_name": "gke-mycluster-mynodepool",
+						"asset.metadata.instance_template":  "gke-mycluster-mynodepool-tpl",
+						"asset.metadata.is_stable":          true,
+						"cloud.account.id":                  "my_project",
+						"cloud.provider":                    "gcp",
+						"cloud.region":                      "europe-west1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+				{
+					Fields: mapstr.M{
+						"asset.ean":                                       "node_pool:1/mynodepool",
+						"asset.id":                                        "1/mynodepool",
+						"asset.type":                                      "gcp.gke.nodepool",
+						"asset.kind":                                      "node_pool",
+						"asset.parents":                                   []string{"cluster:1"},
+						"asset.children":                                  []string{"instance_group:42"},
+						"asset.name":                                      "mynodepool",
+						"asset.metadata.state":                            "RUNNING",
+						"asset.metadata.version":                          "1.27.3-gke.100",
+						"asset.metadata.machine_type":                     "e2-medium",
+						"asset.metadata.initial_node_count":               int32(3),
+						"asset.metadata.autoscaling.enabled":              true,
+						"asset.metadata.autoscaling.min_node_count":       int32(1),
+						"asset.metadata.autoscaling.max_node_count":       int32(5),
+						"asset.metadata.autoscaling.total_min_node_count": int32(0),
+						"asset.metadata.autoscaling.total_max_node_count": int32(0),
+						"asset.metadata.autoscaling.location_policy":      "LOCATION_POLICY_UNSPECIFIED",
+						"cloud.account.id":                                "my_project",
+						"cloud.provider":                                  "gcp",
+						"cloud.region":                                    "europe-west1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+				{
+					Fields: mapstr.M{
+						"asset.ean":            "cluster:1",
+						"asset.id":             "1",
+						"asset.type":           "k8s.cluster",
+						"asset.kind":           "cluster",
+						"asset.parents":        []string{"network:1"},
+						"asset.metadata.state": "RUNNING",
+						"asset.children":       []string{"node_pool:1/mynodepool"},
+						"asset.name":           "myCluster",
+						"cloud.account.id":     "my_project",
+						"cloud.provider":       "gcp",
+						"cloud.region":         "europe-west1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+			},
+		},
+		{
+			name: "with asset types filtering out clusters and instance groups",
+
+			ctx: context.Background(),
+			cfg: config{
+				BaseConfig: internal.BaseConfig{
+					AssetTypes: []string{"gcp.gke.nodepool"},
+				},
+				Projects: []string{"my_project"},
+			},
+
+			apiResponses: map[string]*containerpb.ListClustersResponse{
+				"my_project": {
+					Clusters: []*containerpb.Cluster{
+						{
+							Id:       "1",
+							Location: "europe-west1",
+							NetworkConfig: &containerpb.NetworkConfig{
+								Network: "projects/my_project/global/networks/my_network",
+							},
+							Status: containerpb.Cluster_RUNNING,
+							NodePools: []*containerpb.NodePool{
+								{
+									Name:              "mynodepool",
+									Status:            containerpb.NodePool_RUNNING,
+									InstanceGroupUrls: []string{"https://www.googleapis.com/compute/v1/projects/my_project/zones/europe-west1-d/instanceGroupManagers/gke-mycluster-mynodepool-grp"},
+								},
+							},
+							Name: "myCluster",
+						},
+					},
+				},
+			},
+			computeAssetsCache: getTestComputeCache(),
 			expectedEvents: []beat.Event{
+				{
+					Fields: mapstr.M{
+						"asset.ean":                         "node_pool:1/mynodepool",
+						"asset.id":                          "1/mynodepool",
+						"asset.type":                        "gcp.gke.nodepool",
+						"asset.kind":                        "node_pool",
+						"asset.parents":                     []string{"cluster:1"},
+						"asset.children":                    []string{"host:123"},
+						"asset.name":                        "mynodepool",
+						"asset.metadata.state":              "RUNNING",
+						"asset.metadata.version":            "",
+						"asset.metadata.machine_type":       "",
+						"asset.metadata.initial_node_count": int32(0),
+						"cloud.account.id":                  "my_project",
+						"cloud.provider":                    "gcp",
+						"cloud.region":                      "europe-west1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+			},
+		},
+		{
+			name: "with only clusters enabled, the clusters are linked to their hosts",
+
+			ctx: context.Background(),
+			cfg: config{
+				BaseConfig: internal.BaseConfig{
+					AssetTypes: []string{"k8s.cluster"},
+				},
+				Projects: []string{"my_project"},
+			},
+
+			apiResponses: map[string]*containerpb.ListClustersResponse{
+				"my_project": {
+					Clusters: []*containerpb.Cluster{
+						{
+							Id:       "1",
+							Location: "europe-west1",
+							NetworkConfig: &containerpb.NetworkConfig{
+								Network: "projects/my_project/global/networks/my_network",
+							},
+							Status: containerpb.Cluster_RUNNING,
+							NodePools: []*containerpb.NodePool{
+								{
+									Name:              "mynodepool",
+									InstanceGroupUrls: []string{"https://www.googleapis.com/compute/v1/projects/my_project/zones/europe-west1-d/instanceGroupManagers/gke-mycluster-mynodepool-grp"},
+								},
+							},
+							Name: "myCluster",
+						},
+					},
+				},
+			},
+			computeAssetsCache: getTestComputeCache(),
+			expectedEvents: []beat.Event{
+				{
+					Fields: mapstr.M{
+						"asset.ean":            "cluster:1",
+						"asset.id":             "1",
+						"asset.type":           "k8s.cluster",
+						"asset.kind":           "cluster",
+						"asset.parents":        []string{"network:1"},
+						"asset.metadata.state": "RUNNING",
+						"asset.children":       []string{"host:123"},
+						"asset.name":           "myCluster",
+						"cloud.account.id":     "my_project",
+						"cloud.provider":       "gcp",
+						"cloud.region":         "europe-west1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+			},
+		},
+		{
+			name: "with clusters and instance groups enabled, the clusters are linked to their instance groups",
+
+			ctx: context.Background(),
+			cfg: config{
+				BaseConfig: internal.BaseConfig{
+					AssetTypes: []string{"k8s.cluster", "gcp.compute.instance_group"},
+				},
+				Projects: []string{"my_project"},
+			},
+
+			apiResponses: map[string]*containerpb.ListClustersResponse{
+				"my_project": {
+					Clusters: []*containerpb.Cluster{
+						{
+							Id:       "1",
+							Location: "europe-west1",
+							NetworkConfig: &containerpb.NetworkConfig{
+								Network: "projects/my_project/global/networks/my_network",
+							},
+							Status: containerpb.Cluster_RUNNING,
+							NodePools: []*containerpb.NodePool{
+								{
+									Name:              "mynodepool",
+									InstanceGroupUrls: []string{"https://www.googleapis.com/compute/v1/projects/my_project/zones/europe-west1-d/instanceGroupManagers/gke-mycluster-mynodepool-grp"},
+								},
+							},
+							Name: "myCluster",
+						},
+					},
+				},
+			},
+			instanceGroups: map[string]*computepb.InstanceGroupManager{
+				"gke-mycluster-mynodepool-grp": {
+					Id:   proto.Uint64(42),
+					Name: proto.String("gke-mycluster-mynodepool-grp"),
+				},
+			},
+			computeAssetsCache: getTestComputeCache(),
+			expectedEvents: []beat.Event{
+				{
+					Fields: mapstr.M{
+						"asset.ean":                         "instance_group:42",
+						"asset.id":                          "42",
+						"asset.type":                        "gcp.compute.instance_group",
+						"asset.kind":                        "instance_group",
+						"asset.parents":                     []string{"node_pool:1/mynodepool"},
+						"asset.children":                    []string{"host:123"},
+						"asset.name":                        "gke-mycluster-mynodepool-grp",
+						"asset.metadata.zone":               "europe-west1-d",
+						"asset.metadata.target_size":        int32(0),
+						"asset.metadata.base_instance_name": "",
+						"asset.metadata.instance_template":  "",
+						"asset.metadata.is_stable":          false,
+						"cloud.account.id":                  "my_project",
+						"cloud.provider":                    "gcp",
+						"cloud.region":                      "europe-west1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
 				{
 					Fields: mapstr.M{
 						"asset.ean":            "cluster:1",
@@ -513,7 +872,7 @@ func TestCollectGKEAssets(t *testing.T) {
 						"asset.kind":           "cluster",
 						"asset.parents":        []string{"network:1"},
 						"asset.metadata.state": "RUNNING",
-						"asset.children":       []string{"host:124"},
+						"asset.children":       []string{"instance_group:42"},
 						"asset.name":           "myCluster",
 						"cloud.account.id":     "my_project",
 						"cloud.provider":       "gcp",
@@ -535,9 +894,10 @@ func TestCollectGKEAssets(t *testing.T) {
 					return listInstanceClient.AggregatedList(ctx, req, opts...)
 				},
 			}
+			instanceGroupClient := InstanceGroupManagersClientStub{InstanceGroupManagers: tt.instanceGroups}
 			publisher := testutil.NewInMemoryPublisher()
 			log := logp.NewLogger("mylogger")
-			err := collectGKEAssets(tt.ctx, tt.cfg, vpcAssetsCache, tt.computeAssetsCache, log, listInstanceClientCreator, &listClusterClient, publisher)
+			err := collectGKEAssets(tt.ctx, tt.cfg, vpcAssetsCache, tt.computeAssetsCache, log, listInstanceClientCreator, &listClusterClient, &instanceGroupClient, publisher)
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedEvents, publisher.Events)
 		})
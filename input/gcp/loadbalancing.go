@@ -0,0 +1,397 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/googleapis/gax-go/v2"
+	"golang.org/x/exp/slices"
+	"google.golang.org/api/iterator"
+
+	"github.com/elastic/assetbeat/input/internal"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type AggregatedForwardingRuleIterator interface {
+	Next() (compute.ForwardingRulesScopedListPair, error)
+}
+
+type listForwardingRuleAPIClient struct {
+	AggregatedList func(ctx context.Context, req *computepb.AggregatedListForwardingRulesRequest, opts ...gax.CallOption) AggregatedForwardingRuleIterator
+}
+
+type AggregatedBackendServiceIterator interface {
+	Next() (compute.BackendServicesScopedListPair, error)
+}
+
+type listBackendServiceAPIClient struct {
+	AggregatedList func(ctx context.Context, req *computepb.AggregatedListBackendServicesRequest, opts ...gax.CallOption) AggregatedBackendServiceIterator
+}
+
+type AggregatedTargetHttpProxyIterator interface {
+	Next() (compute.TargetHttpProxiesScopedListPair, error)
+}
+
+type AggregatedTargetHttpsProxyIterator interface {
+	Next() (compute.TargetHttpsProxiesScopedListPair, error)
+}
+
+type AggregatedTargetTcpProxyIterator interface {
+	Next() (compute.TargetTcpProxiesScopedListPair, error)
+}
+
+type TargetSslProxyIterator interface {
+	Next() (*computepb.TargetSslProxy, error)
+}
+
+type AggregatedUrlMapIterator interface {
+	Next() (compute.UrlMapsScopedListPair, error)
+}
+
+// listTargetAPIClient lists the target proxies and URL maps forwarding rules of
+// proxy-based load balancers point to. SSL proxies are global only.
+type listTargetAPIClient struct {
+	AggregatedListHttpProxies  func(ctx context.Context, req *computepb.AggregatedListTargetHttpProxiesRequest, opts ...gax.CallOption) AggregatedTargetHttpProxyIterator
+	AggregatedListHttpsProxies func(ctx context.Context, req *computepb.AggregatedListTargetHttpsProxiesRequest, opts ...gax.CallOption) AggregatedTargetHttpsProxyIterator
+	AggregatedListTcpProxies   func(ctx context.Context, req *computepb.AggregatedListTargetTcpProxiesRequest, opts ...gax.CallOption) AggregatedTargetTcpProxyIterator
+	ListSslProxies             func(ctx context.Context, req *computepb.ListTargetSslProxiesRequest, opts ...gax.CallOption) TargetSslProxyIterator
+	AggregatedListUrlMaps      func(ctx context.Context, req *computepb.AggregatedListUrlMapsRequest, opts ...gax.CallOption) AggregatedUrlMapIterator
+}
+
+type loadBalancingResource struct {
+	ID       string
+	Region   string
+	Account  string
+	Parents  []string
+	Children []string
+	Labels   map[string]string
+	Metadata mapstr.M
+	Name     string
+}
+
+// collectLoadBalancingAssets collects backend services and the forwarding rules pointing to them.
+// Backend services are retrieved first, so that forwarding rules can reference them by id.
+func collectLoadBalancingAssets(ctx context.Context, cfg config, vpcAssetCache *assetCache[*vpc], forwardingRuleClient listForwardingRuleAPIClient, targetClient listTargetAPIClient, backendServiceClient listBackendServiceAPIClient, instanceGroupClient getInstanceGroupManagerAPIClient, publisher stateless.Publisher, log *logp.Logger) error {
+	backendServices, backendServiceIDs, err := getAllBackendServices(ctx, cfg, vpcAssetCache, backendServiceClient, instanceGroupClient, log)
+	if err != nil {
+		return err
+	}
+
+	if internal.IsTypeEnabled(cfg.AssetTypes, "gcp.compute.backend_service") {
+		log.Debug("Publishing GCP backend services")
+		publishLoadBalancingResources(publisher, "gcp.compute.backend_service", "backend_service", backendServices)
+	}
+	if forwardingRuleCollector.isEnabled(cfg.AssetTypes) {
+		forwardingRules, err := getAllForwardingRules(ctx, cfg, vpcAssetCache, backendServiceIDs, forwardingRuleClient, targetClient)
+		if err != nil {
+			return err
+		}
+		log.Debug("Publishing GCP forwarding rules")
+		publishLoadBalancingResources(publisher, "gcp.compute.forwarding_rule", "forwarding_rule", forwardingRules)
+	}
+	return nil
+}
+
+func publishLoadBalancingResources(publisher stateless.Publisher, assetType string, assetKind string, resources []loadBalancingResource) {
+	for _, r := range resources {
+		options := []internal.AssetOption{
+			internal.WithAssetCloudProvider("gcp"),
+			internal.WithAssetAccountID(r.Account),
+			internal.WithAssetKindAndID(assetKind, r.ID),
+			internal.WithAssetType(assetType),
+			internal.WithAssetParents(r.Parents),
+			internal.WithAssetChildren(r.Children),
+			WithAssetLabels(internal.ToMapstr(r.Labels)),
+			internal.WithAssetMetadata(r.Metadata),
+		}
+		// global resources are not bound to any region
+		if r.Region != "" {
+			options = append(options, internal.WithAssetRegion(r.Region))
+		}
+		if r.Name != "" {
+			options = append(options, internal.WithAssetName(r.Name))
+		}
+		internal.Publish(publisher, nil, options...)
+	}
+}
+
+// getAllBackendServices returns the backend services, along with their ids keyed by self link.
+func getAllBackendServices(ctx context.Context, cfg config, vpcAssetCache *assetCache[*vpc], client listBackendServiceAPIClient, instanceGroupClient getInstanceGroupManagerAPIClient, log *logp.Logger) ([]loadBalancingResource, map[string]string, error) {
+	var backendServices []loadBalancingResource
+	ids := make(map[string]string)
+
+	for _, p := range cfg.Projects {
+		// backends are often shared between backend services
+		instanceGroupIDs := make(map[string]string)
+
+		req := &computepb.AggregatedListBackendServicesRequest{
+			Project: p,
+		}
+		it := client.AggregatedList(ctx, req)
+		for {
+			pair, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("error retrieving backend services for project %s: %w", p, err)
+			}
+			if !wantScope(pair.Key, cfg.Regions) {
+				continue
+			}
+			for _, bs := range pair.Value.GetBackendServices() {
+				var parents []string
+				if vpcID := getVpcIdFromLink(bs.GetNetwork(), vpcAssetCache); vpcID != "" {
+					parents = append(parents, "network:"+vpcID)
+				}
+
+				var children []string
+				for _, backend := range bs.GetBackends() {
+					id, err := getBackendInstanceGroupID(ctx, p, backend.GetGroup(), instanceGroupIDs, instanceGroupClient)
+					if err != nil {
+						log.Debugf("Unable to resolve backend %s of backend service %s: %+v", backend.GetGroup(), bs.GetName(), err)
+						continue
+					}
+					children = append(children, "instance_group:"+id)
+				}
+
+				id := strconv.FormatUint(bs.GetId(), 10)
+				ids[bs.GetSelfLink()] = id
+				backendServices = append(backendServices, loadBalancingResource{
+					ID:       id,
+					Region:   getResourceNameFromURL(bs.GetRegion()),
+					Account:  p,
+					Parents:  parents,
+					Children: children,
+					Metadata: mapstr.M{
+						"protocol":              bs.GetProtocol(),
+						"load_balancing_scheme": bs.GetLoadBalancingScheme(),
+						"port_name":             bs.GetPortName(),
+						"session_affinity":      bs.GetSessionAffinity(),
+						"health_checks":         getResourceNamesFromURLs(bs.GetHealthChecks()),
+					},
+					Name: bs.GetName(),
+				})
+			}
+		}
+	}
+
+	return backendServices, ids, nil
+}
+
+// getBackendInstanceGroupID resolves a backend group to the id of the managed instance group
+// behind it, as published by the GKE collection. Instance groups and their managers share
+// zone and name. Network endpoint groups and regional or unmanaged instance groups cannot be resolved.
+func getBackendInstanceGroupID(ctx context.Context, project string, group string, resolved map[string]string, client getInstanceGroupManagerAPIClient) (string, error) {
+	if id, ok := resolved[group]; ok {
+		return id, nil
+	}
+	ig, err := getInstanceGroup(ctx, project, group, client)
+	if err != nil {
+		return "", err
+	}
+	resolved[group] = ig.ID
+	return ig.ID, nil
+}
+
+// getAllForwardingRules returns the forwarding rules, with the backend services they point
+// to as children, either directly or through a target proxy and its URL map.
+// Target pools, target instances and target gRPC proxies are not resolved.
+func getAllForwardingRules(ctx context.Context, cfg config, vpcAssetCache *assetCache[*vpc], backendServiceIDs map[string]string, client listForwardingRuleAPIClient, targetClient listTargetAPIClient) ([]loadBalancingResource, error) {
+	var forwardingRules []loadBalancingResource
+
+	for _, p := range cfg.Projects {
+		targets, err := getTargetBackendServices(ctx, p, targetClient)
+		if err != nil {
+			return nil, err
+		}
+
+		req := &computepb.AggregatedListForwardingRulesRequest{
+			Project: p,
+		}
+		it := client.AggregatedList(ctx, req)
+		for {
+			pair, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error retrieving forwarding rules for project %s: %w", p, err)
+			}
+			if !wantScope(pair.Key, cfg.Regions) {
+				continue
+			}
+			for _, fr := range pair.Value.GetForwardingRules() {
+				var parents []string
+				if vpcID := getVpcIdFromLink(fr.GetNetwork(), vpcAssetCache); vpcID != "" {
+					parents = append(parents, "network:"+vpcID)
+				}
+
+				var children []string
+				links := append([]string{fr.GetBackendService()}, targets[fr.GetTarget()]...)
+				for _, link := range links {
+					id, ok := backendServiceIDs[link]
+					if !ok {
+						continue
+					}
+					if ean := "backend_service:" + id; !slices.Contains(children, ean) {
+						children = append(children, ean)
+					}
+				}
+
+				forwardingRules = append(forwardingRules, loadBalancingResource{
+					ID:       strconv.FormatUint(fr.GetId(), 10),
+					Region:   getResourceNameFromURL(fr.GetRegion()),
+					Account:  p,
+					Parents:  parents,
+					Children: children,
+					Labels:   fr.GetLabels(),
+					Metadata: mapstr.M{
+						"ip_address":            fr.GetIPAddress(),
+						"ip_protocol":           fr.GetIPProtocol(),
+						"port_range":            fr.GetPortRange(),
+						"ports":                 fr.GetPorts(),
+						"load_balancing_scheme": fr.GetLoadBalancingScheme(),
+						"network_tier":          fr.GetNetworkTier(),
+						"target":                getResourceNameFromURL(fr.GetTarget()),
+					},
+					Name: fr.GetName(),
+				})
+			}
+		}
+	}
+
+	return forwardingRules, nil
+}
+
+// getTargetBackendServices returns the self links of the backend services behind each
+// target proxy of the project, keyed by the self link of the target proxy.
+func getTargetBackendServices(ctx context.Context, project string, client listTargetAPIClient) (map[string][]string, error) {
+	urlMaps, err := getUrlMapBackendServices(ctx, project, client)
+	if err != nil {
+		return nil, err
+	}
+	targets := make(map[string][]string)
+
+	httpIt := client.AggregatedListHttpProxies(ctx, &computepb.AggregatedListTargetHttpProxiesRequest{Project: project})
+	for {
+		pair, err := httpIt.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving target HTTP proxies for project %s: %w", project, err)
+		}
+		for _, proxy := range pair.Value.GetTargetHttpProxies() {
+			targets[proxy.GetSelfLink()] = urlMaps[proxy.GetUrlMap()]
+		}
+	}
+
+	httpsIt := client.AggregatedListHttpsProxies(ctx, &computepb.AggregatedListTargetHttpsProxiesRequest{Project: project})
+	for {
+		pair, err := httpsIt.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving target HTTPS proxies for project %s: %w", project, err)
+		}
+		for _, proxy := range pair.Value.GetTargetHttpsProxies() {
+			targets[proxy.GetSelfLink()] = urlMaps[proxy.GetUrlMap()]
+		}
+	}
+
+	tcpIt := client.AggregatedListTcpProxies(ctx, &computepb.AggregatedListTargetTcpProxiesRequest{Project: project})
+	for {
+		pair, err := tcpIt.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving target TCP proxies for project %s: %w", project, err)
+		}
+		for _, proxy := range pair.Value.GetTargetTcpProxies() {
+			targets[proxy.GetSelfLink()] = []string{proxy.GetService()}
+		}
+	}
+
+	sslIt := client.ListSslProxies(ctx, &computepb.ListTargetSslProxiesRequest{Project: project})
+	for {
+		proxy, err := sslIt.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving target SSL proxies for project %s: %w", project, err)
+		}
+		targets[proxy.GetSelfLink()] = []string{proxy.GetService()}
+	}
+
+	return targets, nil
+}
+
+// getUrlMapBackendServices returns the self links of the backend services a URL map routes
+// to, keyed by the self link of the URL map. Backend buckets are ignored, as they are not collected.
+func getUrlMapBackendServices(ctx context.Context, project string, client listTargetAPIClient) (map[string][]string, error) {
+	urlMaps := make(map[string][]string)
+
+	it := client.AggregatedListUrlMaps(ctx, &computepb.AggregatedListUrlMapsRequest{Project: project})
+	for {
+		pair, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving URL maps for project %s: %w", project, err)
+		}
+		for _, um := range pair.Value.GetUrlMaps() {
+			services := []string{um.GetDefaultService()}
+			services = append(services, getWeightedBackendServices(um.GetDefaultRouteAction())...)
+			for _, pm := range um.GetPathMatchers() {
+				services = append(services, pm.GetDefaultService())
+				services = append(services, getWeightedBackendServices(pm.GetDefaultRouteAction())...)
+				for _, rule := range pm.GetPathRules() {
+					services = append(services, rule.GetService())
+					services = append(services, getWeightedBackendServices(rule.GetRouteAction())...)
+				}
+				for _, rule := range pm.GetRouteRules() {
+					services = append(services, rule.GetService())
+					services = append(services, getWeightedBackendServices(rule.GetRouteAction())...)
+				}
+			}
+			urlMaps[um.GetSelfLink()] = services
+		}
+	}
+
+	return urlMaps, nil
+}
+
+func getWeightedBackendServices(action *computepb.HttpRouteAction) []string {
+	var services []string
+	for _, wbs := range action.GetWeightedBackendServices() {
+		services = append(services, wbs.GetBackendService())
+	}
+	return services
+}
@@ -0,0 +1,544 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/assetbeat/input/testutil"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type StubAggregatedForwardingRuleListIterator struct {
+	iterCounter                     int
+	ReturnScopedForwardingRulesList []compute.ForwardingRulesScopedListPair
+	ReturnForwardingRulesError      error
+}
+
+func (it *StubAggregatedForwardingRuleListIterator) Next() (compute.ForwardingRulesScopedListPair, error) {
+
+	if it.ReturnForwardingRulesError != nil {
+		return compute.ForwardingRulesScopedListPair{}, it.ReturnForwardingRulesError
+	}
+
+	if it.iterCounter == len(it.ReturnScopedForwardingRulesList) {
+		return compute.ForwardingRulesScopedListPair{}, iterator.Done
+	}
+
+	pair := it.ReturnScopedForwardingRulesList[it.iterCounter]
+	it.iterCounter++
+
+	return pair, nil
+}
+
+type ForwardingRulesClientStub struct {
+	AggregatedForwardingRuleIterator map[string]*StubAggregatedForwardingRuleListIterator
+}
+
+func (s *ForwardingRulesClientStub) AggregatedList(ctx context.Context, req *computepb.AggregatedListForwardingRulesRequest, opts ...gax.CallOption) AggregatedForwardingRuleIterator {
+	return s.AggregatedForwardingRuleIterator[req.Project]
+}
+
+type StubAggregatedBackendServiceListIterator struct {
+	iterCounter                     int
+	ReturnScopedBackendServicesList []compute.BackendServicesScopedListPair
+	ReturnBackendServicesError      error
+}
+
+func (it *StubAggregatedBackendServiceListIterator) Next() (compute.BackendServicesScopedListPair, error) {
+
+	if it.ReturnBackendServicesError != nil {
+		return compute.BackendServicesScopedListPair{}, it.ReturnBackendServicesError
+	}
+
+	if it.iterCounter == len(it.ReturnScopedBackendServicesList) {
+		return compute.BackendServicesScopedListPair{}, iterator.Done
+	}
+
+	pair := it.ReturnScopedBackendServicesList[it.iterCounter]
+	it.iterCounter++
+
+	return pair, nil
+}
+
+type BackendServicesClientStub struct {
+	AggregatedBackendServiceIterator map[string]*StubAggregatedBackendServiceListIterator
+}
+
+func (s *BackendServicesClientStub) AggregatedList(ctx context.Context, req *computepb.AggregatedListBackendServicesRequest, opts ...gax.CallOption) AggregatedBackendServiceIterator {
+	return s.AggregatedBackendServiceIterator[req.Project]
+}
+
+// StubListIterator iterates over the target proxies and URL maps of a test case.
+type StubListIterator[T any] struct {
+	iterCounter int
+	ReturnList  []T
+}
+
+func (it *StubListIterator[T]) Next() (T, error) {
+	var item T
+	if it.iterCounter == len(it.ReturnList) {
+		return item, iterator.Done
+	}
+	item = it.ReturnList[it.iterCounter]
+	it.iterCounter++
+	return item, nil
+}
+
+// getTestTargetClient returns a client listing the given target proxies and URL maps, all global.
+func getTestTargetClient(httpsProxies []*computepb.TargetHttpsProxy, sslProxies []*computepb.TargetSslProxy, urlMaps []*computepb.UrlMap) listTargetAPIClient {
+	return listTargetAPIClient{
+		AggregatedListHttpProxies: func(ctx context.Context, req *computepb.AggregatedListTargetHttpProxiesRequest, opts ...gax.CallOption) AggregatedTargetHttpProxyIterator {
+			return &StubListIterator[compute.TargetHttpProxiesScopedListPair]{}
+		},
+		AggregatedListHttpsProxies: func(ctx context.Context, req *computepb.AggregatedListTargetHttpsProxiesRequest, opts ...gax.CallOption) AggregatedTargetHttpsProxyIterator {
+			return &StubListIterator[compute.TargetHttpsProxiesScopedListPair]{ReturnList: []compute.TargetHttpsProxiesScopedListPair{
+				{Key: "global", Value: &computepb.TargetHttpsProxiesScopedList{TargetHttpsProxies: httpsProxies}},
+			}}
+		},
+		AggregatedListTcpProxies: func(ctx context.Context, req *computepb.AggregatedListTargetTcpProxiesRequest, opts ...gax.CallOption) AggregatedTargetTcpProxyIterator {
+			return &StubListIterator[compute.TargetTcpProxiesScopedListPair]{}
+		},
+		ListSslProxies: func(ctx context.Context, req *computepb.ListTargetSslProxiesRequest, opts ...gax.CallOption) TargetSslProxyIterator {
+			return &StubListIterator[*computepb.TargetSslProxy]{ReturnList: sslProxies}
+		},
+		AggregatedListUrlMaps: func(ctx context.Context, req *computepb.AggregatedListUrlMapsRequest, opts ...gax.CallOption) AggregatedUrlMapIterator {
+			return &StubListIterator[compute.UrlMapsScopedListPair]{ReturnList: []compute.UrlMapsScopedListPair{
+				{Key: "global", Value: &computepb.UrlMapsScopedList{UrlMaps: urlMaps}},
+			}}
+		},
+	}
+}
+
+func TestCollectLoadBalancingAssets(t *testing.T) {
+	var children []string
+	for _, tt := range []struct {
+		name string
+
+		cfg             config
+		forwardingRules map[string]*StubAggregatedForwardingRuleListIterator
+		backendServices map[string]*StubAggregatedBackendServiceListIterator
+		instanceGroups  map[string]*computepb.InstanceGroupManager
+		httpsProxies    []*computepb.TargetHttpsProxy
+		sslProxies      []*computepb.TargetSslProxy
+		urlMaps         []*computepb.UrlMap
+		expectedEvents  []beat.Event
+	}{
+		{
+			name: "with no project specified",
+			cfg:  config{},
+		},
+		{
+			name: "internal load balancer with a managed instance group backend",
+			cfg: config{
+				Projects: []string{"my_project"},
+				Regions:  []string{"europe-west1"},
+			},
+			backendServices: map[string]*StubAggregatedBackendServiceListIterator{
+				"my_project": {
+					ReturnScopedBackendServicesList: []compute.BackendServicesScopedListPair{
+						{
+							Key: "regions/europe-west1",
+							Value: &computepb.BackendServicesScopedList{
+								BackendServices: []*computepb.BackendService{
+									{
+										Id:                  proto.Uint64(10),
+										Name:                proto.String("my-backend"),
+										SelfLink:            proto.String("https://www.googleapis.com/compute/v1/projects/my_project/regions/europe-west1/backendServices/my-backend"),
+										Region:              proto.String("https://www.googleapis.com/compute/v1/projects/my_project/regions/europe-west1"),
+										Network:             proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/networks/my_network"),
+										Protocol:            proto.String("TCP"),
+										LoadBalancingScheme: proto.String("INTERNAL"),
+										SessionAffinity:     proto.String("NONE"),
+										HealthChecks:        []string{"https://www.googleapis.com/compute/v1/projects/my_project/regions/europe-west1/healthChecks/my-hc"},
+										Backends: []*computepb.Backend{
+											{Group: proto.String("https://www.googleapis.com/compute/v1/projects/my_project/zones/europe-west1-d/instanceGroups/my-group")},
+											{Group: proto.String("https://www.googleapis.com/compute/v1/projects/my_project/zones/europe-west1-d/networkEndpointGroups/my-neg")},
+										},
+									},
+								},
+							},
+						},
+						{
+							Key: "regions/us-central1",
+							Value: &computepb.BackendServicesScopedList{
+								BackendServices: []*computepb.BackendService{
+									{
+										Id:   proto.Uint64(11),
+										Name: proto.String("my-filtered-backend"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			forwardingRules: map[string]*StubAggregatedForwardingRuleListIterator{
+				"my_project": {
+					ReturnScopedForwardingRulesList: []compute.ForwardingRulesScopedListPair{
+						{
+							Key: "regions/europe-west1",
+							Value: &computepb.ForwardingRulesScopedList{
+								ForwardingRules: []*computepb.ForwardingRule{
+									{
+										Id:                  proto.Uint64(20),
+										Name:                proto.String("my-rule"),
+										Region:              proto.String("https://www.googleapis.com/compute/v1/projects/my_project/regions/europe-west1"),
+										Network:             proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/networks/my_network"),
+										BackendService:      proto.String("https://www.googleapis.com/compute/v1/projects/my_project/regions/europe-west1/backendServices/my-backend"),
+										IPAddress:           proto.String("10.0.0.10"),
+										IPProtocol:          proto.String("TCP"),
+										Ports:               []string{"80", "443"},
+										LoadBalancingScheme: proto.String("INTERNAL"),
+										NetworkTier:         proto.String("PREMIUM"),
+										Labels:              map[string]string{"team": "obs"},
+									},
+								},
+							},
+						},
+						{
+							Key: "global",
+							Value: &computepb.ForwardingRulesScopedList{
+								ForwardingRules: []*computepb.ForwardingRule{
+									{
+										Id:                  proto.Uint64(21),
+										Name:                proto.String("my-global-rule"),
+										IPAddress:           proto.String("34.1.2.3"),
+										IPProtocol:          proto.String("TCP"),
+										PortRange:           proto.String("443-443"),
+										LoadBalancingScheme: proto.String("EXTERNAL_MANAGED"),
+										NetworkTier:         proto.String("PREMIUM"),
+										Target:              proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/targetHttpsProxies/my-proxy"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			instanceGroups: map[string]*computepb.InstanceGroupManager{
+				"my-group": {
+					Id: proto.Uint64(42),
+				},
+			},
+			expectedEvents: []beat.Event{
+				{
+					Fields: mapstr.M{
+						"asset.ean":                            "backend_service:10",
+						"asset.id":                             "10",
+						"asset.name":                           "my-backend",
+						"asset.type":                           "gcp.compute.backend_service",
+						"asset.kind":                           "backend_service",
+						"asset.parents":                        []string{"network:1"},
+						"asset.children":                       []string{"instance_group:42"},
+						"asset.metadata.protocol":              "TCP",
+						"asset.metadata.load_balancing_scheme": "INTERNAL",
+						"asset.metadata.port_name":             "",
+						"asset.metadata.session_affinity":      "NONE",
+						"asset.metadata.health_checks":         []string{"my-hc"},
+						"cloud.account.id":                     "my_project",
+						"cloud.provider":                       "gcp",
+						"cloud.region":                         "europe-west1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+				{
+					Fields: mapstr.M{
+						"asset.ean":                            "forwarding_rule:20",
+						"asset.id":                             "20",
+						"asset.name":                           "my-rule",
+						"asset.type":                           "gcp.compute.forwarding_rule",
+						"asset.kind":                           "forwarding_rule",
+						"asset.parents":                        []string{"network:1"},
+						"asset.children":                       []string{"backend_service:10"},
+						"asset.metadata.labels.team":           "obs",
+						"asset.metadata.ip_address":            "10.0.0.10",
+						"asset.metadata.ip_protocol":           "TCP",
+						"asset.metadata.port_range":            "",
+						"asset.metadata.ports":                 []string{"80", "443"},
+						"asset.metadata.load_balancing_scheme": "INTERNAL",
+						"asset.metadata.network_tier":          "PREMIUM",
+						"asset.metadata.target":                "",
+						"cloud.account.id":                     "my_project",
+						"cloud.provider":                       "gcp",
+						"cloud.region":                         "europe-west1",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+				{
+					Fields: mapstr.M{
+						"asset.ean":                            "forwarding_rule:21",
+						"asset.id":                             "21",
+						"asset.name":                           "my-global-rule",
+						"asset.type":                           "gcp.compute.forwarding_rule",
+						"asset.kind":                           "forwarding_rule",
+						"asset.parents":                        children,
+						"asset.children":                       children,
+						"asset.metadata.ip_address":            "34.1.2.3",
+						"asset.metadata.ip_protocol":           "TCP",
+						"asset.metadata.port_range":            "443-443",
+						"asset.metadata.ports":                 children,
+						"asset.metadata.load_balancing_scheme": "EXTERNAL_MANAGED",
+						"asset.metadata.network_tier":          "PREMIUM",
+						"asset.metadata.target":                "my-proxy",
+						"cloud.account.id":                     "my_project",
+						"cloud.provider":                       "gcp",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+			},
+		},
+		{
+			name: "proxy load balancers resolved through their target proxy and URL map",
+			cfg: config{
+				Projects: []string{"my_project"},
+			},
+			backendServices: map[string]*StubAggregatedBackendServiceListIterator{
+				"my_project": {
+					ReturnScopedBackendServicesList: []compute.BackendServicesScopedListPair{
+						{
+							Key: "global",
+							Value: &computepb.BackendServicesScopedList{
+								BackendServices: []*computepb.BackendService{
+									{
+										Id:       proto.Uint64(12),
+										Name:     proto.String("my-web-backend"),
+										SelfLink: proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/backendServices/my-web-backend"),
+									},
+									{
+										Id:       proto.Uint64(13),
+										Name:     proto.String("my-api-backend"),
+										SelfLink: proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/backendServices/my-api-backend"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			urlMaps: []*computepb.UrlMap{
+				{
+					SelfLink:       proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/urlMaps/my-map"),
+					DefaultService: proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/backendServices/my-web-backend"),
+					PathMatchers: []*computepb.PathMatcher{
+						{
+							DefaultService: proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/backendServices/my-web-backend"),
+							PathRules: []*computepb.PathRule{
+								{Service: proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/backendServices/my-api-backend")},
+								{Service: proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/backendBuckets/my-bucket")},
+							},
+						},
+					},
+				},
+			},
+			httpsProxies: []*computepb.TargetHttpsProxy{
+				{
+					SelfLink: proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/targetHttpsProxies/my-https-proxy"),
+					UrlMap:   proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/urlMaps/my-map"),
+				},
+			},
+			sslProxies: []*computepb.TargetSslProxy{
+				{
+					SelfLink: proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/targetSslProxies/my-ssl-proxy"),
+					Service:  proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/backendServices/my-api-backend"),
+				},
+			},
+			forwardingRules: map[string]*StubAggregatedForwardingRuleListIterator{
+				"my_project": {
+					ReturnScopedForwardingRulesList: []compute.ForwardingRulesScopedListPair{
+						{
+							Key: "global",
+							Value: &computepb.ForwardingRulesScopedList{
+								ForwardingRules: []*computepb.ForwardingRule{
+									{
+										Id:     proto.Uint64(22),
+										Name:   proto.String("my-https-rule"),
+										Target: proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/targetHttpsProxies/my-https-proxy"),
+									},
+									{
+										Id:     proto.Uint64(23),
+										Name:   proto.String("my-ssl-rule"),
+										Target: proto.String("https://www.googleapis.com/compute/v1/projects/my_project/global/targetSslProxies/my-ssl-proxy"),
+									},
+									{
+										Id:     proto.Uint64(24),
+										Name:   proto.String("my-pool-rule"),
+										Target: proto.String("https://www.googleapis.com/compute/v1/projects/my_project/regions/europe-west1/targetPools/my-pool"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedEvents: []beat.Event{
+				getTestBackendServiceEvent("12", "my-web-backend"),
+				getTestBackendServiceEvent("13", "my-api-backend"),
+				getTestForwardingRuleEvent("22", "my-https-rule", "my-https-proxy", []string{"backend_service:12", "backend_service:13"}),
+				getTestForwardingRuleEvent("23", "my-ssl-rule", "my-ssl-proxy", []string{"backend_service:13"}),
+				getTestForwardingRuleEvent("24", "my-pool-rule", "my-pool", children),
+			},
+		},
+		{
+			name: "with only backend services enabled, forwarding rules are not listed",
+			cfg: config{
+				BaseConfig: internal.BaseConfig{AssetTypes: []string{"gcp.compute.backend_service"}},
+				Projects:   []string{"my_project"},
+			},
+			backendServices: map[string]*StubAggregatedBackendServiceListIterator{
+				"my_project": {
+					ReturnScopedBackendServicesList: []compute.BackendServicesScopedListPair{
+						{
+							Key: "global",
+							Value: &computepb.BackendServicesScopedList{
+								BackendServices: []*computepb.BackendService{
+									{
+										Id:   proto.Uint64(12),
+										Name: proto.String("my-global-backend"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			forwardingRules: map[string]*StubAggregatedForwardingRuleListIterator{
+				"my_project": {ReturnForwardingRulesError: errors.New("permission compute.forwardingRules.list denied")},
+			},
+			expectedEvents: []beat.Event{
+				{
+					Fields: mapstr.M{
+						"asset.ean":                            "backend_service:12",
+						"asset.id":                             "12",
+						"asset.name":                           "my-global-backend",
+						"asset.type":                           "gcp.compute.backend_service",
+						"asset.kind":                           "backend_service",
+						"asset.parents":                        children,
+						"asset.children":                       children,
+						"asset.metadata.protocol":              "",
+						"asset.metadata.load_balancing_scheme": "",
+						"asset.metadata.port_name":             "",
+						"asset.metadata.session_affinity":      "",
+						"asset.metadata.health_checks":         children,
+						"cloud.account.id":                     "my_project",
+						"cloud.provider":                       "gcp",
+					},
+					Meta: mapstr.M{
+						"index": internal.GetDefaultIndexName(),
+					},
+				},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := testutil.NewInMemoryPublisher()
+
+			ctx := context.Background()
+			frClient := ForwardingRulesClientStub{AggregatedForwardingRuleIterator: tt.forwardingRules}
+			bsClient := BackendServicesClientStub{AggregatedBackendServiceIterator: tt.backendServices}
+			listForwardingRuleClient := listForwardingRuleAPIClient{
+				AggregatedList: func(ctx context.Context, req *computepb.AggregatedListForwardingRulesRequest, opts ...gax.CallOption) AggregatedForwardingRuleIterator {
+					return frClient.AggregatedList(ctx, req, opts...)
+				},
+			}
+			listBackendServiceClient := listBackendServiceAPIClient{
+				AggregatedList: func(ctx context.Context, req *computepb.AggregatedListBackendServicesRequest, opts ...gax.CallOption) AggregatedBackendServiceIterator {
+					return bsClient.AggregatedList(ctx, req, opts...)
+				},
+			}
+			instanceGroupClient := InstanceGroupManagersClientStub{InstanceGroupManagers: tt.instanceGroups}
+			log := logp.NewLogger("mylogger")
+			targetClient := getTestTargetClient(tt.httpsProxies, tt.sslProxies, tt.urlMaps)
+			err := collectLoadBalancingAssets(ctx, tt.cfg, getTestVpcCache(), listForwardingRuleClient, targetClient, listBackendServiceClient, &instanceGroupClient, publisher, log)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedEvents, publisher.Events)
+		})
+	}
+}
+
+// getTestBackendServiceEvent returns the event of a global backend service with no backend.
+func getTestBackendServiceEvent(id string, name string) beat.Event {
+	var empty []string
+	return beat.Event{
+		Fields: mapstr.M{
+			"asset.ean":                            "backend_service:" + id,
+			"asset.id":                             id,
+			"asset.name":                           name,
+			"asset.type":                           "gcp.compute.backend_service",
+			"asset.kind":                           "backend_service",
+			"asset.parents":                        empty,
+			"asset.children":                       empty,
+			"asset.metadata.protocol":              "",
+			"asset.metadata.load_balancing_scheme": "",
+			"asset.metadata.port_name":             "",
+			"asset.metadata.session_affinity":      "",
+			"asset.metadata.health_checks":         empty,
+			"cloud.account.id":                     "my_project",
+			"cloud.provider":                       "gcp",
+		},
+		Meta: mapstr.M{
+			"index": internal.GetDefaultIndexName(),
+		},
+	}
+}
+
+// getTestForwardingRuleEvent returns the event of a global forwarding rule pointing to a target.
+func getTestForwardingRuleEvent(id string, name string, target string, children []string) beat.Event {
+	var empty []string
+	return beat.Event{
+		Fields: mapstr.M{
+			"asset.ean":                            "forwarding_rule:" + id,
+			"asset.id":                             id,
+			"asset.name":                           name,
+			"asset.type":                           "gcp.compute.forwarding_rule",
+			"asset.kind":                           "forwarding_rule",
+			"asset.parents":                        empty,
+			"asset.children":                       children,
+			"asset.metadata.ip_address":            "",
+			"asset.metadata.ip_protocol":           "",
+			"asset.metadata.port_range":            "",
+			"asset.metadata.ports":                 empty,
+			"asset.metadata.load_balancing_scheme": "",
+			"asset.metadata.network_tier":          "",
+			"asset.metadata.target":                target,
+			"cloud.account.id":                     "my_project",
+			"cloud.provider":                       "gcp",
+		},
+		Meta: mapstr.M{
+			"index": internal.GetDefaultIndexName(),
+		},
+	}
+}
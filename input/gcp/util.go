@@ -18,6 +18,7 @@
 package gcp
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
@@ -27,7 +28,7 @@ import (
 
 	"github.com/cespare/xxhash"
 
-	"github.com/elastic/go-freelru"
+	"github.com/elastic/elastic-agent-libs/monitoring"
 )
 
 func getResourceNameFromURL(res string) string {
@@ -35,13 +36,44 @@ func getResourceNameFromURL(res string) string {
 	return s[len(s)-1]
 }
 
+func getResourceNamesFromURLs(res []string) []string {
+	var names []string
+	for _, r := range res {
+		names = append(names, getResourceNameFromURL(r))
+	}
+	return names
+}
+
 func getRegionFromZoneURL(zone string) string {
 	z := getResourceNameFromURL(zone)
 	r := strings.Split(z, "-")
 	return strings.Join(r[:len(r)-1], "-")
 }
 
-func getVpcIdFromLink(selfLink string, vpcAssetCache *freelru.LRU[string, *vpc]) string {
+// getZoneFromResourceURL extracts the zone from a zonal resource URL or name,
+// e.g. projects/{project}/zones/{zone}/instanceGroupManagers/{name}
+func getZoneFromResourceURL(res string) string {
+	s := strings.Split(res, "/")
+	for i := 0; i < len(s)-1; i++ {
+		if s[i] == "zones" {
+			return s[i+1]
+		}
+	}
+	return ""
+}
+
+// getZonalResourceKey identifies a zonal resource by its zone and name, so that
+// self links and relative names referring to the same resource can be matched
+// regardless of the project being referenced by id or by number.
+func getZonalResourceKey(res string) string {
+	zone := getZoneFromResourceURL(res)
+	if zone == "" {
+		return ""
+	}
+	return zone + "/" + getResourceNameFromURL(res)
+}
+
+func getVpcIdFromLink(selfLink string, vpcAssetCache *assetCache[*vpc]) string {
 	v, ok := vpcAssetCache.Get(selfLink)
 	if ok {
 		return v.ID
@@ -49,7 +81,7 @@ func getVpcIdFromLink(selfLink string, vpcAssetCache *freelru.LRU[string, *vpc])
 	return ""
 }
 
-func getSubnetIdFromLink(selfLink string, subnetAssetCache *freelru.LRU[string, *subnet]) string {
+func getSubnetIdFromLink(selfLink string, subnetAssetCache *assetCache[*subnet]) string {
 	v, ok := subnetAssetCache.Get(selfLink)
 	if ok {
 		return v.ID
@@ -66,10 +98,73 @@ func getNetSelfLinkFromNetConfig(networkConfig *containerpb.NetworkConfig) strin
 	return ""
 }
 
+// getNetSelfLinkFromName accepts a network either as a bare name, a relative
+// resource name or a full self link, and always returns its self link.
+func getNetSelfLinkFromName(project string, network string) string {
+	switch {
+	case network == "":
+		return ""
+	case strings.HasPrefix(network, "https://"):
+		return network
+	case strings.HasPrefix(network, "projects/"):
+		return "https://www.googleapis.com/compute/v1/" + network
+	default:
+		return fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/global/networks/%s", project, network)
+	}
+}
+
+// getLocationFromResourceName extracts the location from a resource name
+// in the form of projects/{project}/locations/{location}/...
+func getLocationFromResourceName(name string) string {
+	s := strings.Split(name, "/")
+	for i := 0; i < len(s)-1; i++ {
+		if s[i] == "locations" {
+			return s[i+1]
+		}
+	}
+	return ""
+}
+
+// getProjectFromResourceName extracts the project from a resource name
+// in the form of projects/{project}/...
+func getProjectFromResourceName(name string) string {
+	s := strings.Split(name, "/")
+	if len(s) > 1 && s[0] == "projects" {
+		return s[1]
+	}
+	return ""
+}
+
+// getLocationParents returns the parents to be used in List requests for
+// location based APIs. When no region is configured, the "-" wildcard is used
+// to list resources across all locations.
+func getLocationParents(project string, regions []string) []string {
+	if len(regions) == 0 {
+		return []string{fmt.Sprintf("projects/%s/locations/-", project)}
+	}
+	var parents []string
+	for _, region := range regions {
+		parents = append(parents, fmt.Sprintf("projects/%s/locations/%s", project, region))
+	}
+	return parents
+}
+
 func hashStringXXHASH(s string) uint32 {
 	return uint32(xxhash.Sum64String(s))
 }
 
+// wantScope filters the scopes of an aggregated list, in the form of regions/us-west2,
+// zones/us-west2-a or global. Global resources are always wanted.
+func wantScope(scope string, confRegions []string) bool {
+	if scope == "global" {
+		return true
+	}
+	if strings.HasPrefix(scope, "zones/") {
+		return wantZone(scope, confRegions)
+	}
+	return wantRegion(scope, confRegions)
+}
+
 // region is in the form of regions/us-west2
 func wantRegion(region string, confRegions []string) bool {
 	if len(confRegions) == 0 {
@@ -101,8 +196,8 @@ func wantZone(zone string, confRegions []string) bool {
 	return false
 }
 
-func getTestVpcCache() *freelru.LRU[string, *vpc] {
-	vpcAssetsCache, _ := freelru.New[string, *vpc](8192, hashStringXXHASH)
+func getTestVpcCache() *assetCache[*vpc] {
+	vpcAssetsCache := newAssetCache[*vpc](nil, "vpc")
 	nv := vpc{
 		ID: "1",
 	}
@@ -111,8 +206,8 @@ func getTestVpcCache() *freelru.LRU[string, *vpc] {
 	return vpcAssetsCache
 }
 
-func getTestSubnetCache() *freelru.LRU[string, *subnet] {
-	subnetAssetsCache, _ := freelru.New[string, *subnet](8192, hashStringXXHASH)
+func getTestSubnetCache() *assetCache[*subnet] {
+	subnetAssetsCache := newAssetCache[*subnet](nil, "subnet")
 	sb := subnet{
 		ID: "2",
 	}
@@ -121,8 +216,8 @@ func getTestSubnetCache() *freelru.LRU[string, *subnet] {
 	return subnetAssetsCache
 }
 
-func getTestComputeCache() *freelru.LRU[string, *computeInstance] {
-	computeAssetsCache, _ := freelru.New[string, *computeInstance](8192, hashStringXXHASH)
+func getTestComputeCache() *assetCache[*computeInstance] {
+	computeAssetsCache := newAssetCache[*computeInstance](nil, "compute")
 	cI := computeInstance{
 		ID:     "123",
 		Region: "europe-west1",
@@ -132,6 +227,10 @@ func getTestComputeCache() *freelru.LRU[string, *computeInstance] {
 					Key:   proto.String("kube-labels"),
 					Value: proto.String("cloud.google.com/gke-nodepool=mynodepool"),
 				},
+				{
+					Key:   proto.String("created-by"),
+					Value: proto.String("projects/123456789/zones/europe-west1-d/instanceGroupManagers/gke-mycluster-mynodepool-grp"),
+				},
 			},
 		},
 	}
@@ -140,17 +239,14 @@ func getTestComputeCache() *freelru.LRU[string, *computeInstance] {
 	return computeAssetsCache
 }
 
-func getComputeCache() *freelru.LRU[string, *computeInstance] {
-	computeAssetsCache, _ := freelru.New[string, *computeInstance](8192, hashStringXXHASH)
-	return computeAssetsCache
+func getComputeCache(reg *monitoring.Registry) *assetCache[*computeInstance] {
+	return newAssetCache[*computeInstance](reg, "compute")
 }
 
-func getSubnetCache() *freelru.LRU[string, *subnet] {
-	computeAssetsCache, _ := freelru.New[string, *subnet](8192, hashStringXXHASH)
-	return computeAssetsCache
+func getSubnetCache(reg *monitoring.Registry) *assetCache[*subnet] {
+	return newAssetCache[*subnet](reg, "subnet")
 }
 
-func getVpcCache() *freelru.LRU[string, *vpc] {
-	computeAssetsCache, _ := freelru.New[string, *vpc](8192, hashStringXXHASH)
-	return computeAssetsCache
+func getVpcCache(reg *monitoring.Registry) *assetCache[*vpc] {
+	return newAssetCache[*vpc](reg, "vpc")
 }
@@ -135,6 +135,136 @@ func TestGetNetSelfLinkFromNetConfig(t *testing.T) {
 	}
 }
 
+func TestGetNetSelfLinkFromName(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+
+		network          string
+		expectedSelfLink string
+	}{
+		{
+			name: "with an empty value",
+
+			network:          "",
+			expectedSelfLink: "",
+		},
+		{
+			name: "with a network name",
+
+			network:          "my_network",
+			expectedSelfLink: "https://www.googleapis.com/compute/v1/projects/my_project/global/networks/my_network",
+		},
+		{
+			name: "with a relative resource name",
+
+			network:          "projects/other_project/global/networks/my_network",
+			expectedSelfLink: "https://www.googleapis.com/compute/v1/projects/other_project/global/networks/my_network",
+		},
+		{
+			name: "with a self link",
+
+			network:          "https://www.googleapis.com/compute/v1/projects/my_project/global/networks/my_network",
+			expectedSelfLink: "https://www.googleapis.com/compute/v1/projects/my_project/global/networks/my_network",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedSelfLink, getNetSelfLinkFromName("my_project", tt.network))
+		})
+	}
+}
+
+func TestGetLocationFromResourceName(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+
+		resourceName     string
+		expectedLocation string
+	}{
+		{
+			name: "with an empty value",
+
+			resourceName:     "",
+			expectedLocation: "",
+		},
+		{
+			name: "with a valid resource name",
+
+			resourceName:     "projects/my_project/locations/europe-west1/services/my-service",
+			expectedLocation: "europe-west1",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedLocation, getLocationFromResourceName(tt.resourceName))
+		})
+	}
+}
+
+func TestGetZonalResourceKey(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+
+		resource    string
+		expectedKey string
+	}{
+		{
+			name: "with an empty value",
+
+			resource:    "",
+			expectedKey: "",
+		},
+		{
+			name: "with a non zonal resource",
+
+			resource:    "https://www.googleapis.com/compute/v1/projects/my_project/global/networks/my_network",
+			expectedKey: "",
+		},
+		{
+			name: "with a self link",
+
+			resource:    "https://www.googleapis.com/compute/v1/projects/my_project/zones/europe-west1-d/instanceGroupManagers/my-group",
+			expectedKey: "europe-west1-d/my-group",
+		},
+		{
+			name: "with a relative name using the project number",
+
+			resource:    "projects/123456789/zones/europe-west1-d/instanceGroupManagers/my-group",
+			expectedKey: "europe-west1-d/my-group",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedKey, getZonalResourceKey(tt.resource))
+		})
+	}
+}
+
+func TestGetLocationParents(t *testing.T) {
+	assert.Equal(t, []string{"projects/my_project/locations/-"}, getLocationParents("my_project", nil))
+	assert.Equal(t,
+		[]string{"projects/my_project/locations/us-west1", "projects/my_project/locations/europe-west1"},
+		getLocationParents("my_project", []string{"us-west1", "europe-west1"}))
+}
+
+func TestWantScope(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+
+		scope    string
+		regions  []string
+		expected bool
+	}{
+		{name: "global scope with regions", scope: "global", regions: []string{"us-west2"}, expected: true},
+		{name: "region scope without regions", scope: "regions/us-west2", expected: true},
+		{name: "matching region scope", scope: "regions/us-west2", regions: []string{"us-west2"}, expected: true},
+		{name: "non matching region scope", scope: "regions/us-west1", regions: []string{"us-west2"}, expected: false},
+		{name: "matching zone scope", scope: "zones/us-west2-a", regions: []string{"us-west2"}, expected: true},
+		{name: "non matching zone scope", scope: "zones/us-west1-a", regions: []string{"us-west2"}, expected: false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, wantScope(tt.scope, tt.regions))
+		})
+	}
+}
+
 func TestWantRegion(t *testing.T) {
 
 	for _, tt := range []struct {
@@ -207,3 +337,29 @@ func TestWantZone(t *testing.T) {
 		})
 	}
 }
+
+func TestGetProjectFromResourceName(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+
+		resourceName    string
+		expectedProject string
+	}{
+		{
+			name: "with an empty value",
+
+			resourceName:    "",
+			expectedProject: "",
+		},
+		{
+			name: "with a valid resource name",
+
+			resourceName:    "projects/my_project/locations/europe-west1/connectors/my-connector",
+			expectedProject: "my_project",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedProject, getProjectFromResourceName(tt.resourceName))
+		})
+	}
+}
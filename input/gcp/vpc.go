@@ -30,7 +30,6 @@ import (
 	"github.com/elastic/assetbeat/input/internal"
 	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
 	"github.com/elastic/elastic-agent-libs/logp"
-	"github.com/elastic/go-freelru"
 )
 
 type NetworkIterator interface {
@@ -65,13 +64,17 @@ type subnet struct {
 	Region  string
 }
 
-func collectVpcAssets(ctx context.Context, cfg config, vpcAssetCache *freelru.LRU[string, *vpc], client listNetworkAPIClient, publisher stateless.Publisher, log *logp.Logger) error {
+func collectVpcAssets(ctx context.Context, cfg config, vpcAssetCache *assetCache[*vpc], client listNetworkAPIClient, publisher stateless.Publisher, log *logp.Logger) error {
 	vpcs, err := getAllVPCs(ctx, cfg, vpcAssetCache, client)
 	if err != nil {
 		return err
 	}
 	assetType := "gcp.vpc"
 	assetKind := "network"
+	if !internal.IsTypeEnabled(cfg.AssetTypes, assetType) {
+		// the resources are still cached, as they are needed to resolve relationships
+		return nil
+	}
 
 	log.Debug("Publishing VPCs")
 	for _, vpc := range vpcs {
@@ -91,7 +94,7 @@ func collectVpcAssets(ctx context.Context, cfg config, vpcAssetCache *freelru.LR
 	return nil
 }
 
-func getAllVPCs(ctx context.Context, cfg config, vpcAssetCache *freelru.LRU[string, *vpc], client listNetworkAPIClient) ([]vpc, error) {
+func getAllVPCs(ctx context.Context, cfg config, vpcAssetCache *assetCache[*vpc], client listNetworkAPIClient) ([]vpc, error) {
 	var vpcs []vpc
 	for _, project := range cfg.Projects {
 		req := &computepb.ListNetworksRequest{
@@ -121,7 +124,7 @@ func getAllVPCs(ctx context.Context, cfg config, vpcAssetCache *freelru.LRU[stri
 
 }
 
-func collectSubnetAssets(ctx context.Context, cfg config, subnetAssetCache *freelru.LRU[string, *subnet], client listSubnetworkAPIClient, publisher stateless.Publisher, log *logp.Logger) error {
+func collectSubnetAssets(ctx context.Context, cfg config, subnetAssetCache *assetCache[*subnet], client listSubnetworkAPIClient, publisher stateless.Publisher, log *logp.Logger) error {
 	subnets, err := getAllSubnets(ctx, cfg, subnetAssetCache, client)
 
 	if err != nil {
@@ -130,6 +133,10 @@ func collectSubnetAssets(ctx context.Context, cfg config, subnetAssetCache *free
 
 	assetType := "gcp.subnet"
 	assetKind := "network"
+	if !internal.IsTypeEnabled(cfg.AssetTypes, assetType) {
+		// the resources are still cached, as they are needed to resolve relationships
+		return nil
+	}
 	log.Debug("Publishing Subnets")
 	for _, subnet := range subnets {
 
@@ -149,7 +156,7 @@ func collectSubnetAssets(ctx context.Context, cfg config, subnetAssetCache *free
 	return nil
 }
 
-func getAllSubnets(ctx context.Context, cfg config, subnetAssetCache *freelru.LRU[string, *subnet], client listSubnetworkAPIClient) ([]subnet, error) {
+func getAllSubnets(ctx context.Context, cfg config, subnetAssetCache *assetCache[*subnet], client listSubnetworkAPIClient) ([]subnet, error) {
 	var subnets []subnet
 	for _, project := range cfg.Projects {
 		req := &computepb.AggregatedListSubnetworksRequest{
@@ -106,6 +106,26 @@ func TestCollectVpcAssets(t *testing.T) {
 		networks       map[string]*StubNetworksListIterator
 		expectedEvents []beat.Event
 	}{
+		{
+			name: "with gcp.vpc disabled, vpcs are only cached",
+			cfg: config{
+				BaseConfig: internal.BaseConfig{
+					AssetTypes: []string{"k8s.cluster"},
+				},
+				Projects: []string{"my_project"},
+			},
+			networks: map[string]*StubNetworksListIterator{
+				"my_project": {
+					ReturnNetworksList: []*computepb.Network{
+						{
+							Id:       proto.Uint64(1),
+							Name:     proto.String("test-vpc-1"),
+							SelfLink: proto.String("https://www.googleapis.com/compute/v1/projects/myproject/global/networks/test-vpc-1"),
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "single project, multiple vpcs",
 			cfg: config{
@@ -262,10 +282,14 @@ func TestCollectVpcAssets(t *testing.T) {
 				return client.List(ctx, req, opts...)
 			}}
 			log := logp.NewLogger("mylogger")
-			vpcAssetsCache := getVpcCache()
+			vpcAssetsCache := getVpcCache(nil)
 			err := collectVpcAssets(ctx, tt.cfg, vpcAssetsCache, listClient, publisher, log)
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedEvents, publisher.Events)
+			for _, network := range tt.networks[tt.cfg.Projects[0]].ReturnNetworksList {
+				_, ok := vpcAssetsCache.Get(network.GetSelfLink())
+				assert.True(t, ok)
+			}
 		})
 	}
 }
@@ -531,7 +555,7 @@ func TestCollectSubnetAssets(t *testing.T) {
 				},
 			}
 			log := logp.NewLogger("mylogger")
-			subnetAssetsCache := getSubnetCache()
+			subnetAssetsCache := getSubnetCache(nil)
 			err := collectSubnetAssets(ctx, tt.cfg, subnetAssetsCache, clientCreator, publisher, log)
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedEvents, publisher.Events)
@@ -0,0 +1,83 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/vpcaccess/apiv1/vpcaccesspb"
+	"github.com/googleapis/gax-go/v2"
+
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+type getVPCConnectorAPIClient interface {
+	GetConnector(ctx context.Context, req *vpcaccesspb.GetConnectorRequest, opts ...gax.CallOption) (*vpcaccesspb.Connector, error)
+}
+
+// vpcConnectorResolver resolves the VPC networks of the Serverless VPC Access connectors through
+// the VPC cache, so that the Cloud Run services and Cloud Functions using a connector are parented
+// to its network. The connectors themselves are not published as assets. Each connector is only
+// retrieved once per collection.
+type vpcConnectorResolver struct {
+	client        getVPCConnectorAPIClient
+	vpcAssetCache *assetCache[*vpc]
+	log           *logp.Logger
+	vpcIDs        map[string]string
+}
+
+func newVPCConnectorResolver(client getVPCConnectorAPIClient, vpcAssetCache *assetCache[*vpc], log *logp.Logger) *vpcConnectorResolver {
+	return &vpcConnectorResolver{
+		client:        client,
+		vpcAssetCache: vpcAssetCache,
+		log:           log,
+		vpcIDs:        map[string]string{},
+	}
+}
+
+// getVpcID returns the ID of the VPC network of a connector, given either by its resource name or,
+// in the project and region of the resource using it, by its name. An empty string is returned if
+// the connector cannot be retrieved or if its network is not in the VPC cache.
+func (r *vpcConnectorResolver) getVpcID(ctx context.Context, project, region, connector string) string {
+	if connector == "" {
+		return ""
+	}
+	if !strings.HasPrefix(connector, "projects/") {
+		connector = fmt.Sprintf("projects/%s/locations/%s/connectors/%s", project, region, connector)
+	}
+	if vpcID, ok := r.vpcIDs[connector]; ok {
+		return vpcID
+	}
+
+	var vpcID string
+	c, err := r.client.GetConnector(ctx, &vpcaccesspb.GetConnectorRequest{Name: connector})
+	if err != nil {
+		r.log.Warnf("unable to get the VPC access connector %s: %v", connector, err)
+	} else {
+		// the network of a connector using a shared VPC subnet is in the host project of the subnet
+		networkProject := c.GetSubnet().GetProjectId()
+		if networkProject == "" {
+			networkProject = getProjectFromResourceName(connector)
+		}
+		vpcID = getVpcIdFromLink(getNetSelfLinkFromName(networkProject, c.GetNetwork()), r.vpcAssetCache)
+	}
+	r.vpcIDs[connector] = vpcID
+	return vpcID
+}
@@ -39,3 +39,13 @@ func IsTypeEnabled(configuredTypes []string, currentType string) bool {
 
 	return false
 }
+
+// IsAnyTypeEnabled returns true if at least one of the given asset types is enabled.
+func IsAnyTypeEnabled(configuredTypes []string, assetTypes ...string) bool {
+	for _, t := range assetTypes {
+		if IsTypeEnabled(configuredTypes, t) {
+			return true
+		}
+	}
+	return false
+}
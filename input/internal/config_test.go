@@ -59,3 +59,38 @@ func TestAssets_IsTypeEnabled(t *testing.T) {
 		})
 	}
 }
+
+func TestAssets_IsAnyTypeEnabled(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+
+		configuredTypes []string
+		assetTypes      []string
+		expected        bool
+	}{
+		{
+			name: "with no configured types",
+
+			assetTypes: []string{"gcp.vpc", "k8s.cluster"},
+			expected:   true,
+		},
+		{
+			name: "with one of the types configured",
+
+			configuredTypes: []string{"k8s.cluster"},
+			assetTypes:      []string{"gcp.vpc", "k8s.cluster"},
+			expected:        true,
+		},
+		{
+			name: "with none of the types configured",
+
+			configuredTypes: []string{"gcp.subnet"},
+			assetTypes:      []string{"gcp.vpc", "k8s.cluster"},
+			expected:        false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsAnyTypeEnabled(tt.configuredTypes, tt.assetTypes...))
+		})
+	}
+}
@@ -158,6 +158,29 @@ func WithContainerData(name, uid, namespace, state string, startTime *metav1.Tim
 	}
 }
 
+// WithNamespaceData sets the fields of a kubernetes namespace.
+func WithNamespaceData(name, uid string) AssetOption {
+	return func(e beat.Event) beat.Event {
+		e.Fields["kubernetes.namespace"] = name
+		e.Fields["kubernetes.namespace_uid"] = uid
+		return e
+	}
+}
+
+// WithResourceData sets the fields of a kubernetes resource, e.g. kubernetes.deployment.name for a deployment.
+// The namespace is not set for cluster-scoped resources, whose namespace is empty.
+func WithResourceData(resourceType, name, uid, namespace string, startTime *metav1.Time) AssetOption {
+	return func(e beat.Event) beat.Event {
+		e.Fields["kubernetes."+resourceType+".name"] = name
+		e.Fields["kubernetes."+resourceType+".uid"] = uid
+		e.Fields["kubernetes."+resourceType+".start_time"] = startTime
+		if namespace != "" {
+			e.Fields["kubernetes.namespace"] = namespace
+		}
+		return e
+	}
+}
+
 func ToMapstr(input map[string]string) mapstr.M {
 	out := mapstr.M{}
 	for k, v := range input {
@@ -0,0 +1,332 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/elastic/assetbeat/input/internal"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	kube "github.com/elastic/elastic-agent-autodiscover/kubernetes"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+
+	kuberntescli "k8s.io/client-go/kubernetes"
+)
+
+const (
+	// kubeSystemNamespace is the namespace whose UID identifies the cluster, as it exists in every distribution
+	kubeSystemNamespace = "kube-system"
+	// eksctlClusterNameLabel is set on the nodes of the EKS clusters created with eksctl
+	eksctlClusterNameLabel = "alpha.eksctl.io/cluster-name"
+	azureMetadataURI       = "/metadata/instance/compute?api-version=2021-02-01"
+	// clusterResolveMinBackoff and clusterResolveMaxBackoff bound the delay before retrying a failed resolution
+	clusterResolveMinBackoff = 10 * time.Second
+	clusterResolveMaxBackoff = 5 * time.Minute
+)
+
+// clusterIdentity identifies the cluster watched by the input, and the cloud managed cluster it is, if detected
+type clusterIdentity struct {
+	ID      string
+	Name    string
+	Version string
+	// CloudProvider is the provider of the nodes of the cluster, if any
+	CloudProvider string
+	Region        string
+	AccountID     string
+	// CloudID is the ID of the asset of the cloud managed cluster, i.e. the GKE cluster uid, the EKS cluster ARN
+	// or the AKS cluster resource ID
+	CloudID string
+}
+
+type awsIdentityDocumentClient interface {
+	GetInstanceIdentityDocument(ctx context.Context, params *imds.GetInstanceIdentityDocumentInput, optFns ...func(*imds.Options)) (*imds.GetInstanceIdentityDocumentOutput, error)
+}
+
+// clusterResolver resolves the identity of the watched cluster, which is kept once it has been resolved.
+// A failed resolution is kept as well and only retried after a backoff, doubled on every failure.
+type clusterResolver struct {
+	client      kuberntescli.Interface
+	clusterName string
+	// contextName names the cluster when its name is neither configured nor detected
+	contextName string
+	isInCluster bool
+	metadata    httpResponse
+	awsIMDS     awsIdentityDocumentClient
+
+	mu       sync.Mutex
+	identity *clusterIdentity
+	err      error
+	backoff  time.Duration
+	retryAt  time.Time
+	now      func() time.Time
+}
+
+func newClusterResolver(cfg config, cluster kubeCluster) *clusterResolver {
+	return &clusterResolver{
+		client:      cluster.client,
+		clusterName: cfg.ClusterName,
+		contextName: cluster.name,
+		isInCluster: cluster.isInCluster,
+		metadata:    newhttpFetcher(),
+		awsIMDS:     imds.New(imds.Options{}),
+		now:         time.Now,
+	}
+}
+
+// get returns the identity of the cluster, resolving it if it has not been resolved yet.
+// The error of the last resolution is returned without calling the API until its backoff has elapsed.
+func (r *clusterResolver) get(ctx context.Context, log *logp.Logger) (clusterIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.identity != nil {
+		return *r.identity, nil
+	}
+	now := time.Now
+	if r.now != nil {
+		now = r.now
+	}
+	if r.err != nil && now().Before(r.retryAt) {
+		return clusterIdentity{}, r.err
+	}
+	identity, err := r.resolve(ctx, log)
+	if err != nil {
+		r.backoff *= 2
+		if r.backoff < clusterResolveMinBackoff {
+			r.backoff = clusterResolveMinBackoff
+		} else if r.backoff > clusterResolveMaxBackoff {
+			r.backoff = clusterResolveMaxBackoff
+		}
+		r.err = err
+		r.retryAt = now().Add(r.backoff)
+		return clusterIdentity{}, err
+	}
+	r.identity = &identity
+	r.err = nil
+	return identity, nil
+}
+
+// resolve identifies the cluster by its configured name, or else by the UID of its kube-system namespace.
+// The cloud managed cluster is only detected from the instance metadata when running in the cluster,
+// as the metadata would otherwise be the one of another machine.
+func (r *clusterResolver) resolve(ctx context.Context, log *logp.Logger) (clusterIdentity, error) {
+	identity := clusterIdentity{ID: r.clusterName, Name: r.clusterName}
+	if identity.ID == "" {
+		ns, err := r.client.CoreV1().Namespaces().Get(ctx, kubeSystemNamespace, metav1.GetOptions{})
+		if err != nil {
+			return identity, fmt.Errorf("unable to get the %s namespace identifying the cluster: %w", kubeSystemNamespace, err)
+		}
+		identity.ID = string(ns.UID)
+	}
+
+	if version, err := r.client.Discovery().ServerVersion(); err == nil {
+		identity.Version = version.GitVersion
+	} else {
+		log.Debugf("Unable to get the cluster version: %v", err)
+	}
+
+	nodes, err := r.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{Limit: 1})
+	if err == nil && len(nodes.Items) > 0 {
+		node := nodes.Items[0]
+		identity.CloudProvider = getCspFromProviderId(node.Spec.ProviderID)
+		if r.isInCluster {
+			if err := r.resolveCloudCluster(ctx, log, &identity, &node); err != nil {
+				log.Debugf("Unable to detect the %s managed cluster: %v", identity.CloudProvider, err)
+			}
+		}
+	} else {
+		log.Debugf("Unable to get a node to detect the cloud provider of the cluster: %v", err)
+	}
+	if identity.Name == "" {
+		identity.Name = r.contextName
+	}
+	return identity, nil
+}
+
+// resolveCloudCluster sets the cloud managed cluster of the identity from the instance metadata of the node
+func (r *clusterResolver) resolveCloudCluster(ctx context.Context, log *logp.Logger, identity *clusterIdentity, node *kube.Node) error {
+	switch identity.CloudProvider {
+	case "gcp":
+		clusterUid, err := getGKEClusterUid(ctx, log, r.metadata)
+		if err != nil {
+			return err
+		}
+		identity.CloudID = clusterUid
+	case "aws":
+		doc, err := r.awsIMDS.GetInstanceIdentityDocument(ctx, &imds.GetInstanceIdentityDocumentInput{})
+		if err != nil {
+			return err
+		}
+		identity.Region = doc.Region
+		identity.AccountID = doc.AccountID
+		if identity.Name == "" {
+			identity.Name = node.Labels[eksctlClusterNameLabel]
+		}
+		if identity.Name != "" {
+			identity.CloudID = getEKSClusterARN(doc.Region, doc.AccountID, identity.Name)
+		}
+	case "azure":
+		response, err := r.metadata.FetchResponse(ctx, fmt.Sprintf("http://%s%s", metadataHost, azureMetadataURI), map[string]string{"Metadata": "true"})
+		if err != nil {
+			return err
+		}
+		var compute struct {
+			SubscriptionID    string `json:"subscriptionId"`
+			ResourceGroupName string `json:"resourceGroupName"`
+			Location          string `json:"location"`
+		}
+		if err := json.Unmarshal(response, &compute); err != nil {
+			return err
+		}
+		identity.Region = compute.Location
+		identity.AccountID = compute.SubscriptionID
+		resourceGroup, name, ok := getAKSClusterFromNodeResourceGroup(compute.ResourceGroupName, compute.Location, identity.Name)
+		if ok {
+			identity.Name = name
+			identity.CloudID = fmt.Sprintf("/subscriptions/%s/resourcegroups/%s/providers/Microsoft.ContainerService/managedClusters/%s", compute.SubscriptionID, resourceGroup, name)
+		}
+	}
+	return nil
+}
+
+// getEKSClusterARN returns the ARN of an EKS cluster, in the partition of its region
+func getEKSClusterARN(region, accountID, name string) string {
+	partition := "aws"
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		partition = "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		partition = "aws-us-gov"
+	}
+	return fmt.Sprintf("arn:%s:eks:%s:%s:cluster/%s", partition, region, accountID, name)
+}
+
+// getAKSClusterFromNodeResourceGroup returns the resource group and the name of an AKS cluster from the default name
+// of the resource group of its nodes, i.e. MC_<resource group>_<cluster name>_<location>. Unless the name of the cluster
+// is known, the resource group and the cluster name cannot contain underscores, to be told apart.
+func getAKSClusterFromNodeResourceGroup(nodeResourceGroup, location, clusterName string) (string, string, bool) {
+	prefix, suffix := "mc_", "_"+strings.ToLower(location)
+	if !strings.HasPrefix(strings.ToLower(nodeResourceGroup), prefix) || !strings.HasSuffix(strings.ToLower(nodeResourceGroup), suffix) {
+		return "", "", false
+	}
+	rest := nodeResourceGroup[len(prefix) : len(nodeResourceGroup)-len(suffix)]
+	if clusterName != "" {
+		if !strings.HasSuffix(rest, "_"+clusterName) {
+			return "", "", false
+		}
+		return strings.TrimSuffix(rest, "_"+clusterName), clusterName, true
+	}
+	parts := strings.Split(rest, "_")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// getClusterParents returns the EAN of the watched cluster, or nil if it cannot be identified
+func getClusterParents(ctx context.Context, log *logp.Logger, resolver *clusterResolver) []string {
+	identity, err := resolver.get(ctx, log)
+	if err != nil {
+		log.Errorf("Unable to identify the cluster: %v", err)
+		return nil
+	}
+	return []string{fmt.Sprintf("%s:%s", "cluster", identity.ID)}
+}
+
+// publishK8sCluster publishes the asset of the watched cluster, parented to the cloud managed cluster, if detected
+func publishK8sCluster(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, resolver *clusterResolver) {
+	identity, err := resolver.get(ctx, log)
+	if err != nil {
+		log.Errorf("Publishing cluster asset failed: %v", err)
+		return
+	}
+	log.Debugf("Publish Cluster: %+v", identity.ID)
+
+	assetParents := []string{}
+	if identity.CloudID != "" {
+		assetParents = append(assetParents, fmt.Sprintf("%s:%s", "cluster", identity.CloudID))
+	}
+	options := []internal.AssetOption{
+		internal.WithAssetKindAndID("cluster", identity.ID),
+		internal.WithAssetType("k8s.cluster"),
+		internal.WithAssetParents(assetParents),
+		internal.WithAssetMetadata(mapstr.M{
+			"version": identity.Version,
+		}),
+	}
+	if identity.Name != "" {
+		options = append(options, internal.WithAssetName(identity.Name))
+	}
+	if identity.CloudProvider != "" {
+		options = append(options, internal.WithAssetCloudProvider(identity.CloudProvider))
+	}
+	if identity.Region != "" {
+		options = append(options, internal.WithAssetRegion(identity.Region))
+	}
+	if identity.AccountID != "" {
+		options = append(options, internal.WithAssetAccountID(identity.AccountID))
+	}
+	internal.Publish(publisher, nil, options...)
+}
+
+// clusterPublisher tags the published assets with the identity of their cluster, so that the assets
+// of several clusters collected by the same input can be told apart
+type clusterPublisher struct {
+	ctx       context.Context
+	log       *logp.Logger
+	publisher stateless.Publisher
+	resolver  *clusterResolver
+}
+
+func newClusterPublisher(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, resolver *clusterResolver) *clusterPublisher {
+	return &clusterPublisher{
+		ctx:       ctx,
+		log:       log,
+		publisher: publisher,
+		resolver:  resolver,
+	}
+}
+
+// Publish publishes the event with the orchestrator.* fields of its cluster. The event is published
+// untagged if the cluster cannot be identified.
+func (p *clusterPublisher) Publish(event beat.Event) {
+	identity, err := p.resolver.get(p.ctx, p.log)
+	if err != nil {
+		p.log.Debugf("Unable to tag the asset with its cluster: %v", err)
+		p.publisher.Publish(event)
+		return
+	}
+	event.Fields["orchestrator.type"] = "kubernetes"
+	event.Fields["orchestrator.cluster.id"] = identity.ID
+	if identity.Name != "" {
+		event.Fields["orchestrator.cluster.name"] = identity.Name
+	}
+	if identity.Version != "" {
+		event.Fields["orchestrator.cluster.version"] = identity.Version
+	}
+	p.publisher.Publish(event)
+}
@@ -0,0 +1,340 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/assetbeat/input/testutil"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type mockAWSIdentityDocumentClient struct {
+	document imds.InstanceIdentityDocument
+}
+
+func (c mockAWSIdentityDocumentClient) GetInstanceIdentityDocument(ctx context.Context, params *imds.GetInstanceIdentityDocumentInput, optFns ...func(*imds.Options)) (*imds.GetInstanceIdentityDocumentOutput, error) {
+	return &imds.GetInstanceIdentityDocumentOutput{InstanceIdentityDocument: c.document}, nil
+}
+
+func TestClusterResolver(t *testing.T) {
+	const kubeSystemUID = "0b8f1c6e-6a4d-4f0e-9d6a-7c2b3e4f5a6b"
+	gkeMetadata, _ := json.Marshal(map[string]interface{}{
+		"instance": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"cluster-uid": "ed436d761637404fa772b2822ab1036f14cf4727c4e54a28ac86f5ab8dcda4af",
+			},
+		},
+	})
+	azureMetadata, _ := json.Marshal(map[string]interface{}{
+		"subscriptionId":    "7f1c2b3a-0000-0000-0000-000000000000",
+		"resourceGroupName": "MC_shop-rg_shop-aks_westeurope",
+		"location":          "westeurope",
+	})
+	awsDocument := imds.InstanceIdentityDocument{Region: "eu-west-1", AccountID: "123456789012"}
+
+	for _, tt := range []struct {
+		name        string
+		clusterName string
+		contextName string
+		isInCluster bool
+		node        *v1.Node
+		metadata    []byte
+		expected    clusterIdentity
+	}{
+		{
+			name:     "cluster without cloud provider",
+			node:     &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "kind-control-plane"}},
+			expected: clusterIdentity{ID: kubeSystemUID, Version: "v1.27.3"},
+		},
+		{
+			name:        "cluster with a configured name",
+			clusterName: "production",
+			node:        &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "kind-control-plane"}},
+			expected:    clusterIdentity{ID: "production", Name: "production", Version: "v1.27.3"},
+		},
+		{
+			name:        "cluster of a kubeconfig context",
+			contextName: "kind-kind",
+			node:        &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "kind-control-plane"}},
+			expected:    clusterIdentity{ID: kubeSystemUID, Name: "kind-kind", Version: "v1.27.3"},
+		},
+		{
+			name:     "GKE cluster watched from outside the cluster",
+			node:     &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "gke-node"}, Spec: v1.NodeSpec{ProviderID: "gce://my-project/us-central1-a/gke-node"}},
+			metadata: gkeMetadata,
+			expected: clusterIdentity{ID: kubeSystemUID, Version: "v1.27.3", CloudProvider: "gcp"},
+		},
+		{
+			name:        "GKE cluster",
+			isInCluster: true,
+			node:        &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "gke-node"}, Spec: v1.NodeSpec{ProviderID: "gce://my-project/us-central1-a/gke-node"}},
+			metadata:    gkeMetadata,
+			expected: clusterIdentity{
+				ID:            kubeSystemUID,
+				Version:       "v1.27.3",
+				CloudProvider: "gcp",
+				CloudID:       "ed436d761637404fa772b2822ab1036f14cf4727c4e54a28ac86f5ab8dcda4af",
+			},
+		},
+		{
+			name:        "EKS cluster created with eksctl",
+			isInCluster: true,
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "ip-10-0-1-12", Labels: map[string]string{eksctlClusterNameLabel: "shop"}},
+				Spec:       v1.NodeSpec{ProviderID: "aws:///eu-west-1a/i-0123456789abcdef0"},
+			},
+			expected: clusterIdentity{
+				ID:            kubeSystemUID,
+				Name:          "shop",
+				Version:       "v1.27.3",
+				CloudProvider: "aws",
+				Region:        "eu-west-1",
+				AccountID:     "123456789012",
+				CloudID:       "arn:aws:eks:eu-west-1:123456789012:cluster/shop",
+			},
+		},
+		{
+			name:        "EKS cluster without cluster name",
+			isInCluster: true,
+			node:        &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "ip-10-0-1-12"}, Spec: v1.NodeSpec{ProviderID: "aws:///eu-west-1a/i-0123456789abcdef0"}},
+			expected: clusterIdentity{
+				ID:            kubeSystemUID,
+				Version:       "v1.27.3",
+				CloudProvider: "aws",
+				Region:        "eu-west-1",
+				AccountID:     "123456789012",
+			},
+		},
+		{
+			name:        "AKS cluster",
+			isInCluster: true,
+			node:        &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "aks-nodepool1-0"}, Spec: v1.NodeSpec{ProviderID: "azure:///subscriptions/7f1c2b3a-0000-0000-0000-000000000000/resourceGroups/mc_shop-rg_shop-aks_westeurope/providers/Microsoft.Compute/virtualMachineScaleSets/aks-nodepool1/virtualMachines/0"}},
+			metadata:    azureMetadata,
+			expected: clusterIdentity{
+				ID:            kubeSystemUID,
+				Name:          "shop-aks",
+				Version:       "v1.27.3",
+				CloudProvider: "azure",
+				Region:        "westeurope",
+				AccountID:     "7f1c2b3a-0000-0000-0000-000000000000",
+				CloudID:       "/subscriptions/7f1c2b3a-0000-0000-0000-000000000000/resourcegroups/shop-rg/providers/Microsoft.ContainerService/managedClusters/shop-aks",
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := k8sfake.NewSimpleClientset(
+				&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", UID: kubeSystemUID}},
+				tt.node,
+			)
+			client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{GitVersion: "v1.27.3"}
+			resolver := &clusterResolver{
+				client:      client,
+				clusterName: tt.clusterName,
+				contextName: tt.contextName,
+				isInCluster: tt.isInCluster,
+				metadata:    newMockhttpResponse(tt.metadata),
+				awsIMDS:     mockAWSIdentityDocumentClient{document: awsDocument},
+			}
+
+			identity, err := resolver.get(context.Background(), logp.NewLogger("mylogger"))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, identity)
+		})
+	}
+}
+
+func TestClusterResolver_Unidentified(t *testing.T) {
+	client := k8sfake.NewSimpleClientset()
+	now := time.Now()
+	resolver := &clusterResolver{client: client, now: func() time.Time { return now }}
+	log := logp.NewLogger("mylogger")
+
+	_, err := resolver.get(context.Background(), log)
+	assert.Error(t, err)
+	assert.Nil(t, getClusterParents(context.Background(), log, resolver))
+
+	// the identity is resolved again once the cluster can be identified and the backoff has elapsed
+	_, err = client.CoreV1().Namespaces().Create(context.Background(), &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "kube-system", UID: "0b8f1c6e-6a4d-4f0e-9d6a-7c2b3e4f5a6b"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	now = now.Add(clusterResolveMinBackoff)
+	assert.Equal(t, []string{"cluster:0b8f1c6e-6a4d-4f0e-9d6a-7c2b3e4f5a6b"}, getClusterParents(context.Background(), log, resolver))
+}
+
+func TestClusterResolver_Backoff(t *testing.T) {
+	client := k8sfake.NewSimpleClientset()
+	var gets int
+	client.PrependReactor("get", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		gets++
+		return true, nil, errors.New("forbidden")
+	})
+	now := time.Now()
+	resolver := &clusterResolver{client: client, contextName: "kind-kind", now: func() time.Time { return now }}
+	publisher := testutil.NewInMemoryPublisher()
+	clusterPublisher := newClusterPublisher(context.Background(), logp.NewLogger("mylogger"), publisher, resolver)
+
+	// the failure is kept until its backoff has elapsed
+	for i := 0; i < 3; i++ {
+		internal.Publish(clusterPublisher, nil, internal.WithAssetKindAndID("container_group", "a375d24b-fa20-4ea6-a0ee-1d38671d2c09"))
+	}
+	assert.Nil(t, getClusterParents(context.Background(), logp.NewLogger("mylogger"), resolver))
+	assert.Equal(t, 1, gets)
+	require.Len(t, publisher.Events, 3)
+	for _, event := range publisher.Events {
+		_, err := event.Fields.GetValue("orchestrator.cluster.id")
+		assert.ErrorIs(t, err, mapstr.ErrKeyNotFound)
+	}
+
+	// the backoff is doubled on every failure
+	now = now.Add(clusterResolveMinBackoff)
+	_, err := resolver.get(context.Background(), logp.NewLogger("mylogger"))
+	assert.Error(t, err)
+	assert.Equal(t, 2, gets)
+	now = now.Add(clusterResolveMinBackoff)
+	_, err = resolver.get(context.Background(), logp.NewLogger("mylogger"))
+	assert.Error(t, err)
+	assert.Equal(t, 2, gets)
+	now = now.Add(clusterResolveMinBackoff)
+	_, err = resolver.get(context.Background(), logp.NewLogger("mylogger"))
+	assert.Error(t, err)
+	assert.Equal(t, 3, gets)
+}
+
+func TestGetAKSClusterFromNodeResourceGroup(t *testing.T) {
+	for _, tt := range []struct {
+		name                  string
+		nodeResourceGroup     string
+		clusterName           string
+		expectedResourceGroup string
+		expectedName          string
+		expectedOk            bool
+	}{
+		{
+			name:                  "default node resource group",
+			nodeResourceGroup:     "MC_shop-rg_shop-aks_westeurope",
+			expectedResourceGroup: "shop-rg",
+			expectedName:          "shop-aks",
+			expectedOk:            true,
+		},
+		{
+			name:              "ambiguous node resource group",
+			nodeResourceGroup: "MC_shop_rg_shop-aks_westeurope",
+		},
+		{
+			name:                  "ambiguous node resource group with the cluster name",
+			nodeResourceGroup:     "MC_shop_rg_shop-aks_westeurope",
+			clusterName:           "shop-aks",
+			expectedResourceGroup: "shop_rg",
+			expectedName:          "shop-aks",
+			expectedOk:            true,
+		},
+		{
+			name:              "custom node resource group",
+			nodeResourceGroup: "shop-aks-nodes",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resourceGroup, name, ok := getAKSClusterFromNodeResourceGroup(tt.nodeResourceGroup, "westeurope", tt.clusterName)
+			assert.Equal(t, tt.expectedResourceGroup, resourceGroup)
+			assert.Equal(t, tt.expectedName, name)
+			assert.Equal(t, tt.expectedOk, ok)
+		})
+	}
+}
+
+func TestPublishK8sCluster(t *testing.T) {
+	resolver := &clusterResolver{identity: &clusterIdentity{
+		ID:            "0b8f1c6e-6a4d-4f0e-9d6a-7c2b3e4f5a6b",
+		Name:          "shop",
+		Version:       "v1.27.3",
+		CloudProvider: "aws",
+		Region:        "eu-west-1",
+		AccountID:     "123456789012",
+		CloudID:       "arn:aws:eks:eu-west-1:123456789012:cluster/shop",
+	}}
+	publisher := testutil.NewInMemoryPublisher()
+	publishK8sCluster(context.Background(), logp.NewLogger("mylogger"), publisher, resolver)
+
+	expectedEvent := beat.Event{
+		Fields: mapstr.M{
+			"asset.type":             "k8s.cluster",
+			"asset.kind":             "cluster",
+			"asset.id":               "0b8f1c6e-6a4d-4f0e-9d6a-7c2b3e4f5a6b",
+			"asset.ean":              "cluster:0b8f1c6e-6a4d-4f0e-9d6a-7c2b3e4f5a6b",
+			"asset.name":             "shop",
+			"asset.parents":          []string{"cluster:arn:aws:eks:eu-west-1:123456789012:cluster/shop"},
+			"asset.metadata.version": "v1.27.3",
+			"cloud.provider":         "aws",
+			"cloud.region":           "eu-west-1",
+			"cloud.account.id":       "123456789012",
+		},
+		Meta: mapstr.M{"index": internal.GetDefaultIndexName()},
+	}
+	assert.Equal(t, []beat.Event{expectedEvent}, publisher.Events)
+}
+
+func TestClusterPublisher(t *testing.T) {
+	client := k8sfake.NewSimpleClientset()
+	client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{GitVersion: "v1.27.3"}
+	now := time.Now()
+	resolver := &clusterResolver{client: client, contextName: "kind-kind", now: func() time.Time { return now }}
+	publisher := testutil.NewInMemoryPublisher()
+	clusterPublisher := newClusterPublisher(context.Background(), logp.NewLogger("mylogger"), publisher, resolver)
+
+	// the assets are published untagged until the cluster can be identified
+	internal.Publish(clusterPublisher, nil, internal.WithAssetKindAndID("container_group", "a375d24b-fa20-4ea6-a0ee-1d38671d2c09"))
+	_, err := client.CoreV1().Namespaces().Create(context.Background(), &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "kube-system", UID: "0b8f1c6e-6a4d-4f0e-9d6a-7c2b3e4f5a6b"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	now = now.Add(clusterResolveMinBackoff)
+	internal.Publish(clusterPublisher, nil, internal.WithAssetKindAndID("container_group", "a375d24b-fa20-4ea6-a0ee-1d38671d2c09"))
+
+	require.Len(t, publisher.Events, 2)
+	_, err = publisher.Events[0].Fields.GetValue("orchestrator.cluster.id")
+	assert.ErrorIs(t, err, mapstr.ErrKeyNotFound)
+	assert.Equal(t, mapstr.M{
+		"asset.kind":                   "container_group",
+		"asset.id":                     "a375d24b-fa20-4ea6-a0ee-1d38671d2c09",
+		"asset.ean":                    "container_group:a375d24b-fa20-4ea6-a0ee-1d38671d2c09",
+		"orchestrator.type":            "kubernetes",
+		"orchestrator.cluster.id":      "0b8f1c6e-6a4d-4f0e-9d6a-7c2b3e4f5a6b",
+		"orchestrator.cluster.name":    "kind-kind",
+		"orchestrator.cluster.version": "v1.27.3",
+	}, publisher.Events[1].Fields)
+}
@@ -29,53 +29,59 @@ import (
 	"github.com/elastic/elastic-agent-libs/logp"
 )
 
-// publishK8sPods publishes the pod assets stored in pod watcher cache
+// publishK8sContainers publishes the container assets of the pods stored in pod watcher cache
 func publishK8sContainers(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, podWatcher kube.Watcher) {
 	log.Info("Publishing container assets\n")
-	assetType := "k8s.container"
-	assetKind := "container"
 	for _, obj := range podWatcher.Store().List() {
 		o, ok := obj.(*kube.Pod)
 		if ok {
-			log.Debugf("Publish Pod: %+v", o.Name)
-			parentId := string(o.UID)
-			parentEan := fmt.Sprintf("%s:%s", "container_group", parentId)
-			assetParents := []string{parentEan}
-			namespace := o.Namespace
-
-			containers := kube.GetContainersInPod(o)
-			for _, c := range containers {
-				// If it doesn't have an ID, container doesn't exist in
-				// the runtime
-				if c.ID == "" {
-					continue
-				}
-				assetId := c.ID
-				assetName := c.Spec.Name
-				cPhase := c.Status.State
-				state := ""
-				assetStartTime := metav1.Time{}
-				if cPhase.Waiting != nil {
-					state = "Waiting"
-				} else if cPhase.Running != nil {
-					state = "Running"
-					assetStartTime = cPhase.Running.StartedAt
-				} else if cPhase.Terminated != nil {
-					state = "Terminated"
-					assetStartTime = cPhase.Terminated.StartedAt
-				}
-
-				internal.Publish(publisher, nil,
-					internal.WithAssetKindAndID(assetKind, assetId),
-					internal.WithAssetType(assetType),
-					internal.WithAssetName(assetName),
-					internal.WithAssetParents(assetParents),
-					internal.WithContainerData(assetName, assetId, namespace, state, &assetStartTime),
-				)
-			}
+			publishK8sPodContainers(log, publisher, o)
 		} else {
 			log.Error("Publishing pod assets failed. Type assertion of pod object failed")
 		}
 
 	}
 }
+
+// publishK8sPodContainers publishes the assets of the containers of a pod, with the given additional options
+func publishK8sPodContainers(log *logp.Logger, publisher stateless.Publisher, o *kube.Pod, opts ...internal.AssetOption) {
+	log.Debugf("Publish Pod: %+v", o.Name)
+	assetType := "k8s.container"
+	assetKind := "container"
+	parentId := string(o.UID)
+	parentEan := fmt.Sprintf("%s:%s", "container_group", parentId)
+	assetParents := []string{parentEan}
+	namespace := o.Namespace
+
+	containers := kube.GetContainersInPod(o)
+	for _, c := range containers {
+		// If it doesn't have an ID, container doesn't exist in
+		// the runtime
+		if c.ID == "" {
+			continue
+		}
+		assetId := c.ID
+		assetName := c.Spec.Name
+		cPhase := c.Status.State
+		state := ""
+		assetStartTime := metav1.Time{}
+		if cPhase.Waiting != nil {
+			state = "Waiting"
+		} else if cPhase.Running != nil {
+			state = "Running"
+			assetStartTime = cPhase.Running.StartedAt
+		} else if cPhase.Terminated != nil {
+			state = "Terminated"
+			assetStartTime = cPhase.Terminated.StartedAt
+		}
+
+		options := []internal.AssetOption{
+			internal.WithAssetKindAndID(assetKind, assetId),
+			internal.WithAssetType(assetType),
+			internal.WithAssetName(assetName),
+			internal.WithAssetParents(assetParents),
+			internal.WithContainerData(assetName, assetId, namespace, state, &assetStartTime),
+		}
+		internal.Publish(publisher, nil, append(options, opts...)...)
+	}
+}
@@ -34,7 +34,7 @@ import (
 func TestPublishK8sContainers(t *testing.T) {
 	client := k8sfake.NewSimpleClientset()
 	log := logp.NewLogger("mylogger")
-	podWatcher, err := getPodWatcher(context.Background(), log, client, time.Second*60)
+	podWatcher, err := getPodWatcher(context.Background(), log, client, nil, time.Second*60, nil)
 	if err != nil {
 		t.Fatalf("error initiating Pod watcher")
 	}
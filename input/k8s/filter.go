@@ -0,0 +1,149 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// objectNamespaceField is the field holding the namespace of the namespaced objects
+	objectNamespaceField = "metadata.namespace"
+	// namespaceNameField is the field holding the name of the namespaces
+	namespaceNameField = "metadata.name"
+)
+
+// watchSelectors tells which of the configured selectors filter the objects of a watcher
+type watchSelectors int
+
+const (
+	// withoutSelectors does not filter the objects by the selectors
+	withoutSelectors watchSelectors = iota
+	// withLabelSelector filters the objects by the label selector only, as the fields which can be selected
+	// depend on the type of the objects
+	withLabelSelector
+	// withAllSelectors filters the objects by the label and field selectors
+	withAllSelectors
+)
+
+// listFunc lists the objects of a namespace, or of all the namespaces if namespace is empty
+type listFunc func(namespace string, opts metav1.ListOptions) (runtime.Object, error)
+
+// watchFunc watches the objects of a namespace, or of all the namespaces if namespace is empty
+type watchFunc func(namespace string, opts metav1.ListOptions) (watch.Interface, error)
+
+// watchFilter restricts the objects listed and watched by the watchers to the configured namespaces
+// and selectors, so that the other objects are neither cached nor published
+type watchFilter struct {
+	namespaces        []string
+	excludeNamespaces []string
+	labelSelector     string
+	fieldSelector     string
+}
+
+// newWatchFilter returns the filter of the watchers, or nil if none is configured
+func newWatchFilter(cfg config) *watchFilter {
+	if len(cfg.Namespaces) == 0 && len(cfg.ExcludeNamespaces) == 0 && cfg.LabelSelector == "" && cfg.FieldSelector == "" {
+		return nil
+	}
+	return &watchFilter{
+		namespaces:        cfg.Namespaces,
+		excludeNamespaces: cfg.ExcludeNamespaces,
+		labelSelector:     cfg.LabelSelector,
+		fieldSelector:     cfg.FieldSelector,
+	}
+}
+
+// validateWatchFilter checks that the filter of the watchers is valid
+func validateWatchFilter(cfg config) error {
+	if len(cfg.Namespaces) > 0 && len(cfg.ExcludeNamespaces) > 0 {
+		return fmt.Errorf("namespaces and exclude_namespaces cannot be both set")
+	}
+	if _, err := labels.Parse(cfg.LabelSelector); err != nil {
+		return fmt.Errorf("invalid label_selector: %w", err)
+	}
+	if _, err := fields.ParseSelector(cfg.FieldSelector); err != nil {
+		return fmt.Errorf("invalid field_selector: %w", err)
+	}
+	return nil
+}
+
+// listWatches returns the ListWatches of the objects of the filtered namespaces, also filtered by the given
+// selectors. namespaceField is the field holding the namespace of the objects,
+// i.e. objectNamespaceField or namespaceNameField, or empty for the cluster-scoped objects, which are not
+// filtered by namespace.
+// The excluded namespaces are filtered out by the API server. As field selectors cannot match a set of values,
+// each of the configured namespaces is listed and watched on its own, by its own ListWatch.
+func (f *watchFilter) listWatches(namespaceField string, selectors watchSelectors, list listFunc, watchObjects watchFunc) []*cache.ListWatch {
+	var labelSelector string
+	var fieldSelectors []string
+	var namespaces []string
+	if f != nil {
+		if selectors >= withLabelSelector {
+			labelSelector = f.labelSelector
+		}
+		if selectors == withAllSelectors && f.fieldSelector != "" {
+			fieldSelectors = append(fieldSelectors, f.fieldSelector)
+		}
+		if namespaceField != "" {
+			for _, ns := range f.excludeNamespaces {
+				fieldSelectors = append(fieldSelectors, namespaceField+"!="+ns)
+			}
+			namespaces = f.namespaces
+		}
+	}
+	if len(namespaces) == 0 {
+		return []*cache.ListWatch{newListWatch(metav1.NamespaceAll, labelSelector, strings.Join(fieldSelectors, ","), list, watchObjects)}
+	}
+
+	lws := make([]*cache.ListWatch, 0, len(namespaces))
+	for _, ns := range namespaces {
+		if namespaceField == objectNamespaceField {
+			lws = append(lws, newListWatch(ns, labelSelector, strings.Join(fieldSelectors, ","), list, watchObjects))
+			continue
+		}
+		nsFieldSelectors := append(append([]string(nil), fieldSelectors...), namespaceField+"="+ns)
+		lws = append(lws, newListWatch(metav1.NamespaceAll, labelSelector, strings.Join(nsFieldSelectors, ","), list, watchObjects))
+	}
+	return lws
+}
+
+// newListWatch returns the ListWatch of the objects of a namespace, or of all the namespaces if namespace is empty,
+// matching the given selectors
+func newListWatch(namespace, labelSelector, fieldSelector string, list listFunc, watchObjects watchFunc) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			opts.LabelSelector = labelSelector
+			opts.FieldSelector = fieldSelector
+			return list(namespace, opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.LabelSelector = labelSelector
+			opts.FieldSelector = fieldSelector
+			return watchObjects(namespace, opts)
+		},
+	}
+}
@@ -0,0 +1,311 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	kube "github.com/elastic/elastic-agent-autodiscover/kubernetes"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+func TestWatchFilter_ListOptions(t *testing.T) {
+	type listOptions struct {
+		namespace     string
+		labelSelector string
+		fieldSelector string
+	}
+	for _, tt := range []struct {
+		name           string
+		cfg            config
+		namespaceField string
+		selectors      watchSelectors
+		expected       []listOptions
+	}{
+		{
+			name:           "without filter",
+			namespaceField: objectNamespaceField,
+			selectors:      withAllSelectors,
+			expected:       []listOptions{{}},
+		},
+		{
+			name:           "with selectors",
+			cfg:            config{LabelSelector: "app=shop", FieldSelector: "status.phase=Running"},
+			namespaceField: objectNamespaceField,
+			selectors:      withAllSelectors,
+			expected:       []listOptions{{labelSelector: "app=shop", fieldSelector: "status.phase=Running"}},
+		},
+		{
+			name:           "with the label selector only",
+			cfg:            config{LabelSelector: "app=shop", FieldSelector: "status.phase=Running"},
+			namespaceField: objectNamespaceField,
+			selectors:      withLabelSelector,
+			expected:       []listOptions{{labelSelector: "app=shop"}},
+		},
+		{
+			name:           "with selectors not applied",
+			cfg:            config{LabelSelector: "app=shop", FieldSelector: "status.phase=Running"},
+			namespaceField: objectNamespaceField,
+			expected:       []listOptions{{}},
+		},
+		{
+			name:           "with a single namespace",
+			cfg:            config{Namespaces: []string{"shop"}},
+			namespaceField: objectNamespaceField,
+			selectors:      withAllSelectors,
+			expected:       []listOptions{{namespace: "shop"}},
+		},
+		{
+			name:           "with a single namespace of namespaces",
+			cfg:            config{Namespaces: []string{"shop"}},
+			namespaceField: namespaceNameField,
+			selectors:      withAllSelectors,
+			expected:       []listOptions{{fieldSelector: "metadata.name=shop"}},
+		},
+		{
+			name:           "with several namespaces and selectors",
+			cfg:            config{Namespaces: []string{"shop", "payment"}, LabelSelector: "app=shop", FieldSelector: "status.phase=Running"},
+			namespaceField: objectNamespaceField,
+			selectors:      withAllSelectors,
+			expected: []listOptions{
+				{namespace: "shop", labelSelector: "app=shop", fieldSelector: "status.phase=Running"},
+				{namespace: "payment", labelSelector: "app=shop", fieldSelector: "status.phase=Running"},
+			},
+		},
+		{
+			name:           "with several namespaces of namespaces",
+			cfg:            config{Namespaces: []string{"shop", "payment"}, LabelSelector: "app=shop"},
+			namespaceField: namespaceNameField,
+			expected: []listOptions{
+				{fieldSelector: "metadata.name=shop"},
+				{fieldSelector: "metadata.name=payment"},
+			},
+		},
+		{
+			name:           "with excluded namespaces and a field selector",
+			cfg:            config{ExcludeNamespaces: []string{"kube-system", "tenant-a"}, FieldSelector: "status.phase=Running"},
+			namespaceField: objectNamespaceField,
+			selectors:      withAllSelectors,
+			expected:       []listOptions{{fieldSelector: "status.phase=Running,metadata.namespace!=kube-system,metadata.namespace!=tenant-a"}},
+		},
+		{
+			name:           "with excluded namespaces of namespaces",
+			cfg:            config{ExcludeNamespaces: []string{"kube-system"}},
+			namespaceField: namespaceNameField,
+			selectors:      withAllSelectors,
+			expected:       []listOptions{{fieldSelector: "metadata.name!=kube-system"}},
+		},
+		{
+			name:     "with namespaces and selectors of cluster-scoped objects",
+			cfg:      config{Namespaces: []string{"shop", "payment"}, LabelSelector: "app=shop"},
+			expected: []listOptions{{}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var lists, watches []listOptions
+			lws := newWatchFilter(tt.cfg).listWatches(tt.namespaceField, tt.selectors,
+				func(namespace string, opts metav1.ListOptions) (runtime.Object, error) {
+					assert.Equal(t, "42", opts.ResourceVersion)
+					lists = append(lists, listOptions{namespace, opts.LabelSelector, opts.FieldSelector})
+					return &v1.PodList{}, nil
+				},
+				func(namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+					assert.Equal(t, "42", opts.ResourceVersion)
+					watches = append(watches, listOptions{namespace, opts.LabelSelector, opts.FieldSelector})
+					return watch.NewFake(), nil
+				})
+
+			for _, lw := range lws {
+				_, err := lw.List(metav1.ListOptions{ResourceVersion: "42"})
+				require.NoError(t, err)
+				_, err = lw.Watch(metav1.ListOptions{ResourceVersion: "42"})
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.expected, lists)
+			assert.Equal(t, tt.expected, watches)
+		})
+	}
+}
+
+func TestWatchFilter_SeveralNamespaces(t *testing.T) {
+	client := k8sfake.NewSimpleClientset(
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "cart", Namespace: "shop"}},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "checkout", Namespace: "payment"}},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "billing", Namespace: "tenant-a"}},
+	)
+	// the pods are neither listed nor watched across all the namespaces
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() == metav1.NamespaceAll {
+			return true, nil, errors.New("forbidden")
+		}
+		return false, nil, nil
+	})
+	client.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		if action.GetNamespace() == metav1.NamespaceAll {
+			return true, nil, errors.New("forbidden")
+		}
+		return false, nil, nil
+	})
+	filter := newWatchFilter(config{Namespaces: []string{"shop", "payment"}})
+	podWatcher, err := getPodWatcher(context.Background(), logp.NewLogger("test"), client, filter, time.Minute, nil)
+	require.NoError(t, err)
+	var mu sync.Mutex
+	var added []string
+	podWatcher.AddEventHandler(kube.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			mu.Lock()
+			defer mu.Unlock()
+			added = append(added, obj.(*kube.Pod).Name)
+		},
+	})
+	require.NoError(t, podWatcher.Start())
+	defer podWatcher.Stop()
+
+	assert.ElementsMatch(t, []string{"shop/cart", "payment/checkout"}, podWatcher.Store().ListKeys())
+	_, exists, err := podWatcher.Store().GetByKey("payment/checkout")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	for _, pod := range []*v1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "billing-worker", Namespace: "tenant-a"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "cart-worker", Namespace: "shop"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "checkout-worker", Namespace: "payment"}},
+	} {
+		_, err := client.CoreV1().Pods(pod.Namespace).Create(context.Background(), pod, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(added) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.ElementsMatch(t, []string{"cart-worker", "checkout-worker"}, added)
+	assert.ElementsMatch(t, []string{"shop/cart", "shop/cart-worker", "payment/checkout", "payment/checkout-worker"}, podWatcher.Store().ListKeys())
+}
+
+func TestWatchFilter_ClusterScopedObjects(t *testing.T) {
+	client := k8sfake.NewSimpleClientset(
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}},
+		&v1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-1"}},
+	)
+	// the API server rejects the field selectors of pods on other objects
+	client.PrependReactor("list", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if fieldSelector := action.(k8stesting.ListAction).GetListRestrictions().Fields; !fieldSelector.Empty() && fieldSelector.String() != "metadata.name=shop" {
+			return true, nil, fmt.Errorf("field label not supported: %s", fieldSelector)
+		}
+		return false, nil, nil
+	})
+	filter := newWatchFilter(config{Namespaces: []string{"shop"}, LabelSelector: "app=shop", FieldSelector: "status.phase=Running"})
+	log := logp.NewLogger("test")
+
+	for _, tt := range []struct {
+		name       string
+		getWatcher func() (kube.Watcher, error)
+		key        string
+	}{
+		{
+			name: "nodes",
+			getWatcher: func() (kube.Watcher, error) {
+				return getNodeWatcher(context.Background(), log, client, filter, time.Second, nil)
+			},
+			key: "node-1",
+		},
+		{
+			name: "namespaces",
+			getWatcher: func() (kube.Watcher, error) {
+				return getNamespaceWatcher(context.Background(), log, client, filter, time.Second, nil)
+			},
+			key: "shop",
+		},
+		{
+			name: "persistent volumes",
+			getWatcher: func() (kube.Watcher, error) {
+				return getPersistentVolumeWatcher(context.Background(), log, client, filter, time.Second, nil)
+			},
+			key: "pv-1",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			watcher, err := tt.getWatcher()
+			require.NoError(t, err)
+			require.NoError(t, watcher.Start())
+			defer watcher.Stop()
+
+			_, exists, err := watcher.Store().GetByKey(tt.key)
+			require.NoError(t, err)
+			assert.True(t, exists)
+		})
+	}
+}
+
+func TestValidateWatchFilter(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		cfg           config
+		expectedError string
+	}{
+		{
+			name: "without filter",
+		},
+		{
+			name: "with namespaces and selectors",
+			cfg:  config{Namespaces: []string{"shop"}, LabelSelector: "app in (cart, checkout)", FieldSelector: "status.phase=Running"},
+		},
+		{
+			name:          "with namespaces and excluded namespaces",
+			cfg:           config{Namespaces: []string{"shop"}, ExcludeNamespaces: []string{"kube-system"}},
+			expectedError: "namespaces and exclude_namespaces cannot be both set",
+		},
+		{
+			name:          "with an invalid label selector",
+			cfg:           config{LabelSelector: "app in shop"},
+			expectedError: "invalid label_selector",
+		},
+		{
+			name:          "with an invalid field selector",
+			cfg:           config{FieldSelector: "status.phase"},
+			expectedError: "invalid field_selector",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateWatchFilter(tt.cfg)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,298 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+
+	kube "github.com/elastic/elastic-agent-autodiscover/kubernetes"
+
+	kuberntescli "k8s.io/client-go/kubernetes"
+)
+
+// informerWatcher is a kube.Watcher built from ListWatches, so that the listed and watched
+// objects can be filtered, and that the resources not supported by the autodiscover library,
+// e.g. ingresses and endpoint slices, can be watched. Each ListWatch, e.g. of each of the configured
+// namespaces, is run by its own informer, and the store of the watcher is the union of their caches.
+// Events are only forwarded to the handler once Start has synced the caches of the informers: the
+// adds of the initial lists are dropped, the listed objects being available from the store, so that
+// they are not published twice at startup in watch mode. Resyncs are not forwarded either.
+type informerWatcher struct {
+	client    kuberntescli.Interface
+	informers []cache.SharedIndexInformer
+	store     cache.Indexer
+	timeout   time.Duration
+	ctx       context.Context
+	stop      context.CancelFunc
+	synced    atomic.Bool
+
+	mu      sync.RWMutex
+	handler kube.ResourceEventHandler
+}
+
+func newInformerWatcher(client kuberntescli.Interface, lws []*cache.ListWatch, objType runtime.Object, timeout time.Duration, indexers cache.Indexers) *informerWatcher {
+	if indexers == nil {
+		indexers = cache.Indexers{}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &informerWatcher{
+		client:  client,
+		timeout: timeout,
+		ctx:     ctx,
+		stop:    cancel,
+		handler: kube.NoOpEventHandlerFuncs{},
+	}
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			w.getHandler().OnAdd(obj)
+		},
+		UpdateFunc: func(o, n interface{}) {
+			// resyncs are not forwarded, only changes of the resource version
+			oldObj, err1 := meta.Accessor(o)
+			newObj, err2 := meta.Accessor(n)
+			if err1 == nil && err2 == nil && oldObj.GetResourceVersion() == newObj.GetResourceVersion() {
+				return
+			}
+			w.getHandler().OnUpdate(n)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if deleted, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = deleted.Obj
+			}
+			w.getHandler().OnDelete(obj)
+		},
+	}
+	stores := make(multiIndexer, 0, len(lws))
+	for _, lw := range lws {
+		informer := cache.NewSharedIndexInformer(lw, objType, 0, indexers)
+		informer.AddEventHandler(handler)
+		w.informers = append(w.informers, informer)
+		stores = append(stores, informer.GetIndexer())
+	}
+	w.store = stores
+	if len(stores) == 1 {
+		w.store = stores[0]
+	}
+	return w
+}
+
+// Start runs the informers and waits for their caches to be synced, before forwarding events
+func (w *informerWatcher) Start() error {
+	hasSynced := make([]cache.InformerSynced, 0, len(w.informers))
+	for _, informer := range w.informers {
+		go informer.Run(w.ctx.Done())
+		hasSynced = append(hasSynced, informer.HasSynced)
+	}
+
+	ctx := w.ctx
+	if w.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(w.ctx, w.timeout)
+		defer cancel()
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), hasSynced...) {
+		return fmt.Errorf("kubernetes informer unable to sync cache")
+	}
+	w.synced.Store(true)
+	return nil
+}
+
+// Stop stops the informers
+func (w *informerWatcher) Stop() {
+	w.stop()
+}
+
+// AddEventHandler sets the handler of the events of the watcher, replacing the previous one
+func (w *informerWatcher) AddEventHandler(h kube.ResourceEventHandler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handler = h
+}
+
+// getHandler returns the handler of the events, or a no-op one until the cache has been synced.
+func (w *informerWatcher) getHandler() kube.ResourceEventHandler {
+	if !w.synced.Load() {
+		return kube.NoOpEventHandlerFuncs{}
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.handler
+}
+
+// Store returns the cache of the informers, which is also a cache.Indexer
+func (w *informerWatcher) Store() cache.Store {
+	return w.store
+}
+
+// Client returns the kubernetes client used by the watcher
+func (w *informerWatcher) Client() kuberntescli.Interface {
+	return w.client
+}
+
+// multiIndexer is the union of the caches of several informers, whose objects do not overlap.
+// The informers write to their own cache, so an object written to the union, e.g. by tests,
+// goes to the cache already holding it, or else to the first one.
+type multiIndexer []cache.Indexer
+
+func (m multiIndexer) indexerOf(obj interface{}) cache.Indexer {
+	for _, indexer := range m {
+		if _, exists, err := indexer.Get(obj); err == nil && exists {
+			return indexer
+		}
+	}
+	return m[0]
+}
+
+func (m multiIndexer) Add(obj interface{}) error {
+	return m.indexerOf(obj).Add(obj)
+}
+
+func (m multiIndexer) Update(obj interface{}) error {
+	return m.indexerOf(obj).Update(obj)
+}
+
+func (m multiIndexer) Delete(obj interface{}) error {
+	return m.indexerOf(obj).Delete(obj)
+}
+
+func (m multiIndexer) List() []interface{} {
+	var objs []interface{}
+	for _, indexer := range m {
+		objs = append(objs, indexer.List()...)
+	}
+	return objs
+}
+
+func (m multiIndexer) ListKeys() []string {
+	var keys []string
+	for _, indexer := range m {
+		keys = append(keys, indexer.ListKeys()...)
+	}
+	return keys
+}
+
+func (m multiIndexer) Get(obj interface{}) (interface{}, bool, error) {
+	for _, indexer := range m {
+		if item, exists, err := indexer.Get(obj); err != nil || exists {
+			return item, exists, err
+		}
+	}
+	return nil, false, nil
+}
+
+func (m multiIndexer) GetByKey(key string) (interface{}, bool, error) {
+	for _, indexer := range m {
+		if item, exists, err := indexer.GetByKey(key); err != nil || exists {
+			return item, exists, err
+		}
+	}
+	return nil, false, nil
+}
+
+// Replace replaces the content of the first cache, and empties the others
+func (m multiIndexer) Replace(objs []interface{}, resourceVersion string) error {
+	for i, indexer := range m {
+		var replaced []interface{}
+		if i == 0 {
+			replaced = objs
+		}
+		if err := indexer.Replace(replaced, resourceVersion); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multiIndexer) Resync() error {
+	for _, indexer := range m {
+		if err := indexer.Resync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multiIndexer) Index(indexName string, obj interface{}) ([]interface{}, error) {
+	var objs []interface{}
+	for _, indexer := range m {
+		indexed, err := indexer.Index(indexName, obj)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, indexed...)
+	}
+	return objs, nil
+}
+
+func (m multiIndexer) IndexKeys(indexName, indexedValue string) ([]string, error) {
+	var keys []string
+	for _, indexer := range m {
+		indexed, err := indexer.IndexKeys(indexName, indexedValue)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, indexed...)
+	}
+	return keys, nil
+}
+
+func (m multiIndexer) ListIndexFuncValues(indexName string) []string {
+	var values []string
+	for _, indexer := range m {
+		for _, value := range indexer.ListIndexFuncValues(indexName) {
+			if !slices.Contains(values, value) {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+func (m multiIndexer) ByIndex(indexName, indexedValue string) ([]interface{}, error) {
+	var objs []interface{}
+	for _, indexer := range m {
+		indexed, err := indexer.ByIndex(indexName, indexedValue)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, indexed...)
+	}
+	return objs, nil
+}
+
+func (m multiIndexer) GetIndexers() cache.Indexers {
+	return m[0].GetIndexers()
+}
+
+func (m multiIndexer) AddIndexers(indexers cache.Indexers) error {
+	for _, indexer := range m {
+		if err := indexer.AddIndexers(indexers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	kube "github.com/elastic/elastic-agent-autodiscover/kubernetes"
+)
+
+func TestInformerWatcher_Events(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := k8sfake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: "default"},
+	})
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().ConfigMaps("").List(ctx, opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().ConfigMaps("").Watch(ctx, opts)
+		},
+	}
+
+	var mu sync.Mutex
+	var events []string
+	record := func(action string) func(obj interface{}) {
+		return func(obj interface{}) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, action+" "+obj.(*v1.ConfigMap).Name)
+		}
+	}
+	watcher := newInformerWatcher(client, []*cache.ListWatch{lw}, &v1.ConfigMap{}, time.Minute, nil)
+	watcher.AddEventHandler(kube.ResourceEventHandlerFuncs{
+		AddFunc:    record("add"),
+		UpdateFunc: record("update"),
+		DeleteFunc: record("delete"),
+	})
+	require.NoError(t, watcher.Start())
+	defer watcher.Stop()
+
+	// the objects of the initial list are in the store, but not forwarded
+	_, exists, err := watcher.Store().GetByKey("default/existing")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "mycm", Namespace: "default", ResourceVersion: "1"}}
+	_, err = client.CoreV1().ConfigMaps("default").Create(ctx, cm, metav1.CreateOptions{})
+	require.NoError(t, err)
+	cm.ResourceVersion = "2"
+	cm.Data = map[string]string{"key": "value"}
+	_, err = client.CoreV1().ConfigMaps("default").Update(ctx, cm, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, client.CoreV1().ConfigMaps("default").Delete(ctx, "mycm", metav1.DeleteOptions{}))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"add mycm", "update mycm", "delete mycm"}, events)
+}
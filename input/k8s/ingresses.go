@@ -0,0 +1,160 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/elastic/assetbeat/input/internal"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	kube "github.com/elastic/elastic-agent-autodiscover/kubernetes"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+
+	kuberntescli "k8s.io/client-go/kubernetes"
+)
+
+// ingressClassAnnotation is the annotation setting the class of the ingresses created before IngressClassName
+const ingressClassAnnotation = "kubernetes.io/ingress.class"
+
+// getIngressWatcher initiates and returns a watcher of kubernetes ingresses.
+// The events of the watcher are forwarded to handler, if not nil.
+func getIngressWatcher(log *logp.Logger, client kuberntescli.Interface, filter *watchFilter, timeout time.Duration, handler kube.ResourceEventHandler) kube.Watcher {
+	lws := filter.listWatches(objectNamespaceField, withLabelSelector,
+		func(namespace string, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.NetworkingV1().Ingresses(namespace).List(context.Background(), opts)
+		},
+		func(namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+			return client.NetworkingV1().Ingresses(namespace).Watch(context.Background(), opts)
+		})
+	watcher := newInformerWatcher(client, lws, &networkingv1.Ingress{}, timeout, nil)
+	if handler != nil {
+		watcher.AddEventHandler(handler)
+	}
+	log.Debug("Ingress watcher created")
+	return watcher
+}
+
+// publishK8sIngresses publishes the ingress assets stored in ingress watcher cache
+func publishK8sIngresses(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, ingressWatcher, serviceWatcher, namespaceWatcher kube.Watcher) {
+	log.Info("Publishing ingress assets\n")
+	for _, obj := range ingressWatcher.Store().List() {
+		o, ok := obj.(*networkingv1.Ingress)
+		if ok {
+			publishK8sIngress(log, publisher, o, serviceWatcher, namespaceWatcher)
+		} else {
+			log.Error("Publishing ingress assets failed. Type assertion of ingress object failed")
+		}
+	}
+}
+
+// publishK8sIngress publishes the asset of an ingress, with the given additional options.
+// The children of the ingress are the services its backends point to.
+func publishK8sIngress(log *logp.Logger, publisher stateless.Publisher, o *networkingv1.Ingress, serviceWatcher, namespaceWatcher kube.Watcher, opts ...internal.AssetOption) {
+	log.Debugf("Publish Ingress: %+v", o.Name)
+	assetType := "k8s.ingress"
+	assetKind := "ingress"
+	assetId := string(o.UID)
+	assetStartTime := o.CreationTimestamp
+
+	assetChildren := []string{}
+	if serviceWatcher != nil {
+		assetChildren = getIngressServices(log, o, serviceWatcher)
+	}
+
+	options := []internal.AssetOption{
+		internal.WithAssetKindAndID(assetKind, assetId),
+		internal.WithAssetType(assetType),
+		internal.WithAssetName(o.Name),
+		internal.WithAssetParents(getNamespaceParents(o.Namespace, namespaceWatcher)),
+		internal.WithAssetChildren(assetChildren),
+		internal.WithAssetMetadata(mapstr.M{
+			"ingress_class": getIngressClass(o),
+			"hosts":         getIngressHosts(o),
+		}),
+		internal.WithResourceData("ingress", o.Name, assetId, o.Namespace, &assetStartTime),
+	}
+	internal.Publish(publisher, nil, append(options, opts...)...)
+}
+
+// getIngressServices returns the sorted EANs of the services the backends of an ingress point to.
+// Backends pointing to services missing from the service watcher cache are skipped.
+func getIngressServices(log *logp.Logger, o *networkingv1.Ingress, serviceWatcher kube.Watcher) []string {
+	backends := []*networkingv1.IngressBackend{o.Spec.DefaultBackend}
+	for _, rule := range o.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for i := range rule.HTTP.Paths {
+			backends = append(backends, &rule.HTTP.Paths[i].Backend)
+		}
+	}
+
+	seen := make(map[string]bool)
+	services := []string{}
+	for _, backend := range backends {
+		if backend == nil || backend.Service == nil {
+			continue
+		}
+		key := o.Namespace + "/" + backend.Service.Name
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		obj, exists, err := serviceWatcher.Store().GetByKey(key)
+		if err != nil || !exists {
+			log.Debugf("ingress %s backend service %s not found", o.Name, key)
+			continue
+		}
+		svc, ok := obj.(*kube.Service)
+		if !ok {
+			continue
+		}
+		services = append(services, fmt.Sprintf("%s:%s", "service", svc.UID))
+	}
+	sort.Strings(services)
+	return services
+}
+
+// getIngressClass returns the class of an ingress, from its spec or its legacy annotation
+func getIngressClass(o *networkingv1.Ingress) string {
+	if o.Spec.IngressClassName != nil {
+		return *o.Spec.IngressClassName
+	}
+	return o.Annotations[ingressClassAnnotation]
+}
+
+// getIngressHosts returns the hosts of the rules of an ingress
+func getIngressHosts(o *networkingv1.Ingress) []string {
+	hosts := []string{}
+	for _, rule := range o.Spec.Rules {
+		if rule.Host != "" {
+			hosts = append(hosts, rule.Host)
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,144 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/assetbeat/input/testutil"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestPublishK8sIngresses(t *testing.T) {
+	ingressClass := "nginx"
+	services := []*v1.Service{
+		{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "3c2b1a09-8e4c-4b3a-9b6e-2ab6d4a8b8a1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default", UID: "1a2b3c4d-8e4c-4b3a-9b6e-2ab6d4a8b8a1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "other", UID: "9f8e7d6c-8e4c-4b3a-9b6e-2ab6d4a8b8a1"}},
+	}
+	serviceBackend := func(name string) networkingv1.IngressBackend {
+		return networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: name}}
+	}
+
+	for _, tt := range []struct {
+		name             string
+		ingress          *networkingv1.Ingress
+		expectedClass    string
+		expectedHosts    []string
+		expectedChildren []string
+	}{
+		{
+			name: "with a default backend and the legacy class annotation",
+			ingress: &networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{"kubernetes.io/ingress.class": "traefik"},
+				},
+				Spec: networkingv1.IngressSpec{DefaultBackend: &networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "web"}}},
+			},
+			expectedClass:    "traefik",
+			expectedHosts:    []string{},
+			expectedChildren: []string{"service:3c2b1a09-8e4c-4b3a-9b6e-2ab6d4a8b8a1"},
+		},
+		{
+			name: "with the backends of several rules",
+			ingress: &networkingv1.Ingress{
+				Spec: networkingv1.IngressSpec{
+					IngressClassName: &ingressClass,
+					Rules: []networkingv1.IngressRule{
+						{
+							Host: "www.example.com",
+							IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+								Paths: []networkingv1.HTTPIngressPath{
+									{Path: "/", Backend: serviceBackend("web")},
+									{Path: "/api", Backend: serviceBackend("api")},
+								},
+							}},
+						},
+						{
+							Host: "api.example.com",
+							IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+								Paths: []networkingv1.HTTPIngressPath{
+									{Path: "/", Backend: serviceBackend("api")},
+									{Path: "/missing", Backend: serviceBackend("missing")},
+								},
+							}},
+						},
+					},
+				},
+			},
+			expectedClass: "nginx",
+			expectedHosts: []string{"www.example.com", "api.example.com"},
+			expectedChildren: []string{
+				"service:1a2b3c4d-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+				"service:3c2b1a09-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.ingress.ObjectMeta.Name = "frontend"
+			tt.ingress.ObjectMeta.Namespace = "default"
+			tt.ingress.ObjectMeta.UID = "7e6d5c4b-8e4c-4b3a-9b6e-2ab6d4a8b8a1"
+			tt.ingress.ObjectMeta.CreationTimestamp = startTime
+
+			client := k8sfake.NewSimpleClientset()
+			log := logp.NewLogger("mylogger")
+			serviceWatcher, err := getServiceWatcher(context.Background(), log, client, nil, time.Second*60, nil)
+			require.NoError(t, err)
+			for _, svc := range services {
+				require.NoError(t, serviceWatcher.Store().Add(svc))
+			}
+			ingressWatcher := getIngressWatcher(log, client, nil, time.Second*60, nil)
+			require.NoError(t, ingressWatcher.Store().Add(tt.ingress))
+
+			publisher := testutil.NewInMemoryPublisher()
+			publishK8sIngresses(context.Background(), log, publisher, ingressWatcher, serviceWatcher, nil)
+
+			expectedEvent := beat.Event{
+				Fields: mapstr.M{
+					"asset.type":                    "k8s.ingress",
+					"asset.kind":                    "ingress",
+					"asset.id":                      "7e6d5c4b-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+					"asset.ean":                     "ingress:7e6d5c4b-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+					"asset.name":                    "frontend",
+					"asset.parents":                 []string{},
+					"asset.children":                tt.expectedChildren,
+					"asset.metadata.ingress_class":  tt.expectedClass,
+					"asset.metadata.hosts":          tt.expectedHosts,
+					"kubernetes.ingress.name":       "frontend",
+					"kubernetes.ingress.uid":        "7e6d5c4b-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+					"kubernetes.ingress.start_time": &startTime,
+					"kubernetes.namespace":          "default",
+				},
+				Meta: mapstr.M{"index": internal.GetDefaultIndexName()},
+			}
+			assert.Equal(t, []beat.Event{expectedEvent}, publisher.Events)
+		})
+	}
+}
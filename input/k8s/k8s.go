@@ -19,7 +19,11 @@ package k8s
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -31,18 +35,43 @@ import (
 	conf "github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp"
 
+	networkingv1 "k8s.io/api/networking/v1"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 
 	"github.com/elastic/beats/v7/libbeat/feature"
 	"github.com/elastic/go-concert/ctxtool"
 
 	kuberntescli "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// clusterStartMinBackoff and clusterStartMaxBackoff bound the delay before retrying to start the watchers of a cluster
+	clusterStartMinBackoff = 10 * time.Second
+	clusterStartMaxBackoff = 5 * time.Minute
 )
 
 type config struct {
 	internal.BaseConfig `config:",inline"`
 	KubeConfig          string        `config:"kube_config"`
 	Period              time.Duration `config:"period"`
+	Mode                string        `config:"mode"`
+	Debounce            time.Duration `config:"debounce"`
+	ClusterName         string        `config:"cluster_name"`
+	KubeConfigContexts  []string      `config:"kube_config_contexts"`
+	KubeConfigDir       string        `config:"kube_config_dir"`
+	Namespaces          []string      `config:"namespaces"`
+	ExcludeNamespaces   []string      `config:"exclude_namespaces"`
+	LabelSelector       string        `config:"label_selector"`
+	FieldSelector       string        `config:"field_selector"`
+}
+
+// kubeCluster is a cluster the assets are collected from
+type kubeCluster struct {
+	// name is the kubeconfig context or file of the cluster, empty when a single cluster is configured
+	name        string
+	client      kuberntescli.Interface
+	isInCluster bool
 }
 
 // watchersMap struct containt a sync.Map object to effectively handle
@@ -67,16 +96,39 @@ func configure(inputCfg *conf.C) (stateless.Input, error) {
 		return nil, err
 	}
 	log := logp.NewLogger("assets_k8s")
-	client, err := getKubernetesClient(cfg.KubeConfig, log)
-	if err != nil {
-		log.Errorf("unable to build kubernetes clientset: %w", err)
+	if len(cfg.KubeConfigContexts) == 0 && cfg.KubeConfigDir == "" {
+		client, err := getKubernetesClient(cfg.KubeConfig, log)
+		if err != nil {
+			log.Errorf("unable to build kubernetes clientset: %w", err)
+		}
+		return newAssetsK8s(cfg, client)
 	}
 
-	return newAssetsK8s(cfg, client)
+	s, err := newAssetsK8s(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.Clusters, err = getKubernetesClusters(cfg, log); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
 func newAssetsK8s(cfg config, client kuberntescli.Interface) (*assetsK8s, error) {
-	return &assetsK8s{cfg, client}, nil
+	if cfg.Mode != modePoll && cfg.Mode != modeWatch {
+		return nil, fmt.Errorf("invalid mode %q, must be either %q or %q", cfg.Mode, modePoll, modeWatch)
+	}
+	if len(cfg.KubeConfigContexts) > 0 && cfg.KubeConfigDir != "" {
+		return nil, fmt.Errorf("kube_config_contexts and kube_config_dir cannot be both set")
+	}
+	// the cluster name is used as the ID of the cluster, so that it cannot be shared by several clusters
+	if cfg.ClusterName != "" && (len(cfg.KubeConfigContexts) > 1 || cfg.KubeConfigDir != "") {
+		return nil, fmt.Errorf("cluster_name cannot be set when collecting from several clusters")
+	}
+	if err := validateWatchFilter(cfg); err != nil {
+		return nil, err
+	}
+	return &assetsK8s{Config: cfg, Client: client}, nil
 }
 
 func defaultConfig() config {
@@ -87,12 +139,17 @@ func defaultConfig() config {
 		},
 		KubeConfig: "",
 		Period:     time.Second * 600,
+		Mode:       modePoll,
+		Debounce:   time.Second * 5,
 	}
 }
 
 type assetsK8s struct {
 	Config config
 	Client kuberntescli.Interface
+	// Clusters are the clusters of the configured kubeconfig contexts or files. When empty, the assets are
+	// collected from the single cluster of Client.
+	Clusters []kubeCluster
 }
 
 func (s *assetsK8s) Name() string { return "assets_k8s" }
@@ -109,41 +166,104 @@ func (s *assetsK8s) Run(inputCtx input.Context, publisher stateless.Publisher) e
 	defer log.Info("k8s asset collector run stopped")
 
 	cfg := s.Config
-	ticker := time.NewTicker(cfg.Period)
+	clusters := s.Clusters
+	if len(clusters) == 0 {
+		if s.Client == nil {
+			return fmt.Errorf("Kubernetes client is nil")
+		}
+		clusters = []kubeCluster{{client: s.Client, isInCluster: kube.IsInCluster(cfg.KubeConfig)}}
+	}
+
+	// each cluster has its own watchers, so that a cluster failing does not stop the collection of the others
+	var wg sync.WaitGroup
+	errs := make([]error, len(clusters))
+	for i, cluster := range clusters {
+		wg.Add(1)
+		go func(i int, cluster kubeCluster) {
+			defer wg.Done()
+			clusterLog := log
+			if cluster.name != "" {
+				clusterLog = log.With("cluster", cluster.name)
+			}
+			if err := runK8sCluster(ctx, clusterLog, cfg, publisher, cluster); err != nil {
+				if cluster.name != "" {
+					clusterLog.Errorf("Collecting the cluster assets failed: %v", err)
+					err = fmt.Errorf("cluster %s: %w", cluster.name, err)
+				}
+				errs[i] = err
+			}
+		}(i, cluster)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
 
-	client := s.Client
-	if client == nil {
-		return fmt.Errorf("Kubernetes client is nil")
+// runK8sCluster watches the given cluster and publishes its assets, tagged with the identity of the cluster,
+// until the context is cancelled
+func runK8sCluster(ctx context.Context, log *logp.Logger, cfg config, publisher stateless.Publisher, cluster kubeCluster) error {
+	ticker := time.NewTicker(cfg.Period)
+	defer ticker.Stop()
+
+	client := cluster.client
+	clusterResolver := newClusterResolver(cfg, cluster)
+	publisher = newClusterPublisher(ctx, log, publisher, clusterResolver)
+
+	// In watch mode, assets are also published as soon as they change,
+	// the periodic collection being kept as a resync
+	var watchDebouncer *debouncer
+	if cfg.Mode == modeWatch {
+		watchDebouncer = newDebouncer(cfg.Debounce)
+		defer watchDebouncer.stop()
 	}
 
 	watchersMap := &watchersMap{}
-	select {
-	case <-ctx.Done():
-		return nil
-	default:
-		// Init the watchers
-		if err := initK8sWatchers(ctx, client, log, cfg, publisher, watchersMap); err != nil {
-			return err
+	// a cluster which cannot be reached yet is retried with a backoff, instead of never being collected
+	var backoff time.Duration
+	for {
+		err := initK8sWatchers(ctx, client, log, cfg, publisher, watchersMap, clusterResolver, watchDebouncer)
+		if err == nil {
+			err = startK8sWatchers(ctx, log, cfg, watchersMap)
 		}
-		// Start the watchers
-		if err := startK8sWatchers(ctx, log, cfg, watchersMap); err != nil {
-			// stop any running watcher
-			stopK8sWatchers(ctx, log, watchersMap)
-			return err
+		if err == nil {
+			break
+		}
+		// stop any running watcher
+		stopK8sWatchers(ctx, log, watchersMap)
+		backoff = nextClusterStartBackoff(backoff)
+		log.Warnf("Starting the watchers failed, retrying in %s: %v", backoff, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
 		}
-		// wait 10 seconds for cache to be filled. Only applicable on first run
-		time.AfterFunc(10*time.Second, func() { collectK8sAssets(ctx, log, cfg, publisher, watchersMap) })
 	}
+	// wait 10 seconds for cache to be filled. Only applicable on first run
+	time.AfterFunc(10*time.Second, func() { collectK8sAssets(ctx, log, cfg, publisher, watchersMap, clusterResolver) })
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 		case <-ticker.C:
-			collectK8sAssets(ctx, log, cfg, publisher, watchersMap)
+			collectK8sAssets(ctx, log, cfg, publisher, watchersMap, clusterResolver)
 		}
 	}
 }
 
+// nextClusterStartBackoff returns the delay before retrying to start the watchers of a cluster,
+// doubled on every failure, from clusterStartMinBackoff up to clusterStartMaxBackoff
+func nextClusterStartBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff < clusterStartMinBackoff {
+		return clusterStartMinBackoff
+	}
+	if backoff > clusterStartMaxBackoff {
+		return clusterStartMaxBackoff
+	}
+	return backoff
+}
+
 // getKubernetesClient returns a kubernetes client. If inCluster is true, it returns an
 // in cluster configuration based on the secrets mounted in the Pod. If kubeConfig is passed,
 // it parses the config file to get the config required to build a client.
@@ -162,15 +282,96 @@ func getKubernetesClient(kubeconfigPath string, log *logp.Logger) (kuberntescli.
 	return client, nil
 }
 
+// getKubernetesClusters returns the clusters of the configured kubeconfig contexts, or of the current
+// context of each kubeconfig file of the configured directory.
+func getKubernetesClusters(cfg config, log *logp.Logger) ([]kubeCluster, error) {
+	var clusters []kubeCluster
+	if cfg.KubeConfigDir == "" {
+		// kubeconfig files are loaded the same way as kubectl, unless a kube_config is explicitly set
+		loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+		loadingRules.ExplicitPath = cfg.KubeConfig
+		for _, contextName := range cfg.KubeConfigContexts {
+			log.Infof("Provided kube config context is %s", contextName)
+			clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{CurrentContext: contextName})
+			client, err := getKubernetesClientFromConfig(clientConfig)
+			if err != nil {
+				return nil, fmt.Errorf("kube config context %s: %w", contextName, err)
+			}
+			clusters = append(clusters, kubeCluster{name: contextName, client: client})
+		}
+		return clusters, nil
+	}
+
+	entries, err := os.ReadDir(cfg.KubeConfigDir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read kube config directory: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(cfg.KubeConfigDir, entry.Name())
+		log.Infof("Provided kube config path is %s", path)
+		clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(&clientcmd.ClientConfigLoadingRules{ExplicitPath: path}, &clientcmd.ConfigOverrides{})
+		client, err := getKubernetesClientFromConfig(clientConfig)
+		if err != nil {
+			return nil, fmt.Errorf("kube config %s: %w", path, err)
+		}
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		clusters = append(clusters, kubeCluster{name: name, client: client})
+	}
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("no kube config found in %s", cfg.KubeConfigDir)
+	}
+	return clusters, nil
+}
+
+// getKubernetesClientFromConfig returns a kubernetes client for the current context of the given kubeconfig
+func getKubernetesClientFromConfig(clientConfig clientcmd.ClientConfig) (kuberntescli.Interface, error) {
+	cfg, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("unable to build kubernetes config: %w", err)
+	}
+
+	client, err := kuberntescli.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build kubernetes client: %w", err)
+	}
+
+	return client, nil
+}
+
 // collectK8sAssets collects kubernetes resources from watchers cache and publishes them
-func collectK8sAssets(ctx context.Context, log *logp.Logger, cfg config, publisher stateless.Publisher, watchersMap *watchersMap) {
+func collectK8sAssets(ctx context.Context, log *logp.Logger, cfg config, publisher stateless.Publisher, watchersMap *watchersMap, clusterResolver *clusterResolver) {
+	if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.cluster") {
+		log.Info("Cluster type enabled. Starting collecting")
+		go publishK8sCluster(ctx, log, publisher, clusterResolver)
+	}
+
+	// namespaced assets are parented to their namespace only when namespaces are collected
+	namespaceWatcher, _ := watchersMap.get("namespace")
+
+	if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.namespace") {
+		log.Info("Namespace type enabled. Starting collecting")
+		go func() {
+			if namespaceWatcher == nil {
+				log.Error("Namespace watcher not found")
+				return
+			}
+			rw, ok := watchersMap.get("resourcequota")
+			if !ok {
+				log.Error("Resource quota watcher not found")
+			}
+			publishK8sNamespaces(ctx, log, publisher, namespaceWatcher, rw, getClusterParents(ctx, log, clusterResolver))
+		}()
+	}
 	if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.node") {
 		log.Info("Node type enabled. Starting collecting")
 		go func() {
 			if nodeWatcher, ok := watchersMap.watchers.Load("node"); ok {
 				nw, ok := nodeWatcher.(kube.Watcher)
 				if ok {
-					publishK8sNodes(ctx, log, publisher, nw, kube.IsInCluster(cfg.KubeConfig))
+					publishK8sNodes(ctx, log, publisher, nw, getClusterParents(ctx, log, clusterResolver))
 				} else {
 					log.Error("Node watcher type assertion failed")
 				}
@@ -195,7 +396,7 @@ func collectK8sAssets(ctx context.Context, log *logp.Logger, cfg config, publish
 				}
 				pw, ok := podWatcher.(kube.Watcher)
 				if ok {
-					publishK8sPods(ctx, log, publisher, pw, nw)
+					publishK8sPods(ctx, log, publisher, pw, nw, namespaceWatcher)
 				} else {
 					log.Error("Pod watcher type assertion failed")
 				}
@@ -207,6 +408,76 @@ func collectK8sAssets(ctx context.Context, log *logp.Logger, cfg config, publish
 		}()
 	}
 
+	for _, wt := range workloadTypes {
+		if !internal.IsTypeEnabled(cfg.AssetTypes, wt.assetType) {
+			continue
+		}
+		log.Infof("%s type enabled. Starting collecting", wt.assetType)
+		go func(wt workloadType) {
+			if watcher, ok := watchersMap.get(wt.name); ok {
+				publishK8sWorkloads(ctx, log, publisher, wt, watcher, namespaceWatcher)
+			} else {
+				log.Errorf("%s watcher not found", wt.name)
+			}
+		}(wt)
+	}
+
+	if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.service") {
+		log.Info("Service type enabled. Starting collecting")
+		go func() {
+			sw, ok := watchersMap.get("service")
+			if !ok {
+				log.Error("Service watcher not found")
+				return
+			}
+			ew, ok := watchersMap.get("endpointslice")
+			if !ok {
+				log.Error("Endpoint slice watcher not found")
+			}
+			publishK8sServices(ctx, log, publisher, sw, ew, namespaceWatcher)
+		}()
+	}
+
+	if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.ingress") {
+		log.Info("Ingress type enabled. Starting collecting")
+		go func() {
+			iw, ok := watchersMap.get("ingress")
+			if !ok {
+				log.Error("Ingress watcher not found")
+				return
+			}
+			sw, ok := watchersMap.get("service")
+			if !ok {
+				log.Error("Service watcher not found")
+			}
+			publishK8sIngresses(ctx, log, publisher, iw, sw, namespaceWatcher)
+		}()
+	}
+
+	if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.persistentvolume") {
+		log.Info("Persistent volume type enabled. Starting collecting")
+		go func() {
+			if watcher, ok := watchersMap.get("persistentvolume"); ok {
+				publishK8sPersistentVolumes(ctx, log, publisher, watcher)
+			} else {
+				log.Error("Persistent volume watcher not found")
+			}
+		}()
+	}
+
+	if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.persistentvolumeclaim") {
+		log.Info("Persistent volume claim type enabled. Starting collecting")
+		go func() {
+			if watcher, ok := watchersMap.get("persistentvolumeclaim"); ok {
+				// the pods of the claims are resolved only when pods are collected
+				pw, _ := watchersMap.get("pod")
+				publishK8sPersistentVolumeClaims(ctx, log, publisher, watcher, pw, namespaceWatcher)
+			} else {
+				log.Error("Persistent volume claim watcher not found")
+			}
+		}()
+	}
+
 	if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.container") {
 		log.Info("Container type enabled. Starting collecting")
 		go func() {
@@ -226,12 +497,40 @@ func collectK8sAssets(ctx context.Context, log *logp.Logger, cfg config, publish
 	}
 }
 
-// initK8sWatchers initiates and stores watchers for kubernetes nodes and pods, which watch for resources in kubernetes cluster
-func initK8sWatchers(ctx context.Context, client kuberntescli.Interface, log *logp.Logger, cfg config, publisher stateless.Publisher, watchersMap *watchersMap) error {
+// initK8sWatchers initiates and stores watchers for kubernetes namespaces, nodes, pods, workloads, services, ingresses and storage, which watch for resources in kubernetes cluster.
+// If watchDebouncer is not nil, the watchers publish the assets as soon as they change.
+func initK8sWatchers(ctx context.Context, client kuberntescli.Interface, log *logp.Logger, cfg config, publisher stateless.Publisher, watchersMap *watchersMap, clusterResolver *clusterResolver, watchDebouncer *debouncer) error {
+	filter := newWatchFilter(cfg)
+
+	if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.namespace") {
+		log.Info("Namespace type enabled. Initiate namespace watcher")
+		var handler kube.ResourceEventHandler
+		var namespacePublisher *watchPublisher
+		if watchDebouncer != nil {
+			namespacePublisher = getNamespaceWatchPublisher(ctx, log, publisher, watchersMap, clusterResolver, watchDebouncer)
+			handler = namespacePublisher
+		}
+		namespaceWatcher, err := getNamespaceWatcher(ctx, log, client, filter, time.Second*60, handler)
+		if err != nil {
+			log.Errorf("error initiating Namespace watcher: %w", err)
+			return err
+		}
+		watchersMap.watchers.Store("namespace", namespaceWatcher)
+
+		var resourceQuotaHandler kube.ResourceEventHandler
+		if namespacePublisher != nil {
+			resourceQuotaHandler = getResourceQuotaWatchHandler(namespacePublisher)
+		}
+		watchersMap.watchers.Store("resourcequota", getResourceQuotaWatcher(log, client, filter, time.Second*60, resourceQuotaHandler))
+	}
 
 	if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.node") {
 		log.Info("Node type enabled. Initiate node watcher")
-		nodeWatcher, err := getNodeWatcher(ctx, log, client, time.Second*60)
+		var handler kube.ResourceEventHandler
+		if watchDebouncer != nil {
+			handler = getNodeWatchPublisher(ctx, log, publisher, watchersMap, clusterResolver, watchDebouncer)
+		}
+		nodeWatcher, err := getNodeWatcher(ctx, log, client, filter, time.Second*60, handler)
 		if err != nil {
 			log.Errorf("error initiating Node watcher: %w", err)
 			return err
@@ -241,19 +540,253 @@ func initK8sWatchers(ctx context.Context, client kuberntescli.Interface, log *lo
 
 	if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.pod") {
 		log.Info("Pod type enabled. Initiate pod watcher")
-		podWatcher, err := getPodWatcher(ctx, log, client, time.Second*60)
+		var handler kube.ResourceEventHandler
+		if watchDebouncer != nil {
+			handler = getPodWatchPublisher(log, cfg, publisher, watchersMap, watchDebouncer)
+		}
+		podWatcher, err := getPodWatcher(ctx, log, client, filter, time.Second*60, handler)
 		if err != nil {
 			log.Errorf("error initiating Pod watcher: %w", err)
 			return err
 		}
 		watchersMap.watchers.Store("pod", podWatcher)
 	}
+
+	for _, wt := range workloadTypes {
+		if !internal.IsTypeEnabled(cfg.AssetTypes, wt.assetType) {
+			continue
+		}
+		log.Infof("%s type enabled. Initiate %s watcher", wt.assetType, wt.name)
+		var handler kube.ResourceEventHandler
+		if watchDebouncer != nil {
+			handler = getWorkloadWatchPublisher(log, publisher, wt, watchersMap, watchDebouncer)
+		}
+		watcher, err := getWorkloadWatcher(ctx, log, client, filter, wt, time.Second*60, handler)
+		if err != nil {
+			log.Errorf("error initiating %s watcher: %w", wt.name, err)
+			return err
+		}
+		watchersMap.watchers.Store(wt.name, watcher)
+	}
+
+	// services are also watched for ingresses, to resolve the services of their backends
+	if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.service") || internal.IsTypeEnabled(cfg.AssetTypes, "k8s.ingress") {
+		log.Info("Service or ingress type enabled. Initiate service watcher")
+		var handler kube.ResourceEventHandler
+		var servicePublisher *watchPublisher
+		if watchDebouncer != nil && internal.IsTypeEnabled(cfg.AssetTypes, "k8s.service") {
+			servicePublisher = getServiceWatchPublisher(log, publisher, watchersMap, watchDebouncer)
+			handler = servicePublisher
+		}
+		serviceWatcher, err := getServiceWatcher(ctx, log, client, filter, time.Second*60, handler)
+		if err != nil {
+			log.Errorf("error initiating Service watcher: %w", err)
+			return err
+		}
+		watchersMap.watchers.Store("service", serviceWatcher)
+
+		if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.service") {
+			log.Info("Service type enabled. Initiate endpoint slice watcher")
+			var endpointSliceHandler kube.ResourceEventHandler
+			if servicePublisher != nil {
+				endpointSliceHandler = getEndpointSliceWatchHandler(servicePublisher)
+			}
+			watchersMap.watchers.Store("endpointslice", getEndpointSliceWatcher(log, client, filter, time.Second*60, endpointSliceHandler))
+		}
+	}
+
+	if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.ingress") {
+		log.Info("Ingress type enabled. Initiate ingress watcher")
+		var handler kube.ResourceEventHandler
+		if watchDebouncer != nil {
+			handler = getIngressWatchPublisher(log, publisher, watchersMap, watchDebouncer)
+		}
+		watchersMap.watchers.Store("ingress", getIngressWatcher(log, client, filter, time.Second*60, handler))
+	}
+
+	if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.persistentvolume") {
+		log.Info("Persistent volume type enabled. Initiate persistent volume watcher")
+		var handler kube.ResourceEventHandler
+		if watchDebouncer != nil {
+			handler = getPersistentVolumeWatchPublisher(log, publisher, watchersMap, watchDebouncer)
+		}
+		watcher, err := getPersistentVolumeWatcher(ctx, log, client, filter, time.Second*60, handler)
+		if err != nil {
+			log.Errorf("error initiating PersistentVolume watcher: %w", err)
+			return err
+		}
+		watchersMap.watchers.Store("persistentvolume", watcher)
+	}
+
+	if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.persistentvolumeclaim") {
+		log.Info("Persistent volume claim type enabled. Initiate persistent volume claim watcher")
+		var handler kube.ResourceEventHandler
+		if watchDebouncer != nil {
+			handler = getPersistentVolumeClaimWatchPublisher(log, publisher, watchersMap, watchDebouncer)
+		}
+		watcher, err := getPersistentVolumeClaimWatcher(ctx, log, client, filter, time.Second*60, handler)
+		if err != nil {
+			log.Errorf("error initiating PersistentVolumeClaim watcher: %w", err)
+			return err
+		}
+		watchersMap.watchers.Store("persistentvolumeclaim", watcher)
+	}
 	return nil
 }
 
+// getNamespaceWatchPublisher returns the handler publishing the namespace assets as soon as they change
+func getNamespaceWatchPublisher(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, watchersMap *watchersMap, clusterResolver *clusterResolver, watchDebouncer *debouncer) *watchPublisher {
+	return newWatchPublisher("namespace", log, watchersMap, watchDebouncer, func(obj interface{}, opts ...internal.AssetOption) {
+		o, ok := obj.(*kube.Namespace)
+		if !ok {
+			log.Error("Publishing namespace asset failed. Type assertion of namespace object failed")
+			return
+		}
+		rw, _ := watchersMap.get("resourcequota")
+		publishK8sNamespace(log, publisher, o, rw, getClusterParents(ctx, log, clusterResolver), opts...)
+	})
+}
+
+// getResourceQuotaWatchHandler returns the handler publishing the namespace of a resource quota
+// as soon as the quota changes
+func getResourceQuotaWatchHandler(namespacePublisher *watchPublisher) kube.ResourceEventHandler {
+	schedule := func(obj interface{}) {
+		if namespace := getObjectNamespace(obj); namespace != "" {
+			namespacePublisher.scheduleKey(namespace)
+		}
+	}
+	return kube.ResourceEventHandlerFuncs{
+		AddFunc:    schedule,
+		UpdateFunc: schedule,
+		DeleteFunc: schedule,
+	}
+}
+
+// getNodeWatchPublisher returns the handler publishing the node assets as soon as they change
+func getNodeWatchPublisher(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, watchersMap *watchersMap, clusterResolver *clusterResolver, watchDebouncer *debouncer) *watchPublisher {
+	return newWatchPublisher("node", log, watchersMap, watchDebouncer, func(obj interface{}, opts ...internal.AssetOption) {
+		o, ok := obj.(*kube.Node)
+		if !ok {
+			log.Error("Publishing node asset failed. Type assertion of node object failed")
+			return
+		}
+		publishK8sNode(log, publisher, o, getClusterParents(ctx, log, clusterResolver), opts...)
+	})
+}
+
+// getPodWatchPublisher returns the handler publishing the pod and container assets as soon as they change
+func getPodWatchPublisher(log *logp.Logger, cfg config, publisher stateless.Publisher, watchersMap *watchersMap, watchDebouncer *debouncer) *watchPublisher {
+	return newWatchPublisher("pod", log, watchersMap, watchDebouncer, func(obj interface{}, opts ...internal.AssetOption) {
+		o, ok := obj.(*kube.Pod)
+		if !ok {
+			log.Error("Publishing pod asset failed. Type assertion of pod object failed")
+			return
+		}
+		var nw kube.Watcher
+		if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.node") {
+			nw, _ = watchersMap.get("node")
+		}
+		nsw, _ := watchersMap.get("namespace")
+		publishK8sPod(log, publisher, o, nw, nsw, opts...)
+		if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.container") {
+			publishK8sPodContainers(log, publisher, o, opts...)
+		}
+	})
+}
+
+// getWorkloadWatchPublisher returns the handler publishing the workload assets of the given type as soon as they change
+func getWorkloadWatchPublisher(log *logp.Logger, publisher stateless.Publisher, wt workloadType, watchersMap *watchersMap, watchDebouncer *debouncer) *watchPublisher {
+	return newWatchPublisher(wt.name, log, watchersMap, watchDebouncer, func(obj interface{}, opts ...internal.AssetOption) {
+		nsw, _ := watchersMap.get("namespace")
+		publishK8sWorkload(log, publisher, wt, obj, nsw, opts...)
+	})
+}
+
+// getServiceWatchPublisher returns the handler publishing the service assets as soon as they change
+func getServiceWatchPublisher(log *logp.Logger, publisher stateless.Publisher, watchersMap *watchersMap, watchDebouncer *debouncer) *watchPublisher {
+	return newWatchPublisher("service", log, watchersMap, watchDebouncer, func(obj interface{}, opts ...internal.AssetOption) {
+		o, ok := obj.(*kube.Service)
+		if !ok {
+			log.Error("Publishing service asset failed. Type assertion of service object failed")
+			return
+		}
+		ew, _ := watchersMap.get("endpointslice")
+		nsw, _ := watchersMap.get("namespace")
+		publishK8sService(log, publisher, o, ew, nsw, opts...)
+	})
+}
+
+// getEndpointSliceWatchHandler returns the handler publishing the service of an endpoint slice
+// as soon as the slice changes, which happens when the pods selected by the service change
+func getEndpointSliceWatchHandler(servicePublisher *watchPublisher) kube.ResourceEventHandler {
+	schedule := func(obj interface{}) {
+		if key := getEndpointSliceServiceKey(obj); key != "" {
+			servicePublisher.scheduleKey(key)
+		}
+	}
+	return kube.ResourceEventHandlerFuncs{
+		AddFunc:    schedule,
+		UpdateFunc: schedule,
+		DeleteFunc: schedule,
+	}
+}
+
+// getIngressWatchPublisher returns the handler publishing the ingress assets as soon as they change
+func getIngressWatchPublisher(log *logp.Logger, publisher stateless.Publisher, watchersMap *watchersMap, watchDebouncer *debouncer) *watchPublisher {
+	return newWatchPublisher("ingress", log, watchersMap, watchDebouncer, func(obj interface{}, opts ...internal.AssetOption) {
+		o, ok := obj.(*networkingv1.Ingress)
+		if !ok {
+			log.Error("Publishing ingress asset failed. Type assertion of ingress object failed")
+			return
+		}
+		sw, _ := watchersMap.get("service")
+		nsw, _ := watchersMap.get("namespace")
+		publishK8sIngress(log, publisher, o, sw, nsw, opts...)
+	})
+}
+
+// getPersistentVolumeWatchPublisher returns the handler publishing the persistent volume assets as soon as they change
+func getPersistentVolumeWatchPublisher(log *logp.Logger, publisher stateless.Publisher, watchersMap *watchersMap, watchDebouncer *debouncer) *watchPublisher {
+	return newWatchPublisher("persistentvolume", log, watchersMap, watchDebouncer, func(obj interface{}, opts ...internal.AssetOption) {
+		o, ok := obj.(*kube.PersistentVolume)
+		if !ok {
+			log.Error("Publishing persistent volume asset failed. Type assertion of persistent volume object failed")
+			return
+		}
+		publishK8sPersistentVolume(log, publisher, o, opts...)
+	})
+}
+
+// getPersistentVolumeClaimWatchPublisher returns the handler publishing the persistent volume claim assets as soon as they change
+func getPersistentVolumeClaimWatchPublisher(log *logp.Logger, publisher stateless.Publisher, watchersMap *watchersMap, watchDebouncer *debouncer) *watchPublisher {
+	return newWatchPublisher("persistentvolumeclaim", log, watchersMap, watchDebouncer, func(obj interface{}, opts ...internal.AssetOption) {
+		o, ok := obj.(*kube.PersistentVolumeClaim)
+		if !ok {
+			log.Error("Publishing persistent volume claim asset failed. Type assertion of persistent volume claim object failed")
+			return
+		}
+		pw, _ := watchersMap.get("pod")
+		nsw, _ := watchersMap.get("namespace")
+		publishK8sPersistentVolumeClaim(log, publisher, o, pw, nsw, opts...)
+	})
+}
+
 // startK8sWatchers starts the given watchers
 func startK8sWatchers(ctx context.Context, log *logp.Logger, cfg config, watchersMap *watchersMap) error {
 
+	// the namespaces are started first so that the namespaced objects can be parented to them
+	for _, name := range []string{"resourcequota", "namespace"} {
+		watcher, ok := watchersMap.get(name)
+		if !ok {
+			continue
+		}
+		log.Infof("Starting %s watcher", name)
+		if err := watcher.Start(); err != nil {
+			log.Errorf("Couldn't start %s watcher: %v", name, err)
+			return err
+		}
+	}
+
 	if internal.IsTypeEnabled(cfg.AssetTypes, "k8s.node") {
 		log.Info("Starting node watcher")
 		if nodeWatcher, ok := watchersMap.watchers.Load("node"); ok {
@@ -288,6 +821,35 @@ func startK8sWatchers(ctx context.Context, log *logp.Logger, cfg config, watcher
 		}
 	}
 
+	for _, wt := range workloadTypes {
+		if !internal.IsTypeEnabled(cfg.AssetTypes, wt.assetType) {
+			continue
+		}
+		log.Infof("Starting %s watcher", wt.name)
+		watcher, ok := watchersMap.get(wt.name)
+		if !ok {
+			return fmt.Errorf("%s watcher not found", wt.name)
+		}
+		if err := watcher.Start(); err != nil {
+			log.Errorf("Couldn't start %s watcher: %v", wt.name, err)
+			return err
+		}
+	}
+
+	// the endpoint slices are started first so that the pods of the services are known
+	// when the service watcher starts dispatching its events
+	for _, name := range []string{"endpointslice", "service", "ingress", "persistentvolume", "persistentvolumeclaim"} {
+		watcher, ok := watchersMap.get(name)
+		if !ok {
+			continue
+		}
+		log.Infof("Starting %s watcher", name)
+		if err := watcher.Start(); err != nil {
+			log.Errorf("Couldn't start %s watcher: %v", name, err)
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -316,6 +878,18 @@ func stopK8sWatchers(ctx context.Context, log *logp.Logger, watchersMap *watcher
 	} else {
 		log.Error("node watcher not found")
 	}
+
+	for _, wt := range workloadTypes {
+		if watcher, ok := watchersMap.get(wt.name); ok {
+			watcher.Stop()
+		}
+	}
+
+	for _, name := range []string{"service", "endpointslice", "ingress", "persistentvolume", "persistentvolumeclaim", "namespace", "resourcequota"} {
+		if watcher, ok := watchersMap.get(name); ok {
+			watcher.Stop()
+		}
+	}
 }
 
 // SetClient sets the Kubernetes Client. Used for e2e tests
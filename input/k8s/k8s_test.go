@@ -19,10 +19,14 @@ package k8s
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
@@ -151,7 +155,7 @@ func TestPublishK8sNodeAsset(t *testing.T) {
 func TestCollectK8sAssets(t *testing.T) {
 	client := k8sfake.NewSimpleClientset()
 	log := logp.NewLogger("mylogger")
-	podWatcher, err := getPodWatcher(context.Background(), log, client, time.Second*60)
+	podWatcher, err := getPodWatcher(context.Background(), log, client, nil, time.Second*60, nil)
 	if err != nil {
 		t.Fatalf("error initiating Pod watcher")
 	}
@@ -184,7 +188,151 @@ func TestCollectK8sAssets(t *testing.T) {
 	publisher := testutil.NewInMemoryPublisher()
 	cfg := defaultConfig()
 	cfg.AssetTypes = []string{"k8s.pod"}
-	collectK8sAssets(context.Background(), log, cfg, publisher, watchersMap)
+	collectK8sAssets(context.Background(), log, cfg, publisher, watchersMap, newClusterResolver(cfg, kubeCluster{client: client}))
 	time.Sleep(1 * time.Second)
 	assert.Equal(t, 1, len(publisher.Events))
 }
+
+func TestNewAssetsK8s(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		mode          string
+		clusterName   string
+		contexts      []string
+		dir           string
+		expectedError string
+	}{
+		{
+			name: "with the poll mode",
+			mode: modePoll,
+		},
+		{
+			name: "with the watch mode",
+			mode: modeWatch,
+		},
+		{
+			name:          "with an unknown mode",
+			mode:          "stream",
+			expectedError: "invalid mode \"stream\"",
+		},
+		{
+			name:        "with a cluster name for a single context",
+			mode:        modePoll,
+			clusterName: "production",
+			contexts:    []string{"production"},
+		},
+		{
+			name:          "with a cluster name for several contexts",
+			mode:          modePoll,
+			clusterName:   "production",
+			contexts:      []string{"production", "staging"},
+			expectedError: "cluster_name cannot be set",
+		},
+		{
+			name:          "with both contexts and a kube config directory",
+			mode:          modePoll,
+			contexts:      []string{"production"},
+			dir:           "/etc/kubeconfigs",
+			expectedError: "kube_config_contexts and kube_config_dir cannot be both set",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.Mode = tt.mode
+			cfg.ClusterName = tt.clusterName
+			cfg.KubeConfigContexts = tt.contexts
+			cfg.KubeConfigDir = tt.dir
+			_, err := newAssetsK8s(cfg, k8sfake.NewSimpleClientset())
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// writeKubeConfig writes a kubeconfig with a context per given name, the first one being the current context
+func writeKubeConfig(t *testing.T, path string, contexts ...string) {
+	config := "apiVersion: v1\nkind: Config\ncurrent-context: " + contexts[0] + "\nclusters:\n"
+	for _, name := range contexts {
+		config += fmt.Sprintf("- name: %s\n  cluster:\n    server: https://%s.example.com\n", name, name)
+	}
+	config += "contexts:\n"
+	for _, name := range contexts {
+		config += fmt.Sprintf("- name: %s\n  context:\n    cluster: %s\n    user: admin\n", name, name)
+	}
+	config += "users:\n- name: admin\n  user:\n    token: secret\n"
+	require.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+}
+
+func TestGetKubernetesClusters(t *testing.T) {
+	kubeConfig := filepath.Join(t.TempDir(), "config")
+	writeKubeConfig(t, kubeConfig, "production", "staging")
+	kubeConfigDir := t.TempDir()
+	writeKubeConfig(t, filepath.Join(kubeConfigDir, "eu.yaml"), "eu-admin")
+	writeKubeConfig(t, filepath.Join(kubeConfigDir, "us.yaml"), "us-admin")
+	writeKubeConfig(t, filepath.Join(kubeConfigDir, ".hidden"), "hidden")
+	require.NoError(t, os.Mkdir(filepath.Join(kubeConfigDir, "archive"), 0o700))
+
+	for _, tt := range []struct {
+		name          string
+		contexts      []string
+		dir           string
+		expectedNames []string
+		expectedError string
+	}{
+		{
+			name:          "with kube config contexts",
+			contexts:      []string{"production", "staging"},
+			expectedNames: []string{"production", "staging"},
+		},
+		{
+			name:          "with an unknown kube config context",
+			contexts:      []string{"production", "development"},
+			expectedError: "kube config context development",
+		},
+		{
+			name:          "with a kube config directory",
+			dir:           kubeConfigDir,
+			expectedNames: []string{"eu", "us"},
+		},
+		{
+			name:          "with an empty kube config directory",
+			dir:           t.TempDir(),
+			expectedError: "no kube config found",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.KubeConfig = kubeConfig
+			cfg.KubeConfigContexts = tt.contexts
+			cfg.KubeConfigDir = tt.dir
+			clusters, err := getKubernetesClusters(cfg, logp.NewLogger("mylogger"))
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			var names []string
+			for _, cluster := range clusters {
+				assert.NotNil(t, cluster.client)
+				assert.False(t, cluster.isInCluster)
+				names = append(names, cluster.name)
+			}
+			assert.Equal(t, tt.expectedNames, names)
+		})
+	}
+}
+
+func TestNextClusterStartBackoff(t *testing.T) {
+	var backoffs []time.Duration
+	var backoff time.Duration
+	for i := 0; i < 7; i++ {
+		backoff = nextClusterStartBackoff(backoff)
+		backoffs = append(backoffs, backoff)
+	}
+	assert.Equal(t, []time.Duration{
+		10 * time.Second, 20 * time.Second, 40 * time.Second, 80 * time.Second, 160 * time.Second, 5 * time.Minute, 5 * time.Minute,
+	}, backoffs)
+}
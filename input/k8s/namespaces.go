@@ -0,0 +1,231 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/elastic/assetbeat/input/internal"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	kube "github.com/elastic/elastic-agent-autodiscover/kubernetes"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+
+	kuberntescli "k8s.io/client-go/kubernetes"
+)
+
+// lastAppliedConfigAnnotation is set by kubectl apply to the whole applied object, and is not published
+const lastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+type namespaceEventer struct {
+	watcher kube.Watcher
+	client  kuberntescli.Interface
+	logger  *logp.Logger
+	ctx     context.Context
+	handler kube.ResourceEventHandler
+}
+
+// getNamespaceWatcher initiates and returns a watcher of kubernetes namespaces.
+// The events of the watcher are forwarded to handler, if not nil.
+func getNamespaceWatcher(ctx context.Context, log *logp.Logger, client kuberntescli.Interface, filter *watchFilter, timeout time.Duration, handler kube.ResourceEventHandler) (kube.Watcher, error) {
+	lws := filter.listWatches(namespaceNameField, withoutSelectors,
+		func(_ string, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Namespaces().List(ctx, opts)
+		},
+		func(_ string, opts metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Namespaces().Watch(ctx, opts)
+		})
+	watcher := newInformerWatcher(client, lws, &kube.Namespace{}, timeout, nil)
+
+	n := &namespaceEventer{
+		watcher: watcher,
+		client:  client,
+		logger:  log,
+		ctx:     ctx,
+		handler: handler,
+	}
+
+	watcher.AddEventHandler(n)
+
+	return watcher, nil
+}
+
+// Start starts the eventer
+func (n *namespaceEventer) Start() error {
+	return n.watcher.Start()
+}
+
+// Stop stops the eventer
+func (n *namespaceEventer) Stop() {
+	n.watcher.Stop()
+}
+
+// OnUpdate handles events for namespaces that have been updated.
+func (n *namespaceEventer) OnUpdate(obj interface{}) {
+	n.logger.Debugf("Watcher Namespace update: %+v", getObjectName(obj))
+	if n.handler != nil {
+		n.handler.OnUpdate(obj)
+	}
+}
+
+// OnDelete handles events for namespaces that have been deleted.
+func (n *namespaceEventer) OnDelete(obj interface{}) {
+	n.logger.Debugf("Watcher Namespace delete: %+v", getObjectName(obj))
+	if n.handler != nil {
+		n.handler.OnDelete(obj)
+	}
+}
+
+// OnAdd handles events for namespaces that have been added.
+func (n *namespaceEventer) OnAdd(obj interface{}) {
+	n.logger.Debugf("Watcher Namespace add: %+v", getObjectName(obj))
+	if n.handler != nil {
+		n.handler.OnAdd(obj)
+	}
+}
+
+// getResourceQuotaWatcher initiates and returns a watcher of kubernetes resource quotas, indexed by namespace.
+// The quotas are only filtered by namespace, as they are not published on their own.
+// The events of the watcher are forwarded to handler, if not nil.
+func getResourceQuotaWatcher(log *logp.Logger, client kuberntescli.Interface, filter *watchFilter, timeout time.Duration, handler kube.ResourceEventHandler) kube.Watcher {
+	lws := filter.listWatches(objectNamespaceField, withoutSelectors,
+		func(namespace string, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().ResourceQuotas(namespace).List(context.Background(), opts)
+		},
+		func(namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().ResourceQuotas(namespace).Watch(context.Background(), opts)
+		})
+	watcher := newInformerWatcher(client, lws, &v1.ResourceQuota{}, timeout, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	})
+	if handler != nil {
+		watcher.AddEventHandler(handler)
+	}
+	log.Debug("Resource quota watcher created")
+	return watcher
+}
+
+// publishK8sNamespaces publishes the namespace assets stored in namespace watcher cache
+func publishK8sNamespaces(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, namespaceWatcher, resourceQuotaWatcher kube.Watcher, clusterParents []string) {
+	log.Info("Publishing namespace assets\n")
+	for _, obj := range namespaceWatcher.Store().List() {
+		o, ok := obj.(*kube.Namespace)
+		if ok {
+			publishK8sNamespace(log, publisher, o, resourceQuotaWatcher, clusterParents)
+		} else {
+			log.Error("Publishing namespace assets failed. Type assertion of namespace object failed")
+		}
+	}
+}
+
+// publishK8sNamespace publishes the asset of a namespace, parented to the given cluster parents,
+// with the given additional options
+func publishK8sNamespace(log *logp.Logger, publisher stateless.Publisher, o *kube.Namespace, resourceQuotaWatcher kube.Watcher, clusterParents []string, opts ...internal.AssetOption) {
+	log.Debugf("Publish Namespace: %+v", o.Name)
+	assetType := "k8s.namespace"
+	assetKind := "namespace"
+	assetId := string(o.UID)
+
+	annotations := mapstr.M{}
+	for k, v := range o.Annotations {
+		if k != lastAppliedConfigAnnotation {
+			annotations[k] = v
+		}
+	}
+	metadata := mapstr.M{
+		"labels":      internal.ToMapstr(o.Labels),
+		"annotations": annotations,
+		"phase":       string(o.Status.Phase),
+	}
+	if resourceQuotaWatcher != nil {
+		quotas, err := getResourceQuotas(o.Name, resourceQuotaWatcher)
+		if err == nil {
+			metadata["resource_quotas"] = quotas
+		} else {
+			log.Errorf("namespace resource quotas not collected: %v", err)
+		}
+	}
+
+	options := []internal.AssetOption{
+		internal.WithAssetKindAndID(assetKind, assetId),
+		internal.WithAssetType(assetType),
+		internal.WithAssetName(o.Name),
+		internal.WithAssetParents(clusterParents),
+		internal.WithAssetMetadata(metadata),
+		internal.WithNamespaceData(o.Name, assetId),
+	}
+	internal.Publish(publisher, nil, append(options, opts...)...)
+}
+
+// getResourceQuotas returns the hard limits and the usage of the resource quotas of a namespace, by quota name
+func getResourceQuotas(namespace string, resourceQuotaWatcher kube.Watcher) (mapstr.M, error) {
+	indexer, ok := resourceQuotaWatcher.Store().(cache.Indexer)
+	if !ok {
+		return nil, fmt.Errorf("resource quota watcher store is not indexed")
+	}
+	objs, err := indexer.ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	quotas := mapstr.M{}
+	for _, obj := range objs {
+		quota, ok := obj.(*v1.ResourceQuota)
+		if !ok {
+			continue
+		}
+		quotas[quota.Name] = mapstr.M{
+			"hard": getResourceQuantities(quota.Status.Hard),
+			"used": getResourceQuantities(quota.Status.Used),
+		}
+	}
+	return quotas, nil
+}
+
+func getResourceQuantities(resources v1.ResourceList) map[string]string {
+	quantities := make(map[string]string, len(resources))
+	for name, quantity := range resources {
+		quantities[string(name)] = quantity.String()
+	}
+	return quantities
+}
+
+// getNamespaceParents returns the EAN of the namespace of a namespaced object, if it is stored in
+// the namespace watcher cache
+func getNamespaceParents(namespace string, namespaceWatcher kube.Watcher) []string {
+	if namespaceWatcher == nil || namespace == "" {
+		return []string{}
+	}
+	obj, exists, err := namespaceWatcher.Store().GetByKey(namespace)
+	if err != nil || !exists {
+		return []string{}
+	}
+	o, ok := obj.(*kube.Namespace)
+	if !ok {
+		return []string{}
+	}
+	return []string{fmt.Sprintf("%s:%s", "namespace", o.UID)}
+}
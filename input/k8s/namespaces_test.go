@@ -0,0 +1,146 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/assetbeat/input/testutil"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestPublishK8sNamespaces(t *testing.T) {
+	namespace := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "shop",
+			UID:  "e3c0b5a1-4c1e-4d5e-9a0c-6b7f8e9d0a1b",
+			Labels: map[string]string{
+				"team": "payments",
+			},
+			Annotations: map[string]string{
+				"owner":                     "payments@example.com",
+				lastAppliedConfigAnnotation: `{"apiVersion":"v1","kind":"Namespace"}`,
+			},
+		},
+		Status: v1.NamespaceStatus{Phase: v1.NamespaceActive},
+	}
+	quotas := []*v1.ResourceQuota{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "compute", Namespace: "shop"},
+			Status: v1.ResourceQuotaStatus{
+				Hard: v1.ResourceList{v1.ResourceLimitsCPU: resource.MustParse("4")},
+				Used: v1.ResourceList{v1.ResourceLimitsCPU: resource.MustParse("500m")},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "compute", Namespace: "other"},
+			Status: v1.ResourceQuotaStatus{
+				Hard: v1.ResourceList{v1.ResourcePods: resource.MustParse("10")},
+			},
+		},
+	}
+
+	client := k8sfake.NewSimpleClientset()
+	log := logp.NewLogger("mylogger")
+	namespaceWatcher, err := getNamespaceWatcher(context.Background(), log, client, nil, time.Second*60, nil)
+	require.NoError(t, err)
+	require.NoError(t, namespaceWatcher.Store().Add(namespace))
+	resourceQuotaWatcher := getResourceQuotaWatcher(log, client, nil, time.Second*60, nil)
+	for _, quota := range quotas {
+		require.NoError(t, resourceQuotaWatcher.Store().Add(quota))
+	}
+
+	publisher := testutil.NewInMemoryPublisher()
+	clusterParents := []string{"cluster:3e63bba2eef749e9a120912b8a93023e1f1e545d3f6e4ad6ab14f4654a7c0ef6"}
+	publishK8sNamespaces(context.Background(), log, publisher, namespaceWatcher, resourceQuotaWatcher, clusterParents)
+
+	expectedEvent := beat.Event{
+		Fields: mapstr.M{
+			"asset.type":                       "k8s.namespace",
+			"asset.kind":                       "namespace",
+			"asset.id":                         "e3c0b5a1-4c1e-4d5e-9a0c-6b7f8e9d0a1b",
+			"asset.ean":                        "namespace:e3c0b5a1-4c1e-4d5e-9a0c-6b7f8e9d0a1b",
+			"asset.name":                       "shop",
+			"asset.parents":                    clusterParents,
+			"asset.metadata.labels.team":       "payments",
+			"asset.metadata.annotations.owner": "payments@example.com",
+			"asset.metadata.phase":             "Active",
+			"asset.metadata.resource_quotas.compute.hard": map[string]string{"limits.cpu": "4"},
+			"asset.metadata.resource_quotas.compute.used": map[string]string{"limits.cpu": "500m"},
+			"kubernetes.namespace":                        "shop",
+			"kubernetes.namespace_uid":                    "e3c0b5a1-4c1e-4d5e-9a0c-6b7f8e9d0a1b",
+		},
+		Meta: mapstr.M{"index": internal.GetDefaultIndexName()},
+	}
+	assert.Equal(t, []beat.Event{expectedEvent}, publisher.Events)
+}
+
+func TestGetNamespaceParents(t *testing.T) {
+	client := k8sfake.NewSimpleClientset()
+	log := logp.NewLogger("mylogger")
+	namespaceWatcher, err := getNamespaceWatcher(context.Background(), log, client, nil, time.Second*60, nil)
+	require.NoError(t, err)
+	require.NoError(t, namespaceWatcher.Store().Add(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", UID: "e3c0b5a1-4c1e-4d5e-9a0c-6b7f8e9d0a1b"},
+	}))
+
+	for _, tt := range []struct {
+		name             string
+		namespace        string
+		namespaceWatcher bool
+		expected         []string
+	}{
+		{
+			name:             "known namespace",
+			namespace:        "shop",
+			namespaceWatcher: true,
+			expected:         []string{"namespace:e3c0b5a1-4c1e-4d5e-9a0c-6b7f8e9d0a1b"},
+		},
+		{
+			name:             "unknown namespace",
+			namespace:        "other",
+			namespaceWatcher: true,
+			expected:         []string{},
+		},
+		{
+			name:      "namespaces not collected",
+			namespace: "shop",
+			expected:  []string{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			nsw := namespaceWatcher
+			if !tt.namespaceWatcher {
+				nsw = nil
+			}
+			assert.Equal(t, tt.expected, getNamespaceParents(tt.namespace, nsw))
+		})
+	}
+}
@@ -22,6 +22,10 @@ import (
 	"fmt"
 	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+
 	"github.com/elastic/assetbeat/input/internal"
 	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
 	kube "github.com/elastic/elastic-agent-autodiscover/kubernetes"
@@ -37,6 +41,7 @@ type node struct {
 	client  kuberntescli.Interface
 	logger  *logp.Logger
 	ctx     context.Context
+	handler kube.ResourceEventHandler
 }
 
 const (
@@ -45,25 +50,24 @@ const (
 	gceMetadataURI = "/computeMetadata/v1/?recursive=true&alt=json"
 )
 
-// getNodeWatcher initiates and returns a watcher of kubernetes nodes
-func getNodeWatcher(ctx context.Context, log *logp.Logger, client kuberntescli.Interface, timeout time.Duration) (kube.Watcher, error) {
-	watcher, err := kube.NewNamedWatcher("node", client, &kube.Node{}, kube.WatchOptions{
-		SyncTimeout:  timeout,
-		Node:         "",
-		Namespace:    "",
-		HonorReSyncs: true,
-	}, nil)
-
-	if err != nil {
-		log.Errorf("could not create kubernetes watcher %v", err)
-		return nil, err
-	}
+// getNodeWatcher initiates and returns a watcher of kubernetes nodes.
+// The events of the watcher are forwarded to handler, if not nil.
+func getNodeWatcher(ctx context.Context, log *logp.Logger, client kuberntescli.Interface, filter *watchFilter, timeout time.Duration, handler kube.ResourceEventHandler) (kube.Watcher, error) {
+	lws := filter.listWatches("", withoutSelectors,
+		func(_ string, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Nodes().List(ctx, opts)
+		},
+		func(_ string, opts metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Nodes().Watch(ctx, opts)
+		})
+	watcher := newInformerWatcher(client, lws, &kube.Node{}, timeout, nil)
 
 	n := &node{
 		watcher: watcher,
 		client:  client,
 		logger:  log,
 		ctx:     ctx,
+		handler: handler,
 	}
 
 	watcher.AddEventHandler(n)
@@ -85,18 +89,27 @@ func (n *node) Stop() {
 func (n *node) OnUpdate(obj interface{}) {
 	o := obj.(*kube.Node)
 	n.logger.Debugf("Watcher Node update: %+v", o.Name)
+	if n.handler != nil {
+		n.handler.OnUpdate(obj)
+	}
 }
 
 // OnDelete stops pod objects that are deleted.
 func (n *node) OnDelete(obj interface{}) {
 	o := obj.(*kube.Node)
 	n.logger.Debugf("Watcher Node delete: %+v", o.Name)
+	if n.handler != nil {
+		n.handler.OnDelete(obj)
+	}
 }
 
 // OnAdd ensures processing of node objects that are newly added.
 func (n *node) OnAdd(obj interface{}) {
 	o := obj.(*kube.Node)
 	n.logger.Debugf("Watcher Node add: %+v", o.Name)
+	if n.handler != nil {
+		n.handler.OnAdd(obj)
+	}
 }
 
 // getNodeIdFromName returns kubernetes node id from a provided node name
@@ -112,59 +125,45 @@ func getNodeIdFromName(nodeName string, nodeWatcher kube.Watcher) (string, error
 	return string(n.ObjectMeta.UID), nil
 }
 
-// publishK8sNodes publishes the node assets stored in node watcher cache
-func publishK8sNodes(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, watcher kube.Watcher, isInCluster bool) {
+// publishK8sNodes publishes the node assets stored in node watcher cache, parented to the given cluster parents
+func publishK8sNodes(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, watcher kube.Watcher, assetParents []string) {
 	log.Info("Publishing nodes assets\n")
-	assetType := "k8s.node"
-	assetKind := "host"
-	var assetParents []string
-
-	// Get the first stored node to extract the cluster uid in case of GCP.
-	// Only in case of running InCluster
-	log.Debugf("Is in cluster is %s", isInCluster)
-	if isInCluster {
-		if len(watcher.Store().List()) > 0 {
-			if n1, ok := watcher.Store().List()[0].(*kube.Node); ok {
-				if getCspFromProviderId(n1.Spec.ProviderID) == "gcp" {
-					clusterUid, err := getGKEClusterUid(ctx, log, newhttpFetcher())
-					if err != nil {
-						log.Debugf("Unable to fetch cluster uid from metadata: %+v \n", err)
-					}
-					assetParents = append(assetParents, fmt.Sprintf("%s:%s", "cluster", clusterUid))
-				}
-			}
-		}
-	}
 
 	for _, obj := range watcher.Store().List() {
 		o, ok := obj.(*kube.Node)
 		if ok {
-			log.Debugf("Publish Node: %+v", o.Name)
-			metadata := mapstr.M{
-				"state": getNodeState(o),
-			}
-			log.Debug("Node status: ", metadata["state"])
-			instanceId := getInstanceId(o)
-			log.Debug("Node instance id: ", instanceId)
-			assetId := string(o.ObjectMeta.UID)
-			assetStartTime := o.ObjectMeta.CreationTimestamp
-			options := []internal.AssetOption{
-				internal.WithAssetKindAndID(assetKind, assetId),
-				internal.WithAssetType(assetType),
-				internal.WithAssetName(o.Name),
-				internal.WithAssetMetadata(metadata),
-				internal.WithNodeData(o.Name, &assetStartTime),
-			}
-			if instanceId != "" {
-				options = append(options, internal.WithCloudInstanceId(instanceId))
-			}
-			if assetParents != nil {
-				options = append(options, internal.WithAssetParents(assetParents))
-			}
-			internal.Publish(publisher, nil, options...)
-
+			publishK8sNode(log, publisher, o, assetParents)
 		} else {
 			log.Error("Publishing nodes assets failed. Type assertion of node object failed")
 		}
 	}
 }
+
+// publishK8sNode publishes the asset of a node, with the given additional options
+func publishK8sNode(log *logp.Logger, publisher stateless.Publisher, o *kube.Node, assetParents []string, opts ...internal.AssetOption) {
+	log.Debugf("Publish Node: %+v", o.Name)
+	assetType := "k8s.node"
+	assetKind := "host"
+	metadata := mapstr.M{
+		"state": getNodeState(o),
+	}
+	log.Debug("Node status: ", metadata["state"])
+	instanceId := getInstanceId(o)
+	log.Debug("Node instance id: ", instanceId)
+	assetId := string(o.ObjectMeta.UID)
+	assetStartTime := o.ObjectMeta.CreationTimestamp
+	options := []internal.AssetOption{
+		internal.WithAssetKindAndID(assetKind, assetId),
+		internal.WithAssetType(assetType),
+		internal.WithAssetName(o.Name),
+		internal.WithAssetMetadata(metadata),
+		internal.WithNodeData(o.Name, &assetStartTime),
+	}
+	if instanceId != "" {
+		options = append(options, internal.WithCloudInstanceId(instanceId))
+	}
+	if assetParents != nil {
+		options = append(options, internal.WithAssetParents(assetParents))
+	}
+	internal.Publish(publisher, nil, append(options, opts...)...)
+}
@@ -36,7 +36,7 @@ import (
 func TestGetNodeWatcher(t *testing.T) {
 	client := k8sfake.NewSimpleClientset()
 	log := logp.NewLogger("mylogger")
-	_, err := getNodeWatcher(context.Background(), log, client, time.Second*60)
+	_, err := getNodeWatcher(context.Background(), log, client, nil, time.Second*60, nil)
 	if err != nil {
 		t.Fatalf("error initiating Node watcher")
 	}
@@ -104,7 +104,7 @@ func TestGetNodeIdFromName(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			client := k8sfake.NewSimpleClientset()
 			log := logp.NewLogger("mylogger")
-			nodeWatcher, _ := getNodeWatcher(context.Background(), log, client, time.Second*60)
+			nodeWatcher, _ := getNodeWatcher(context.Background(), log, client, nil, time.Second*60, nil)
 			_ = nodeWatcher.Store().Add(tt.input)
 			_, err := getNodeIdFromName(tt.nodeName, nodeWatcher)
 			assert.Equal(t, err, tt.output)
@@ -115,7 +115,7 @@ func TestGetNodeIdFromName(t *testing.T) {
 func TestPublishK8sNodes(t *testing.T) {
 	client := k8sfake.NewSimpleClientset()
 	log := logp.NewLogger("mylogger")
-	nodeWatcher, err := getNodeWatcher(context.Background(), log, client, time.Second*60)
+	nodeWatcher, err := getNodeWatcher(context.Background(), log, client, nil, time.Second*60, nil)
 	if err != nil {
 		t.Fatalf("error initiating Node watcher")
 	}
@@ -140,7 +140,7 @@ func TestPublishK8sNodes(t *testing.T) {
 	}
 	_ = nodeWatcher.Store().Add(input)
 	publisher := testutil.NewInMemoryPublisher()
-	publishK8sNodes(context.Background(), log, publisher, nodeWatcher, false)
+	publishK8sNodes(context.Background(), log, publisher, nodeWatcher, nil)
 
 	assert.Equal(t, 1, len(publisher.Events))
 }
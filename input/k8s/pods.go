@@ -27,7 +27,11 @@ import (
 	kube "github.com/elastic/elastic-agent-autodiscover/kubernetes"
 	"github.com/elastic/elastic-agent-libs/logp"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
 	kuberntescli "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 )
 
 type pod struct {
@@ -35,27 +39,29 @@ type pod struct {
 	client  kuberntescli.Interface
 	logger  *logp.Logger
 	ctx     context.Context
+	handler kube.ResourceEventHandler
 }
 
-// getPodWatcher initiates and returns a watcher of kubernetes pods
-func getPodWatcher(ctx context.Context, log *logp.Logger, client kuberntescli.Interface, timeout time.Duration) (kube.Watcher, error) {
-	watcher, err := kube.NewNamedWatcher("pod", client, &kube.Pod{}, kube.WatchOptions{
-		SyncTimeout:  timeout,
-		Node:         "",
-		Namespace:    "",
-		HonorReSyncs: true,
-	}, nil)
-
-	if err != nil {
-		log.Errorf("could not create kubernetes watcher %v", err)
-		return nil, err
-	}
+// getPodWatcher initiates and returns a watcher of kubernetes pods, indexed by the persistent volume claims they mount.
+// The events of the watcher are forwarded to handler, if not nil.
+func getPodWatcher(ctx context.Context, log *logp.Logger, client kuberntescli.Interface, filter *watchFilter, timeout time.Duration, handler kube.ResourceEventHandler) (kube.Watcher, error) {
+	lws := filter.listWatches(objectNamespaceField, withAllSelectors,
+		func(namespace string, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Pods(namespace).List(ctx, opts)
+		},
+		func(namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Pods(namespace).Watch(ctx, opts)
+		})
+	watcher := newInformerWatcher(client, lws, &kube.Pod{}, timeout, cache.Indexers{
+		persistentVolumeClaimIndex: getPodPersistentVolumeClaims,
+	})
 
 	p := &pod{
 		watcher: watcher,
 		client:  client,
 		logger:  log,
 		ctx:     ctx,
+		handler: handler,
 	}
 
 	watcher.AddEventHandler(p)
@@ -77,57 +83,74 @@ func (p *pod) Stop() {
 func (p *pod) OnUpdate(obj interface{}) {
 	o := obj.(*kube.Pod)
 	p.logger.Debugf("Watcher Pod update: %+v", o.Name)
+	if p.handler != nil {
+		p.handler.OnUpdate(obj)
+	}
 }
 
 // OnDelete stops pod objects that are deleted.
 func (p *pod) OnDelete(obj interface{}) {
 	o := obj.(*kube.Pod)
 	p.logger.Debugf("Watcher Pod delete: %+v", o.Name)
+	if p.handler != nil {
+		p.handler.OnDelete(obj)
+	}
 }
 
 // OnAdd ensures processing of pod objects that are newly added.
 func (p *pod) OnAdd(obj interface{}) {
 	o := obj.(*kube.Pod)
 	p.logger.Debugf("Watcher Pod add: %+v", o.Name)
+	if p.handler != nil {
+		p.handler.OnAdd(obj)
+	}
 }
 
 // publishK8sPods publishes the pod assets stored in pod watcher cache
-func publishK8sPods(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, podWatcher, nodeWatcher kube.Watcher) {
+func publishK8sPods(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, podWatcher, nodeWatcher, namespaceWatcher kube.Watcher) {
 
 	log.Info("Publishing pod assets\n")
-	assetType := "k8s.pod"
-	assetKind := "container_group"
 	for _, obj := range podWatcher.Store().List() {
 		o, ok := obj.(*kube.Pod)
 		if ok {
-			log.Debugf("Publish Pod: %+v", o.Name)
-			assetName := o.Name
-			assetId := string(o.UID)
-			assetStartTime := o.Status.StartTime
-			namespace := o.Namespace
-			nodeName := o.Spec.NodeName
-
-			assetParents := []string{}
-			if nodeWatcher != nil {
-				nodeId, err := getNodeIdFromName(nodeName, nodeWatcher)
-				if err == nil {
-					nodeAssetName := fmt.Sprintf("%s:%s", "host", nodeId)
-					assetParents = append(assetParents, nodeAssetName)
-				} else {
-					log.Errorf("pod asset parents not collected: %w", err)
-				}
-			}
-
-			internal.Publish(publisher, nil,
-				internal.WithAssetKindAndID(assetKind, assetId),
-				internal.WithAssetType(assetType),
-				internal.WithAssetParents(assetParents),
-				internal.WithAssetName(assetName),
-				internal.WithPodData(assetName, assetId, namespace, assetStartTime),
-			)
+			publishK8sPod(log, publisher, o, nodeWatcher, namespaceWatcher)
 		} else {
 			log.Error("Publishing pod assets failed. Type assertion of pod object failed")
 		}
 
 	}
 }
+
+// publishK8sPod publishes the asset of a pod, with the given additional options
+func publishK8sPod(log *logp.Logger, publisher stateless.Publisher, o *kube.Pod, nodeWatcher, namespaceWatcher kube.Watcher, opts ...internal.AssetOption) {
+	log.Debugf("Publish Pod: %+v", o.Name)
+	assetType := "k8s.pod"
+	assetKind := "container_group"
+	assetName := o.Name
+	assetId := string(o.UID)
+	assetStartTime := o.Status.StartTime
+	namespace := o.Namespace
+	nodeName := o.Spec.NodeName
+
+	assetParents := []string{}
+	if nodeWatcher != nil {
+		nodeId, err := getNodeIdFromName(nodeName, nodeWatcher)
+		if err == nil {
+			nodeAssetName := fmt.Sprintf("%s:%s", "host", nodeId)
+			assetParents = append(assetParents, nodeAssetName)
+		} else {
+			log.Errorf("pod asset parents not collected: %w", err)
+		}
+	}
+	assetParents = append(assetParents, getNamespaceParents(namespace, namespaceWatcher)...)
+	assetParents = append(assetParents, getControllerParents(o.OwnerReferences)...)
+
+	options := []internal.AssetOption{
+		internal.WithAssetKindAndID(assetKind, assetId),
+		internal.WithAssetType(assetType),
+		internal.WithAssetParents(assetParents),
+		internal.WithAssetName(assetName),
+		internal.WithPodData(assetName, assetId, namespace, assetStartTime),
+	}
+	internal.Publish(publisher, nil, append(options, opts...)...)
+}
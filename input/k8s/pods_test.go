@@ -34,7 +34,7 @@ import (
 func TestGetPodWatcher(t *testing.T) {
 	client := k8sfake.NewSimpleClientset()
 	log := logp.NewLogger("mylogger")
-	_, err := getPodWatcher(context.Background(), log, client, time.Second*60)
+	_, err := getPodWatcher(context.Background(), log, client, nil, time.Second*60, nil)
 	if err != nil {
 		t.Fatalf("error initiating Pod watcher")
 	}
@@ -44,7 +44,7 @@ func TestGetPodWatcher(t *testing.T) {
 func TestPublishK8sPods(t *testing.T) {
 	client := k8sfake.NewSimpleClientset()
 	log := logp.NewLogger("mylogger")
-	podWatcher, err := getPodWatcher(context.Background(), log, client, time.Second*60)
+	podWatcher, err := getPodWatcher(context.Background(), log, client, nil, time.Second*60, nil)
 	if err != nil {
 		t.Fatalf("error initiating Pod watcher")
 	}
@@ -71,7 +71,33 @@ func TestPublishK8sPods(t *testing.T) {
 	}
 	_ = podWatcher.Store().Add(input)
 	publisher := testutil.NewInMemoryPublisher()
-	publishK8sPods(context.Background(), log, publisher, podWatcher, nil)
+	publishK8sPods(context.Background(), log, publisher, podWatcher, nil, nil)
 
 	assert.Equal(t, 1, len(publisher.Events))
 }
+
+func TestPublishK8sPods_ControllerParent(t *testing.T) {
+	client := k8sfake.NewSimpleClientset()
+	log := logp.NewLogger("mylogger")
+	podWatcher, err := getPodWatcher(context.Background(), log, client, nil, time.Second*60, nil)
+	if err != nil {
+		t.Fatalf("error initiating Pod watcher")
+	}
+	isController := true
+	input := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nginx-7c5ddbdf54-x2x7k",
+			UID:       "a375d24b-fa20-4ea6-a0ee-1d38671d2c09",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: "nginx-7c5ddbdf54", UID: "5b4a7b7e-1f4f-4f5c-8d2e-3d1b6a7c9e10", Controller: &isController},
+			},
+		},
+	}
+	_ = podWatcher.Store().Add(input)
+	publisher := testutil.NewInMemoryPublisher()
+	publishK8sPods(context.Background(), log, publisher, podWatcher, nil, nil)
+
+	assert.Equal(t, 1, len(publisher.Events))
+	assert.Equal(t, []string{"workload:5b4a7b7e-1f4f-4f5c-8d2e-3d1b6a7c9e10"}, publisher.Events[0].Fields["asset.parents"])
+}
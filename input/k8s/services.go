@@ -0,0 +1,230 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/elastic/assetbeat/input/internal"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	kube "github.com/elastic/elastic-agent-autodiscover/kubernetes"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+
+	kuberntescli "k8s.io/client-go/kubernetes"
+)
+
+// serviceIndex is the name of the index of the endpoint slices by the namespace/name of their service
+const serviceIndex = "service"
+
+type service struct {
+	watcher kube.Watcher
+	client  kuberntescli.Interface
+	logger  *logp.Logger
+	ctx     context.Context
+	handler kube.ResourceEventHandler
+}
+
+// getServiceWatcher initiates and returns a watcher of kubernetes services.
+// The events of the watcher are forwarded to handler, if not nil.
+func getServiceWatcher(ctx context.Context, log *logp.Logger, client kuberntescli.Interface, filter *watchFilter, timeout time.Duration, handler kube.ResourceEventHandler) (kube.Watcher, error) {
+	lws := filter.listWatches(objectNamespaceField, withLabelSelector,
+		func(namespace string, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Services(namespace).List(ctx, opts)
+		},
+		func(namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Services(namespace).Watch(ctx, opts)
+		})
+	watcher := newInformerWatcher(client, lws, &kube.Service{}, timeout, nil)
+
+	s := &service{
+		watcher: watcher,
+		client:  client,
+		logger:  log,
+		ctx:     ctx,
+		handler: handler,
+	}
+
+	watcher.AddEventHandler(s)
+
+	return watcher, nil
+}
+
+// Start starts the eventer
+func (s *service) Start() error {
+	return s.watcher.Start()
+}
+
+// Stop stops the eventer
+func (s *service) Stop() {
+	s.watcher.Stop()
+}
+
+// OnUpdate handles events for services that have been updated.
+func (s *service) OnUpdate(obj interface{}) {
+	s.logger.Debugf("Watcher Service update: %+v", getObjectName(obj))
+	if s.handler != nil {
+		s.handler.OnUpdate(obj)
+	}
+}
+
+// OnDelete handles events for services that have been deleted.
+func (s *service) OnDelete(obj interface{}) {
+	s.logger.Debugf("Watcher Service delete: %+v", getObjectName(obj))
+	if s.handler != nil {
+		s.handler.OnDelete(obj)
+	}
+}
+
+// OnAdd handles events for services that have been added.
+func (s *service) OnAdd(obj interface{}) {
+	s.logger.Debugf("Watcher Service add: %+v", getObjectName(obj))
+	if s.handler != nil {
+		s.handler.OnAdd(obj)
+	}
+}
+
+// getEndpointSliceWatcher initiates and returns a watcher of kubernetes endpoint slices,
+// indexed by the service they belong to. The slices are only filtered by namespace, as they are
+// not published on their own. The events of the watcher are forwarded to handler, if not nil.
+func getEndpointSliceWatcher(log *logp.Logger, client kuberntescli.Interface, filter *watchFilter, timeout time.Duration, handler kube.ResourceEventHandler) kube.Watcher {
+	lws := filter.listWatches(objectNamespaceField, withoutSelectors,
+		func(namespace string, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.DiscoveryV1().EndpointSlices(namespace).List(context.Background(), opts)
+		},
+		func(namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+			return client.DiscoveryV1().EndpointSlices(namespace).Watch(context.Background(), opts)
+		})
+	watcher := newInformerWatcher(client, lws, &discoveryv1.EndpointSlice{}, timeout, cache.Indexers{
+		serviceIndex: func(obj interface{}) ([]string, error) {
+			if key := getEndpointSliceServiceKey(obj); key != "" {
+				return []string{key}, nil
+			}
+			return nil, nil
+		},
+	})
+	if handler != nil {
+		watcher.AddEventHandler(handler)
+	}
+	log.Debug("Endpoint slice watcher created")
+	return watcher
+}
+
+// getEndpointSliceServiceKey returns the namespace/name key of the service an endpoint slice belongs to,
+// or an empty string if the endpoint slice is not managed for a service.
+func getEndpointSliceServiceKey(obj interface{}) string {
+	o, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return ""
+	}
+	name := o.Labels[discoveryv1.LabelServiceName]
+	if name == "" {
+		return ""
+	}
+	return o.Namespace + "/" + name
+}
+
+// publishK8sServices publishes the service assets stored in service watcher cache
+func publishK8sServices(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, serviceWatcher, endpointSliceWatcher, namespaceWatcher kube.Watcher) {
+	log.Info("Publishing service assets\n")
+	for _, obj := range serviceWatcher.Store().List() {
+		o, ok := obj.(*kube.Service)
+		if ok {
+			publishK8sService(log, publisher, o, endpointSliceWatcher, namespaceWatcher)
+		} else {
+			log.Error("Publishing service assets failed. Type assertion of service object failed")
+		}
+	}
+}
+
+// publishK8sService publishes the asset of a service, with the given additional options.
+// The children of the service are the pods it selects, as resolved in its endpoint slices.
+func publishK8sService(log *logp.Logger, publisher stateless.Publisher, o *kube.Service, endpointSliceWatcher, namespaceWatcher kube.Watcher, opts ...internal.AssetOption) {
+	log.Debugf("Publish Service: %+v", o.Name)
+	assetType := "k8s.service"
+	assetKind := "service"
+	assetId := string(o.UID)
+	assetStartTime := o.CreationTimestamp
+
+	assetChildren := []string{}
+	if endpointSliceWatcher != nil {
+		pods, err := getServicePods(o, endpointSliceWatcher)
+		if err == nil {
+			assetChildren = pods
+		} else {
+			log.Errorf("service asset children not collected: %v", err)
+		}
+	}
+
+	options := []internal.AssetOption{
+		internal.WithAssetKindAndID(assetKind, assetId),
+		internal.WithAssetType(assetType),
+		internal.WithAssetName(o.Name),
+		internal.WithAssetParents(getNamespaceParents(o.Namespace, namespaceWatcher)),
+		internal.WithAssetChildren(assetChildren),
+		internal.WithAssetMetadata(mapstr.M{
+			"type":       string(o.Spec.Type),
+			"cluster_ip": o.Spec.ClusterIP,
+		}),
+		internal.WithResourceData("service", o.Name, assetId, o.Namespace, &assetStartTime),
+	}
+	internal.Publish(publisher, nil, append(options, opts...)...)
+}
+
+// getServicePods returns the sorted EANs of the pods targeted by the endpoints of a service
+func getServicePods(o *kube.Service, endpointSliceWatcher kube.Watcher) ([]string, error) {
+	indexer, ok := endpointSliceWatcher.Store().(cache.Indexer)
+	if !ok {
+		return nil, fmt.Errorf("endpoint slice watcher store is not indexed")
+	}
+	slices, err := indexer.ByIndex(serviceIndex, o.Namespace+"/"+o.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	pods := []string{}
+	for _, obj := range slices {
+		slice, ok := obj.(*discoveryv1.EndpointSlice)
+		if !ok {
+			continue
+		}
+		for _, endpoint := range slice.Endpoints {
+			ref := endpoint.TargetRef
+			if ref == nil || ref.Kind != "Pod" || ref.UID == "" {
+				continue
+			}
+			ean := fmt.Sprintf("%s:%s", "container_group", ref.UID)
+			if !seen[ean] {
+				seen[ean] = true
+				pods = append(pods, ean)
+			}
+		}
+	}
+	sort.Strings(pods)
+	return pods, nil
+}
@@ -0,0 +1,136 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/assetbeat/input/testutil"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestPublishK8sServices(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "nginx",
+			UID:               "3c2b1a09-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+			Namespace:         "default",
+			CreationTimestamp: startTime,
+		},
+		Spec: v1.ServiceSpec{Type: v1.ServiceTypeClusterIP, ClusterIP: "10.96.0.12"},
+	}
+	newEndpointSlice := func(name, namespace, service string, podUIDs ...string) *discoveryv1.EndpointSlice {
+		slice := &discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    map[string]string{discoveryv1.LabelServiceName: service},
+			},
+		}
+		for _, uid := range podUIDs {
+			slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+				TargetRef: &v1.ObjectReference{Kind: "Pod", UID: types.UID(uid)},
+			})
+		}
+		return slice
+	}
+
+	for _, tt := range []struct {
+		name             string
+		endpointSlices   []*discoveryv1.EndpointSlice
+		expectedChildren []string
+	}{
+		{
+			name:             "without endpoints",
+			expectedChildren: []string{},
+		},
+		{
+			name: "with the pods of several endpoint slices",
+			endpointSlices: []*discoveryv1.EndpointSlice{
+				newEndpointSlice("nginx-abcde", "default", "nginx", "b2a1c3d4-0000-0000-0000-000000000002", "a1b2c3d4-0000-0000-0000-000000000001"),
+				newEndpointSlice("nginx-fghij", "default", "nginx", "a1b2c3d4-0000-0000-0000-000000000001", "c3d4e5f6-0000-0000-0000-000000000003"),
+			},
+			expectedChildren: []string{
+				"container_group:a1b2c3d4-0000-0000-0000-000000000001",
+				"container_group:b2a1c3d4-0000-0000-0000-000000000002",
+				"container_group:c3d4e5f6-0000-0000-0000-000000000003",
+			},
+		},
+		{
+			name: "ignoring the endpoint slices of other services",
+			endpointSlices: []*discoveryv1.EndpointSlice{
+				newEndpointSlice("nginx-abcde", "other", "nginx", "a1b2c3d4-0000-0000-0000-000000000001"),
+				newEndpointSlice("redis-abcde", "default", "redis", "b2a1c3d4-0000-0000-0000-000000000002"),
+			},
+			expectedChildren: []string{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := k8sfake.NewSimpleClientset()
+			log := logp.NewLogger("mylogger")
+			serviceWatcher, err := getServiceWatcher(context.Background(), log, client, nil, time.Second*60, nil)
+			require.NoError(t, err)
+			require.NoError(t, serviceWatcher.Store().Add(svc))
+			namespaceWatcher, err := getNamespaceWatcher(context.Background(), log, client, nil, time.Second*60, nil)
+			require.NoError(t, err)
+			require.NoError(t, namespaceWatcher.Store().Add(&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "default", UID: "e3c0b5a1-4c1e-4d5e-9a0c-6b7f8e9d0a1b"},
+			}))
+			endpointSliceWatcher := getEndpointSliceWatcher(log, client, nil, time.Second*60, nil)
+			for _, slice := range tt.endpointSlices {
+				require.NoError(t, endpointSliceWatcher.Store().Add(slice))
+			}
+
+			publisher := testutil.NewInMemoryPublisher()
+			publishK8sServices(context.Background(), log, publisher, serviceWatcher, endpointSliceWatcher, namespaceWatcher)
+
+			expectedEvent := beat.Event{
+				Fields: mapstr.M{
+					"asset.type":                    "k8s.service",
+					"asset.kind":                    "service",
+					"asset.id":                      "3c2b1a09-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+					"asset.ean":                     "service:3c2b1a09-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+					"asset.name":                    "nginx",
+					"asset.parents":                 []string{"namespace:e3c0b5a1-4c1e-4d5e-9a0c-6b7f8e9d0a1b"},
+					"asset.children":                tt.expectedChildren,
+					"asset.metadata.type":           "ClusterIP",
+					"asset.metadata.cluster_ip":     "10.96.0.12",
+					"kubernetes.service.name":       "nginx",
+					"kubernetes.service.uid":        "3c2b1a09-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+					"kubernetes.service.start_time": &startTime,
+					"kubernetes.namespace":          "default",
+				},
+				Meta: mapstr.M{"index": internal.GetDefaultIndexName()},
+			}
+			assert.Equal(t, []beat.Event{expectedEvent}, publisher.Events)
+		})
+	}
+}
@@ -0,0 +1,350 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/elastic/assetbeat/input/internal"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	kube "github.com/elastic/elastic-agent-autodiscover/kubernetes"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+
+	kuberntescli "k8s.io/client-go/kubernetes"
+)
+
+const (
+	// persistentVolumeClaimIndex is the name of the index of the pods by the namespace/name of the claims they mount
+	persistentVolumeClaimIndex = "persistentvolumeclaim"
+
+	// CSI drivers whose volume handles identify a cloud disk
+	awsEBSCSIDriver    = "ebs.csi.aws.com"
+	gcePDCSIDriver     = "pd.csi.storage.gke.io"
+	azureDiskCSIDriver = "disk.csi.azure.com"
+)
+
+type storage struct {
+	watcher kube.Watcher
+	client  kuberntescli.Interface
+	logger  *logp.Logger
+	ctx     context.Context
+	name    string
+	handler kube.ResourceEventHandler
+}
+
+// getPersistentVolumeWatcher initiates and returns a watcher of kubernetes persistent volumes.
+// The events of the watcher are forwarded to handler, if not nil.
+func getPersistentVolumeWatcher(ctx context.Context, log *logp.Logger, client kuberntescli.Interface, filter *watchFilter, timeout time.Duration, handler kube.ResourceEventHandler) (kube.Watcher, error) {
+	lws := filter.listWatches("", withoutSelectors,
+		func(_ string, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().PersistentVolumes().List(ctx, opts)
+		},
+		func(_ string, opts metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().PersistentVolumes().Watch(ctx, opts)
+		})
+	return getStorageWatcher(ctx, log, client, "persistentvolume", lws, &kube.PersistentVolume{}, timeout, handler)
+}
+
+// getPersistentVolumeClaimWatcher initiates and returns a watcher of kubernetes persistent volume claims.
+// The events of the watcher are forwarded to handler, if not nil.
+func getPersistentVolumeClaimWatcher(ctx context.Context, log *logp.Logger, client kuberntescli.Interface, filter *watchFilter, timeout time.Duration, handler kube.ResourceEventHandler) (kube.Watcher, error) {
+	lws := filter.listWatches(objectNamespaceField, withLabelSelector,
+		func(namespace string, opts metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().PersistentVolumeClaims(namespace).List(ctx, opts)
+		},
+		func(namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().PersistentVolumeClaims(namespace).Watch(ctx, opts)
+		})
+	return getStorageWatcher(ctx, log, client, "persistentvolumeclaim", lws, &kube.PersistentVolumeClaim{}, timeout, handler)
+}
+
+func getStorageWatcher(ctx context.Context, log *logp.Logger, client kuberntescli.Interface, name string, lws []*cache.ListWatch, resource kube.Resource, timeout time.Duration, handler kube.ResourceEventHandler) (kube.Watcher, error) {
+	watcher := newInformerWatcher(client, lws, resource, timeout, nil)
+
+	s := &storage{
+		watcher: watcher,
+		client:  client,
+		logger:  log,
+		ctx:     ctx,
+		name:    name,
+		handler: handler,
+	}
+
+	watcher.AddEventHandler(s)
+
+	return watcher, nil
+}
+
+// Start starts the eventer
+func (s *storage) Start() error {
+	return s.watcher.Start()
+}
+
+// Stop stops the eventer
+func (s *storage) Stop() {
+	s.watcher.Stop()
+}
+
+// OnUpdate handles events for storage objects that have been updated.
+func (s *storage) OnUpdate(obj interface{}) {
+	s.logger.Debugf("Watcher %s update: %+v", s.name, getObjectName(obj))
+	if s.handler != nil {
+		s.handler.OnUpdate(obj)
+	}
+}
+
+// OnDelete handles events for storage objects that have been deleted.
+func (s *storage) OnDelete(obj interface{}) {
+	s.logger.Debugf("Watcher %s delete: %+v", s.name, getObjectName(obj))
+	if s.handler != nil {
+		s.handler.OnDelete(obj)
+	}
+}
+
+// OnAdd handles events for storage objects that have been added.
+func (s *storage) OnAdd(obj interface{}) {
+	s.logger.Debugf("Watcher %s add: %+v", s.name, getObjectName(obj))
+	if s.handler != nil {
+		s.handler.OnAdd(obj)
+	}
+}
+
+// getPodPersistentVolumeClaims indexes the pods by the namespace/name of the persistent volume claims they mount
+func getPodPersistentVolumeClaims(obj interface{}) ([]string, error) {
+	o, ok := obj.(*kube.Pod)
+	if !ok {
+		return nil, nil
+	}
+	var claims []string
+	for _, volume := range o.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			claims = append(claims, o.Namespace+"/"+volume.PersistentVolumeClaim.ClaimName)
+		}
+	}
+	return claims, nil
+}
+
+// publishK8sPersistentVolumes publishes the persistent volume assets stored in persistent volume watcher cache
+func publishK8sPersistentVolumes(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, watcher kube.Watcher) {
+	log.Info("Publishing persistent volume assets\n")
+	for _, obj := range watcher.Store().List() {
+		o, ok := obj.(*kube.PersistentVolume)
+		if ok {
+			publishK8sPersistentVolume(log, publisher, o)
+		} else {
+			log.Error("Publishing persistent volume assets failed. Type assertion of persistent volume object failed")
+		}
+	}
+}
+
+// publishK8sPersistentVolume publishes the asset of a persistent volume, with the given additional options.
+// The volume is parented to the cloud disk backing it, if any, and is the parent of the claim bound to it.
+func publishK8sPersistentVolume(log *logp.Logger, publisher stateless.Publisher, o *kube.PersistentVolume, opts ...internal.AssetOption) {
+	log.Debugf("Publish PersistentVolume: %+v", o.Name)
+	assetType := "k8s.persistentvolume"
+	assetKind := "volume"
+	assetId := string(o.UID)
+	assetStartTime := o.CreationTimestamp
+
+	assetParents := []string{}
+	if disk := getCloudDiskEAN(o); disk != "" {
+		assetParents = append(assetParents, disk)
+	}
+	assetChildren := []string{}
+	if ref := o.Spec.ClaimRef; ref != nil && ref.UID != "" {
+		assetChildren = append(assetChildren, fmt.Sprintf("%s:%s", "volume_claim", ref.UID))
+	}
+
+	driver, handle := getVolumeSource(o)
+	metadata := mapstr.M{
+		"phase":          string(o.Status.Phase),
+		"storage_class":  o.Spec.StorageClassName,
+		"reclaim_policy": string(o.Spec.PersistentVolumeReclaimPolicy),
+		"access_modes":   getAccessModes(o.Spec.AccessModes),
+		"capacity":       getStorageQuantity(o.Spec.Capacity),
+		"driver":         driver,
+		"volume_handle":  handle,
+	}
+
+	options := []internal.AssetOption{
+		internal.WithAssetKindAndID(assetKind, assetId),
+		internal.WithAssetType(assetType),
+		internal.WithAssetName(o.Name),
+		internal.WithAssetParents(assetParents),
+		internal.WithAssetChildren(assetChildren),
+		internal.WithAssetMetadata(metadata),
+		internal.WithResourceData("persistentvolume", o.Name, assetId, "", &assetStartTime),
+	}
+	internal.Publish(publisher, nil, append(options, opts...)...)
+}
+
+// publishK8sPersistentVolumeClaims publishes the persistent volume claim assets stored in persistent volume claim watcher cache
+func publishK8sPersistentVolumeClaims(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, watcher, podWatcher, namespaceWatcher kube.Watcher) {
+	log.Info("Publishing persistent volume claim assets\n")
+	for _, obj := range watcher.Store().List() {
+		o, ok := obj.(*kube.PersistentVolumeClaim)
+		if ok {
+			publishK8sPersistentVolumeClaim(log, publisher, o, podWatcher, namespaceWatcher)
+		} else {
+			log.Error("Publishing persistent volume claim assets failed. Type assertion of persistent volume claim object failed")
+		}
+	}
+}
+
+// publishK8sPersistentVolumeClaim publishes the asset of a persistent volume claim, with the given additional options.
+// The children of the claim are the pods mounting it, when the pods are watched.
+func publishK8sPersistentVolumeClaim(log *logp.Logger, publisher stateless.Publisher, o *kube.PersistentVolumeClaim, podWatcher, namespaceWatcher kube.Watcher, opts ...internal.AssetOption) {
+	log.Debugf("Publish PersistentVolumeClaim: %+v", o.Name)
+	assetType := "k8s.persistentvolumeclaim"
+	assetKind := "volume_claim"
+	assetId := string(o.UID)
+	assetStartTime := o.CreationTimestamp
+
+	assetChildren := []string{}
+	if podWatcher != nil {
+		pods, err := getClaimPods(o, podWatcher)
+		if err == nil {
+			assetChildren = pods
+		} else {
+			log.Errorf("persistent volume claim asset children not collected: %v", err)
+		}
+	}
+
+	storageClass := ""
+	if o.Spec.StorageClassName != nil {
+		storageClass = *o.Spec.StorageClassName
+	}
+	metadata := mapstr.M{
+		"phase":         string(o.Status.Phase),
+		"storage_class": storageClass,
+		"volume_name":   o.Spec.VolumeName,
+		"access_modes":  getAccessModes(o.Spec.AccessModes),
+		"requested":     getStorageQuantity(o.Spec.Resources.Requests),
+		"capacity":      getStorageQuantity(o.Status.Capacity),
+	}
+
+	options := []internal.AssetOption{
+		internal.WithAssetKindAndID(assetKind, assetId),
+		internal.WithAssetType(assetType),
+		internal.WithAssetName(o.Name),
+		internal.WithAssetParents(getNamespaceParents(o.Namespace, namespaceWatcher)),
+		internal.WithAssetChildren(assetChildren),
+		internal.WithAssetMetadata(metadata),
+		internal.WithResourceData("persistentvolumeclaim", o.Name, assetId, o.Namespace, &assetStartTime),
+	}
+	internal.Publish(publisher, nil, append(options, opts...)...)
+}
+
+// getClaimPods returns the sorted EANs of the pods mounting a persistent volume claim
+func getClaimPods(o *kube.PersistentVolumeClaim, podWatcher kube.Watcher) ([]string, error) {
+	indexer, ok := podWatcher.Store().(cache.Indexer)
+	if !ok {
+		return nil, fmt.Errorf("pod watcher store is not indexed")
+	}
+	objs, err := indexer.ByIndex(persistentVolumeClaimIndex, o.Namespace+"/"+o.Name)
+	if err != nil {
+		return nil, err
+	}
+	pods := []string{}
+	for _, obj := range objs {
+		if pod, ok := obj.(*kube.Pod); ok {
+			pods = append(pods, fmt.Sprintf("%s:%s", "container_group", pod.UID))
+		}
+	}
+	sort.Strings(pods)
+	return pods, nil
+}
+
+// getVolumeSource returns the driver of a persistent volume, i.e. its CSI driver or the type of its
+// in-tree volume source, and the handle of the volume for the driver, if any
+func getVolumeSource(o *kube.PersistentVolume) (string, string) {
+	source := o.Spec.PersistentVolumeSource
+	switch {
+	case source.CSI != nil:
+		return source.CSI.Driver, source.CSI.VolumeHandle
+	case source.AWSElasticBlockStore != nil:
+		return "aws_ebs", source.AWSElasticBlockStore.VolumeID
+	case source.GCEPersistentDisk != nil:
+		return "gce_pd", source.GCEPersistentDisk.PDName
+	case source.AzureDisk != nil:
+		return "azure_disk", source.AzureDisk.DataDiskURI
+	case source.NFS != nil:
+		return "nfs", source.NFS.Server + ":" + source.NFS.Path
+	case source.HostPath != nil:
+		return "host_path", source.HostPath.Path
+	case source.Local != nil:
+		return "local", source.Local.Path
+	}
+	return "", ""
+}
+
+// getCloudDiskEAN returns the EAN of the cloud disk backing a persistent volume, when it is an EBS volume,
+// a GCE persistent disk or an Azure managed disk, or an empty string otherwise.
+// EBS volumes are identified by their volume ID, GCE persistent disks by their relative resource name,
+// i.e. projects/{project}/zones/{zone}/disks/{name}, and Azure disks by their resource ID.
+func getCloudDiskEAN(o *kube.PersistentVolume) string {
+	source := o.Spec.PersistentVolumeSource
+	diskID := ""
+	switch {
+	case source.CSI != nil:
+		switch source.CSI.Driver {
+		case awsEBSCSIDriver, gcePDCSIDriver, azureDiskCSIDriver:
+			diskID = source.CSI.VolumeHandle
+		}
+	case source.AWSElasticBlockStore != nil:
+		// in-tree volume IDs may be prefixed with the zone, e.g. aws://us-east-1a/vol-0123456789abcdef0
+		volumeID := source.AWSElasticBlockStore.VolumeID
+		diskID = volumeID[strings.LastIndex(volumeID, "/")+1:]
+	case source.AzureDisk != nil:
+		if source.AzureDisk.Kind != nil && *source.AzureDisk.Kind == v1.AzureManagedDisk {
+			diskID = source.AzureDisk.DataDiskURI
+		}
+	}
+	if diskID == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s", "disk", diskID)
+}
+
+func getAccessModes(modes []v1.PersistentVolumeAccessMode) []string {
+	accessModes := make([]string, 0, len(modes))
+	for _, mode := range modes {
+		accessModes = append(accessModes, string(mode))
+	}
+	return accessModes
+}
+
+// getStorageQuantity returns the storage of a resource list, e.g. 10Gi, or an empty string if not set
+func getStorageQuantity(resources v1.ResourceList) string {
+	quantity, ok := resources[v1.ResourceStorage]
+	if !ok {
+		return ""
+	}
+	return quantity.String()
+}
@@ -0,0 +1,240 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/assetbeat/input/testutil"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestGetCloudDiskEAN(t *testing.T) {
+	managed := v1.AzureManagedDisk
+	shared := v1.AzureSharedBlobDisk
+	for _, tt := range []struct {
+		name     string
+		source   v1.PersistentVolumeSource
+		expected string
+	}{
+		{
+			name: "EBS CSI volume",
+			source: v1.PersistentVolumeSource{CSI: &v1.CSIPersistentVolumeSource{
+				Driver: "ebs.csi.aws.com", VolumeHandle: "vol-0123456789abcdef0",
+			}},
+			expected: "disk:vol-0123456789abcdef0",
+		},
+		{
+			name: "GCE PD CSI volume",
+			source: v1.PersistentVolumeSource{CSI: &v1.CSIPersistentVolumeSource{
+				Driver: "pd.csi.storage.gke.io", VolumeHandle: "projects/my-project/zones/us-central1-a/disks/pvc-8f3c",
+			}},
+			expected: "disk:projects/my-project/zones/us-central1-a/disks/pvc-8f3c",
+		},
+		{
+			name: "Azure disk CSI volume",
+			source: v1.PersistentVolumeSource{CSI: &v1.CSIPersistentVolumeSource{
+				Driver: "disk.csi.azure.com", VolumeHandle: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/disks/pvc-8f3c",
+			}},
+			expected: "disk:/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/disks/pvc-8f3c",
+		},
+		{
+			name: "other CSI volume",
+			source: v1.PersistentVolumeSource{CSI: &v1.CSIPersistentVolumeSource{
+				Driver: "efs.csi.aws.com", VolumeHandle: "fs-0123456789abcdef0",
+			}},
+		},
+		{
+			name: "in-tree EBS volume with zone",
+			source: v1.PersistentVolumeSource{AWSElasticBlockStore: &v1.AWSElasticBlockStoreVolumeSource{
+				VolumeID: "aws://us-east-1a/vol-0123456789abcdef0",
+			}},
+			expected: "disk:vol-0123456789abcdef0",
+		},
+		{
+			name: "in-tree Azure managed disk",
+			source: v1.PersistentVolumeSource{AzureDisk: &v1.AzureDiskVolumeSource{
+				Kind: &managed, DataDiskURI: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/disks/data",
+			}},
+			expected: "disk:/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/disks/data",
+		},
+		{
+			name: "in-tree Azure blob disk",
+			source: v1.PersistentVolumeSource{AzureDisk: &v1.AzureDiskVolumeSource{
+				Kind: &shared, DataDiskURI: "https://account.blob.core.windows.net/vhds/data.vhd",
+			}},
+		},
+		{
+			name:   "NFS volume",
+			source: v1.PersistentVolumeSource{NFS: &v1.NFSVolumeSource{Server: "nfs.example.com", Path: "/exports"}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pv := &v1.PersistentVolume{Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: tt.source}}
+			assert.Equal(t, tt.expected, getCloudDiskEAN(pv))
+		})
+	}
+}
+
+func TestPublishK8sPersistentVolumes(t *testing.T) {
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "pvc-8f3c",
+			UID:               "6a5b4c3d-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+			CreationTimestamp: startTime,
+		},
+		Spec: v1.PersistentVolumeSpec{
+			Capacity:                      v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")},
+			AccessModes:                   []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			PersistentVolumeReclaimPolicy: v1.PersistentVolumeReclaimDelete,
+			StorageClassName:              "gp3",
+			ClaimRef:                      &v1.ObjectReference{Kind: "PersistentVolumeClaim", Name: "data", Namespace: "default", UID: "2f1e0d9c-8e4c-4b3a-9b6e-2ab6d4a8b8a1"},
+			PersistentVolumeSource: v1.PersistentVolumeSource{CSI: &v1.CSIPersistentVolumeSource{
+				Driver: "ebs.csi.aws.com", VolumeHandle: "vol-0123456789abcdef0",
+			}},
+		},
+		Status: v1.PersistentVolumeStatus{Phase: v1.VolumeBound},
+	}
+
+	client := k8sfake.NewSimpleClientset()
+	log := logp.NewLogger("mylogger")
+	watcher, err := getPersistentVolumeWatcher(context.Background(), log, client, nil, time.Second*60, nil)
+	require.NoError(t, err)
+	require.NoError(t, watcher.Store().Add(pv))
+
+	publisher := testutil.NewInMemoryPublisher()
+	publishK8sPersistentVolumes(context.Background(), log, publisher, watcher)
+
+	expectedEvent := beat.Event{
+		Fields: mapstr.M{
+			"asset.type":                             "k8s.persistentvolume",
+			"asset.kind":                             "volume",
+			"asset.id":                               "6a5b4c3d-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+			"asset.ean":                              "volume:6a5b4c3d-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+			"asset.name":                             "pvc-8f3c",
+			"asset.parents":                          []string{"disk:vol-0123456789abcdef0"},
+			"asset.children":                         []string{"volume_claim:2f1e0d9c-8e4c-4b3a-9b6e-2ab6d4a8b8a1"},
+			"asset.metadata.phase":                   "Bound",
+			"asset.metadata.storage_class":           "gp3",
+			"asset.metadata.reclaim_policy":          "Delete",
+			"asset.metadata.access_modes":            []string{"ReadWriteOnce"},
+			"asset.metadata.capacity":                "10Gi",
+			"asset.metadata.driver":                  "ebs.csi.aws.com",
+			"asset.metadata.volume_handle":           "vol-0123456789abcdef0",
+			"kubernetes.persistentvolume.name":       "pvc-8f3c",
+			"kubernetes.persistentvolume.uid":        "6a5b4c3d-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+			"kubernetes.persistentvolume.start_time": &startTime,
+		},
+		Meta: mapstr.M{"index": internal.GetDefaultIndexName()},
+	}
+	assert.Equal(t, []beat.Event{expectedEvent}, publisher.Events)
+}
+
+func TestPublishK8sPersistentVolumeClaims(t *testing.T) {
+	storageClass := "gp3"
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "data",
+			Namespace:         "default",
+			UID:               "2f1e0d9c-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+			CreationTimestamp: startTime,
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			StorageClassName: &storageClass,
+			VolumeName:       "pvc-8f3c",
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("8Gi")},
+			},
+		},
+		Status: v1.PersistentVolumeClaimStatus{
+			Phase:    v1.ClaimBound,
+			Capacity: v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")},
+		},
+	}
+	newPod := func(name, uid, namespace, claim string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(uid), Namespace: namespace},
+			Spec: v1.PodSpec{Volumes: []v1.Volume{{
+				Name: "data",
+				VolumeSource: v1.VolumeSource{
+					PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claim},
+				},
+			}}},
+		}
+	}
+
+	client := k8sfake.NewSimpleClientset()
+	log := logp.NewLogger("mylogger")
+	watcher, err := getPersistentVolumeClaimWatcher(context.Background(), log, client, nil, time.Second*60, nil)
+	require.NoError(t, err)
+	require.NoError(t, watcher.Store().Add(pvc))
+	podWatcher, err := getPodWatcher(context.Background(), log, client, nil, time.Second*60, nil)
+	require.NoError(t, err)
+	for _, pod := range []*v1.Pod{
+		newPod("db-1", "b2a1c3d4-0000-0000-0000-000000000002", "default", "data"),
+		newPod("db-0", "a1b2c3d4-0000-0000-0000-000000000001", "default", "data"),
+		newPod("web", "c3d4e5f6-0000-0000-0000-000000000003", "default", "logs"),
+		newPod("db-0", "d4e5f6a7-0000-0000-0000-000000000004", "other", "data"),
+	} {
+		require.NoError(t, podWatcher.Store().Add(pod))
+	}
+
+	publisher := testutil.NewInMemoryPublisher()
+	publishK8sPersistentVolumeClaims(context.Background(), log, publisher, watcher, podWatcher, nil)
+
+	expectedEvent := beat.Event{
+		Fields: mapstr.M{
+			"asset.type":    "k8s.persistentvolumeclaim",
+			"asset.kind":    "volume_claim",
+			"asset.id":      "2f1e0d9c-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+			"asset.ean":     "volume_claim:2f1e0d9c-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+			"asset.name":    "data",
+			"asset.parents": []string{},
+			"asset.children": []string{
+				"container_group:a1b2c3d4-0000-0000-0000-000000000001",
+				"container_group:b2a1c3d4-0000-0000-0000-000000000002",
+			},
+			"asset.metadata.phase":                        "Bound",
+			"asset.metadata.storage_class":                "gp3",
+			"asset.metadata.volume_name":                  "pvc-8f3c",
+			"asset.metadata.access_modes":                 []string{"ReadWriteOnce"},
+			"asset.metadata.requested":                    "8Gi",
+			"asset.metadata.capacity":                     "10Gi",
+			"kubernetes.persistentvolumeclaim.name":       "data",
+			"kubernetes.persistentvolumeclaim.uid":        "2f1e0d9c-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+			"kubernetes.persistentvolumeclaim.start_time": &startTime,
+			"kubernetes.namespace":                        "default",
+		},
+		Meta: mapstr.M{"index": internal.GetDefaultIndexName()},
+	}
+	assert.Equal(t, []beat.Event{expectedEvent}, publisher.Events)
+}
@@ -0,0 +1,181 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	kube "github.com/elastic/elastic-agent-autodiscover/kubernetes"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const (
+	// modePoll publishes the assets stored in the watchers cache every period
+	modePoll = "poll"
+	// modeWatch also publishes the assets as soon as they are added, updated or deleted
+	modeWatch = "watch"
+)
+
+// WithAssetDeleted marks the published asset as deleted from the cluster.
+func WithAssetDeleted() internal.AssetOption {
+	return func(e beat.Event) beat.Event {
+		e.Fields["event.action"] = "deleted"
+		return e
+	}
+}
+
+// debouncer runs a function once no new call has been made for the same key during a delay.
+type debouncer struct {
+	delay  time.Duration
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+}
+
+func newDebouncer(delay time.Duration) *debouncer {
+	return &debouncer{
+		delay:  delay,
+		timers: make(map[string]*time.Timer),
+	}
+}
+
+// run schedules f after the delay, replacing the function scheduled for key, if any.
+func (d *debouncer) run(key string, f func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if t, ok := d.timers[key]; ok {
+		t.Stop()
+	}
+	var t *time.Timer
+	t = time.AfterFunc(d.delay, func() {
+		d.mu.Lock()
+		if d.timers[key] == t {
+			delete(d.timers, key)
+		}
+		d.mu.Unlock()
+		f()
+	})
+	d.timers[key] = t
+}
+
+// cancel drops the function scheduled for key, if any.
+func (d *debouncer) cancel(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if t, ok := d.timers[key]; ok {
+		t.Stop()
+		delete(d.timers, key)
+	}
+}
+
+// stop drops all the scheduled functions.
+func (d *debouncer) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key, t := range d.timers {
+		t.Stop()
+		delete(d.timers, key)
+	}
+}
+
+// watchPublisher publishes the assets of the objects of a watcher as soon as they are added,
+// updated or deleted. Upserts are debounced per object, so that a burst of updates, e.g. a pod
+// being scheduled then started, is published once with the latest state of the object.
+type watchPublisher struct {
+	name        string
+	log         *logp.Logger
+	watchersMap *watchersMap
+	debouncer   *debouncer
+	publish     func(obj interface{}, opts ...internal.AssetOption)
+}
+
+func newWatchPublisher(name string, log *logp.Logger, watchersMap *watchersMap, watchDebouncer *debouncer, publish func(obj interface{}, opts ...internal.AssetOption)) *watchPublisher {
+	return &watchPublisher{
+		name:        name,
+		log:         log,
+		watchersMap: watchersMap,
+		debouncer:   watchDebouncer,
+		publish:     publish,
+	}
+}
+
+// OnAdd schedules the publication of an added object.
+func (w *watchPublisher) OnAdd(obj interface{}) {
+	w.schedule(obj)
+}
+
+// OnUpdate schedules the publication of an updated object.
+func (w *watchPublisher) OnUpdate(obj interface{}) {
+	w.schedule(obj)
+}
+
+// OnDelete publishes a deleted object right away, dropping its scheduled publication.
+func (w *watchPublisher) OnDelete(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		w.log.Errorf("Unable to get the key of deleted %s: %v", w.name, err)
+		return
+	}
+	w.debouncer.cancel(w.name + "/" + key)
+	w.publish(obj, WithAssetDeleted())
+}
+
+// schedule publishes an object once it has not been updated during the debounce delay.
+func (w *watchPublisher) schedule(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		w.log.Errorf("Unable to get the key of %s: %v", w.name, err)
+		return
+	}
+	w.scheduleKey(key)
+}
+
+// scheduleKey publishes the object with the given namespace/name key once it has not been
+// updated during the debounce delay, with its state in the watcher cache at that time.
+func (w *watchPublisher) scheduleKey(key string) {
+	w.debouncer.run(w.name+"/"+key, func() {
+		watcher, ok := w.watchersMap.get(w.name)
+		if !ok {
+			return
+		}
+		latest, exists, err := watcher.Store().GetByKey(key)
+		if err != nil {
+			w.log.Errorf("Unable to get %s %s from the watcher cache: %v", w.name, key, err)
+			return
+		}
+		// deleted objects are published by OnDelete
+		if !exists {
+			return
+		}
+		w.publish(latest)
+	})
+}
+
+// get returns the watcher stored with the given name.
+func (m *watchersMap) get(name string) (kube.Watcher, bool) {
+	v, ok := m.watchers.Load(name)
+	if !ok {
+		return nil, false
+	}
+	w, ok := v.(kube.Watcher)
+	return w, ok
+}
@@ -0,0 +1,190 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+func TestDebouncer(t *testing.T) {
+	d := newDebouncer(50 * time.Millisecond)
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(value string) func() {
+		return func() {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, value)
+		}
+	}
+
+	d.run("pod/a", record("a1"))
+	d.run("pod/a", record("a2"))
+	d.run("pod/b", record("b1"))
+	d.run("pod/c", record("c1"))
+	d.cancel("pod/b")
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(calls) == 2
+	}, time.Second, 10*time.Millisecond)
+	mu.Lock()
+	assert.ElementsMatch(t, []string{"a2", "c1"}, calls)
+	mu.Unlock()
+
+	d.run("pod/a", record("a3"))
+	d.stop()
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	assert.ElementsMatch(t, []string{"a2", "c1"}, calls)
+	mu.Unlock()
+}
+
+// chanPublisher sends the published events to a channel, to wait for the events published asynchronously.
+type chanPublisher chan beat.Event
+
+func (c chanPublisher) Publish(e beat.Event) {
+	c <- e
+}
+
+func (c chanPublisher) next(t *testing.T) beat.Event {
+	select {
+	case e := <-c:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event published")
+	}
+	return beat.Event{}
+}
+
+func TestWatchPublisher_Pods(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// the pods existing at startup are left to the first periodic publication
+	existingPod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "existingpod",
+			UID:       "6b1e0c6d-3f0a-4a8e-8f0c-2f4f8f1e5b7a",
+			Namespace: "default",
+		},
+	}
+	client := k8sfake.NewSimpleClientset(existingPod)
+	log := logp.NewLogger("mylogger")
+	publisher := make(chanPublisher, 10)
+	cfg := defaultConfig()
+	cfg.AssetTypes = []string{"k8s.pod"}
+
+	watchersMap := &watchersMap{}
+	watchDebouncer := newDebouncer(10 * time.Millisecond)
+	defer watchDebouncer.stop()
+	podWatcher, err := getPodWatcher(ctx, log, client, nil, time.Second*60, getPodWatchPublisher(log, cfg, publisher, watchersMap, watchDebouncer))
+	require.NoError(t, err)
+	watchersMap.watchers.Store("pod", podWatcher)
+	require.NoError(t, podWatcher.Start())
+	defer podWatcher.Stop()
+	_, exists, err := podWatcher.Store().GetByKey("default/existingpod")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mypod",
+			UID:       "a375d24b-fa20-4ea6-a0ee-1d38671d2c09",
+			Namespace: "default",
+		},
+		Spec: v1.PodSpec{
+			NodeName: "testnode",
+		},
+	}
+	_, err = client.CoreV1().Pods("default").Create(ctx, pod, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	event := publisher.next(t)
+	assert.Equal(t, "container_group:a375d24b-fa20-4ea6-a0ee-1d38671d2c09", event.Fields["asset.ean"])
+	assert.NotContains(t, event.Fields, "event.action")
+
+	require.NoError(t, client.CoreV1().Pods("default").Delete(ctx, "mypod", metav1.DeleteOptions{}))
+
+	event = publisher.next(t)
+	assert.Equal(t, "container_group:a375d24b-fa20-4ea6-a0ee-1d38671d2c09", event.Fields["asset.ean"])
+	assert.Equal(t, "deleted", event.Fields["event.action"])
+}
+
+func TestWatchPublisher_ServiceEndpoints(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := k8sfake.NewSimpleClientset()
+	log := logp.NewLogger("mylogger")
+	publisher := make(chanPublisher, 10)
+	cfg := defaultConfig()
+	cfg.AssetTypes = []string{"k8s.service"}
+
+	watchersMap := &watchersMap{}
+	watchDebouncer := newDebouncer(10 * time.Millisecond)
+	defer watchDebouncer.stop()
+	require.NoError(t, initK8sWatchers(ctx, client, log, cfg, publisher, watchersMap, newClusterResolver(cfg, kubeCluster{client: client}), watchDebouncer))
+	require.NoError(t, startK8sWatchers(ctx, log, cfg, watchersMap))
+	defer stopK8sWatchers(ctx, log, watchersMap)
+
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nginx",
+			UID:       "3c2b1a09-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+			Namespace: "default",
+		},
+	}
+	_, err := client.CoreV1().Services("default").Create(ctx, svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	event := publisher.next(t)
+	assert.Equal(t, "service:3c2b1a09-8e4c-4b3a-9b6e-2ab6d4a8b8a1", event.Fields["asset.ean"])
+	assert.Equal(t, []string{}, event.Fields["asset.children"])
+
+	// the service is published again when its endpoints change
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nginx-abcde",
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "nginx"},
+		},
+		Endpoints: []discoveryv1.Endpoint{
+			{TargetRef: &v1.ObjectReference{Kind: "Pod", UID: "a375d24b-fa20-4ea6-a0ee-1d38671d2c09"}},
+		},
+	}
+	_, err = client.DiscoveryV1().EndpointSlices("default").Create(ctx, slice, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	event = publisher.next(t)
+	assert.Equal(t, "service:3c2b1a09-8e4c-4b3a-9b6e-2ab6d4a8b8a1", event.Fields["asset.ean"])
+	assert.Equal(t, []string{"container_group:a375d24b-fa20-4ea6-a0ee-1d38671d2c09"}, event.Fields["asset.children"])
+}
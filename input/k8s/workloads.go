@@ -0,0 +1,286 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/elastic/assetbeat/input/internal"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	kube "github.com/elastic/elastic-agent-autodiscover/kubernetes"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+
+	kuberntescli "k8s.io/client-go/kubernetes"
+)
+
+// workloadAssetKind is the kind of all the workload assets, which are told apart by their type
+const workloadAssetKind = "workload"
+
+// workloadType describes a kind of kubernetes workload collected as an asset
+type workloadType struct {
+	// assetType is the type of the published assets, e.g. k8s.deployment
+	assetType string
+	// name is the name of the watcher and of the kubernetes fields of the workload, e.g. kubernetes.deployment.name
+	name string
+	// kind is the kind of the workload in the owner references of its children, e.g. Deployment
+	kind string
+	// resource is the object watched
+	resource kube.Resource
+}
+
+var workloadTypes = []workloadType{
+	{assetType: "k8s.deployment", name: "deployment", kind: "Deployment", resource: &kube.Deployment{}},
+	{assetType: "k8s.statefulset", name: "statefulset", kind: "StatefulSet", resource: &kube.StatefulSet{}},
+	{assetType: "k8s.daemonset", name: "daemonset", kind: "DaemonSet", resource: &kube.DaemonSet{}},
+	{assetType: "k8s.replicaset", name: "replicaset", kind: "ReplicaSet", resource: &kube.ReplicaSet{}},
+	{assetType: "k8s.job", name: "job", kind: "Job", resource: &kube.Job{}},
+	{assetType: "k8s.cronjob", name: "cronjob", kind: "CronJob", resource: &kube.CronJob{}},
+}
+
+type workload struct {
+	watcher kube.Watcher
+	client  kuberntescli.Interface
+	logger  *logp.Logger
+	ctx     context.Context
+	name    string
+	handler kube.ResourceEventHandler
+}
+
+// getWorkloadWatcher initiates and returns a watcher of kubernetes workloads of the given type.
+// The events of the watcher are forwarded to handler, if not nil.
+func getWorkloadWatcher(ctx context.Context, log *logp.Logger, client kuberntescli.Interface, filter *watchFilter, wt workloadType, timeout time.Duration, handler kube.ResourceEventHandler) (kube.Watcher, error) {
+	list, watchWorkloads, err := getWorkloadListWatchFuncs(ctx, client, wt)
+	if err != nil {
+		log.Errorf("could not create kubernetes watcher %v", err)
+		return nil, err
+	}
+	watcher := newInformerWatcher(client, filter.listWatches(objectNamespaceField, withLabelSelector, list, watchWorkloads), wt.resource, timeout, nil)
+
+	w := &workload{
+		watcher: watcher,
+		client:  client,
+		logger:  log,
+		ctx:     ctx,
+		name:    wt.name,
+		handler: handler,
+	}
+
+	watcher.AddEventHandler(w)
+
+	return watcher, nil
+}
+
+// getWorkloadListWatchFuncs returns the functions listing and watching the workloads of the given type
+func getWorkloadListWatchFuncs(ctx context.Context, client kuberntescli.Interface, wt workloadType) (listFunc, watchFunc, error) {
+	switch wt.resource.(type) {
+	case *kube.Deployment:
+		return func(namespace string, opts metav1.ListOptions) (runtime.Object, error) {
+				return client.AppsV1().Deployments(namespace).List(ctx, opts)
+			}, func(namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+				return client.AppsV1().Deployments(namespace).Watch(ctx, opts)
+			}, nil
+	case *kube.StatefulSet:
+		return func(namespace string, opts metav1.ListOptions) (runtime.Object, error) {
+				return client.AppsV1().StatefulSets(namespace).List(ctx, opts)
+			}, func(namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+				return client.AppsV1().StatefulSets(namespace).Watch(ctx, opts)
+			}, nil
+	case *kube.DaemonSet:
+		return func(namespace string, opts metav1.ListOptions) (runtime.Object, error) {
+				return client.AppsV1().DaemonSets(namespace).List(ctx, opts)
+			}, func(namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+				return client.AppsV1().DaemonSets(namespace).Watch(ctx, opts)
+			}, nil
+	case *kube.ReplicaSet:
+		return func(namespace string, opts metav1.ListOptions) (runtime.Object, error) {
+				return client.AppsV1().ReplicaSets(namespace).List(ctx, opts)
+			}, func(namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+				return client.AppsV1().ReplicaSets(namespace).Watch(ctx, opts)
+			}, nil
+	case *kube.Job:
+		return func(namespace string, opts metav1.ListOptions) (runtime.Object, error) {
+				return client.BatchV1().Jobs(namespace).List(ctx, opts)
+			}, func(namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+				return client.BatchV1().Jobs(namespace).Watch(ctx, opts)
+			}, nil
+	case *kube.CronJob:
+		return func(namespace string, opts metav1.ListOptions) (runtime.Object, error) {
+				return client.BatchV1().CronJobs(namespace).List(ctx, opts)
+			}, func(namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+				return client.BatchV1().CronJobs(namespace).Watch(ctx, opts)
+			}, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported workload type %s", wt.assetType)
+}
+
+// Start starts the eventer
+func (w *workload) Start() error {
+	return w.watcher.Start()
+}
+
+// Stop stops the eventer
+func (w *workload) Stop() {
+	w.watcher.Stop()
+}
+
+// OnUpdate handles events for workloads that have been updated.
+func (w *workload) OnUpdate(obj interface{}) {
+	w.logger.Debugf("Watcher %s update: %+v", w.name, getObjectName(obj))
+	if w.handler != nil {
+		w.handler.OnUpdate(obj)
+	}
+}
+
+// OnDelete handles events for workloads that have been deleted.
+func (w *workload) OnDelete(obj interface{}) {
+	w.logger.Debugf("Watcher %s delete: %+v", w.name, getObjectName(obj))
+	if w.handler != nil {
+		w.handler.OnDelete(obj)
+	}
+}
+
+// OnAdd handles events for workloads that have been added.
+func (w *workload) OnAdd(obj interface{}) {
+	w.logger.Debugf("Watcher %s add: %+v", w.name, getObjectName(obj))
+	if w.handler != nil {
+		w.handler.OnAdd(obj)
+	}
+}
+
+func getObjectName(obj interface{}) string {
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	return o.GetName()
+}
+
+func getObjectNamespace(obj interface{}) string {
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	return o.GetNamespace()
+}
+
+// publishK8sWorkloads publishes the workload assets stored in workload watcher cache
+func publishK8sWorkloads(ctx context.Context, log *logp.Logger, publisher stateless.Publisher, wt workloadType, watcher, namespaceWatcher kube.Watcher) {
+	log.Infof("Publishing %s assets\n", wt.name)
+	for _, obj := range watcher.Store().List() {
+		publishK8sWorkload(log, publisher, wt, obj, namespaceWatcher)
+	}
+}
+
+// publishK8sWorkload publishes the asset of a workload, with the given additional options
+func publishK8sWorkload(log *logp.Logger, publisher stateless.Publisher, wt workloadType, obj interface{}, namespaceWatcher kube.Watcher, opts ...internal.AssetOption) {
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		log.Errorf("Publishing %s assets failed: %v", wt.name, err)
+		return
+	}
+	log.Debugf("Publish %s: %+v", wt.name, o.GetName())
+	assetId := string(o.GetUID())
+	assetStartTime := o.GetCreationTimestamp()
+	assetParents := append(getNamespaceParents(o.GetNamespace(), namespaceWatcher), getControllerParents(o.GetOwnerReferences())...)
+
+	options := []internal.AssetOption{
+		internal.WithAssetKindAndID(workloadAssetKind, assetId),
+		internal.WithAssetType(wt.assetType),
+		internal.WithAssetName(o.GetName()),
+		internal.WithAssetParents(assetParents),
+		internal.WithResourceData(wt.name, o.GetName(), assetId, o.GetNamespace(), &assetStartTime),
+	}
+	if metadata := getWorkloadMetadata(obj); metadata != nil {
+		options = append(options, internal.WithAssetMetadata(metadata))
+	}
+	internal.Publish(publisher, nil, append(options, opts...)...)
+}
+
+// getControllerParents returns the EAN of the workload controlling an object, e.g. the replicaset of a pod
+// or the deployment of a replicaset, according to its owner references.
+func getControllerParents(ownerReferences []metav1.OwnerReference) []string {
+	parents := []string{}
+	for _, ref := range ownerReferences {
+		if ref.Controller == nil || !*ref.Controller {
+			continue
+		}
+		for _, wt := range workloadTypes {
+			if ref.Kind == wt.kind {
+				parents = append(parents, fmt.Sprintf("%s:%s", workloadAssetKind, ref.UID))
+			}
+		}
+	}
+	return parents
+}
+
+// getWorkloadMetadata returns the replicas or the status of a workload
+func getWorkloadMetadata(obj interface{}) mapstr.M {
+	switch o := obj.(type) {
+	case *kube.Deployment:
+		return mapstr.M{
+			"replicas":           getReplicas(o.Spec.Replicas),
+			"ready_replicas":     o.Status.ReadyReplicas,
+			"available_replicas": o.Status.AvailableReplicas,
+		}
+	case *kube.StatefulSet:
+		return mapstr.M{
+			"replicas":       getReplicas(o.Spec.Replicas),
+			"ready_replicas": o.Status.ReadyReplicas,
+		}
+	case *kube.DaemonSet:
+		return mapstr.M{
+			"desired_scheduled": o.Status.DesiredNumberScheduled,
+			"ready":             o.Status.NumberReady,
+		}
+	case *kube.ReplicaSet:
+		return mapstr.M{
+			"replicas":       getReplicas(o.Spec.Replicas),
+			"ready_replicas": o.Status.ReadyReplicas,
+		}
+	case *kube.Job:
+		return mapstr.M{
+			"active":    o.Status.Active,
+			"succeeded": o.Status.Succeeded,
+			"failed":    o.Status.Failed,
+		}
+	case *kube.CronJob:
+		suspend := o.Spec.Suspend != nil && *o.Spec.Suspend
+		return mapstr.M{
+			"schedule": o.Spec.Schedule,
+			"suspend":  suspend,
+			"active":   len(o.Status.Active),
+		}
+	}
+	return nil
+}
+
+// getReplicas returns the desired replicas of a workload, which default to 1
+func getReplicas(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
@@ -0,0 +1,214 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/elastic/assetbeat/input/internal"
+	"github.com/elastic/assetbeat/input/testutil"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func getWorkloadType(t *testing.T, name string) workloadType {
+	for _, wt := range workloadTypes {
+		if wt.name == name {
+			return wt
+		}
+	}
+	t.Fatalf("unknown workload type %s", name)
+	return workloadType{}
+}
+
+func TestPublishK8sWorkloads(t *testing.T) {
+	replicas := int32(3)
+	isController := true
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "nginx",
+			UID:               "d1b9bd23-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+			Namespace:         "default",
+			CreationTimestamp: startTime,
+		},
+		Spec:   appsv1.DeploymentSpec{Replicas: &replicas},
+		Status: appsv1.DeploymentStatus{ReadyReplicas: 2, AvailableReplicas: 2},
+	}
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "nginx-7c5ddbdf54",
+			UID:               "5b4a7b7e-1f4f-4f5c-8d2e-3d1b6a7c9e10",
+			Namespace:         "default",
+			CreationTimestamp: startTime,
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "Deployment", Name: "nginx", UID: deployment.UID, Controller: &isController},
+			},
+		},
+		Spec:   appsv1.ReplicaSetSpec{Replicas: &replicas},
+		Status: appsv1.ReplicaSetStatus{ReadyReplicas: 2},
+	}
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "backup",
+			UID:               "0f0e2b44-5a8d-4e2a-a7c4-7c6f0b3d2e11",
+			Namespace:         "jobs",
+			CreationTimestamp: startTime,
+		},
+		Spec: batchv1.CronJobSpec{Schedule: "0 * * * *"},
+	}
+
+	for _, tt := range []struct {
+		name          string
+		workloadType  string
+		object        interface{}
+		expectedEvent beat.Event
+	}{
+		{
+			name:         "publish deployment",
+			workloadType: "deployment",
+			object:       deployment,
+			expectedEvent: beat.Event{
+				Fields: mapstr.M{
+					"asset.type":                        "k8s.deployment",
+					"asset.kind":                        "workload",
+					"asset.id":                          "d1b9bd23-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+					"asset.ean":                         "workload:d1b9bd23-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+					"asset.name":                        "nginx",
+					"asset.parents":                     []string{},
+					"asset.metadata.replicas":           int32(3),
+					"asset.metadata.ready_replicas":     int32(2),
+					"asset.metadata.available_replicas": int32(2),
+					"kubernetes.deployment.name":        "nginx",
+					"kubernetes.deployment.uid":         "d1b9bd23-8e4c-4b3a-9b6e-2ab6d4a8b8a1",
+					"kubernetes.deployment.start_time":  &startTime,
+					"kubernetes.namespace":              "default",
+				},
+				Meta: mapstr.M{"index": internal.GetDefaultIndexName()},
+			},
+		},
+		{
+			name:         "publish replicaset owned by a deployment",
+			workloadType: "replicaset",
+			object:       replicaSet,
+			expectedEvent: beat.Event{
+				Fields: mapstr.M{
+					"asset.type":                       "k8s.replicaset",
+					"asset.kind":                       "workload",
+					"asset.id":                         "5b4a7b7e-1f4f-4f5c-8d2e-3d1b6a7c9e10",
+					"asset.ean":                        "workload:5b4a7b7e-1f4f-4f5c-8d2e-3d1b6a7c9e10",
+					"asset.name":                       "nginx-7c5ddbdf54",
+					"asset.parents":                    []string{"workload:d1b9bd23-8e4c-4b3a-9b6e-2ab6d4a8b8a1"},
+					"asset.metadata.replicas":          int32(3),
+					"asset.metadata.ready_replicas":    int32(2),
+					"kubernetes.replicaset.name":       "nginx-7c5ddbdf54",
+					"kubernetes.replicaset.uid":        "5b4a7b7e-1f4f-4f5c-8d2e-3d1b6a7c9e10",
+					"kubernetes.replicaset.start_time": &startTime,
+					"kubernetes.namespace":             "default",
+				},
+				Meta: mapstr.M{"index": internal.GetDefaultIndexName()},
+			},
+		},
+		{
+			name:         "publish cronjob",
+			workloadType: "cronjob",
+			object:       cronJob,
+			expectedEvent: beat.Event{
+				Fields: mapstr.M{
+					"asset.type":                    "k8s.cronjob",
+					"asset.kind":                    "workload",
+					"asset.id":                      "0f0e2b44-5a8d-4e2a-a7c4-7c6f0b3d2e11",
+					"asset.ean":                     "workload:0f0e2b44-5a8d-4e2a-a7c4-7c6f0b3d2e11",
+					"asset.name":                    "backup",
+					"asset.parents":                 []string{},
+					"asset.metadata.schedule":       "0 * * * *",
+					"asset.metadata.suspend":        false,
+					"asset.metadata.active":         0,
+					"kubernetes.cronjob.name":       "backup",
+					"kubernetes.cronjob.uid":        "0f0e2b44-5a8d-4e2a-a7c4-7c6f0b3d2e11",
+					"kubernetes.cronjob.start_time": &startTime,
+					"kubernetes.namespace":          "jobs",
+				},
+				Meta: mapstr.M{"index": internal.GetDefaultIndexName()},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := k8sfake.NewSimpleClientset()
+			log := logp.NewLogger("mylogger")
+			wt := getWorkloadType(t, tt.workloadType)
+			watcher, err := getWorkloadWatcher(context.Background(), log, client, nil, wt, time.Second*60, nil)
+			require.NoError(t, err)
+			require.NoError(t, watcher.Store().Add(tt.object))
+
+			publisher := testutil.NewInMemoryPublisher()
+			publishK8sWorkloads(context.Background(), log, publisher, wt, watcher, nil)
+
+			assert.Equal(t, []beat.Event{tt.expectedEvent}, publisher.Events)
+		})
+	}
+}
+
+func TestGetControllerParents(t *testing.T) {
+	isController := true
+	notController := false
+	for _, tt := range []struct {
+		name            string
+		ownerReferences []metav1.OwnerReference
+		expected        []string
+	}{
+		{
+			name:     "without owner",
+			expected: []string{},
+		},
+		{
+			name: "with a replicaset controller",
+			ownerReferences: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", UID: "5b4a7b7e-1f4f-4f5c-8d2e-3d1b6a7c9e10", Controller: &isController},
+			},
+			expected: []string{"workload:5b4a7b7e-1f4f-4f5c-8d2e-3d1b6a7c9e10"},
+		},
+		{
+			name: "with an owner which is not the controller",
+			ownerReferences: []metav1.OwnerReference{
+				{Kind: "Job", UID: "7a6b5c4d-1f4f-4f5c-8d2e-3d1b6a7c9e10", Controller: &notController},
+			},
+			expected: []string{},
+		},
+		{
+			name: "with a controller which is not a workload",
+			ownerReferences: []metav1.OwnerReference{
+				{Kind: "Rollout", UID: "8c7b6a5d-1f4f-4f5c-8d2e-3d1b6a7c9e10", Controller: &isController},
+			},
+			expected: []string{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, getControllerParents(tt.ownerReferences))
+		})
+	}
+}